@@ -45,6 +45,46 @@ func CommandFactory() map[string]cli.CommandFactory {
 				UI: ui,
 			}, nil
 		},
+		"status": func() (cli.Command, error) {
+			return &StatusCommand{
+				UI: ui,
+			}, nil
+		},
+		"exec": func() (cli.Command, error) {
+			return &ExecCommand{
+				UI: ui,
+			}, nil
+		},
+		"grep": func() (cli.Command, error) {
+			return &GrepCommand{
+				UI: ui,
+			}, nil
+		},
+		"search": func() (cli.Command, error) {
+			return &SearchCommand{
+				UI: ui,
+			}, nil
+		},
+		"report": func() (cli.Command, error) {
+			return &ReportCommand{
+				UI: ui,
+			}, nil
+		},
+		"history": func() (cli.Command, error) {
+			return &HistoryCommand{
+				UI: ui,
+			}, nil
+		},
+		"prune": func() (cli.Command, error) {
+			return &PruneCommand{
+				UI: ui,
+			}, nil
+		},
+		"trash": func() (cli.Command, error) {
+			return &TrashCommand{
+				UI: ui,
+			}, nil
+		},
 		"config": func() (cli.Command, error) {
 			return &ConfigCmdCommand{
 				UI: ui,
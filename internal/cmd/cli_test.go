@@ -14,6 +14,14 @@ func TestCommandFactory(t *testing.T) {
 		"reclone-cron",
 		"reclone-server",
 		"ls",
+		"status",
+		"exec",
+		"grep",
+		"search",
+		"report",
+		"history",
+		"prune",
+		"trash",
 		"config",
 	}
 
@@ -54,7 +62,7 @@ func TestCommandFactory(t *testing.T) {
 func TestCommandFactoryCount(t *testing.T) {
 	commands := CommandFactory()
 
-	expectedCount := 8
+	expectedCount := 16
 	if len(commands) != expectedCount {
 		t.Errorf("Expected %d commands, got %d", expectedCount, len(commands))
 	}
@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net/url"
@@ -65,8 +66,9 @@ type CloneCommand struct {
 
 type CloneFlags struct {
 	// Global flags (these were on rootCmd in the old cobra version)
-	Config string `long:"config" description:"GHORG_CONFIG - Manually set the path to your config file"`
-	Color  string `long:"color" description:"GHORG_COLOR - Toggles colorful output, enabled/disabled (default: disabled)"`
+	Config  string `long:"config" description:"GHORG_CONFIG - Manually set the path to your config file"`
+	Profile string `long:"profile" description:"GHORG_PROFILE - Name of the profile in the config file to use, its keys take precedence over the rest of the file"`
+	Color   string `long:"color" description:"GHORG_COLOR - Toggles colorful output, enabled/disabled (default: disabled)"`
 
 	// Path and protocol flags
 	Path     string `short:"p" long:"path" description:"GHORG_ABSOLUTE_PATH_TO_CLONE_TO - Absolute path to the home for ghorg clones. Must start with / (default $HOME/ghorg)"`
@@ -105,6 +107,7 @@ type CloneFlags struct {
 	PruneNoConfirm          bool `long:"prune-no-confirm" description:"GHORG_PRUNE_NO_CONFIRM - Don't prompt on every prune candidate, just delete"`
 	PruneUntouched          bool `long:"prune-untouched" description:"GHORG_PRUNE_UNTOUCHED - Prune repositories that don't have any local changes, see sample-conf.yaml for more details"`
 	PruneUntouchedNoConfirm bool `long:"prune-untouched-no-confirm" description:"GHORG_PRUNE_UNTOUCHED_NO_CONFIRM - Automatically delete repos without showing an interactive confirmation prompt"`
+	PruneTrash              bool `long:"prune-trash" description:"GHORG_PRUNE_TRASH - Move pruned repos into a timestamped .ghorg-trash directory inside the clone directory instead of deleting them, restore them with ghorg trash restore"`
 	FetchAll                bool `long:"fetch-all" description:"GHORG_FETCH_ALL - Fetches all remote branches for each repo by running a git fetch --all"`
 	DryRun                  bool `long:"dry-run" description:"GHORG_DRY_RUN - Perform a dry run of the clone; fetches repos but does not clone them"`
 	Backup                  bool `long:"backup" description:"GHORG_BACKUP - Backup mode, clone as mirror, no working copy (ignores branch parameter)"`
@@ -122,6 +125,7 @@ type CloneFlags struct {
 
 	// Directory and output flags
 	PreserveDir         bool   `long:"preserve-dir" description:"GHORG_PRESERVE_DIRECTORY_STRUCTURE - Clones repos in a directory structure that matches gitlab namespaces eg company/unit/subunit/app would clone into ghorg/unit/subunit/app, gitlab only"`
+	TargetsFile         string `long:"targets-file" description:"GHORG_TARGETS_FILE - Path to file listing orgs or users to clone in one run, one per line as [scm:][org|user:]name"`
 	OutputDir           string `long:"output-dir" description:"GHORG_OUTPUT_DIR - Name of directory repos will be cloned into (default name of org/repo being cloned"`
 	NoDirSize           bool   `long:"no-dir-size" description:"GHORG_NO_DIR_SIZE - Skips the calculation of the output directory size at the end of a clone operation. This can save time, especially when cloning a large number of repositories"`
 	PreserveSCMHostname bool   `long:"preserve-scm-hostname" description:"GHORG_PRESERVE_SCM_HOSTNAME - Appends the scm hostname to the GHORG_ABSOLUTE_PATH_TO_CLONE_TO which will organize your clones into specific folders by the scm provider. e.g. /github.com/kubernetes"`
@@ -137,6 +141,23 @@ type CloneFlags struct {
 	// Resumability
 	RetryFailed bool `long:"retry-failed" description:"GHORG_RETRY_FAILED - Only attempt repos that failed during the previous run (reads _ghorg_state.json from the clone target directory). Composes with other filters. If no state file exists, falls back to cloning everything"`
 
+	// Push mirror flags
+	PushMirrorTo      string `long:"push-mirror-to" description:"GHORG_PUSH_MIRROR_TO - After each clone or pull, push all branches and tags to this URL. Go template with .Name, .Path, .Owner and .Target, e.g. https://gitea.example.com/{{.Owner}}/{{.Name}}.git. A URL without a template is treated as <url>/<repo>.git"`
+	PushMirrorSCM     string `long:"push-mirror-scm" description:"GHORG_PUSH_MIRROR_SCM_TYPE - SCM type of the mirror, used to create missing destination repos before pushing (currently gitea)"`
+	PushMirrorBaseURL string `long:"push-mirror-base-url" description:"GHORG_PUSH_MIRROR_BASE_URL - API base URL of the mirror SCM, used when --push-mirror-scm is set"`
+	PushMirrorOwner   string `long:"push-mirror-owner" description:"GHORG_PUSH_MIRROR_OWNER - Org or user that owns the mirror repos (default: the clone target)"`
+
+	// Hook flags
+	HookPreRun              string `long:"hook-pre-run" description:"GHORG_HOOKS_PRE_RUN - Shell command run before any repo is cloned. A failing pre-run hook aborts the run"`
+	HookPostClone           string `long:"hook-post-clone" description:"GHORG_HOOKS_POST_CLONE - Shell command run in each newly cloned repo"`
+	HookPostPullWithChanges string `long:"hook-post-pull-with-changes" description:"GHORG_HOOKS_POST_PULL_WITH_CHANGES - Shell command run in each pulled repo whose HEAD moved"`
+	HookOnError             string `long:"hook-on-error" description:"GHORG_HOOKS_ON_ERROR - Shell command run for each repo that failed to clone or pull"`
+	HookPostRun             string `long:"hook-post-run" description:"GHORG_HOOKS_POST_RUN - Shell command run once after all repos are processed"`
+	HookTimeout             string `long:"hook-timeout" description:"GHORG_HOOKS_TIMEOUT - Maximum duration of a single hook, e.g. 30s or 10m (default 5m)"`
+	HookConcurrency         string `long:"hook-concurrency" description:"GHORG_HOOKS_CONCURRENCY - Maximum number of repo hooks running at once (default 4)"`
+	PruneTrashRetentionDays string `long:"prune-trash-retention-days" description:"GHORG_PRUNE_TRASH_RETENTION_DAYS - Days to keep pruned repos in .ghorg-trash before deleting them, 0 to keep them until ghorg trash empty (default 30)"`
+	PruneMaxPercent         string `long:"prune-max-percent" description:"GHORG_PRUNE_MAX_PERCENT - Do not prune at all when more than this percentage of the local clones would be pruned, 0 to disable (default 0)"`
+
 	// Exit code flags
 	ExitCodeOnCloneInfos  string `long:"exit-code-on-clone-infos" description:"GHORG_EXIT_CODE_ON_CLONE_INFOS - Allows you to control the exit code when ghorg runs into a problem (info level message) cloning a repo from the remote. Info messages will appear after a clone is complete, similar to success messages. (default 0)"`
 	ExitCodeOnCloneIssues string `long:"exit-code-on-clone-issues" description:"GHORG_EXIT_CODE_ON_CLONE_ISSUES - Allows you to control the exit code when ghorg runs into a problem (issue level message) cloning a repo from the remote. Issue messages will appear after a clone is complete, similar to success messages (default 1)"`
@@ -144,6 +165,7 @@ type CloneFlags struct {
 	// Logging and stats flags
 	Quiet        bool `long:"quiet" description:"GHORG_QUIET - Emit critical output only"`
 	StatsEnabled bool `long:"stats-enabled" description:"GHORG_STATS_ENABLED - Creates a CSV in the GHORG_ABSOLUTE_PATH_TO_CLONE_TO called _ghorg_stats.csv with info about each clone. This allows you to track clone data over time such as number of commits and size in megabytes of the clone directory"`
+	StatsHistory bool `long:"stats-history" description:"GHORG_STATS_HISTORY - Record each clone run and the outcome and timing of every repo in a SQLite database, _ghorg_history.db next to _ghorg_stats.csv"`
 
 	// GitHub specific flags
 	GitHubTokenFromGitHubApp string `long:"github-token-from-github-app" description:"GHORG_GITHUB_TOKEN_FROM_GITHUB_APP - Indicate that the Github token should be treated as an app token. Needed if you already obtained a github app token outside the context of ghorg"`
@@ -166,17 +188,32 @@ type CloneFlags struct {
 	// Protect local flags
 	ProtectLocal bool `long:"protect-local" description:"GHORG_PROTECT_LOCAL - Skip repos with uncommitted changes or unpushed commits instead of overwriting them"`
 
+	// Output flags
+	Output string `long:"output" description:"GHORG_OUTPUT - Write a machine-readable run report to stdout and send human output to stderr, one of json (summary when the run finishes) or ndjson (one event per repo as it is processed)"`
+
+	OutputFile string `long:"output-file" description:"GHORG_OUTPUT_FILE - Write the run report to this file instead of stdout, keeping human output on stdout. Implies --output=json unless --output is set"`
+
+	// JUnit report flags
+	JUnitReport string `long:"junit-report" description:"GHORG_JUNIT_REPORT - Write a JUnit XML report to this path with one test case per repo, for CI systems"`
+
+	// Search index flags
+	SearchIndex bool `long:"search-index" description:"GHORG_SEARCH_INDEX - Maintain a trigram search index of cloned repos for ghorg search, rebuilt only for repos whose HEAD changed"`
+
 	// Bitbucket additional auth flags
 	BitbucketAPIToken string `long:"bitbucket-api-token" description:"GHORG_BITBUCKET_API_TOKEN - Bitbucket Cloud API token for authentication (newer alternative to app passwords)"`
 	BitbucketAPIEmail string `long:"bitbucket-api-email" description:"GHORG_BITBUCKET_API_EMAIL - Email associated with the Bitbucket Cloud API token"`
 }
 
 func (c *CloneCommand) Help() string {
-	return `Usage: ghorg clone [options] [org/user]
+	return `Usage: ghorg clone [options] [org/user...]
 
 Clone user or org repos from GitHub, GitLab, Gitea or Bitbucket.
 See $HOME/.config/ghorg/conf.yaml for defaults.
 
+Several targets, given as arguments or one per line in --targets-file, are
+cloned in one run under a single concurrency limit, each into its own
+directory. Targets are written as [scm:][org|user:]name.
+
 For complete examples of how to clone repos from each SCM provider, run:
   ghorg examples github
   ghorg examples gitlab
@@ -186,6 +223,7 @@ For complete examples of how to clone repos from each SCM provider, run:
 Or see examples directory at https://github.com/blairham/ghorg/tree/master/examples
 
 Options:
+  --profile                            Profile in the config file to use (see ghorg config --help)
   -p, --path                           Absolute path to clone repos to
   --protocol                           Protocol to clone with (ssh or https)
   -b, --branch                         Branch to checkout for each repo
@@ -196,7 +234,10 @@ Options:
   --skip-archived                      Skip archived repos
   --skip-forks                         Skip forked repos
   --no-clean                           Only clone new repos, don't clean existing
+  --targets-file                       File listing orgs or users to clone in one run
   --prune                              Delete local repos not found on remote
+  --prune-trash                        Move pruned repos to .ghorg-trash instead of deleting
+  --prune-max-percent                  Skip pruning when more than this % of clones would go
   --fetch-all                          Fetch all remote branches
   --fetch-prune                        Remove stale remote-tracking branches during fetch
   --protect-local                      Skip repos with uncommitted changes or unpushed commits
@@ -208,20 +249,33 @@ Options:
   --github-user-gists                  Clone GitHub user's gists (--clone-type=user only)
   --gitlab-group-match-regex           Include only GitLab groups matching regex
   --bitbucket-api-token                Bitbucket Cloud API token authentication
+  --push-mirror-to                     Push every repo to a second remote (URL template)
+  --hook-post-clone                    Run a shell command in each new clone (see also --hook-*)
+  --search-index                       Maintain a search index for ghorg search
+  --output                             Machine-readable run report on stdout (json, ndjson)
+  --output-file                        Write the run report to a file instead of stdout
+  --junit-report                       Write a JUnit XML report to a path
   --quiet                              Emit critical output only
   --stats-enabled                      Create stats CSV file
+  --stats-history                      Record runs and repo outcomes in a SQLite database
 
 Examples:
   ghorg clone kubernetes                                  # Clone all repos in a GitHub org
   ghorg clone --scm gitlab my-org                         # Clone from GitLab
   ghorg clone --clone-type user my-user                   # Clone a user's repos
+  ghorg clone my-org gitlab:my-group github:user:my-user  # Clone several targets in one run
   ghorg clone --branch develop my-org                     # Clone with specific branch
   ghorg clone --protocol ssh my-org                       # Clone using SSH
   ghorg clone --match-regex "^api-" my-org                # Clone repos matching a regex
   ghorg clone --skip-archived --skip-forks my-org         # Skip archived repos and forks
   ghorg clone --protect-local my-org                      # Skip repos with local changes
   ghorg clone --fetch-all --fetch-prune my-org            # Fetch all branches and prune stale
+  ghorg clone --prune --prune-trash my-org                # Prune into .ghorg-trash, undo with ghorg trash restore
   ghorg clone --clone-type user --github-user-gists user  # Clone user's gists
+  ghorg clone --push-mirror-to "https://gitea.example.com/{{.Owner}}/{{.Name}}.git" my-org  # Mirror to Gitea
+  ghorg clone --hook-post-pull-with-changes 'make test' my-org  # Run a command in updated repos
+  ghorg clone --output=ndjson my-org 2>/dev/null | jq -c 'select(.event == "error")'     # Stream failures
+  ghorg clone --junit-report=ghorg-junit.xml my-org       # Report each repo as a CI test case
 `
 }
 
@@ -268,6 +322,23 @@ func applyStringFlags(opts *CloneFlags) {
 		{"GHORG_GITLAB_GROUP_MATCH_REGEX", opts.GitlabGroupMatchRegex, nil},
 		{"GHORG_BITBUCKET_API_TOKEN", opts.BitbucketAPIToken, nil},
 		{"GHORG_BITBUCKET_API_EMAIL", opts.BitbucketAPIEmail, nil},
+		{"GHORG_PUSH_MIRROR_TO", opts.PushMirrorTo, nil},
+		{"GHORG_PUSH_MIRROR_BASE_URL", opts.PushMirrorBaseURL, nil},
+		{"GHORG_PUSH_MIRROR_OWNER", opts.PushMirrorOwner, nil},
+		{"GHORG_PUSH_MIRROR_SCM_TYPE", opts.PushMirrorSCM, strings.ToLower},
+		{"GHORG_HOOKS_PRE_RUN", opts.HookPreRun, nil},
+		{"GHORG_HOOKS_POST_CLONE", opts.HookPostClone, nil},
+		{"GHORG_HOOKS_POST_PULL_WITH_CHANGES", opts.HookPostPullWithChanges, nil},
+		{"GHORG_HOOKS_ON_ERROR", opts.HookOnError, nil},
+		{"GHORG_HOOKS_POST_RUN", opts.HookPostRun, nil},
+		{"GHORG_HOOKS_TIMEOUT", opts.HookTimeout, nil},
+		{"GHORG_HOOKS_CONCURRENCY", opts.HookConcurrency, nil},
+		{"GHORG_PRUNE_TRASH_RETENTION_DAYS", opts.PruneTrashRetentionDays, nil},
+		{"GHORG_PRUNE_MAX_PERCENT", opts.PruneMaxPercent, nil},
+		{"GHORG_TARGETS_FILE", opts.TargetsFile, nil},
+		{"GHORG_OUTPUT", opts.Output, strings.ToLower},
+		{"GHORG_OUTPUT_FILE", opts.OutputFile, nil},
+		{"GHORG_JUNIT_REPORT", opts.JUnitReport, nil},
 		{"GHORG_CLONE_TYPE", opts.CloneType, strings.ToLower},
 		{"GHORG_SCM_TYPE", opts.SCMType, strings.ToLower},
 		{"GHORG_ABSOLUTE_PATH_TO_CLONE_TO", opts.Path, configs.EnsureTrailingSlashOnFilePath},
@@ -293,11 +364,13 @@ func applyBoolFlags(opts *CloneFlags) {
 		{"GHORG_PRESERVE_SCM_HOSTNAME", opts.PreserveSCMHostname},
 		{"GHORG_SKIP_ARCHIVED", opts.SkipArchived},
 		{"GHORG_STATS_ENABLED", opts.StatsEnabled},
+		{"GHORG_STATS_HISTORY", opts.StatsHistory},
 		{"GHORG_NO_CLEAN", opts.NoClean},
 		{"GHORG_PRUNE", opts.Prune},
 		{"GHORG_PRUNE_NO_CONFIRM", opts.PruneNoConfirm},
 		{"GHORG_PRUNE_UNTOUCHED", opts.PruneUntouched},
 		{"GHORG_PRUNE_UNTOUCHED_NO_CONFIRM", opts.PruneUntouchedNoConfirm},
+		{"GHORG_PRUNE_TRASH", opts.PruneTrash},
 		{"GHORG_FETCH_ALL", opts.FetchAll},
 		{"GHORG_INCLUDE_SUBMODULES", opts.IncludeSubmodules},
 		{"GHORG_DRY_RUN", opts.DryRun},
@@ -316,6 +389,7 @@ func applyBoolFlags(opts *CloneFlags) {
 		{"GHORG_SYNC_DEFAULT_BRANCH", opts.SyncDefaultBranch},
 		{"GHORG_FETCH_PRUNE", opts.FetchPrune},
 		{"GHORG_PROTECT_LOCAL", opts.ProtectLocal},
+		{"GHORG_SEARCH_INDEX", opts.SearchIndex},
 		{"GHORG_GITHUB_USER_GISTS", opts.GitHubUserGists},
 		{"GHORG_RETRY_FAILED", opts.RetryFailed},
 	}
@@ -328,13 +402,23 @@ func applyBoolFlags(opts *CloneFlags) {
 }
 
 // setTokenForSCM routes the --token flag value to the correct SCM-specific env var.
-func setTokenForSCM(opts *CloneFlags) {
+func setTokenForSCM(opts *CloneFlags) error {
 	if opts.Token == "" {
-		return
+		return nil
 	}
 	token := opts.Token
-	if configs.IsFilePath(token) {
-		token = configs.GetTokenFromFile(token)
+	if configs.IsSecretRef(token) {
+		secret, err := configs.ResolveSecretRef(token)
+		if err != nil {
+			return fmt.Errorf("--token %w", err)
+		}
+		token = secret
+	} else if configs.IsFilePath(token) {
+		secret, err := configs.GetTokenFromFile(token)
+		if err != nil {
+			return fmt.Errorf("--token %w", err)
+		}
+		token = secret
 	}
 	switch os.Getenv("GHORG_SCM_TYPE") {
 	case "github":
@@ -354,6 +438,7 @@ func setTokenForSCM(opts *CloneFlags) {
 	case "sourcehut":
 		os.Setenv("GHORG_SOURCEHUT_TOKEN", token)
 	}
+	return nil
 }
 
 // parseAndApplyFlags parses CLI flags and applies them as environment variables.
@@ -370,20 +455,22 @@ func (c *CloneCommand) parseAndApplyFlags(args []string) ([]string, error) {
 		}
 		return nil, fmt.Errorf("error parsing flags: %w", err)
 	}
+	return applyCloneFlags(&opts, remaining)
+}
 
-	// Handle config flag first - if set, reinitialize configuration
-	if opts.Config != "" {
-		os.Setenv("GHORG_CONFIG", opts.Config)
-		InitConfig()
-	}
+// applyCloneFlags applies parsed clone flags as environment variables and
+// returns the positional args, which must name the org or user unless
+// GHORG_TARGETS_FILE lists the targets.
+func applyCloneFlags(opts *CloneFlags, remaining []string) ([]string, error) {
+	applyConfigFlags(opts)
 	if opts.Color != "" {
 		os.Setenv("GHORG_COLOR", opts.Color)
 	}
 
-	applyStringFlags(&opts)
-	applyBoolFlags(&opts)
+	applyStringFlags(opts)
+	applyBoolFlags(opts)
 
-	if len(remaining) < 1 {
+	if len(remaining) < 1 && os.Getenv("GHORG_TARGETS_FILE") == "" {
 		if os.Getenv("GHORG_SCM_TYPE") == "github" && os.Getenv("GHORG_CLONE_TYPE") == "user" {
 			remaining = append(remaining, "")
 		} else {
@@ -391,12 +478,32 @@ func (c *CloneCommand) parseAndApplyFlags(args []string) ([]string, error) {
 		}
 	}
 
-	configs.GetOrSetToken()
-	setTokenForSCM(&opts)
+	if err := configs.GetOrSetToken(); err != nil {
+		return nil, err
+	}
+	if err := setTokenForSCM(opts); err != nil {
+		return nil, err
+	}
 
 	return remaining, nil
 }
 
+// applyConfigFlags reloads the configuration when --config or --profile is
+// set. It must run before the other flags are applied, which take precedence
+// over the configuration.
+func applyConfigFlags(opts *CloneFlags) {
+	if opts.Config == "" && opts.Profile == "" {
+		return
+	}
+	if opts.Config != "" {
+		os.Setenv("GHORG_CONFIG", opts.Config)
+	}
+	if opts.Profile != "" {
+		os.Setenv("GHORG_PROFILE", opts.Profile)
+	}
+	InitConfig()
+}
+
 // validateConfig verifies tokens and configuration are set correctly.
 func validateConfig() error {
 	if err := configs.VerifyTokenSet(); err != nil {
@@ -418,29 +525,68 @@ func (c *CloneCommand) Run(args []string) int {
 		return 0
 	}
 
-	if err := validateConfig(); err != nil {
-		colorlog.PrintError(err)
-		return 1
+	// Each target of a multi-target clone is validated with its own SCM
+	multiTarget := isMultiTargetClone(remaining)
+	if !multiTarget {
+		if err := validateConfig(); err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
 	}
 
-	if os.Getenv("GHORG_PRESERVE_SCM_HOSTNAME") == "true" {
-		updateAbsolutePathToCloneToWithHostname()
+	runReportOutput = colorlog.Output()
+	if outputFile := os.Getenv("GHORG_OUTPUT_FILE"); outputFile != "" {
+		if os.Getenv("GHORG_OUTPUT") == "" {
+			os.Setenv("GHORG_OUTPUT", OutputFormatJSON)
+		}
+		f, err := os.Create(outputFile)
+		if err != nil {
+			colorlog.PrintError(fmt.Sprintf("Could not create run report %s: %v", outputFile, err))
+			return 1
+		}
+		defer f.Close()
+		runReportOutput = f
+	} else if os.Getenv("GHORG_OUTPUT") != "" {
+		var restore func()
+		runReportOutput, restore = redirectHumanOutput()
+		defer restore()
 	}
 
-	setOutputDirName(remaining)
-	setOuputDirAbsolutePath()
-	targetCloneSource = remaining[0]
-
 	// Auto-adjust concurrency for clone delay before setup (silently)
 	if _, _, shouldAdjust := shouldAutoAdjustConcurrency(); shouldAdjust {
 		os.Setenv("GHORG_CONCURRENCY", "1")
 		os.Setenv("GHORG_CONCURRENCY_AUTO_ADJUSTED", "true")
 	}
 
+	if multiTarget {
+		specs, err := cloneTargetSpecs(remaining)
+		if err == nil {
+			err = cloneMultipleTargets(git.NewGit(), specs)
+		}
+		if err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+		return 0
+	}
+
+	setCloneTarget(remaining)
 	setupRepoClone()
 	return 0
 }
 
+// setCloneTarget sets the org or user to clone and the directory it is
+// cloned into from the arguments left after parsing the flags.
+func setCloneTarget(remaining []string) {
+	if os.Getenv("GHORG_PRESERVE_SCM_HOSTNAME") == "true" {
+		updateAbsolutePathToCloneToWithHostname()
+	}
+
+	setOutputDirName(remaining)
+	setOuputDirAbsolutePath()
+	targetCloneSource = remaining[0]
+}
+
 func setupRepoClone() {
 	// Clear global slices and cached values at the start of each clone operation
 	// to prevent memory leaks in long-running processes like reclone-server
@@ -448,6 +594,7 @@ func setupRepoClone() {
 	cloneInfos = nil
 	cachedDirSizeMB = 0
 	isDirSizeCached = false
+	lastRunReport = nil
 
 	if os.Getenv("GHORG_GITHUB_USER_GISTS") == "true" {
 		if os.Getenv("GHORG_SCM_TYPE") != "github" {
@@ -461,12 +608,12 @@ func setupRepoClone() {
 		if err != nil {
 			colorlog.PrintError("Encountered an error fetching gists, aborting")
 			fmt.Println(err)
-			os.Exit(1)
+			colorlog.Exit(1)
 		}
 
 		if len(gistTargets) == 0 {
 			colorlog.PrintInfo("No gists found for github user: " + targetCloneSource + ", please verify you have sufficient permissions, double check spelling and try again.")
-			os.Exit(0)
+			colorlog.Exit(0)
 		}
 
 		// Clone gists into a ghorg-gists subdirectory within the user's clone directory
@@ -487,18 +634,18 @@ func setupRepoClone() {
 		cloneTargets, err = getAllUserCloneUrls()
 	} else {
 		colorlog.PrintError("GHORG_CLONE_TYPE not set or unsupported")
-		os.Exit(1)
+		colorlog.Exit(1)
 	}
 
 	if err != nil {
 		colorlog.PrintError("Encountered an error, aborting")
 		fmt.Println(err)
-		os.Exit(1)
+		colorlog.Exit(1)
 	}
 
 	if len(cloneTargets) == 0 {
 		colorlog.PrintInfo("No repos found for " + os.Getenv("GHORG_SCM_TYPE") + " " + os.Getenv("GHORG_CLONE_TYPE") + ": " + targetCloneSource + ", please verify you have sufficient permissions to clone target repos, double check spelling and try again.")
-		os.Exit(0)
+		colorlog.Exit(0)
 	}
 	git := git.NewGit()
 	CloneAllRepos(git, cloneTargets)
@@ -518,7 +665,7 @@ func getAllUserGistCloneUrls() ([]scm.Repo, error) {
 	client, err := scm.GetClient("github")
 	if err != nil {
 		colorlog.PrintError(err)
-		os.Exit(1)
+		colorlog.Exit(1)
 	}
 
 	githubClient, ok := client.(scm.Github)
@@ -535,12 +682,12 @@ func getCloneUrls(isOrg bool) ([]scm.Repo, error) {
 	scmType := strings.ToLower(os.Getenv("GHORG_SCM_TYPE"))
 	if len(scmType) == 0 {
 		colorlog.PrintError("GHORG_SCM_TYPE not set")
-		os.Exit(1)
+		colorlog.Exit(1)
 	}
 	client, err := scm.GetClient(scmType)
 	if err != nil {
 		colorlog.PrintError(err)
-		os.Exit(1)
+		colorlog.Exit(1)
 	}
 
 	if isOrg {
@@ -669,7 +816,8 @@ func printDryRun(repos []scm.Repo) {
 
 			repositories, err := getRelativePathRepositories(outputDirAbsolutePath)
 			if err != nil {
-				log.Fatal(err)
+				log.Print(err)
+				colorlog.Exit(1)
 			}
 
 			eligibleForPrune := 0
@@ -682,6 +830,9 @@ func printDryRun(repos []scm.Repo) {
 				}
 			}
 			colorlog.PrintSuccess(fmt.Sprintf("Local clones eligible for pruning: %d", eligibleForPrune))
+			if err := checkPruneThreshold(eligibleForPrune, len(repositories)); err != nil {
+				colorlog.PrintError(fmt.Sprintf("Pruning would be skipped, %v", err))
+			}
 		}
 	}
 }
@@ -723,6 +874,9 @@ func getRelativePathRepositories(root string) ([]string, error) {
 		if err != nil {
 			return err
 		}
+		if file.IsDir() && file.Name() == TrashDirName {
+			return filepath.SkipDir
+		}
 		if path != outputDirAbsolutePath && file.IsDir() && isGitRepository(path) {
 			rel, err := filepath.Rel(outputDirAbsolutePath, path)
 			if err != nil {
@@ -774,42 +928,58 @@ func resolveRepoSlug(repo *scm.Repo) string {
 	return getAppNameFromURL(repo.URL)
 }
 
-// pruneUntouchedRepos prompts for confirmation (if needed) and removes repos not touched during clone
-func pruneUntouchedRepos(untouchedReposToPrune []string) int {
+// pruneUntouchedRepos prompts for confirmation (if needed) and removes repos not touched during clone,
+// moving them into trash when it is set. It returns the paths of the removed repos.
+func pruneUntouchedRepos(trash *trashBatch, untouchedReposToPrune []string) []string {
 	if os.Getenv("GHORG_PRUNE_UNTOUCHED") != "true" || len(untouchedReposToPrune) == 0 {
-		return 0
+		return nil
+	}
+
+	if repositories, err := getRelativePathRepositories(outputDirAbsolutePath); err == nil {
+		if err := checkPruneThreshold(len(untouchedReposToPrune), len(repositories)); err != nil {
+			colorlog.PrintError(fmt.Sprintf("Not pruning untouched repositories, %v", err))
+			return nil
+		}
 	}
 
 	if os.Getenv("GHORG_PRUNE_UNTOUCHED_NO_CONFIRM") != "true" {
-		colorlog.PrintSuccess(fmt.Sprintf("PLEASE CONFIRM: The following %d untouched repositories will be deleted. Press enter to confirm: ", len(untouchedReposToPrune)))
+		colorlog.PrintSuccess(fmt.Sprintf("PLEASE CONFIRM: The following %d untouched repositories will be %s. Press enter to confirm: ", len(untouchedReposToPrune), pruneVerb(trash)))
 		for _, repoPath := range untouchedReposToPrune {
 			colorlog.PrintInfo(fmt.Sprintf("- %s", repoPath))
 		}
 		_, _ = fmt.Scanln()
 	}
 
-	var pruned int
+	var pruned []string
 	for _, repoPath := range untouchedReposToPrune {
-		err := os.RemoveAll(repoPath)
+		err := trash.discard(repoPath, "untouched")
 		if err != nil {
 			colorlog.PrintError(fmt.Sprintf("Failed to prune repository at %s: %v", repoPath, err))
 		} else {
-			pruned++
-			colorlog.PrintSuccess(fmt.Sprintf("Successfully deleted %s", repoPath))
+			pruned = append(pruned, repoPath)
+			colorlog.PrintSuccess(fmt.Sprintf("Successfully %s %s", pruneVerb(trash), repoPath))
 		}
 	}
 	return pruned
 }
 
+// pruneVerb describes what pruning does to a repo.
+func pruneVerb(trash *trashBatch) string {
+	if trash != nil {
+		return "moved to " + TrashDirName
+	}
+	return "deleted"
+}
+
 // printCollisionWarning prints a warning if repo name collisions were detected
 func printCollisionWarning(hasCollisions bool, repoNameWithCollisions map[string]bool) {
 	if !hasCollisions {
 		return
 	}
-	fmt.Println("")
+	fmt.Fprintln(colorlog.Output(), "")
 	colorlog.PrintInfo("ATTENTION: ghorg detected collisions in repo names from the groups that were cloned. This occurs when one or more groups share common repo names trying to be cloned to the same directory. The repos that would have collisions were renamed with the group/subgroup appended.")
 	if os.Getenv("GHORG_DEBUG") != "" {
-		fmt.Println("")
+		fmt.Fprintln(colorlog.Output(), "")
 		colorlog.PrintInfo("Collisions Occured in the following repos...")
 		for repoName, collision := range repoNameWithCollisions {
 			if collision {
@@ -829,24 +999,65 @@ func handleExitCodes(cloneInfosCount, cloneErrorsCount int) {
 		exitCode, err := strconv.Atoi(os.Getenv("GHORG_EXIT_CODE_ON_CLONE_INFOS"))
 		if err != nil {
 			colorlog.PrintError("Could not convert GHORG_EXIT_CODE_ON_CLONE_INFOS from string to integer")
-			os.Exit(1)
+			colorlog.Exit(1)
 		}
-		os.Exit(exitCode)
+		colorlog.Exit(exitCode)
 	}
 
 	if cloneErrorsCount > 0 {
 		exitCode, err := strconv.Atoi(os.Getenv("GHORG_EXIT_CODE_ON_CLONE_ISSUES"))
 		if err != nil {
 			colorlog.PrintError("Could not convert GHORG_EXIT_CODE_ON_CLONE_ISSUES from string to integer")
-			os.Exit(1)
+			colorlog.Exit(1)
 		}
-		os.Exit(exitCode)
+		colorlog.Exit(exitCode)
 	}
 }
 
 // CloneAllRepos clones all repos
 func CloneAllRepos(git git.Gitter, cloneTargets []scm.Repo) {
+	run := startCloneRun(git, cloneTargets, getGhorgStateFilePath())
+	if run == nil {
+		return
+	}
+
+	limit := limiter.NewConcurrencyLimiter(concurrencyFromEnv())
+	run.queue(limit)
+	limit.WaitAndClose()
+
+	stats := run.finish()
+	handleExitCodes(len(stats.CloneInfos), len(stats.CloneErrors))
+}
+
+// concurrencyFromEnv returns GHORG_CONCURRENCY, exiting when it is not a number.
+func concurrencyFromEnv() int {
+	l, err := strconv.Atoi(os.Getenv("GHORG_CONCURRENCY"))
+	if err != nil {
+		log.Print("Could not determine GHORG_CONCURRENCY")
+		colorlog.Exit(1)
+	}
+	return l
+}
+
+// cloneRun is the clone of the repos of the target configured in the
+// environment. It is started with startCloneRun, its repos are queued on a
+// limiter, possibly shared with other targets, and it is finished once the
+// limiter is done.
+type cloneRun struct {
+	targets       []scm.Repo
+	processor     *RepositoryProcessor
+	reporter      *runReporter
+	save          func()
+	collisions    map[string]bool
+	hasCollisions bool
+}
+
+// startCloneRun filters cloneTargets, prints the inventory and prepares the
+// clone directory and processor, recording into the state manifest at
+// statePath. It returns nil for dry runs, after printing what would be cloned.
+func startCloneRun(git git.Gitter, cloneTargets []scm.Repo, statePath string) *cloneRun {
 	filter := NewRepositoryFilter()
+	filter.statePath = statePath
 	cloneTargets = filter.ApplyAllFilters(cloneTargets)
 
 	totalResourcesToClone, reposToCloneCount, snippetToCloneCount, wikisToCloneCount, gistsToCloneCount := getCloneableInventory(cloneTargets)
@@ -854,70 +1065,97 @@ func CloneAllRepos(git git.Gitter, cloneTargets []scm.Repo) {
 
 	if os.Getenv("GHORG_DRY_RUN") == "true" {
 		printDryRun(cloneTargets)
-		return
+		return nil
 	}
 
 	if err := createDirIfNotExist(); err != nil {
 		colorlog.PrintError(err)
-		os.Exit(1)
+		colorlog.Exit(1)
 	}
 
 	repoNameWithCollisions, hasCollisions := hasRepoNameCollisions(cloneTargets)
 
-	l, err := strconv.Atoi(os.Getenv("GHORG_CONCURRENCY"))
+	processor, reporter, saveProcessor, err := newRunProcessor(git, statePath)
 	if err != nil {
-		log.Fatal("Could not determine GHORG_CONCURRENCY")
+		colorlog.PrintError(err)
+		colorlog.Exit(1)
 	}
 
-	limit := limiter.NewConcurrencyLimiter(l)
-	processor := NewRepositoryProcessor(git)
+	if err := processor.RunPreRunHook(len(cloneTargets)); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Not cloning, %v", err))
+		colorlog.Exit(1)
+	}
 
-	statePath := getGhorgStateFilePath()
-	scmType := strings.ToLower(os.Getenv("GHORG_SCM_TYPE"))
-	state, stateErr := LoadState(statePath, scmType, targetCloneSource)
-	if stateErr != nil {
-		colorlog.PrintInfo(fmt.Sprintf("Could not load state file %s, starting fresh: %v", statePath, stateErr))
-		state = NewStateManifest(scmType, targetCloneSource)
+	return &cloneRun{
+		targets:       cloneTargets,
+		processor:     processor,
+		reporter:      reporter,
+		save:          saveProcessor,
+		collisions:    repoNameWithCollisions,
+		hasCollisions: hasCollisions,
 	}
-	processor.SetState(state)
+}
 
-	for i := range cloneTargets {
-		repo := cloneTargets[i]
+// queue queues the repos of the run on limit.
+func (r *cloneRun) queue(limit *limiter.ConcurrencyLimiter) {
+	preserveDirs := os.Getenv("GHORG_PRESERVE_DIRECTORY_STRUCTURE") == "true"
+	for i := range r.targets {
+		repo := r.targets[i]
 		repoSlug := resolveRepoSlug(&repo)
 
 		if !isPathSegmentSafe(repoSlug) {
-			log.Fatal("Unsafe path segment found in SCM output")
+			log.Print("Unsafe path segment found in SCM output")
+			colorlog.Exit(1)
 		}
 
+		r.processor.reportQueued(repo)
+
 		//nolint:errcheck // Error handling is done inside the goroutine via addError/addInfo
 		limit.Execute(func() {
-			if repo.Path != "" && os.Getenv("GHORG_PRESERVE_DIRECTORY_STRUCTURE") == "true" {
+			if repo.Path != "" && preserveDirs {
 				repoSlug = repo.Path
 			}
-			processor.ProcessRepository(&repo, repoNameWithCollisions, hasCollisions, repoSlug, i)
+			r.processor.ProcessRepository(&repo, r.collisions, r.hasCollisions, repoSlug, i)
 		})
 	}
+}
 
-	limit.WaitAndClose()
+// finish prunes, prints the summary and writes the stats, state, reports and
+// notifications of the run once all its repos are processed, and returns its
+// stats.
+func (r *cloneRun) finish() CloneStats {
+	processor, reporter := r.processor, r.reporter
+	cloneTargets := r.targets
+	repoNameWithCollisions, hasCollisions := r.collisions, r.hasCollisions
+	scmType := strings.ToLower(os.Getenv("GHORG_SCM_TYPE"))
 
 	totalDuration := time.Since(commandStartTime)
 	processor.SetTotalDuration(int(totalDuration.Seconds() + 0.5))
 
 	stats := processor.GetStats()
-	untouchedPrunes := pruneUntouchedRepos(processor.GetUntouchedRepos())
+	trash := newTrashFromEnv(outputDirAbsolutePath)
+	if trash != nil {
+		expireTrashFromEnv(outputDirAbsolutePath)
+	}
+	untouchedPruned := pruneUntouchedRepos(trash, processor.GetUntouchedRepos())
+	untouchedPrunes := len(untouchedPruned)
 
 	cloneInfos = stats.CloneInfos
 	cloneErrors = stats.CloneErrors
 
 	printRemainingMessages()
-	printCloneStatsMessage(stats.CloneCount, stats.PulledCount, stats.SkippedCount, stats.ProtectedCount, stats.UpdateRemoteCount, stats.NewCommits, stats.SyncedCount, untouchedPrunes, stats.TotalDurationSeconds)
+	printCloneStatsMessage(stats.CloneCount, stats.PulledCount, stats.SkippedCount, stats.ProtectedCount, stats.RenamedCount, stats.UpdateRemoteCount, stats.NewCommits, stats.SyncedCount, untouchedPrunes, stats.TotalDurationSeconds)
 	printCollisionWarning(hasCollisions, repoNameWithCollisions)
 
-	var pruneCount int
+	var pruned []string
 	allReposToCloneCount := len(cloneTargets)
 	if os.Getenv("GHORG_PRUNE") == "true" {
-		pruneCount = pruneRepos(cloneTargets)
+		pruned = pruneRepos(processor, trash, cloneTargets)
+		// pruning protects clones with local changes and fails above
+		// GHORG_PRUNE_MAX_PERCENT, both of which end up in the stats
+		stats = processor.GetStats()
 	}
+	pruneCount := len(pruned)
 
 	if os.Getenv("GHORG_QUIET") != "true" {
 		if os.Getenv("GHORG_NO_DIR_SIZE") == "false" {
@@ -927,16 +1165,138 @@ func CloneAllRepos(git git.Gitter, cloneTargets []scm.Repo) {
 		}
 	}
 
+	finishedAt := time.Now()
 	if os.Getenv("GHORG_STATS_ENABLED") == "true" {
-		date := time.Now().Format("2006-01-02 15:04:05")
+		date := finishedAt.Format("2006-01-02 15:04:05")
 		_ = writeGhorgStats(date, allReposToCloneCount, stats.CloneCount, stats.PulledCount, len(stats.CloneInfos), len(stats.CloneErrors), stats.UpdateRemoteCount, stats.NewCommits, stats.SyncedCount, pruneCount, stats.TotalDurationSeconds, hasCollisions)
 	}
 
-	if err := SaveState(statePath, state); err != nil {
-		colorlog.PrintInfo(fmt.Sprintf("Could not write state file %s: %v", statePath, err))
+	if os.Getenv("GHORG_STATS_HISTORY") == "true" {
+		run := statsRun{
+			Time:            finishedAt.Truncate(time.Second),
+			ClonePath:       outputDirAbsolutePath,
+			SCM:             os.Getenv("GHORG_SCM_TYPE"),
+			CloneType:       os.Getenv("GHORG_CLONE_TYPE"),
+			Target:          targetCloneSource,
+			Total:           allReposToCloneCount,
+			NewClones:       stats.CloneCount,
+			Pulled:          stats.PulledCount,
+			DirSizeMB:       cachedDirSizeMB,
+			NewCommits:      stats.NewCommits,
+			Synced:          stats.SyncedCount,
+			Infos:           len(stats.CloneInfos),
+			Errors:          len(stats.CloneErrors),
+			UpdateRemote:    stats.UpdateRemoteCount,
+			Prune:           pruneCount,
+			HasCollisions:   hasCollisions,
+			GhorgIgnore:     configs.GhorgIgnoreDetected(),
+			GhorgOnly:       configs.GhorgOnlyDetected(),
+			DurationSeconds: stats.TotalDurationSeconds,
+			Version:         GetVersion(),
+		}
+		if err := recordHistory(run, reporter.Outcomes()); err != nil {
+			colorlog.PrintInfo(fmt.Sprintf("Could not record run history: %v", err))
+		}
 	}
 
-	handleExitCodes(len(stats.CloneInfos), len(stats.CloneErrors))
+	r.save()
+
+	summary := stats
+	summary.UntouchedPrunes = untouchedPrunes
+	report := RunReport{
+		SCM:        scmType,
+		Target:     targetCloneSource,
+		OutputDir:  outputDirAbsolutePath,
+		StartedAt:  commandStartTime.UTC(),
+		FinishedAt: time.Now().UTC(),
+		Summary:    summary,
+		API:        scm.APIStats(),
+	}
+	report.Repos = reporter.Outcomes()
+	for _, repoPath := range untouchedPruned {
+		if rel, err := filepath.Rel(outputDirAbsolutePath, repoPath); err == nil {
+			repoPath = rel
+		}
+		report.Pruned = append(report.Pruned, filepath.ToSlash(repoPath))
+	}
+	report.Pruned = append(report.Pruned, pruned...)
+	lastRunReport = &report
+	if err := reporter.Finish(report); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Could not write run report: %v", err))
+	}
+
+	if junitPath := os.Getenv("GHORG_JUNIT_REPORT"); junitPath != "" {
+		suite := newJUnitSuite(targetCloneSource, commandStartTime, reporter.Outcomes(), stats)
+		if err := writeJUnitReport(junitPath, []junitTestSuite{suite}); err != nil {
+			colorlog.PrintError(fmt.Sprintf("Could not write JUnit report: %v", err))
+		}
+	}
+
+	processor.RunPostRunHook()
+	notifyCloneRun(&report)
+	return summary
+}
+
+// newRunProcessor returns a RepositoryProcessor using git with the state
+// manifest at statePath, the search index next to it, and the push mirror,
+// hooks and run reporter of the clone configured in the environment. save
+// writes the state manifest and search index back once the run is done.
+func newRunProcessor(git git.Gitter, statePath string) (processor *RepositoryProcessor, reporter *runReporter, save func(), err error) {
+	processor = NewRepositoryProcessor(git)
+	processor.SetOutputDir(outputDirAbsolutePath)
+
+	scmType := strings.ToLower(os.Getenv("GHORG_SCM_TYPE"))
+	state, err := LoadState(statePath, scmType, targetCloneSource)
+	if err != nil {
+		colorlog.PrintInfo(fmt.Sprintf("Could not load state file %s, starting fresh: %v", statePath, err))
+		state = NewStateManifest(scmType, targetCloneSource)
+	}
+	processor.SetState(state)
+
+	mirror, err := newPushMirrorFromEnv()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	processor.SetPushMirror(mirror)
+
+	hooks, err := newHooksFromEnv()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	processor.SetHooks(hooks)
+
+	if runReportOutput == nil {
+		runReportOutput = colorlog.Output()
+	}
+	reporter = newRunReporter(os.Getenv("GHORG_OUTPUT"), runReportOutput)
+	if reporter == nil {
+		reporter = &runReporter{out: io.Discard}
+	}
+	processor.SetReporter(reporter)
+
+	var searchIndex *SearchIndex
+	if os.Getenv("GHORG_SEARCH_INDEX") == "true" && os.Getenv("GHORG_BACKUP") != "true" {
+		searchIndexPath := filepath.Join(filepath.Dir(statePath), SearchIndexDirName)
+		searchIndex, err = LoadSearchIndex(searchIndexPath)
+		if err != nil {
+			colorlog.PrintInfo(fmt.Sprintf("Could not load search index %s, rebuilding it: %v", searchIndexPath, err))
+			searchIndex = NewSearchIndex(searchIndexPath)
+		}
+		processor.SetSearchIndex(searchIndex)
+	}
+
+	save = func() {
+		if err := SaveState(statePath, state); err != nil {
+			colorlog.PrintInfo(fmt.Sprintf("Could not write state file %s: %v", statePath, err))
+		}
+
+		if searchIndex != nil {
+			if err := searchIndex.Save(); err != nil {
+				colorlog.PrintInfo(fmt.Sprintf("Could not write search index: %v", err))
+			}
+		}
+	}
+	return processor, reporter, save, nil
 }
 
 // getGhorgStateFilePath returns the path of the per-repo state manifest,
@@ -1097,20 +1457,36 @@ func getCachedOrCalculatedOutputDirSizeInMb() (float64, error) {
 	return cachedDirSizeMB, nil
 }
 
-func pruneRepos(cloneTargets []scm.Repo) int {
-	count := 0
+// pruneRepos removes local clones whose repo no longer exists on the remote,
+// moving them into trash when it is set, and returns their paths relative to
+// the clone directory. Clones with local changes or unpushed commits are kept.
+func pruneRepos(processor *RepositoryProcessor, trash *trashBatch, cloneTargets []scm.Repo) []string {
+	var pruned []string
 	colorlog.PrintInfo("\nScanning for local clones that have been removed on remote...")
 
 	repositories, err := getRelativePathRepositories(outputDirAbsolutePath)
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		colorlog.Exit(1)
+	}
+
+	var candidates []string
+	for _, repository := range repositories {
+		if !sliceContainsNamedRepo(cloneTargets, repository) {
+			candidates = append(candidates, repository)
+		}
+	}
+	if err := checkPruneThreshold(len(candidates), len(repositories)); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Not pruning, %v", err))
+		processor.addError(fmt.Sprintf("Not pruning, %v", err))
+		return nil
 	}
 
 	// The first time around, we set userAgreesToDelete to true, otherwise we'd immediately
 	// break out of the loop.
 	userAgreesToDelete := true
 	pruneNoConfirm := os.Getenv("GHORG_PRUNE_NO_CONFIRM") == "true"
-	for _, repository := range repositories {
+	for _, repository := range candidates {
 		absolutePathToDelete := filepath.Join(outputDirAbsolutePath, repository)
 
 		// Safeguard: Ensure the path is within the expected base directory
@@ -1118,28 +1494,42 @@ func pruneRepos(cloneTargets []scm.Repo) int {
 			colorlog.PrintErrorAndExit(fmt.Sprintf("DANGEROUS ACTION DETECTED! Preventing deletion of %s as it is outside the base directory this deletion is not expected, exiting.", absolutePathToDelete))
 		}
 
-		// For each item in the org's clone directory, let's make sure we found a corresponding
-		// repo on the remote.  We check userAgreesToDelete here too, so that if the user says
+		// We check userAgreesToDelete here too, so that if the user says
 		// "No" at any time, we stop trying to prune things altogether.
-		if userAgreesToDelete && !sliceContainsNamedRepo(cloneTargets, repository) {
-			// If the user specified --prune-no-confirm, we needn't prompt interactively.
-			userAgreesToDelete = pruneNoConfirm || interactiveYesNoPrompt(
-				fmt.Sprintf("%s was not found in remote.  Do you want to prune it? %s", repository, absolutePathToDelete))
-			if userAgreesToDelete {
-				colorlog.PrintSubtleInfo(
-					fmt.Sprintf("Deleting %s", absolutePathToDelete))
-				err = os.RemoveAll(absolutePathToDelete)
-				count++
-				if err != nil {
-					log.Fatal(err)
-				}
-			} else {
-				colorlog.PrintError("Pruning cancelled by user.  No more prunes will be considered.")
-			}
+		if !userAgreesToDelete {
+			break
 		}
+
+		start := time.Now()
+		repo := scm.Repo{Name: filepath.Base(repository), URL: filepath.ToSlash(repository), HostPath: absolutePathToDelete}
+		if err := processor.checkPruneSafe(repo); err != nil {
+			colorlog.PrintWarning(fmt.Sprintf("Not pruning %s (%v)", absolutePathToDelete, err))
+			processor.addProtected(fmt.Sprintf("%s: %v", absolutePathToDelete, err))
+			processor.report(RepoEventProtected, &repo, start, "", err.Error())
+			continue
+		}
+
+		// If the user specified --prune-no-confirm, we needn't prompt interactively.
+		userAgreesToDelete = pruneNoConfirm || interactiveYesNoPrompt(
+			fmt.Sprintf("%s was not found in remote.  Do you want to prune it? %s", repository, absolutePathToDelete))
+		if !userAgreesToDelete {
+			colorlog.PrintError("Pruning cancelled by user.  No more prunes will be considered.")
+			break
+		}
+
+		if trash != nil {
+			colorlog.PrintSubtleInfo(fmt.Sprintf("Moving %s to %s", absolutePathToDelete, TrashDirName))
+		} else {
+			colorlog.PrintSubtleInfo(fmt.Sprintf("Deleting %s", absolutePathToDelete))
+		}
+		if err := trash.discard(absolutePathToDelete, "not found on remote"); err != nil {
+			log.Print(err)
+			colorlog.Exit(1)
+		}
+		pruned = append(pruned, filepath.ToSlash(strings.TrimPrefix(repository, string(filepath.Separator))))
 	}
 
-	return count
+	return pruned
 }
 
 // formatDurationText formats duration in seconds to a human-readable string
@@ -1157,7 +1547,7 @@ func formatDurationText(durationSeconds int) string {
 	}
 }
 
-func printCloneStatsMessage(cloneCount, pulledCount, skippedCount, protectedCount, updateRemoteCount, newCommits, syncedCount, untouchedPrunes, durationSeconds int) {
+func printCloneStatsMessage(cloneCount, pulledCount, skippedCount, protectedCount, renamedCount, updateRemoteCount, newCommits, syncedCount, untouchedPrunes, durationSeconds int) {
 	durationText := formatDurationText(durationSeconds)
 
 	// Build the stats line dynamically to avoid combinatorial explosion
@@ -1171,6 +1561,9 @@ func printCloneStatsMessage(cloneCount, pulledCount, skippedCount, protectedCoun
 	if protectedCount > 0 {
 		parts = append(parts, fmt.Sprintf("Protected: %v", protectedCount))
 	}
+	if renamedCount > 0 {
+		parts = append(parts, fmt.Sprintf("Renamed: %v", renamedCount))
+	}
 	if newCommits > 0 {
 		parts = append(parts, fmt.Sprintf("total new commits: %v", newCommits))
 	}
@@ -1184,7 +1577,7 @@ func printCloneStatsMessage(cloneCount, pulledCount, skippedCount, protectedCoun
 		parts = append(parts, fmt.Sprintf("total prunes: %v", untouchedPrunes))
 	}
 
-	fmt.Println()
+	fmt.Fprintln(colorlog.Output())
 	colorlog.PrintSuccess(strings.Join(parts, ", ") + durationText)
 }
 
@@ -1227,7 +1620,7 @@ func sliceContainsNamedRepo(haystack []scm.Repo, needle string) bool {
 
 		if normalizedPath == needle {
 			if os.Getenv("GHORG_DEBUG") != "" {
-				fmt.Printf("Debug: Match found for repo path: %s\n", repo.Path)
+				fmt.Fprintf(colorlog.Output(), "Debug: Match found for repo path: %s\n", repo.Path)
 			}
 			return true
 		}
@@ -1252,6 +1645,9 @@ func PrintConfigs() {
 	}
 
 	colorlog.PrintInfo("*************************************")
+	if os.Getenv("GHORG_PROFILE") != "" {
+		colorlog.PrintInfo("* Profile       : " + os.Getenv("GHORG_PROFILE"))
+	}
 	colorlog.PrintInfo("* SCM           : " + os.Getenv("GHORG_SCM_TYPE"))
 	colorlog.PrintInfo("* Type          : " + os.Getenv("GHORG_CLONE_TYPE"))
 	colorlog.PrintInfo("* Protocol      : " + os.Getenv("GHORG_CLONE_PROTOCOL"))
@@ -1320,6 +1716,9 @@ func PrintConfigs() {
 		if os.Getenv("GHORG_PRUNE_NO_CONFIRM") == "true" {
 			noConfirmText = " (skipping confirmation)"
 		}
+		if os.Getenv("GHORG_PRUNE_TRASH") == "true" {
+			noConfirmText += " (to " + TrashDirName + ")"
+		}
 		colorlog.PrintInfo("* Prune         : " + "true" + noConfirmText)
 	}
 	if os.Getenv("GHORG_FETCH_ALL") == "true" {
@@ -1344,6 +1743,24 @@ func PrintConfigs() {
 	if os.Getenv("GHORG_DRY_RUN") == "true" {
 		colorlog.PrintInfo("* Dry Run       : " + "true")
 	}
+	if os.Getenv("GHORG_PUSH_MIRROR_TO") != "" {
+		colorlog.PrintInfo("* Push Mirror   : " + scrubURLCredentials(os.Getenv("GHORG_PUSH_MIRROR_TO")))
+	}
+	if hooks := configuredHooks(); hooks != "" {
+		colorlog.PrintInfo("* Hooks         : " + hooks)
+	}
+	if os.Getenv("GHORG_SEARCH_INDEX") == "true" {
+		colorlog.PrintInfo("* Search Index  : " + "true")
+	}
+	if os.Getenv("GHORG_OUTPUT") != "" {
+		colorlog.PrintInfo("* Output        : " + os.Getenv("GHORG_OUTPUT"))
+	}
+	if os.Getenv("GHORG_OUTPUT_FILE") != "" {
+		colorlog.PrintInfo("* Output File   : " + os.Getenv("GHORG_OUTPUT_FILE"))
+	}
+	if os.Getenv("GHORG_JUNIT_REPORT") != "" {
+		colorlog.PrintInfo("* JUnit Report  : " + os.Getenv("GHORG_JUNIT_REPORT"))
+	}
 
 	if os.Getenv("GHORG_RECLONE_PATH") != "" && os.Getenv("GHORG_RECLONE_RUNNING") == "true" {
 		colorlog.PrintInfo("* Reclone Conf  : " + os.Getenv("GHORG_RECLONE_PATH"))
@@ -1365,6 +1782,9 @@ func PrintConfigs() {
 	if os.Getenv("GHORG_STATS_ENABLED") == "true" {
 		colorlog.PrintInfo("* Stats Enabled : " + os.Getenv("GHORG_STATS_ENABLED"))
 	}
+	if os.Getenv("GHORG_STATS_HISTORY") == "true" {
+		colorlog.PrintInfo("* Stats History : " + getGhorgHistoryDBPath())
+	}
 	colorlog.PrintInfo("* Ghorg version : " + GetVersion())
 
 	colorlog.PrintInfo("*************************************")
@@ -0,0 +1,383 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/korovkin/limiter"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/configs"
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// cloneTargetEnv are the environment variables that differ between the
+// targets of a multi-target clone.
+var cloneTargetEnv = []string{
+	"GHORG_SCM_TYPE",
+	"GHORG_CLONE_TYPE",
+	"GHORG_SCM_BASE_URL",
+	"GHORG_ABSOLUTE_PATH_TO_CLONE_TO",
+	"GHORG_ORIGINAL_ABSOLUTE_PATH_TO_CLONE_TO",
+}
+
+// CloneTarget is one org or user of a multi-target clone. BaseURL is the
+// target's own GHORG_SCM_BASE_URL, empty for the one of the command.
+type CloneTarget struct {
+	SCM       string
+	CloneType string
+	Name      string
+	BaseURL   string
+}
+
+func (t CloneTarget) String() string {
+	s := t.SCM + ":" + t.CloneType + ":" + t.Name
+	if t.BaseURL != "" {
+		s += "@" + t.BaseURL
+	}
+	return s
+}
+
+// parseCloneTarget parses a target given as [scm:][org|user:]name[@base-url],
+// taking the SCM and clone type missing from spec from scmType and cloneType.
+func parseCloneTarget(spec, scmType, cloneType string) (CloneTarget, error) {
+	t := CloneTarget{SCM: scmType, CloneType: cloneType}
+	spec, t.BaseURL, _ = strings.Cut(strings.TrimSpace(spec), "@")
+	if t.BaseURL != "" && !strings.Contains(t.BaseURL, "://") {
+		return CloneTarget{}, fmt.Errorf("invalid target %q, the base url must include the scheme", spec+"@"+t.BaseURL)
+	}
+	parts := strings.SplitN(spec, ":", 3)
+	t.Name = parts[len(parts)-1]
+
+	var scmSet, typeSet bool
+	for _, p := range parts[:len(parts)-1] {
+		switch {
+		case !scmSet && !typeSet && slices.Contains(scm.SupportedClients(), p):
+			t.SCM, scmSet = p, true
+		case !typeSet && (p == "org" || p == "user"):
+			t.CloneType, typeSet = p, true
+		default:
+			return CloneTarget{}, fmt.Errorf("invalid target %q, targets are [scm:][org|user:]name", spec)
+		}
+	}
+
+	// Like a single clone, only GitHub can clone the authenticated user without a name
+	if t.Name == "" && (t.SCM != "github" || t.CloneType != "user") {
+		return CloneTarget{}, fmt.Errorf("invalid target %q, missing the org or user", spec)
+	}
+	return t, nil
+}
+
+// readTargetsFile reads the targets in path, one per line. Blank lines and
+// lines starting with # are skipped.
+func readTargetsFile(path string) ([]string, error) {
+	lines, err := readLinesFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var specs []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			specs = append(specs, line)
+		}
+	}
+	return specs, nil
+}
+
+// cloneTargetSpecs returns the targets given as args followed by those in
+// GHORG_TARGETS_FILE.
+func cloneTargetSpecs(args []string) ([]string, error) {
+	specs := slices.Clone(args)
+	if path := os.Getenv("GHORG_TARGETS_FILE"); path != "" {
+		fromFile, err := readTargetsFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read GHORG_TARGETS_FILE %s: %w", path, err)
+		}
+		specs = append(specs, fromFile...)
+	}
+	return specs, nil
+}
+
+// isMultiTargetClone reports whether args and GHORG_TARGETS_FILE make a clone
+// of several targets rather than a single org or user.
+func isMultiTargetClone(args []string) bool {
+	return len(args) > 1 || os.Getenv("GHORG_TARGETS_FILE") != ""
+}
+
+// targetRun is a target of a multi-target clone with the environment and
+// clone directory it is cloned with, and the config its repos are listed with.
+type targetRun struct {
+	CloneTarget
+	env           map[string]string
+	outputDirName string
+	outputDir     string
+	clientConfig  scm.ClientConfig
+
+	repos   []scm.Repo
+	listErr error
+	run     *cloneRun
+	stats   CloneStats
+}
+
+// activate switches the environment and clone directory to those of the target.
+func (t *targetRun) activate() {
+	for _, key := range cloneTargetEnv {
+		if v := t.env[key]; v != "" {
+			os.Setenv(key, v)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+	outputDirName = t.outputDirName
+	outputDirAbsolutePath = t.outputDir
+	targetCloneSource = t.Name
+}
+
+// statePath returns the state manifest of the target, kept in its clone
+// directory next to its search index so targets don't share them.
+func (t *targetRun) statePath() string {
+	return filepath.Join(t.outputDir, StateFileName)
+}
+
+// currentTargetRun returns the environment and clone directory in effect,
+// to restore them with activate.
+func currentTargetRun() *targetRun {
+	t := &targetRun{
+		env:           make(map[string]string, len(cloneTargetEnv)),
+		outputDirName: outputDirName,
+		outputDir:     outputDirAbsolutePath,
+	}
+	t.Name = targetCloneSource
+	for _, key := range cloneTargetEnv {
+		t.env[key] = os.Getenv(key)
+	}
+	return t
+}
+
+// configureCloneTargets resolves the token, configuration and clone
+// directory of every target, starting each from the environment the command
+// was run with.
+func configureCloneTargets(targets []CloneTarget) ([]*targetRun, error) {
+	base := make(map[string]string, len(cloneTargetEnv))
+	for _, key := range cloneTargetEnv {
+		base[key] = os.Getenv(key)
+	}
+
+	runs := make([]*targetRun, 0, len(targets))
+	dirs := make(map[string]CloneTarget)
+	var gitlabAll, gitlabOther bool
+	for _, target := range targets {
+		(&targetRun{env: base}).activate()
+		os.Setenv("GHORG_SCM_TYPE", target.SCM)
+		os.Setenv("GHORG_CLONE_TYPE", target.CloneType)
+		if target.BaseURL != "" {
+			os.Setenv("GHORG_SCM_BASE_URL", target.BaseURL)
+		}
+		if err := configs.GetOrSetToken(); err != nil {
+			return nil, fmt.Errorf("%s: %w", target, err)
+		}
+		if err := validateConfig(); err != nil {
+			return nil, fmt.Errorf("%s: %w", target, err)
+		}
+		setCloneTarget([]string{target.Name})
+
+		if other, ok := dirs[outputDirAbsolutePath]; ok {
+			return nil, fmt.Errorf("%s and %s would both be cloned into %s", other, target, outputDirAbsolutePath)
+		}
+		dirs[outputDirAbsolutePath] = target
+
+		// The GitLab client remembers all-groups and all-users clones for
+		// the rest of the process, which would change the paths of others.
+		if target.SCM == "gitlab" {
+			if target.Name == "all-groups" || target.Name == "all-users" {
+				gitlabAll = true
+			} else {
+				gitlabOther = true
+			}
+		}
+
+		run := &targetRun{
+			CloneTarget:   target,
+			env:           make(map[string]string, len(cloneTargetEnv)),
+			outputDirName: outputDirName,
+			outputDir:     outputDirAbsolutePath,
+			clientConfig:  scm.ClientConfigFromEnv(target.SCM),
+		}
+		for _, key := range cloneTargetEnv {
+			run.env[key] = os.Getenv(key)
+		}
+		runs = append(runs, run)
+	}
+	(&targetRun{env: base}).activate()
+	if gitlabAll && gitlabOther {
+		return nil, fmt.Errorf("GitLab all-groups and all-users targets can't be cloned together with other GitLab targets")
+	}
+	return runs, nil
+}
+
+// listTargetRepos lists the repos of every target concurrently, up to
+// GHORG_CONCURRENCY at a time. Each target's client is created with its own
+// base URL, clone type and token, the filters the targets share are read
+// from the environment. The clients are created one after another, as
+// creating a GitHub App client sets GHORG_GITHUB_TOKEN.
+func listTargetRepos(runs []*targetRun) {
+	clients := make([]scm.Client, len(runs))
+	for i, t := range runs {
+		clients[i], t.listErr = scm.GetClientWithConfig(t.SCM, t.clientConfig)
+	}
+
+	limit := limiter.NewConcurrencyLimiter(concurrencyFromEnv())
+	for i, t := range runs {
+		if t.listErr != nil {
+			continue
+		}
+		client := clients[i]
+		limit.Execute(func() {
+			if t.CloneType == "user" {
+				t.repos, t.listErr = client.GetUserRepos(t.Name)
+			} else {
+				t.repos, t.listErr = client.GetOrgRepos(t.Name)
+			}
+		})
+	}
+	limit.WaitAndClose()
+}
+
+// cloneMultipleTargets clones the orgs and users given as specs in one run.
+// Their repos are listed concurrently and cloned under a single
+// GHORG_CONCURRENCY limit, each target into its own clone directory with its
+// own summary, followed by a combined summary. It returns an error when the
+// targets are invalid, before anything is cloned.
+func cloneMultipleTargets(g git.Gitter, specs []string) error {
+	if len(specs) == 0 {
+		return fmt.Errorf("you must provide an org or user to clone")
+	}
+	if os.Getenv("GHORG_OUTPUT_DIR") != "" {
+		return fmt.Errorf("GHORG_OUTPUT_DIR can't be used with multiple targets, each target is cloned into its own directory")
+	}
+	if os.Getenv("GHORG_GITHUB_USER_GISTS") == "true" {
+		return fmt.Errorf("GHORG_GITHUB_USER_GISTS can't be used with multiple targets")
+	}
+
+	targets := make([]CloneTarget, 0, len(specs))
+	for _, spec := range specs {
+		target, err := parseCloneTarget(spec, os.Getenv("GHORG_SCM_TYPE"), os.Getenv("GHORG_CLONE_TYPE"))
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	}
+	runs, err := configureCloneTargets(targets)
+	if err != nil {
+		return err
+	}
+
+	cloneErrors = nil
+	cloneInfos = nil
+	lastRunReport = nil
+
+	asciiTime()
+	PrintConfigs()
+	colorlog.PrintInfo(fmt.Sprintf("Listing the repos of %d targets...", len(runs)))
+	listTargetRepos(runs)
+
+	handleExitCodes(cloneListedTargets(g, runs))
+	return nil
+}
+
+// cloneListedTargets clones the listed repos of runs under a single
+// GHORG_CONCURRENCY limit and prints the summary of each target and the
+// combined summary. It returns the number of infos and errors of all targets,
+// counting failed listings as errors.
+func cloneListedTargets(g git.Gitter, runs []*targetRun) (int, int) {
+	limit := limiter.NewConcurrencyLimiter(concurrencyFromEnv())
+	for _, t := range runs {
+		if t.listErr != nil {
+			colorlog.PrintError(fmt.Sprintf("Could not list the repos of %s: %v", t.CloneTarget, t.listErr))
+			continue
+		}
+		if len(t.repos) == 0 {
+			colorlog.PrintInfo(fmt.Sprintf("No repos found for %s, please verify you have sufficient permissions to clone target repos, double check spelling and try again.", t.CloneTarget))
+			continue
+		}
+		t.activate()
+		colorlog.PrintInfo(fmt.Sprintf("\n%s -> %s", t.CloneTarget, t.outputDir))
+		t.run = startCloneRun(g, t.repos, t.statePath())
+		if t.run != nil {
+			t.run.queue(limit)
+		}
+	}
+	limit.WaitAndClose()
+
+	var finished int
+	for _, t := range runs {
+		if t.run == nil {
+			continue
+		}
+		t.activate()
+		colorlog.PrintInfo(fmt.Sprintf("\n============ %s ============", t.CloneTarget))
+		cachedDirSizeMB = 0
+		isDirSizeCached = false
+		t.stats = t.run.finish()
+		finished++
+	}
+
+	if finished == 0 && os.Getenv("GHORG_DRY_RUN") == "true" {
+		var errs int
+		for _, t := range runs {
+			if t.listErr != nil {
+				errs++
+			}
+		}
+		return 0, errs
+	}
+	return printCombinedSummary(runs)
+}
+
+// printCombinedSummary prints a line per target and the totals of all targets
+// and returns their number of infos and errors.
+func printCombinedSummary(runs []*targetRun) (int, int) {
+	var total CloneStats
+	var infos, errs int
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tDIRECTORY\tCLONED\tUPDATED\tINFOS\tISSUES")
+	for _, t := range runs {
+		switch {
+		case t.listErr != nil:
+			errs++
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\tlisting failed\n", t.CloneTarget, t.outputDir)
+		case t.run == nil:
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\n", t.CloneTarget, t.outputDir)
+		default:
+			s := t.stats
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\n", t.CloneTarget, t.outputDir, s.CloneCount, s.PulledCount, len(s.CloneInfos), len(s.CloneErrors))
+			total.CloneCount += s.CloneCount
+			total.PulledCount += s.PulledCount
+			total.SkippedCount += s.SkippedCount
+			total.ProtectedCount += s.ProtectedCount
+			total.RenamedCount += s.RenamedCount
+			total.UpdateRemoteCount += s.UpdateRemoteCount
+			total.NewCommits += s.NewCommits
+			total.SyncedCount += s.SyncedCount
+			total.UntouchedPrunes += s.UntouchedPrunes
+			infos += len(s.CloneInfos)
+			errs += len(s.CloneErrors)
+		}
+	}
+	tw.Flush()
+
+	if os.Getenv("GHORG_QUIET") != "true" {
+		colorlog.PrintInfo(fmt.Sprintf("\n============ %d targets ============\n", len(runs)))
+		fmt.Fprint(colorlog.Output(), b.String())
+	}
+	printCloneStatsMessage(total.CloneCount, total.PulledCount, total.SkippedCount, total.ProtectedCount, total.RenamedCount, total.UpdateRemoteCount, total.NewCommits, total.SyncedCount, total.UntouchedPrunes, int(time.Since(commandStartTime).Seconds()+0.5))
+	return infos, errs
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+func TestParseCloneTarget(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    CloneTarget
+		wantErr string
+	}{
+		{spec: "kubernetes", want: CloneTarget{"github", "org", "kubernetes", ""}},
+		{spec: "gitlab:my-group/sub", want: CloneTarget{"gitlab", "org", "my-group/sub", ""}},
+		{spec: "user:jdoe", want: CloneTarget{"github", "user", "jdoe", ""}},
+		{spec: "gitea:user:jdoe", want: CloneTarget{"gitea", "user", "jdoe", ""}},
+		{spec: "github", want: CloneTarget{"github", "org", "github", ""}},
+		{spec: "github:user:", want: CloneTarget{"github", "user", "", ""}},
+		{spec: "user:github:jdoe", wantErr: "invalid target"},
+		{spec: "svn:org:x", wantErr: "invalid target"},
+		{spec: "gitlab:user:", wantErr: "missing the org or user"},
+		{spec: "gitea:infra@https://git.example.com", want: CloneTarget{"gitea", "org", "infra", "https://git.example.com"}},
+		{spec: "gitlab:user:jdoe@http://gitlab.local:8080", want: CloneTarget{"gitlab", "user", "jdoe", "http://gitlab.local:8080"}},
+		{spec: "infra@git.example.com", wantErr: "must include the scheme"},
+	}
+	for _, tt := range tests {
+		got, err := parseCloneTarget(tt.spec, "github", "org")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: got %+v, %v; want error %q", tt.spec, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %+v, %v; want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestCloneTargetSpecs(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	if isMultiTargetClone([]string{"org"}) {
+		t.Error("a single target is not a multi-target clone")
+	}
+
+	path := filepath.Join(t.TempDir(), "targets")
+	if err := os.WriteFile(path, []byte("# work\ngitlab:group-a\n\n  github:user:jdoe  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GHORG_TARGETS_FILE", path)
+	if !isMultiTargetClone(nil) {
+		t.Error("GHORG_TARGETS_FILE should make a multi-target clone")
+	}
+	specs, err := cloneTargetSpecs([]string{"kubernetes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(specs, ",") != "kubernetes,gitlab:group-a,github:user:jdoe" {
+		t.Errorf("specs = %q", specs)
+	}
+}
+
+func TestConfigureCloneTargets(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedDir, savedName, savedTarget := outputDirAbsolutePath, outputDirName, targetCloneSource
+	defer func() { outputDirAbsolutePath, outputDirName, targetCloneSource = savedDir, savedName, savedTarget }()
+	root := t.TempDir()
+	os.Setenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO", root)
+	os.Setenv("GHORG_SCM_TYPE", "github")
+	os.Setenv("GHORG_CLONE_TYPE", "org")
+	os.Setenv("GHORG_CLONE_PROTOCOL", "https")
+	os.Setenv("GHORG_GITHUB_TOKEN", "gh")
+	os.Setenv("GHORG_GITLAB_TOKEN", "gl")
+
+	runs, err := configureCloneTargets([]CloneTarget{{"github", "org", "Kubernetes", ""}, {"gitlab", "user", "jdoe", ""}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("GHORG_SCM_TYPE") != "github" || os.Getenv("GHORG_CLONE_TYPE") != "org" {
+		t.Error("the environment of the command was not restored")
+	}
+	runs[1].activate()
+	if os.Getenv("GHORG_SCM_TYPE") != "gitlab" || os.Getenv("GHORG_CLONE_TYPE") != "user" || outputDirAbsolutePath != filepath.Join(root, "jdoe") || targetCloneSource != "jdoe" {
+		t.Errorf("gitlab target not activated: %s %s %s", os.Getenv("GHORG_SCM_TYPE"), outputDirAbsolutePath, targetCloneSource)
+	}
+	if runs[0].outputDir != filepath.Join(root, "kubernetes") {
+		t.Errorf("github target dir = %s", runs[0].outputDir)
+	}
+
+	if _, err := configureCloneTargets([]CloneTarget{{"github", "org", "same", ""}, {"gitlab", "org", "same", ""}}); err == nil || !strings.Contains(err.Error(), "would both be cloned into") {
+		t.Errorf("expected an error for targets sharing a directory, got %v", err)
+	}
+	if _, err := configureCloneTargets([]CloneTarget{{"gitlab", "org", "all-groups", ""}, {"gitlab", "org", "group", ""}}); err == nil || !strings.Contains(err.Error(), "all-groups") {
+		t.Errorf("expected an error for all-groups with another GitLab target, got %v", err)
+	}
+	os.Unsetenv("GHORG_GITLAB_TOKEN")
+	if _, err := configureCloneTargets([]CloneTarget{{"gitlab", "org", "group", ""}}); err == nil || !strings.HasPrefix(err.Error(), "gitlab:org:group: ") {
+		t.Errorf("expected the target in the missing token error, got %v", err)
+	}
+}
+
+// giteaListServer serves the repos of org as a Gitea instance would.
+// giteaListServer serves the repos of org. Its first listing is marked done
+// on listing and waits until the listings of the other servers started.
+func giteaListServer(t *testing.T, listing *sync.WaitGroup, org string, repos ...string) *httptest.Server {
+	t.Helper()
+	var started sync.Once
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"version": "1.21.0"})
+	})
+	mux.HandleFunc("/api/v1/orgs/"+org+"/repos", func(w http.ResponseWriter, r *http.Request) {
+		var list []map[string]any
+		if r.URL.Query().Get("page") == "1" {
+			started.Do(func() {
+				listing.Done()
+				waited := make(chan struct{})
+				go func() {
+					listing.Wait()
+					close(waited)
+				}()
+				select {
+				case <-waited:
+				case <-time.After(5 * time.Second):
+					t.Errorf("%s was listed before the other targets started listing", org)
+				}
+			})
+			for i, name := range repos {
+				list = append(list, map[string]any{
+					"id":             i + 1,
+					"name":           name,
+					"full_name":      org + "/" + name,
+					"clone_url":      "http://" + r.Host + "/" + org + "/" + name + ".git",
+					"default_branch": "main",
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestListTargetReposConcurrentlyWithEachTargetsBaseURL(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedDir, savedName, savedTarget := outputDirAbsolutePath, outputDirName, targetCloneSource
+	defer func() { outputDirAbsolutePath, outputDirName, targetCloneSource = savedDir, savedName, savedTarget }()
+	os.Setenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO", t.TempDir())
+	os.Setenv("GHORG_SCM_TYPE", "github")
+	os.Setenv("GHORG_CLONE_TYPE", "org")
+	os.Setenv("GHORG_CLONE_PROTOCOL", "https")
+	os.Setenv("GHORG_GITEA_TOKEN", "token")
+	os.Setenv("GHORG_INSECURE_GITEA_CLIENT", "true")
+	os.Setenv("GHORG_CONCURRENCY", "2")
+
+	var listing sync.WaitGroup
+	listing.Add(2)
+	serverA := giteaListServer(t, &listing, "infra", "api", "web")
+	serverB := giteaListServer(t, &listing, "tools", "cli")
+
+	var targets []CloneTarget
+	for _, spec := range []string{"gitea:infra@" + serverA.URL, "gitea:tools@" + serverB.URL} {
+		target, err := parseCloneTarget(spec, "github", "org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, target)
+	}
+	runs, err := configureCloneTargets(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listTargetRepos(runs)
+
+	for i, want := range []struct {
+		server *httptest.Server
+		names  string
+	}{{serverA, "api,web"}, {serverB, "cli"}} {
+		run := runs[i]
+		if run.listErr != nil {
+			t.Fatalf("%s: %v", run.CloneTarget, run.listErr)
+		}
+		var names []string
+		for _, repo := range run.repos {
+			names = append(names, repo.Name)
+			if !strings.HasPrefix(repo.CloneURL, want.server.URL) {
+				t.Errorf("%s: repo %s listed from %s", run.CloneTarget, repo.Name, repo.CloneURL)
+			}
+		}
+		if strings.Join(names, ",") != want.names {
+			t.Errorf("%s: repos = %v, want %s", run.CloneTarget, names, want.names)
+		}
+	}
+	if os.Getenv("GHORG_SCM_BASE_URL") != "" || os.Getenv("GHORG_SCM_TYPE") != "github" {
+		t.Error("listing changed the environment of the command")
+	}
+}
+
+// hostPathMockGit clones into the host path of each repo.
+type hostPathMockGit struct {
+	MockGitClient
+}
+
+func (g hostPathMockGit) Clone(repo scm.Repo) error {
+	return os.MkdirAll(filepath.Join(repo.HostPath, ".git"), 0o755)
+}
+
+func TestCloneListedTargets(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedDir, savedName, savedTarget := outputDirAbsolutePath, outputDirName, targetCloneSource
+	defer func() { outputDirAbsolutePath, outputDirName, targetCloneSource = savedDir, savedName, savedTarget }()
+	root := t.TempDir()
+	os.Setenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO", root)
+	os.Setenv("GHORG_SCM_TYPE", "github")
+	os.Setenv("GHORG_CLONE_TYPE", "org")
+	os.Setenv("GHORG_CLONE_PROTOCOL", "https")
+	os.Setenv("GHORG_GITHUB_TOKEN", "gh")
+	os.Setenv("GHORG_GITLAB_TOKEN", "gl")
+	os.Setenv("GHORG_CONCURRENCY", "2")
+	os.Setenv("GHORG_NO_DIR_SIZE", "true")
+	os.Setenv("GHORG_DONT_EXIT_UNDER_TEST", "true")
+	commandStartTime = time.Now()
+
+	os.Setenv("GHORG_GITHUB_USER_OPTION", "owner")
+	runs, err := configureCloneTargets([]CloneTarget{{"github", "org", "org-a", ""}, {"gitlab", "org", "group-b", ""}, {"github", "user", "gone", ""}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs[0].repos = []scm.Repo{{Name: "one", URL: "https://github.com/org-a/one.git"}, {Name: "two", URL: "https://github.com/org-a/two.git"}}
+	runs[1].repos = []scm.Repo{{Name: "three", URL: "https://gitlab.com/group-b/three.git"}}
+	runs[2].listErr = errors.New("404 Not Found")
+
+	infos, errs := cloneListedTargets(hostPathMockGit{}, runs)
+	if infos != 0 || errs != 1 {
+		t.Errorf("infos, errors = %d, %d; want 0, 1 for the failed listing", infos, errs)
+	}
+	for _, dir := range []string{"org-a/one", "org-a/two", "group-b/three"} {
+		if _, err := os.Stat(filepath.Join(root, dir)); err != nil {
+			t.Errorf("%s was not cloned into its target directory: %v", dir, err)
+		}
+	}
+	if runs[0].stats.CloneCount != 2 || runs[1].stats.CloneCount != 1 {
+		t.Errorf("per target clone counts = %d, %d", runs[0].stats.CloneCount, runs[1].stats.CloneCount)
+	}
+	for _, want := range []struct {
+		dir, target string
+		urls        []string
+	}{
+		{"org-a", "org-a", []string{"https://github.com/org-a/one.git", "https://github.com/org-a/two.git"}},
+		{"group-b", "group-b", []string{"https://gitlab.com/group-b/three.git"}},
+	} {
+		state, err := LoadState(filepath.Join(root, want.dir, StateFileName), "", want.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(state.Repos) != len(want.urls) {
+			t.Errorf("%s state has %d repos, want %v", want.dir, len(state.Repos), want.urls)
+		}
+		for _, url := range want.urls {
+			if _, ok := state.Repos[url]; !ok {
+				t.Errorf("%s is missing from the state file of %s", url, want.dir)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, StateFileName)); !os.IsNotExist(err) {
+		t.Errorf("the targets recorded into a state file shared in %s", root)
+	}
+}
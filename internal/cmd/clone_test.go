@@ -216,6 +216,11 @@ func (g MockGitClient) GetRemoteURL(repo scm.Repo, remote string) (string, error
 	return "https://github.com/mock/repo.git", nil
 }
 
+// GetRemoteDefaultBranch returns the default branch name from the remote.
+func (g MockGitClient) GetRemoteDefaultBranch(repo scm.Repo) (string, error) {
+	return "main", nil
+}
+
 // HasLocalChanges returns true if there are uncommitted changes in the working tree.
 func (g MockGitClient) HasLocalChanges(repo scm.Repo) (bool, error) {
 	return false, nil
@@ -226,11 +231,21 @@ func (g MockGitClient) HasUnpushedCommits(repo scm.Repo) (bool, error) {
 	return false, nil
 }
 
+// HasUnpushedBranches returns true if any local branch has commits that are not on a remote.
+func (g MockGitClient) HasUnpushedBranches(repo scm.Repo) (bool, error) {
+	return false, nil
+}
+
 // GetCurrentBranch returns the currently checked-out branch name.
 func (g MockGitClient) GetCurrentBranch(repo scm.Repo) (string, error) {
 	return "main", nil
 }
 
+// IsDetachedHead reports whether HEAD points at a commit instead of a branch.
+func (g MockGitClient) IsDetachedHead(repo scm.Repo) (bool, error) {
+	return false, nil
+}
+
 // CheckoutBranch checks out the specified branch by name.
 func (g MockGitClient) CheckoutBranch(repo scm.Repo, branch string) error {
 	return nil
@@ -260,6 +275,11 @@ func (g MockGitClient) HeadSHA(repo scm.Repo) (string, error) {
 	return "", nil
 }
 
+// PushMirror pushes every branch and tag of the local clone to the given remote URL.
+func (g MockGitClient) PushMirror(repo scm.Repo, url, token string) error {
+	return nil
+}
+
 func TestInitialClone(t *testing.T) {
 	defer UnsetEnv("GHORG_")()
 	dir, err := os.MkdirTemp("", "ghorg_test_initial")
@@ -802,7 +822,7 @@ func TestPruneRepos(t *testing.T) {
 		t.Fatalf("Failed to create directory: %v", err)
 	}
 
-	pruneRepos(cloneTargets)
+	pruneRepos(NewRepositoryProcessor(NewMockGit()), nil, cloneTargets)
 
 	if _, err := os.Stat(repository); os.IsNotExist(err) {
 		t.Errorf("Expected '%s' to exist, but it was deleted", repository)
@@ -1158,7 +1178,7 @@ func TestPrintCloneStatsMessage_WithTiming(t *testing.T) {
 			// and the timing formatting logic is correct
 
 			// This should not panic and should execute successfully
-			printCloneStatsMessage(tc.cloneCount, tc.pulledCount, tc.skippedCount, 0, tc.updateRemoteCount,
+			printCloneStatsMessage(tc.cloneCount, tc.pulledCount, tc.skippedCount, 0, 0, tc.updateRemoteCount,
 				tc.newCommits, tc.syncedCount, tc.untouchedPrunes, tc.durationSeconds)
 
 			// The expectedText should be present in the output (in a real test with output capture)
@@ -3,6 +3,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -26,9 +27,21 @@ var (
 	cachedDirSizeMB       float64
 	isDirSizeCached       bool
 	commandStartTime      time.Time
+	// runReportOutput receives the --output report, stdout before human
+	// output was redirected to stderr.
+	runReportOutput io.Writer
+	// lastRunReport is the report of the last clone run, read by reclone
+	// after running an entry in-process.
+	lastRunReport *RunReport
 
 	// Global koanf instance for configuration
 	k = koanf.New(".")
+
+	// configEnv holds the environment variables InitConfig set from the
+	// configuration files or defaults, with the values it set them to. They
+	// are resolved again when the configuration is reloaded, e.g. for
+	// --config or --profile, while variables set by the user are kept.
+	configEnv = map[string]string{}
 )
 
 func init() {
@@ -81,17 +94,28 @@ func getDefaultValue(envVar string) string {
 }
 
 // resolveKoanfValue looks up a config value in koanf, checking both the new
-// dot-notation key and the legacy GHORG_* flat key.
+// dot-notation key and the legacy GHORG_* flat key. Values of the profile
+// selected with GHORG_PROFILE take precedence over the rest of the file.
 func resolveKoanfValue(envVar string) string {
+	if profile := os.Getenv("GHORG_PROFILE"); profile != "" {
+		if val := resolveKoanfKey(configs.ProfileKey(profile, "")+".", envVar); val != "" {
+			return val
+		}
+	}
+	return resolveKoanfKey("", envVar)
+}
+
+// resolveKoanfKey looks up envVar below prefix in koanf.
+func resolveKoanfKey(prefix, envVar string) string {
 	// Try new dot-notation key first (e.g., "scm.type")
 	ck := configs.LookupByEnvVar(envVar)
 	if ck != nil {
-		if val := k.String(ck.DotNotation); val != "" {
+		if val := k.String(prefix + ck.DotNotation); val != "" {
 			return val
 		}
 	}
 	// Fall back to legacy flat key (e.g., "GHORG_SCM_TYPE")
-	return k.String(envVar)
+	return k.String(prefix + envVar)
 }
 
 // reads in configuration file and updates anything not set to default
@@ -117,6 +141,11 @@ func getOrSetDefaults(envVar string) {
 	// When a user does not set value in config file, set the default values,
 	// else set env to what they have added to the file.
 	if os.Getenv(envVar) == "" {
+		defer func() {
+			if v := os.Getenv(envVar); v != "" {
+				configEnv[envVar] = v
+			}
+		}()
 		koanfResult := resolveKoanfValue(envVar)
 		if koanfResult != "" {
 			// Handle path-related env vars that need special formatting
@@ -151,6 +180,14 @@ func InitConfig() {
 	// Reset koanf instance for testing
 	k = koanf.New(".")
 
+	// Resolve what an earlier load set again, unless it was changed since
+	for envVar, value := range configEnv {
+		if os.Getenv(envVar) == value {
+			os.Unsetenv(envVar)
+		}
+	}
+	configEnv = map[string]string{}
+
 	curDir, _ := os.Getwd()
 	legacyLocalConfig := filepath.Join(curDir, "ghorg.yaml")
 	newLocalConfig := filepath.Join(curDir, ".ghorg", "config.yaml")
@@ -177,7 +214,7 @@ func InitConfig() {
 			os.Setenv("GHORG_CONFIG", "none")
 		} else {
 			colorlog.PrintError(fmt.Sprintf("Something unexpected happened reading configuration file: %s, err: %s", os.Getenv("GHORG_CONFIG"), err))
-			os.Exit(1)
+			colorlog.Exit(1)
 		}
 	}
 
@@ -190,10 +227,15 @@ func InitConfig() {
 		}
 	}
 
+	if profile := os.Getenv("GHORG_PROFILE"); profile != "" && !k.Exists(configs.ProfileKey(profile, "")) {
+		colorlog.PrintError(fmt.Sprintf("Profile %q not found in %s, see ghorg config profiles", profile, os.Getenv("GHORG_CONFIG")))
+		colorlog.Exit(1)
+	}
+
 	if os.Getenv("GHORG_DEBUG") != "" {
-		fmt.Println("-------- Setting Default ENV values ---------")
+		fmt.Fprintln(colorlog.Output(), "-------- Setting Default ENV values ---------")
 		if os.Getenv("GHORG_CONCURRENCY_DEBUG") == "" {
-			fmt.Println("Setting concurrency to 1, this can be overwritten by setting GHORG_CONCURRENCY_DEBUG; however when using concurrency with GHORG_DEBUG, not all debugging output will be printed in serial order.")
+			fmt.Fprintln(colorlog.Output(), "Setting concurrency to 1, this can be overwritten by setting GHORG_CONCURRENCY_DEBUG; however when using concurrency with GHORG_DEBUG, not all debugging output will be printed in serial order.")
 			os.Setenv("GHORG_CONCURRENCY", "1")
 		}
 	}
@@ -204,11 +246,14 @@ func InitConfig() {
 	}
 
 	if os.Getenv("GHORG_DEBUG") != "" {
-		fmt.Println("Koanf config file used:", os.Getenv("GHORG_CONFIG"))
-		fmt.Printf("GHORG_CONFIG SET TO: %s\n", os.Getenv("GHORG_CONFIG"))
+		fmt.Fprintln(colorlog.Output(), "Koanf config file used:", os.Getenv("GHORG_CONFIG"))
+		fmt.Fprintf(colorlog.Output(), "GHORG_CONFIG SET TO: %s\n", os.Getenv("GHORG_CONFIG"))
 		// Print all loaded config keys for debugging
-		fmt.Println("Loaded config keys:", k.Keys())
+		fmt.Fprintln(colorlog.Output(), "Loaded config keys:", k.Keys())
 	}
 
 	updateAbsolutePathToCloneToWithHostname()
+	if _, ok := configEnv["GHORG_ABSOLUTE_PATH_TO_CLONE_TO"]; ok {
+		configEnv["GHORG_ABSOLUTE_PATH_TO_CLONE_TO"] = os.Getenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO")
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/hashicorp/cli"
@@ -20,12 +21,13 @@ type ConfigCmdCommand struct {
 }
 
 type configFlags struct {
-	Get     bool `long:"get" description:"Get the value of a config key"`
-	Unset   bool `long:"unset" description:"Remove a config key"`
-	List    bool `long:"list" short:"l" description:"List all config settings"`
-	Global  bool `long:"global" description:"Use global config file (~/.config/ghorg/conf.yaml)"`
-	Local   bool `long:"local" description:"Use local config file (.ghorg/config.yaml in current directory)"`
-	Migrate bool `long:"migrate" description:"Convert legacy GHORG_* config file to new nested format"`
+	Get     bool   `long:"get" description:"Get the value of a config key"`
+	Unset   bool   `long:"unset" description:"Remove a config key"`
+	List    bool   `long:"list" short:"l" description:"List all config settings"`
+	Global  bool   `long:"global" description:"Use global config file (~/.config/ghorg/conf.yaml)"`
+	Local   bool   `long:"local" description:"Use local config file (.ghorg/config.yaml in current directory)"`
+	Migrate bool   `long:"migrate" description:"Convert legacy GHORG_* config file to new nested format"`
+	Profile string `long:"profile" description:"Read and write the keys of this profile"`
 }
 
 func (c *ConfigCmdCommand) Help() string {
@@ -49,6 +51,11 @@ List all values:
   ghorg config --list --global
   ghorg config --list --local
 
+Profiles:
+  ghorg config --profile work scm.base-url https://github.example.com
+  ghorg config --profile work --list
+  ghorg config profiles
+
 Migrate legacy config:
   ghorg config --migrate
   ghorg config --migrate --local
@@ -60,6 +67,7 @@ Options:
   --global       Use global config (~/.config/ghorg/conf.yaml)
   --local        Use local config (.ghorg/config.yaml in current directory)
   --migrate      Convert legacy GHORG_* config to new nested format
+  --profile      Read and write the keys of this profile
 
 Configuration files:
   Global: ~/.config/ghorg/conf.yaml (default)
@@ -67,10 +75,27 @@ Configuration files:
 
   Local config values override global when both are present.
 
+Profiles:
+  A profile is a named set of config keys below profiles.<name> in a config
+  file, selected with --profile or GHORG_PROFILE. Its values take precedence
+  over the rest of the config files, flags and environment variables take
+  precedence over both:
+
+    profiles:
+      work:
+        scm:
+          type: github
+          base-url: https://github.example.com
+        github:
+          token: ~/.config/ghorg/work-token
+
+  ghorg config profiles lists the profiles, marking the one selected with
+  GHORG_PROFILE.
+
 Available sections:
   core, scm, clone, auth, git, filter, prune, fetch,
   exit-code, stats, ssh, github, gitlab, bitbucket,
-  gitea, sourcehut, reclone
+  gitea, sourcehut, mirror, reclone
 `
 }
 
@@ -91,6 +116,17 @@ func (c *ConfigCmdCommand) Run(args []string) int {
 		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
 		return 1
 	}
+	if opts.Profile != "" {
+		if err := configs.ValidateProfileName(opts.Profile); err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+	}
+
+	// profiles: list the profiles
+	if len(remaining) == 1 && remaining[0] == "profiles" && !opts.List && !opts.Unset && !opts.Migrate {
+		return c.runProfiles(opts)
+	}
 
 	// --migrate: convert legacy config to new format
 	if opts.Migrate {
@@ -152,7 +188,7 @@ func (c *ConfigCmdCommand) runGet(key string, opts configFlags) int {
 	// Determine which files to check based on scope
 	if opts.Local {
 		path := localConfigPath()
-		val, found, err := configs.ReadConfigValue(path, key)
+		val, found, err := readProfileValue(path, key, opts.Profile)
 		if err != nil {
 			if os.IsNotExist(err) {
 				colorlog.PrintError("No local config file found. Create one with: ghorg config --local <key> <value>")
@@ -170,7 +206,7 @@ func (c *ConfigCmdCommand) runGet(key string, opts configFlags) int {
 
 	if opts.Global {
 		path := configs.DefaultConfFile()
-		val, found, err := configs.ReadConfigValue(path, key)
+		val, found, err := readProfileValue(path, key, opts.Profile)
 		if err != nil {
 			if os.IsNotExist(err) {
 				colorlog.PrintError("No global config file found")
@@ -189,17 +225,22 @@ func (c *ConfigCmdCommand) runGet(key string, opts configFlags) int {
 	// Default: check local config, then primary config, then default
 	// (matches git config behavior — reads from files, not env vars)
 	localPath := localConfigPath()
-	if val, found, err := configs.ReadConfigValue(localPath, key); err == nil && found {
+	if val, found, err := readProfileValue(localPath, key, opts.Profile); err == nil && found {
 		fmt.Println(val)
 		return 0
 	}
 
 	primaryPath := c.targetConfigPath(configFlags{})
-	if val, found, err := configs.ReadConfigValue(primaryPath, key); err == nil && found {
+	if val, found, err := readProfileValue(primaryPath, key, opts.Profile); err == nil && found {
 		fmt.Println(val)
 		return 0
 	}
 
+	// A profile only holds the keys set in it
+	if opts.Profile != "" {
+		return 1
+	}
+
 	// Fall back to default
 	ck := configs.LookupByDot(key)
 	if ck != nil && ck.DefaultValue != "" {
@@ -229,7 +270,7 @@ func (c *ConfigCmdCommand) runSet(key, value string, opts configFlags) int {
 		return 1
 	}
 
-	if err := configs.WriteConfigValue(path, key, value); err != nil {
+	if err := configs.WriteConfigValue(path, profileConfigKey(key, opts.Profile), value); err != nil {
 		colorlog.PrintError(fmt.Sprintf("Error writing config: %v", err))
 		return 1
 	}
@@ -254,7 +295,7 @@ func (c *ConfigCmdCommand) runUnset(key string, opts configFlags) int {
 
 	path := c.targetConfigPath(opts)
 
-	if err := configs.UnsetConfigValue(path, key); err != nil {
+	if err := configs.UnsetConfigValue(path, profileConfigKey(key, opts.Profile)); err != nil {
 		if os.IsNotExist(err) {
 			return 0 // nothing to unset
 		}
@@ -309,7 +350,12 @@ func (c *ConfigCmdCommand) runList(opts configFlags) int {
 		}
 	}
 
+	merged = configs.ProfileValues(merged, opts.Profile)
 	if len(merged) == 0 {
+		if opts.Profile != "" {
+			colorlog.PrintInfo(fmt.Sprintf("No configuration values set in profile %s", opts.Profile))
+			return 0
+		}
 		colorlog.PrintInfo("No configuration values set")
 		return 0
 	}
@@ -318,6 +364,78 @@ func (c *ConfigCmdCommand) runList(opts configFlags) int {
 	return 0
 }
 
+// runProfiles lists the profiles of the config files, marking the one
+// selected with GHORG_PROFILE.
+func (c *ConfigCmdCommand) runProfiles(opts configFlags) int {
+	var paths []string
+	switch {
+	case opts.Local:
+		paths = []string{localConfigPath()}
+	case opts.Global:
+		paths = []string{configs.DefaultConfFile()}
+	default:
+		paths = []string{c.targetConfigPath(configFlags{}), localConfigPath()}
+	}
+
+	var names []string
+	for _, path := range paths {
+		profiles, err := configs.ListProfiles(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			colorlog.PrintError(fmt.Sprintf("Error reading config: %v", err))
+			return 1
+		}
+		for _, name := range profiles {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		colorlog.PrintInfo("No profiles set, add one with: ghorg config --profile <name> <key> <value>")
+		return 0
+	}
+
+	slices.Sort(names)
+	active := os.Getenv("GHORG_PROFILE")
+	for _, name := range names {
+		if name == active {
+			fmt.Println("* " + name)
+		} else {
+			fmt.Println("  " + name)
+		}
+	}
+	return 0
+}
+
+// profileConfigKey returns the config file key of key in profile, key
+// itself without a profile.
+func profileConfigKey(key, profile string) string {
+	if profile == "" {
+		return key
+	}
+	return configs.ProfileKey(profile, key)
+}
+
+// readProfileValue reads key of profile from the config file at path, like
+// configs.ReadConfigValue without a profile. Legacy GHORG_* keys are read in
+// profiles too.
+func readProfileValue(path, key, profile string) (string, bool, error) {
+	if profile == "" {
+		return configs.ReadConfigValue(path, key)
+	}
+	val, found, err := configs.ReadConfigValue(path, configs.ProfileKey(profile, key))
+	if err != nil || found {
+		return val, found, err
+	}
+	if envVar := configs.DotToEnvVar(key); envVar != "" {
+		return configs.ReadConfigValue(path, configs.ProfileKey(profile, envVar))
+	}
+	return "", false, nil
+}
+
 // targetConfigPath returns the file path to read/write based on --global/--local flags.
 // Without flags, uses GHORG_CONFIG if set, otherwise the global default.
 func (c *ConfigCmdCommand) targetConfigPath(opts configFlags) string {
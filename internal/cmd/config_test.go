@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/configs"
 )
 
 func TestDefaultSettings(t *testing.T) {
@@ -134,3 +137,90 @@ func TestSyncDefaultBranchFlagSetsEnvironment(t *testing.T) {
 		t.Errorf("GHORG_SYNC_DEFAULT_BRANCH should be true when flag is set, got: %v", syncDefaultBranch)
 	}
 }
+
+func TestInitConfigProfiles(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedK, savedConfigEnv := k, configEnv
+	defer func() { k, configEnv = savedK, savedConfigEnv }()
+
+	conf := filepath.Join(t.TempDir(), "conf.yaml")
+	content := "scm:\n  type: github\nclone:\n  protocol: ssh\nprofiles:\n  work:\n    scm:\n      type: gitlab\n    GHORG_CLONE_TYPE: user\n"
+	if err := os.WriteFile(conf, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GHORG_CONFIG", conf)
+
+	InitConfig()
+	if got := os.Getenv("GHORG_SCM_TYPE"); got != "github" {
+		t.Errorf("without a profile GHORG_SCM_TYPE = %q, want github", got)
+	}
+
+	// profile > base, reloading replaces what the first load set
+	os.Setenv("GHORG_PROFILE", "work")
+	InitConfig()
+	if scm, cloneType, protocol := os.Getenv("GHORG_SCM_TYPE"), os.Getenv("GHORG_CLONE_TYPE"), os.Getenv("GHORG_CLONE_PROTOCOL"); scm != "gitlab" || cloneType != "user" || protocol != "ssh" {
+		t.Errorf("with the work profile got scm %q, clone type %q, protocol %q; want gitlab, user, ssh", scm, cloneType, protocol)
+	}
+
+	// env > profile
+	os.Setenv("GHORG_CLONE_TYPE", "org")
+	InitConfig()
+	if got := os.Getenv("GHORG_CLONE_TYPE"); got != "org" {
+		t.Errorf("GHORG_CLONE_TYPE set in the environment was replaced by %q", got)
+	}
+
+	// flag > env > profile
+	os.Unsetenv("GHORG_PROFILE")
+	InitConfig()
+	if _, err := applyCloneFlags(&CloneFlags{Profile: "work", Protocol: "https"}, []string{"group"}); err != nil {
+		t.Fatal(err)
+	}
+	if scm, protocol := os.Getenv("GHORG_SCM_TYPE"), os.Getenv("GHORG_CLONE_PROTOCOL"); scm != "gitlab" || protocol != "https" {
+		t.Errorf("with --profile work --protocol https got scm %q, protocol %q", scm, protocol)
+	}
+
+	os.Setenv("GHORG_PROFILE", "missing")
+	if code := colorlog.CatchExit(func() int { InitConfig(); return 0 }); code != 1 {
+		t.Errorf("a missing profile exited with %d, want 1", code)
+	}
+}
+
+func TestConfigCommandProfiles(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	conf := filepath.Join(t.TempDir(), "conf.yaml")
+	os.Setenv("GHORG_CONFIG", conf)
+	c := &ConfigCmdCommand{}
+
+	if code := c.Run([]string{"scm.type", "github"}); code != 0 {
+		t.Fatalf("set exited with %d", code)
+	}
+	if code := c.Run([]string{"--profile", "work", "scm.type", "gitlab"}); code != 0 {
+		t.Fatalf("set in profile exited with %d", code)
+	}
+	if val, found, _ := configs.ReadConfigValue(conf, "profiles.work.scm.type"); !found || val != "gitlab" {
+		t.Errorf("profiles.work.scm.type = %q, %v", val, found)
+	}
+	if val, _, _ := configs.ReadConfigValue(conf, "scm.type"); val != "github" {
+		t.Errorf("setting the profile changed scm.type to %q", val)
+	}
+
+	if code := c.Run([]string{"--profile", "work", "scm.type"}); code != 0 {
+		t.Errorf("get from profile exited with %d", code)
+	}
+	if code := c.Run([]string{"--profile", "work", "clone.protocol"}); code != 1 {
+		t.Errorf("get of a key not set in the profile exited with %d, want 1", code)
+	}
+	if code := c.Run([]string{"profiles"}); code != 0 {
+		t.Errorf("profiles exited with %d", code)
+	}
+	if code := c.Run([]string{"--profile", "a.b", "scm.type", "x"}); code != 1 {
+		t.Errorf("an invalid profile name exited with %d, want 1", code)
+	}
+
+	if code := c.Run([]string{"--profile", "work", "--unset", "scm.type"}); code != 0 {
+		t.Fatalf("unset in profile exited with %d", code)
+	}
+	if profiles, _ := configs.ListProfiles(conf); len(profiles) != 0 {
+		t.Errorf("profiles after unsetting its only key = %v", profiles)
+	}
+}
@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/cli"
+	"github.com/jessevdk/go-flags"
+	"github.com/korovkin/limiter"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/git"
+)
+
+type ExecCommand struct {
+	UI cli.Ui
+}
+
+type ExecFlags struct {
+	Concurrency string `long:"concurrency" description:"GHORG_CONCURRENCY - Max commands to run at once (default 25)"`
+	Shell       bool   `long:"shell" description:"Run the command through sh -c so pipes, globs and && work"`
+	Collect     bool   `long:"collect" description:"Print each repo's output as one block when its command finishes instead of streaming prefixed lines"`
+	Timeout     string `long:"timeout" description:"Kill the command in a repo after this duration, e.g. 30s or 5m"`
+	LogDir      string `long:"log-dir" description:"Write the output of each repo to <log-dir>/<repo>.log"`
+	JSONFile    string `long:"json-file" description:"Write a JSON file with the exit code, duration and log file of every repo"`
+	LocalFilterFlags
+}
+
+// ExecResult is the outcome of running the command in one repo.
+type ExecResult struct {
+	Name            string  `json:"name"`
+	Path            string  `json:"path"`
+	ExitCode        int     `json:"exit_code"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+	LogFile         string  `json:"log_file,omitempty"`
+}
+
+// ExecReport is the content of the --json-file result file.
+type ExecReport struct {
+	Command   []string     `json:"command"`
+	Dir       string       `json:"dir"`
+	StartedAt time.Time    `json:"started_at"`
+	Total     int          `json:"total"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []ExecResult `json:"results"`
+}
+
+// execOptions controls how runExec runs the command in each repo.
+type execOptions struct {
+	command     []string
+	shell       bool
+	collect     bool
+	timeout     time.Duration
+	logDir      string
+	concurrency int
+	out         io.Writer
+}
+
+func (c *ExecCommand) Help() string {
+	return `Usage: ghorg exec [options] [dir] -- <command> [args...]
+
+Run a command in every repo under a ghorg directory with bounded concurrency.
+If no dir is specified it will run in every repo in GHORG_ABSOLUTE_PATH_TO_CLONE_TO.
+
+By default output is streamed with each line prefixed by the repo name. Use
+--collect to print each repo's output as one block once its command finishes.
+The same regex, prefix, target repos, ghorgonly and ghorgignore selection used
+by ghorg clone can be applied. Exits non-zero when the command fails in any repo.
+
+Options:
+  --concurrency            Max commands to run at once
+  --shell                  Run the command through sh -c
+  --collect                Print output grouped per repo
+  --timeout                Kill the command in a repo after this duration
+  --log-dir                Write each repo's output to <log-dir>/<repo>.log
+  --json-file              Write a JSON result file
+  --match-regex            Only run in repos matching regex
+  --exclude-match-regex    Skip repos matching regex
+  --match-prefix           Only run in repos with matching prefix
+  --exclude-match-prefix   Skip repos with matching prefix
+  --ghorgonly-path         Path to a ghorgonly file
+  --ghorgignore-path       Path to a ghorgignore file
+  --target-repos-path      Path to a file of repo names to run in
+
+Examples:
+  ghorg exec kubernetes -- git log -1 --oneline
+  ghorg exec --match-prefix=api- my-org -- make lint
+  ghorg exec --shell --collect my-org -- 'go mod tidy && git diff --stat'
+  ghorg exec --log-dir /tmp/logs --json-file /tmp/result.json my-org -- go test ./...
+`
+}
+
+func (c *ExecCommand) Synopsis() string {
+	return "Run a command in every cloned repo"
+}
+
+func (c *ExecCommand) Run(args []string) int {
+	flagArgs, command := splitExecArgs(args)
+
+	var opts ExecFlags
+	parser := flags.NewParser(&opts, flags.Default&^flags.PassDoubleDash)
+	remaining, err := parser.ParseArgs(flagArgs)
+	if err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(c.Help())
+			return 0
+		}
+		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
+		return 1
+	}
+
+	// Without "--" everything that is not a flag is the command.
+	var dir string
+	if command == nil {
+		command = remaining
+	} else if len(remaining) > 0 {
+		dir = remaining[0]
+	}
+	if len(command) == 0 {
+		colorlog.PrintError("You must provide a command to run, e.g. ghorg exec my-org -- git status")
+		return 1
+	}
+
+	applyExecFlags(&opts)
+
+	var timeout time.Duration
+	if opts.Timeout != "" {
+		timeout, err = time.ParseDuration(opts.Timeout)
+		if err != nil {
+			colorlog.PrintError(fmt.Sprintf("Invalid --timeout: %v", err))
+			return 1
+		}
+	}
+
+	root, err := resolveGhorgDir(dir)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+
+	repos, err := findLocalRepos(root)
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error reading %s: %v", root, err))
+		return 1
+	}
+	repos = filterLocalRepos(git.NewGit(), repos)
+	if len(repos) == 0 {
+		colorlog.PrintError("No clones found. Please clone some and try again.")
+		return 1
+	}
+
+	startedAt := time.Now().UTC()
+	results := runExec(repos, execOptions{
+		command:     command,
+		shell:       opts.Shell,
+		collect:     opts.Collect,
+		timeout:     timeout,
+		logDir:      opts.LogDir,
+		concurrency: statusConcurrency(),
+		out:         os.Stdout,
+	})
+
+	report := newExecReport(command, root, startedAt, results)
+	printExecSummary(report)
+
+	if opts.JSONFile != "" {
+		if err := writeExecReport(opts.JSONFile, report); err != nil {
+			colorlog.PrintError(fmt.Sprintf("Could not write %s: %v", opts.JSONFile, err))
+			return 1
+		}
+	}
+
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// splitExecArgs splits args at the first "--". The command is nil when no
+// separator is present.
+func splitExecArgs(args []string) (flagArgs, command []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], append([]string{}, args[i+1:]...)
+		}
+	}
+	return args, nil
+}
+
+// applyExecFlags sets the concurrency and filter environment variables.
+func applyExecFlags(opts *ExecFlags) {
+	if opts.Concurrency != "" {
+		os.Setenv("GHORG_CONCURRENCY", opts.Concurrency)
+	}
+	opts.LocalFilterFlags.apply()
+}
+
+// runExec runs the command in every repo and returns the results sorted by name.
+func runExec(repos []localRepo, opts execOptions) []ExecResult {
+	results := make([]ExecResult, len(repos))
+	limit := limiter.NewConcurrencyLimiter(opts.concurrency)
+	outMu := &sync.Mutex{}
+
+	if opts.logDir != "" {
+		if err := os.MkdirAll(opts.logDir, 0o755); err != nil {
+			colorlog.PrintError(fmt.Sprintf("Could not create log dir %s: %v", opts.logDir, err))
+			opts.logDir = ""
+		}
+	}
+
+	for i := range repos {
+		//nolint:errcheck // results are written into results
+		limit.Execute(func() {
+			results[i] = execInRepo(repos[i], opts, outMu)
+		})
+	}
+	limit.WaitAndClose()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+// execInRepo runs the command in a single repo.
+func execInRepo(repo localRepo, opts execOptions, outMu *sync.Mutex) ExecResult {
+	result := ExecResult{Name: repo.Name, Path: repo.Path}
+
+	ctx := context.Background()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if opts.shell {
+		cmd = exec.CommandContext(ctx, "sh", "-c", strings.Join(opts.command, " "))
+	} else {
+		cmd = exec.CommandContext(ctx, opts.command[0], opts.command[1:]...)
+	}
+	cmd.Dir = repo.Path
+	// Don't wait forever on children of a killed command that still hold the output pipe.
+	cmd.WaitDelay = time.Second
+
+	var captured bytes.Buffer
+	var live *prefixWriter
+	writers := []io.Writer{&captured}
+	if !opts.collect {
+		live = &prefixWriter{prefix: "[" + repo.Name + "] ", out: opts.out, mu: outMu}
+		writers = append(writers, live)
+	}
+	output := io.MultiWriter(writers...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	start := time.Now()
+	err := cmd.Run()
+	result.DurationSeconds = time.Since(start).Seconds()
+
+	if live != nil {
+		live.Flush()
+	}
+
+	if err != nil {
+		result.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.Error = err.Error()
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			result.Error = fmt.Sprintf("timed out after %s", opts.timeout)
+		}
+	}
+
+	if opts.collect {
+		outMu.Lock()
+		fmt.Fprintf(opts.out, "==> %s (exit %d)\n", repo.Name, result.ExitCode)
+		opts.out.Write(captured.Bytes())
+		if captured.Len() > 0 && !bytes.HasSuffix(captured.Bytes(), []byte("\n")) {
+			fmt.Fprintln(opts.out)
+		}
+		outMu.Unlock()
+	}
+
+	if opts.logDir != "" {
+		logFile := filepath.Join(opts.logDir, strings.ReplaceAll(repo.Name, "/", "_")+".log")
+		if writeErr := os.WriteFile(logFile, captured.Bytes(), 0o644); writeErr != nil {
+			colorlog.PrintError(fmt.Sprintf("Could not write log for %s: %v", repo.Name, writeErr))
+		} else {
+			result.LogFile = logFile
+		}
+	}
+
+	return result
+}
+
+// prefixWriter writes complete lines to out, each prefixed with prefix.
+// Writes from concurrent repos are serialized by mu so lines never interleave.
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any trailing output that did not end in a newline.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+	w.writeLine(append(w.buf, '\n'))
+	w.buf = nil
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	io.WriteString(w.out, w.prefix)
+	w.out.Write(line)
+}
+
+// newExecReport summarizes results.
+func newExecReport(command []string, dir string, startedAt time.Time, results []ExecResult) ExecReport {
+	report := ExecReport{
+		Command:   command,
+		Dir:       dir,
+		StartedAt: startedAt,
+		Total:     len(results),
+		Results:   results,
+	}
+	for _, r := range results {
+		if r.ExitCode == 0 {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// printExecSummary prints the exit code summary and every failed repo.
+func printExecSummary(report ExecReport) {
+	fmt.Println()
+	for _, r := range report.Results {
+		if r.ExitCode == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("%s exited with %d", r.Name, r.ExitCode)
+		if r.Error != "" {
+			msg += ": " + r.Error
+		}
+		colorlog.PrintError(msg)
+	}
+
+	summary := fmt.Sprintf("Ran in %d repos: %d succeeded, %d failed", report.Total, report.Succeeded, report.Failed)
+	if report.Failed > 0 {
+		colorlog.PrintError(summary)
+	} else {
+		colorlog.PrintSuccess(summary)
+	}
+}
+
+// writeExecReport writes report to path as indented JSON.
+func writeExecReport(path string, report ExecReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
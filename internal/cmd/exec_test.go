@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setupExecFixture creates root/<name>/.git for each name and returns root.
+func setupExecFixture(t *testing.T, names ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Join(root, name, ".git"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSplitExecArgs(t *testing.T) {
+	flagArgs, command := splitExecArgs([]string{"--shell", "my-org", "--", "git", "log", "--oneline"})
+	if strings.Join(flagArgs, " ") != "--shell my-org" {
+		t.Errorf("flagArgs = %v", flagArgs)
+	}
+	if strings.Join(command, " ") != "git log --oneline" {
+		t.Errorf("command = %v", command)
+	}
+
+	flagArgs, command = splitExecArgs([]string{"git", "status"})
+	if command != nil || len(flagArgs) != 2 {
+		t.Errorf("without separator: flagArgs = %v, command = %v", flagArgs, command)
+	}
+}
+
+func TestRunExecPrefixedOutput(t *testing.T) {
+	root := setupExecFixture(t, "api", "web", "broken")
+	if err := os.WriteFile(filepath.Join(root, "broken", "fail"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := findLocalRepos(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	logDir := filepath.Join(t.TempDir(), "logs")
+	results := runExec(repos, execOptions{
+		command:     []string{"if [ -f fail ]; then echo failing; exit 3; fi; echo ok; printf partial"},
+		shell:       true,
+		logDir:      logDir,
+		concurrency: 2,
+		out:         &out,
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	codes := map[string]int{}
+	for _, r := range results {
+		codes[r.Name] = r.ExitCode
+	}
+	if codes["api"] != 0 || codes["web"] != 0 || codes["broken"] != 3 {
+		t.Errorf("exit codes = %v", codes)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for _, want := range []string{"[api] ok", "[api] partial", "[web] ok", "[broken] failing"} {
+		found := false
+		for _, line := range lines {
+			if line == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("output missing line %q:\n%s", want, out.String())
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(logDir, "broken.log"))
+	if err != nil {
+		t.Fatalf("Expected per-repo log: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "failing" {
+		t.Errorf("broken.log = %q", data)
+	}
+}
+
+func TestRunExecCollectAndTimeout(t *testing.T) {
+	root := setupExecFixture(t, "slow")
+	repos, err := findLocalRepos(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	results := runExec(repos, execOptions{
+		command:     []string{"sleep", "5"},
+		collect:     true,
+		timeout:     100 * time.Millisecond,
+		concurrency: 1,
+		out:         &out,
+	})
+
+	if results[0].ExitCode == 0 || !strings.Contains(results[0].Error, "timed out") {
+		t.Errorf("Expected timeout failure, got %+v", results[0])
+	}
+	if !strings.HasPrefix(out.String(), "==> slow (exit") {
+		t.Errorf("Expected collected block header, got %q", out.String())
+	}
+}
+
+func TestExecReportJSON(t *testing.T) {
+	results := []ExecResult{
+		{Name: "a", ExitCode: 0},
+		{Name: "b", ExitCode: 2},
+	}
+	report := newExecReport([]string{"make", "lint"}, "/ghorg/org", time.Now(), results)
+	if report.Succeeded != 1 || report.Failed != 1 || report.Total != 2 {
+		t.Errorf("report counts = %+v", report)
+	}
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	if err := writeExecReport(path, report); err != nil {
+		t.Fatalf("writeExecReport failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ExecReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded.Results[1].ExitCode != 2 || decoded.Command[0] != "make" {
+		t.Errorf("decoded = %+v", decoded)
+	}
+}
+
+func TestFilterLocalRepos(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	root := setupExecFixture(t, "api-one", "api-two", "web")
+	os.Setenv("GHORG_MATCH_PREFIX", "api-")
+	os.Setenv("GHORG_EXCLUDE_MATCH_REGEX", "two")
+	os.Setenv("GHORG_IGNORE_PATH", filepath.Join(root, "missing-ghorgignore"))
+	os.Setenv("GHORG_ONLY_PATH", filepath.Join(root, "missing-ghorgonly"))
+
+	repos, err := findLocalRepos(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filtered := filterLocalRepos(NewMockGit(), repos)
+	if len(filtered) != 1 || filtered[0].Name != "api-one" {
+		t.Errorf("filtered = %+v, want [api-one]", filtered)
+	}
+}
+
+func TestPrefixWriterSerializesLines(t *testing.T) {
+	var out bytes.Buffer
+	mu := &sync.Mutex{}
+	w := &prefixWriter{prefix: "[r] ", out: &out, mu: mu}
+
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\nthree"))
+	w.Flush()
+
+	if out.String() != "[r] one\n[r] two\n[r] three\n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
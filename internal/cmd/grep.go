@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/cli"
+	"github.com/jessevdk/go-flags"
+	"github.com/korovkin/limiter"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/git"
+)
+
+// binarySniffLen is how much of a file is checked for NUL bytes, the same
+// heuristic git uses to decide a file is binary.
+const binarySniffLen = 8000
+
+type GrepCommand struct {
+	UI cli.Ui
+}
+
+type GrepFlags struct {
+	IgnoreCase       bool   `short:"i" long:"ignore-case" description:"Match case insensitively"`
+	FixedStrings     bool   `short:"F" long:"fixed-strings" description:"Treat the pattern as a literal string instead of a regex"`
+	FilesWithMatches bool   `short:"l" long:"files-with-matches" description:"Only print the paths of files that contain a match"`
+	JSON             bool   `long:"json" description:"Print matches grouped by repo as JSON"`
+	Concurrency      string `long:"concurrency" description:"GHORG_CONCURRENCY - Max repos to search at once (default 25)"`
+	LocalFilterFlags
+}
+
+// GrepMatch is a single matching line.
+type GrepMatch struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+}
+
+// GrepResult holds the matches found in one repo.
+type GrepResult struct {
+	Name    string      `json:"name"`
+	Path    string      `json:"path"`
+	Matches []GrepMatch `json:"matches"`
+	Error   string      `json:"error,omitempty"`
+}
+
+func (c *GrepCommand) Help() string {
+	return `Usage: ghorg grep [options] <pattern> [dir]
+
+Search every repo under a ghorg directory for a regex pattern.
+If no dir is specified it will search every repo in GHORG_ABSOLUTE_PATH_TO_CLONE_TO.
+
+Only files tracked in each repo's git index are searched, files matched by
+.gitignore and binary files are skipped. Repos are searched in parallel and
+results are grouped by repo as path:line:text, where path is relative to the
+current directory. The same regex, prefix, target repos, ghorgonly and
+ghorgignore selection used by ghorg clone can be applied.
+
+Options:
+  -i, --ignore-case          Match case insensitively
+  -F, --fixed-strings        Treat the pattern as a literal string
+  -l, --files-with-matches   Only print the paths of matching files
+  --json                     Print matches as JSON
+  --concurrency              Max repos to search at once
+  --match-regex              Only search repos matching regex
+  --exclude-match-regex      Skip repos matching regex
+  --match-prefix             Only search repos with matching prefix
+  --exclude-match-prefix     Skip repos with matching prefix
+  --ghorgonly-path           Path to a ghorgonly file
+  --ghorgignore-path         Path to a ghorgignore file
+  --target-repos-path        Path to a file of repo names to search
+
+Examples:
+  ghorg grep 'TODO\(.*\)' kubernetes
+  ghorg grep -i -l deprecated my-org
+  ghorg grep --match-prefix=api- --json 'os\.Getenv' my-org
+`
+}
+
+func (c *GrepCommand) Synopsis() string {
+	return "Search tracked files across cloned repos"
+}
+
+func (c *GrepCommand) Run(args []string) int {
+	var opts GrepFlags
+	parser := flags.NewParser(&opts, flags.Default)
+	remaining, err := parser.ParseArgs(args)
+	if err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(c.Help())
+			return 0
+		}
+		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
+		return 1
+	}
+
+	if len(remaining) == 0 {
+		colorlog.PrintError("You must provide a pattern to search for, e.g. ghorg grep TODO my-org")
+		return 1
+	}
+
+	re, err := compileGrepPattern(remaining[0], opts.IgnoreCase, opts.FixedStrings)
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Invalid pattern: %v", err))
+		return 1
+	}
+
+	if opts.Concurrency != "" {
+		os.Setenv("GHORG_CONCURRENCY", opts.Concurrency)
+	}
+	opts.LocalFilterFlags.apply()
+
+	var dir string
+	if len(remaining) > 1 {
+		dir = remaining[1]
+	}
+	root, err := resolveGhorgDir(dir)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+
+	repos, err := findLocalRepos(root)
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error reading %s: %v", root, err))
+		return 1
+	}
+	repos = filterLocalRepos(git.NewGit(), repos)
+	if len(repos) == 0 {
+		colorlog.PrintError("No clones found. Please clone some and try again.")
+		return 1
+	}
+
+	results := grepRepos(repos, re, statusConcurrency())
+
+	if opts.JSON {
+		if err := writeGrepJSON(os.Stdout, results); err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+	} else {
+		cwd, _ := os.Getwd()
+		writeGrepText(os.Stdout, results, cwd, opts.FilesWithMatches)
+	}
+
+	for _, r := range results {
+		if len(r.Matches) > 0 {
+			return 0
+		}
+	}
+	return 1
+}
+
+// compileGrepPattern builds the regex used to match lines.
+func compileGrepPattern(pattern string, ignoreCase, fixed bool) (*regexp.Regexp, error) {
+	if fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// grepRepos searches every repo in parallel and returns the results in the
+// same order as repos.
+func grepRepos(repos []localRepo, re *regexp.Regexp, concurrency int) []GrepResult {
+	results := make([]GrepResult, len(repos))
+	limit := limiter.NewConcurrencyLimiter(concurrency)
+
+	for i := range repos {
+		//nolint:errcheck // results are written into results
+		limit.Execute(func() {
+			results[i] = grepRepo(repos[i], re)
+		})
+	}
+	limit.WaitAndClose()
+
+	return results
+}
+
+// grepRepo searches the tracked files of a single repo.
+func grepRepo(repo localRepo, re *regexp.Regexp) GrepResult {
+	result := GrepResult{Name: repo.Name, Path: repo.Path, Matches: []GrepMatch{}}
+
+	files, err := git.TrackedFiles(repo.Path)
+	if err != nil {
+		result.Error = fmt.Sprintf("could not read index: %v", err)
+		return result
+	}
+
+	for _, file := range files {
+		matches, err := grepFile(filepath.Join(repo.Path, filepath.FromSlash(file)), re)
+		if err != nil {
+			// Tracked files deleted from the work tree are not an error.
+			continue
+		}
+		for _, m := range matches {
+			m.File = file
+			result.Matches = append(result.Matches, m)
+		}
+	}
+	return result
+}
+
+// grepFile returns the matching lines of a single file. Binary files return
+// no matches.
+func grepFile(path string, re *regexp.Regexp) ([]GrepMatch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(data) {
+		return nil, nil
+	}
+
+	var matches []GrepMatch
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Bytes()
+		loc := re.FindIndex(text)
+		if loc == nil {
+			continue
+		}
+		matches = append(matches, GrepMatch{
+			Line:   line,
+			Column: loc[0] + 1,
+			Text:   strings.TrimRight(string(text), "\r"),
+		})
+	}
+	return matches, scanner.Err()
+}
+
+// isBinary reports whether data looks like a binary file.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0
+}
+
+// writeGrepJSON writes the repos that have matches or errors as an indented
+// JSON array.
+func writeGrepJSON(w io.Writer, results []GrepResult) error {
+	shown := make([]GrepResult, 0, len(results))
+	for _, r := range results {
+		if len(r.Matches) > 0 || r.Error != "" {
+			shown = append(shown, r)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(shown)
+}
+
+// writeGrepText writes the matches grouped by repo. Paths are made relative to
+// cwd when possible so terminals and editors can open path:line directly.
+func writeGrepText(w io.Writer, results []GrepResult, cwd string, filesOnly bool) {
+	first := true
+	for _, r := range results {
+		if r.Error != "" {
+			colorlog.PrintError(fmt.Sprintf("%s: %s", r.Name, r.Error))
+			continue
+		}
+		if len(r.Matches) == 0 {
+			continue
+		}
+
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		fmt.Fprintln(w, r.Name)
+
+		lastFile := ""
+		for _, m := range r.Matches {
+			path := displayPath(filepath.Join(r.Path, filepath.FromSlash(m.File)), cwd)
+			if filesOnly {
+				if m.File != lastFile {
+					fmt.Fprintln(w, path)
+				}
+				lastFile = m.File
+				continue
+			}
+			fmt.Fprintf(w, "%s:%d:%s\n", path, m.Line, m.Text)
+		}
+	}
+}
+
+// displayPath returns path relative to cwd, or path itself when it is not
+// below cwd.
+func displayPath(path, cwd string) string {
+	if cwd == "" {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
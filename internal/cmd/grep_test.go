@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupGrepFixture creates a ghorg directory with two committed repos. Each
+// contains a tracked file with a match, plus an untracked file, a binary file
+// and a force-added ignored file that must not be searched.
+func setupGrepFixture(t *testing.T) string {
+	t.Helper()
+	org := filepath.Join(t.TempDir(), "org")
+
+	for _, name := range []string{"api", "web"} {
+		dir := filepath.Join(org, name)
+		if err := os.MkdirAll(filepath.Join(dir, "src"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		runTestGit(t, dir, "init", "-b", "main")
+
+		files := map[string]string{
+			".gitignore":   "*.log\n",
+			"src/main.go":  "package main\n\n// TODO: " + name + " cleanup\n",
+			"binary.dat":   "TODO\x00binary",
+			"ignored.log":  "TODO: ignored\n",
+			"untracked.md": "TODO: untracked\n",
+		}
+		for file, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		runTestGit(t, dir, "add", ".gitignore", "src/main.go", "binary.dat")
+		runTestGit(t, dir, "add", "-f", "ignored.log")
+		runTestGit(t, dir, "commit", "-m", "initial")
+	}
+	return org
+}
+
+func TestGrepRepos(t *testing.T) {
+	org := setupGrepFixture(t)
+	repos, err := findLocalRepos(org)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re, err := compileGrepPattern("todo", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := grepRepos(repos, re, 2)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			t.Fatalf("%s: unexpected error %s", r.Name, r.Error)
+		}
+		if len(r.Matches) != 1 {
+			t.Fatalf("%s: expected only the tracked source match, got %+v", r.Name, r.Matches)
+		}
+		m := r.Matches[0]
+		if m.File != "src/main.go" || m.Line != 3 || m.Column != 4 {
+			t.Errorf("%s: match = %+v, want src/main.go:3 column 4", r.Name, m)
+		}
+	}
+}
+
+func TestCompileGrepPattern(t *testing.T) {
+	re, err := compileGrepPattern("a.b", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.MatchString("axb") || !re.MatchString("a.b") {
+		t.Error("fixed string pattern should match literally")
+	}
+
+	if _, err := compileGrepPattern("(", false, false); err == nil {
+		t.Error("Expected error for invalid regex")
+	}
+}
+
+func TestWriteGrepOutput(t *testing.T) {
+	results := []GrepResult{
+		{Name: "org/api", Path: "/ghorg/org/api", Matches: []GrepMatch{
+			{File: "main.go", Line: 3, Text: "// TODO one"},
+			{File: "main.go", Line: 9, Text: "// TODO two"},
+		}},
+		{Name: "org/empty", Path: "/ghorg/org/empty", Matches: []GrepMatch{}},
+	}
+
+	var text bytes.Buffer
+	writeGrepText(&text, results, "/ghorg", false)
+	want := "org/api\norg/api/main.go:3:// TODO one\norg/api/main.go:9:// TODO two\n"
+	if text.String() != want {
+		t.Errorf("text output = %q, want %q", text.String(), want)
+	}
+
+	var files bytes.Buffer
+	writeGrepText(&files, results, "/elsewhere", true)
+	if files.String() != "org/api\n/ghorg/org/api/main.go\n" {
+		t.Errorf("files output = %q", files.String())
+	}
+
+	var js bytes.Buffer
+	if err := writeGrepJSON(&js, results); err != nil {
+		t.Fatalf("writeGrepJSON failed: %v", err)
+	}
+	var decoded []GrepResult
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Matches[1].Line != 9 {
+		t.Errorf("decoded = %+v, want only org/api", decoded)
+	}
+}
+
+func TestGrepCommand_Help(t *testing.T) {
+	cmd := &GrepCommand{}
+	help := cmd.Help()
+	for _, flag := range []string{"--ignore-case", "--json", "--match-prefix"} {
+		if !strings.Contains(help, flag) {
+			t.Errorf("Help should mention %s", flag)
+		}
+	}
+}
@@ -0,0 +1,509 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go SQLite driver, registered as "sqlite"
+)
+
+// HistoryDBFileName is the SQLite history store written next to
+// _ghorg_stats.csv when GHORG_STATS_HISTORY is enabled.
+const HistoryDBFileName = "_ghorg_history.db"
+
+// historyTimeFormat is how timestamps are stored. Fixed width UTC so that
+// string comparison in SQL orders them correctly.
+const historyTimeFormat = "2006-01-02T15:04:05Z"
+
+// historyMigrations are applied in order, each in its own transaction. The
+// schema version is the number of applied migrations. Never edit a migration
+// that has been released, append a new one instead.
+var historyMigrations = []string{
+	// 1: runs and per-repo outcomes.
+	`CREATE TABLE runs (
+		id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+		recorded_at        TEXT NOT NULL,
+		clone_path         TEXT NOT NULL DEFAULT '',
+		scm                TEXT NOT NULL DEFAULT '',
+		clone_type         TEXT NOT NULL DEFAULT '',
+		target             TEXT NOT NULL DEFAULT '',
+		total_count        INTEGER NOT NULL DEFAULT 0,
+		new_clones_count   INTEGER NOT NULL DEFAULT 0,
+		pulled_count       INTEGER NOT NULL DEFAULT 0,
+		dir_size_mb        REAL NOT NULL DEFAULT 0,
+		new_commits        INTEGER NOT NULL DEFAULT 0,
+		synced_count       INTEGER NOT NULL DEFAULT 0,
+		infos_count        INTEGER NOT NULL DEFAULT 0,
+		errors_count       INTEGER NOT NULL DEFAULT 0,
+		update_remote_count INTEGER NOT NULL DEFAULT 0,
+		prune_count        INTEGER NOT NULL DEFAULT 0,
+		has_collisions     INTEGER NOT NULL DEFAULT 0,
+		ghorgignore        INTEGER NOT NULL DEFAULT 0,
+		ghorgonly          INTEGER NOT NULL DEFAULT 0,
+		duration_seconds   INTEGER NOT NULL DEFAULT 0,
+		ghorg_version      TEXT NOT NULL DEFAULT ''
+	);
+	CREATE UNIQUE INDEX runs_identity ON runs (recorded_at, target, clone_path);
+	CREATE TABLE repo_outcomes (
+		id               INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id           INTEGER REFERENCES runs (id) ON DELETE CASCADE,
+		recorded_at      TEXT NOT NULL,
+		name             TEXT NOT NULL DEFAULT '',
+		url              TEXT NOT NULL DEFAULT '',
+		path             TEXT NOT NULL DEFAULT '',
+		branch           TEXT NOT NULL DEFAULT '',
+		outcome          TEXT NOT NULL,
+		duration_seconds REAL NOT NULL DEFAULT 0,
+		new_commits      INTEGER NOT NULL DEFAULT 0,
+		sha_before       TEXT NOT NULL DEFAULT '',
+		sha_after        TEXT NOT NULL DEFAULT '',
+		message          TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX repo_outcomes_run ON repo_outcomes (run_id);
+	CREATE UNIQUE INDEX repo_outcomes_identity ON repo_outcomes (url, recorded_at);`,
+}
+
+// HistoryStore is the SQLite backed history of clone runs and the outcome of
+// every repo in them. It replaces reading _ghorg_stats.csv, whose schema
+// changes split history across files.
+type HistoryStore struct {
+	db *sql.DB
+}
+
+// OpenHistory opens or creates the store at path and applies any pending
+// migrations.
+func OpenHistory(path string) (*HistoryStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("open history %s: %w", path, err)
+	}
+	// SQLite allows a single writer, serialize access instead of failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	h := &HistoryStore{db: db}
+	if err := h.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate history %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// Close closes the underlying database.
+func (h *HistoryStore) Close() error {
+	return h.db.Close()
+}
+
+// SchemaVersion returns the number of applied migrations.
+func (h *HistoryStore) SchemaVersion() (int, error) {
+	var version int
+	err := h.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+func (h *HistoryStore) migrate() error {
+	if _, err := h.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	current, err := h.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > len(historyMigrations) {
+		return fmt.Errorf("schema version %d is newer than this ghorg supports (%d)", current, len(historyMigrations))
+	}
+
+	for i := current; i < len(historyMigrations); i++ {
+		tx, err := h.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(historyMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, i+1, time.Now().UTC().Format(historyTimeFormat)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordRun stores run together with the outcome of every repo in it. A run
+// with the same time, target and clone path is only stored once, so
+// importing a stats CSV more than once is safe. It reports whether the run
+// was new.
+func (h *HistoryStore) RecordRun(run statsRun, outcomes []RepoEvent) (bool, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT OR IGNORE INTO runs (
+		recorded_at, clone_path, scm, clone_type, target, total_count, new_clones_count, pulled_count,
+		dir_size_mb, new_commits, synced_count, infos_count, errors_count, update_remote_count, prune_count,
+		has_collisions, ghorgignore, ghorgonly, duration_seconds, ghorg_version
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.Time.UTC().Format(historyTimeFormat), run.ClonePath, run.SCM, run.CloneType, run.Target,
+		run.Total, run.NewClones, run.Pulled, run.DirSizeMB, run.NewCommits, run.Synced, run.Infos,
+		run.Errors, run.UpdateRemote, run.Prune, run.HasCollisions, run.GhorgIgnore, run.GhorgOnly,
+		run.DurationSeconds, run.Version)
+	if err != nil {
+		return false, fmt.Errorf("insert run: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+
+	for _, ev := range outcomes {
+		if err := insertRepoOutcome(tx, &runID, ev); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// ImportStatsCSV records every run of a stats CSV file, including files
+// written with an older header. It returns the number of new runs.
+func (h *HistoryStore) ImportStatsCSV(path string) (int, error) {
+	runs, err := readStatsRuns(path, "")
+	if err != nil {
+		return 0, err
+	}
+	imported := 0
+	for _, run := range runs {
+		added, err := h.RecordRun(run, nil)
+		if err != nil {
+			return imported, err
+		}
+		if added {
+			imported++
+		}
+	}
+	return imported, nil
+}
+
+// ImportState records the last known outcome of every repo in a state
+// manifest. These outcomes do not belong to a run. It returns the number of
+// new outcomes.
+func (h *HistoryStore) ImportState(m *StateManifest) (int, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := countRows(tx, "repo_outcomes")
+	if err != nil {
+		return 0, err
+	}
+	for url, r := range m.Repos {
+		outcome := RepoEventPulled
+		switch r.LastStatus {
+		case StateStatusError:
+			outcome = RepoEventError
+		case StateStatusSkipped:
+			outcome = RepoEventSkipped
+		}
+		ev := RepoEvent{
+			Event:           outcome,
+			Time:            r.LastSeenAt,
+			Name:            r.Name,
+			URL:             url,
+			Path:            r.HostPath,
+			Branch:          r.LastBranch,
+			DurationSeconds: r.LastDurationSeconds,
+			SHAAfter:        r.LastSHA,
+			Message:         r.LastError,
+		}
+		if err := insertRepoOutcome(tx, nil, ev); err != nil {
+			return 0, err
+		}
+	}
+	after, err := countRows(tx, "repo_outcomes")
+	if err != nil {
+		return 0, err
+	}
+	return after - before, tx.Commit()
+}
+
+func insertRepoOutcome(tx *sql.Tx, runID *int64, ev RepoEvent) error {
+	recordedAt := ev.Time
+	if recordedAt.IsZero() {
+		recordedAt = time.Now()
+	}
+	_, err := tx.Exec(`INSERT OR IGNORE INTO repo_outcomes (
+		run_id, recorded_at, name, url, path, branch, outcome, duration_seconds, new_commits, sha_before, sha_after, message
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		runID, recordedAt.UTC().Format(historyTimeFormat), ev.Name, ev.URL, ev.Path, ev.Branch, ev.Event,
+		ev.DurationSeconds, ev.NewCommits, ev.SHABefore, ev.SHAAfter, ev.Message)
+	if err != nil {
+		return fmt.Errorf("insert outcome of %s: %w", ev.URL, err)
+	}
+	return nil
+}
+
+func countRows(tx *sql.Tx, table string) (int, error) {
+	var n int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n)
+	return n, err
+}
+
+// HistoryQuery selects runs. Zero values do not filter. Until is exclusive.
+// A Limit of 0 returns every run after Offset.
+type HistoryQuery struct {
+	Since  time.Time
+	Until  time.Time
+	Target string
+	Limit  int
+	Offset int
+}
+
+// parseHistoryQuery reads since, until, target, limit and offset from URL
+// query parameters. Dates are YYYY-MM-DD or RFC 3339; a date-only until
+// includes that whole day.
+func parseHistoryQuery(values url.Values) (HistoryQuery, error) {
+	var q HistoryQuery
+	var err error
+
+	parse := func(name string, endOfDay bool) (time.Time, error) {
+		v := values.Get(name)
+		if v == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s %q, use YYYY-MM-DD or RFC 3339", name, v)
+		}
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if q.Since, err = parse("since", false); err != nil {
+		return q, err
+	}
+	if q.Until, err = parse("until", true); err != nil {
+		return q, err
+	}
+
+	for name, dst := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset} {
+		v := values.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("invalid %s %q, must be a non-negative integer", name, v)
+		}
+		*dst = n
+	}
+	q.Target = values.Get("target")
+	return q, nil
+}
+
+// Runs returns the runs matching q, oldest first, and the number of runs
+// matching q without Limit and Offset.
+func (h *HistoryStore) Runs(q HistoryQuery) ([]statsRun, int, error) {
+	var where []string
+	var args []any
+	if !q.Since.IsZero() {
+		where = append(where, "recorded_at >= ?")
+		args = append(args, q.Since.UTC().Format(historyTimeFormat))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "recorded_at < ?")
+		args = append(args, q.Until.UTC().Format(historyTimeFormat))
+	}
+	if q.Target != "" {
+		where = append(where, "target = ? COLLATE NOCASE")
+		args = append(args, q.Target)
+	}
+	clause := ""
+	if len(where) > 0 {
+		clause = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM runs`+clause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = -1
+	}
+	rows, err := h.db.Query(`SELECT
+		recorded_at, clone_path, scm, clone_type, target, total_count, new_clones_count, pulled_count,
+		dir_size_mb, new_commits, synced_count, infos_count, errors_count, update_remote_count, prune_count,
+		has_collisions, ghorgignore, ghorgonly, duration_seconds, ghorg_version
+		FROM runs`+clause+` ORDER BY recorded_at, id LIMIT ? OFFSET ?`, append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var runs []statsRun
+	for rows.Next() {
+		var r statsRun
+		var recordedAt string
+		if err := rows.Scan(&recordedAt, &r.ClonePath, &r.SCM, &r.CloneType, &r.Target, &r.Total, &r.NewClones,
+			&r.Pulled, &r.DirSizeMB, &r.NewCommits, &r.Synced, &r.Infos, &r.Errors, &r.UpdateRemote, &r.Prune,
+			&r.HasCollisions, &r.GhorgIgnore, &r.GhorgOnly, &r.DurationSeconds, &r.Version); err != nil {
+			return nil, 0, err
+		}
+		t, err := time.Parse(historyTimeFormat, recordedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid recorded_at %q: %w", recordedAt, err)
+		}
+		r.Time = t.Local()
+		runs = append(runs, r)
+	}
+	return runs, total, rows.Err()
+}
+
+// filterStatsRuns applies q to runs read from a stats CSV, mirroring
+// HistoryStore.Runs.
+func filterStatsRuns(runs []statsRun, q HistoryQuery) ([]statsRun, int) {
+	var matched []statsRun
+	for _, r := range runs {
+		if !q.Since.IsZero() && r.Time.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !r.Time.Before(q.Until) {
+			continue
+		}
+		if q.Target != "" && !strings.EqualFold(r.Target, q.Target) {
+			continue
+		}
+		matched = append(matched, r)
+	}
+	total := len(matched)
+	if q.Offset >= len(matched) {
+		return nil, total
+	}
+	matched = matched[q.Offset:]
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return matched, total
+}
+
+// csvRecord returns the run keyed by the _ghorg_stats.csv header, formatted
+// the way writeGhorgStats writes it.
+func (r statsRun) csvRecord() map[string]string {
+	return map[string]string{
+		"datetime":                     r.Time.Format("2006-01-02 15:04:05"),
+		"clonePath":                    r.ClonePath,
+		"scm":                          r.SCM,
+		"cloneType":                    r.CloneType,
+		"cloneTarget":                  r.Target,
+		"totalCount":                   strconv.Itoa(r.Total),
+		"newClonesCount":               strconv.Itoa(r.NewClones),
+		"existingResourcesPulledCount": strconv.Itoa(r.Pulled),
+		"dirSizeInMB":                  fmt.Sprintf("%.2f", r.DirSizeMB),
+		"newCommits":                   strconv.Itoa(r.NewCommits),
+		"syncedCount":                  strconv.Itoa(r.Synced),
+		"cloneInfosCount":              strconv.Itoa(r.Infos),
+		"cloneErrorsCount":             strconv.Itoa(r.Errors),
+		"updateRemoteCount":            strconv.Itoa(r.UpdateRemote),
+		"pruneCount":                   strconv.Itoa(r.Prune),
+		"hasCollisions":                strconv.FormatBool(r.HasCollisions),
+		"ghorgignore":                  strconv.FormatBool(r.GhorgIgnore),
+		"ghorgonly":                    strconv.FormatBool(r.GhorgOnly),
+		"totalDurationSeconds":         strconv.Itoa(r.DurationSeconds),
+		"ghorgVersion":                 r.Version,
+	}
+}
+
+// getGhorgHistoryDBPath returns GHORG_STATS_HISTORY_PATH, or the history
+// store next to _ghorg_stats.csv.
+func getGhorgHistoryDBPath() string {
+	if p := os.Getenv("GHORG_STATS_HISTORY_PATH"); p != "" {
+		return p
+	}
+	return filepath.Join(filepath.Dir(getGhorgStatsFilePath()), HistoryDBFileName)
+}
+
+// statsCSVFiles returns _ghorg_stats.csv and every
+// ghorg_stats_new_header_<hash>.csv written after a header change.
+func statsCSVFiles() []string {
+	files := []string{getGhorgStatsFilePath()}
+	seen := map[string]bool{files[0]: true}
+	for _, dir := range []string{filepath.Dir(files[0]), os.Getenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO")} {
+		if dir == "" {
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(dir, "ghorg_stats_new_header_*.csv"))
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	return files
+}
+
+// recordHistory adds a finished clone run to the history store. Failures are
+// reported but never fail the clone.
+func recordHistory(run statsRun, outcomes []RepoEvent) error {
+	h, err := OpenHistory(getGhorgHistoryDBPath())
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	_, err = h.RecordRun(run, outcomes)
+	return err
+}
+
+// errNoStats is returned when neither the history store nor the stats CSV
+// exist yet.
+var errNoStats = errors.New("no stats recorded yet")
+
+// queryStats returns runs matching q from the history store at historyDB
+// when it is set and exists, falling back to the stats CSV at statsFile.
+func queryStats(q HistoryQuery, historyDB, statsFile string) ([]statsRun, int, error) {
+	if historyDB != "" {
+		if _, err := os.Stat(historyDB); err == nil {
+			h, err := OpenHistory(historyDB)
+			if err != nil {
+				return nil, 0, err
+			}
+			defer h.Close()
+			return h.Runs(q)
+		}
+	}
+
+	runs, err := readStatsRuns(statsFile, "")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, errNoStats
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	matched, total := filterStatsRuns(runs, q)
+	return matched, total, nil
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/hashicorp/cli"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+)
+
+type HistoryCommand struct {
+	UI cli.Ui
+}
+
+type HistoryImportFlags struct {
+	DB        string   `long:"db" description:"GHORG_STATS_HISTORY_PATH - Path of the history database"`
+	StatsFile []string `long:"stats-file" description:"Stats CSV to import, may be repeated (default _ghorg_stats.csv and ghorg_stats_new_header_*.csv)"`
+	StateFile string   `long:"state-file" description:"State file to import (default _ghorg_state.json)"`
+}
+
+type HistoryRunsFlags struct {
+	DB     string `long:"db" description:"GHORG_STATS_HISTORY_PATH - Path of the history database"`
+	Since  string `long:"since" description:"Only runs at or after this date (YYYY-MM-DD or RFC 3339)"`
+	Until  string `long:"until" description:"Only runs before the end of this date (YYYY-MM-DD or RFC 3339)"`
+	Target string `long:"target" description:"Only runs of this clone target"`
+	Limit  int    `long:"limit" default:"20" description:"Maximum number of runs to print, 0 for all"`
+	Offset int    `long:"offset" description:"Number of matching runs to skip"`
+	JSON   bool   `long:"json" description:"Print runs as JSON, keyed like _ghorg_stats.csv"`
+}
+
+func (c *HistoryCommand) Help() string {
+	return `Usage: ghorg history <subcommand> [options]
+
+Manage the SQLite history of clone runs recorded with ghorg clone
+--stats-history (GHORG_STATS_HISTORY). The database is _ghorg_history.db next
+to _ghorg_stats.csv unless GHORG_STATS_HISTORY_PATH is set.
+
+Subcommands:
+  import    Import existing stats CSVs and the state file. Safe to run more
+            than once, runs already in the history are skipped.
+  runs      List recorded runs, oldest first.
+
+Import options:
+  --db            Path of the history database
+  --stats-file    Stats CSV to import, may be repeated
+  --state-file    State file to import
+
+Runs options:
+  --db            Path of the history database
+  --since         Only runs at or after this date (YYYY-MM-DD or RFC 3339)
+  --until         Only runs up to and including this date
+  --target        Only runs of this clone target
+  --limit         Maximum number of runs to print (default 20, 0 for all)
+  --offset        Number of matching runs to skip
+  --json          Print runs as JSON
+
+Examples:
+  ghorg history import
+  ghorg history runs --since 2026-01-01 --target kubernetes
+  ghorg history runs --limit 0 --json | jq '.[].totalDurationSeconds'
+`
+}
+
+func (c *HistoryCommand) Synopsis() string {
+	return "Import and query the SQLite history of clone runs"
+}
+
+func (c *HistoryCommand) Run(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	switch args[0] {
+	case "import":
+		return c.runImport(args[1:])
+	case "runs":
+		return c.runRuns(args[1:])
+	case "-h", "--help", "help":
+		fmt.Println(c.Help())
+		return 0
+	default:
+		colorlog.PrintError(fmt.Sprintf("Unknown history subcommand: %s", args[0]))
+		return 1
+	}
+}
+
+// parseHistoryFlags parses args into opts, returning an exit code when the
+// command should stop.
+func (c *HistoryCommand) parseHistoryFlags(opts any, args []string) (int, bool) {
+	parser := flags.NewParser(opts, flags.Default)
+	if _, err := parser.ParseArgs(args); err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(c.Help())
+			return 0, true
+		}
+		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
+		return 1, true
+	}
+	return 0, false
+}
+
+func (c *HistoryCommand) runImport(args []string) int {
+	var opts HistoryImportFlags
+	if code, stop := c.parseHistoryFlags(&opts, args); stop {
+		return code
+	}
+	if opts.DB != "" {
+		os.Setenv("GHORG_STATS_HISTORY_PATH", opts.DB)
+	}
+
+	h, err := OpenHistory(getGhorgHistoryDBPath())
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	defer h.Close()
+
+	statsFiles := opts.StatsFile
+	if len(statsFiles) == 0 {
+		statsFiles = statsCSVFiles()
+	}
+	for _, path := range statsFiles {
+		n, err := h.ImportStatsCSV(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			colorlog.PrintError(fmt.Sprintf("Error importing %s: %v", path, err))
+			return 1
+		}
+		colorlog.PrintSuccess(fmt.Sprintf("Imported %d new runs from %s", n, path))
+	}
+
+	statePath := opts.StateFile
+	if statePath == "" {
+		statePath = getGhorgStateFilePath()
+	}
+	if _, err := os.Stat(statePath); err == nil {
+		state, err := LoadState(statePath, "", "")
+		if err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+		n, err := h.ImportState(state)
+		if err != nil {
+			colorlog.PrintError(fmt.Sprintf("Error importing %s: %v", statePath, err))
+			return 1
+		}
+		colorlog.PrintSuccess(fmt.Sprintf("Imported %d new repo outcomes from %s", n, statePath))
+	}
+	return 0
+}
+
+func (c *HistoryCommand) runRuns(args []string) int {
+	var opts HistoryRunsFlags
+	if code, stop := c.parseHistoryFlags(&opts, args); stop {
+		return code
+	}
+	if opts.DB != "" {
+		os.Setenv("GHORG_STATS_HISTORY_PATH", opts.DB)
+	}
+
+	q, err := parseHistoryQuery(url.Values{
+		"since":  {opts.Since},
+		"until":  {opts.Until},
+		"target": {opts.Target},
+		"limit":  {strconv.Itoa(opts.Limit)},
+		"offset": {strconv.Itoa(opts.Offset)},
+	})
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+
+	path := getGhorgHistoryDBPath()
+	if _, err := os.Stat(path); err != nil {
+		colorlog.PrintError(fmt.Sprintf("No history found at %s, run ghorg clone --stats-history or ghorg history import first", path))
+		return 1
+	}
+	h, err := OpenHistory(path)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	defer h.Close()
+
+	runs, total, err := h.Runs(q)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+
+	if opts.JSON {
+		records := make([]map[string]string, 0, len(runs))
+		for _, r := range runs {
+			records = append(records, r.csvRecord())
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(records); err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSCM\tTARGET\tREPOS\tNEW\tPULLED\tCOMMITS\tERRORS\tDURATION")
+	for _, r := range runs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%ds\n",
+			r.Time.Format("2006-01-02 15:04:05"), r.SCM, r.Target, r.Total, r.NewClones, r.Pulled, r.NewCommits, r.Errors, r.DurationSeconds)
+	}
+	tw.Flush()
+	if len(runs) < total {
+		colorlog.PrintSubtleInfo(fmt.Sprintf("Showing %d of %d runs, use --limit and --offset to page", len(runs), total))
+	}
+	return 0
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+func openTestHistory(t *testing.T) *HistoryStore {
+	t.Helper()
+	h, err := OpenHistory(filepath.Join(t.TempDir(), HistoryDBFileName))
+	if err != nil {
+		t.Fatalf("OpenHistory failed: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestOpenHistoryMigrates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HistoryDBFileName)
+	for range 2 {
+		h, err := OpenHistory(path)
+		if err != nil {
+			t.Fatalf("OpenHistory failed: %v", err)
+		}
+		version, err := h.SchemaVersion()
+		if err != nil || version != len(historyMigrations) {
+			t.Errorf("SchemaVersion = %d, %v; want %d", version, err, len(historyMigrations))
+		}
+		h.Close()
+	}
+}
+
+func TestHistoryRecordRunAndQuery(t *testing.T) {
+	h := openTestHistory(t)
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+
+	for i := range 5 {
+		run := statsRun{Time: base.AddDate(0, 0, i), Target: "org", ClonePath: "/ghorg/org", Total: 10, Errors: i}
+		outcomes := []RepoEvent{
+			{Event: RepoEventCloned, Time: run.Time, Name: "api", URL: "https://example.com/org/api", DurationSeconds: 1.5},
+			{Event: RepoEventError, Time: run.Time, Name: "web", URL: "https://example.com/org/web", Message: "boom"},
+		}
+		added, err := h.RecordRun(run, outcomes)
+		if err != nil || !added {
+			t.Fatalf("RecordRun = %v, %v; want added", added, err)
+		}
+	}
+	if added, err := h.RecordRun(statsRun{Time: base, Target: "org", ClonePath: "/ghorg/org"}, nil); err != nil || added {
+		t.Errorf("RecordRun of a duplicate = %v, %v; want ignored", added, err)
+	}
+	if _, err := h.RecordRun(statsRun{Time: base, Target: "other"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var outcomes int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM repo_outcomes WHERE run_id IS NOT NULL`).Scan(&outcomes); err != nil {
+		t.Fatal(err)
+	}
+	if outcomes != 10 {
+		t.Errorf("Expected 10 repo outcomes, got %d", outcomes)
+	}
+
+	tests := []struct {
+		name      string
+		q         HistoryQuery
+		wantTotal int
+		wantErrs  []int
+	}{
+		{"all", HistoryQuery{Target: "org"}, 5, []int{0, 1, 2, 3, 4}},
+		{"since", HistoryQuery{Target: "org", Since: base.AddDate(0, 0, 3)}, 2, []int{3, 4}},
+		{"until", HistoryQuery{Target: "ORG", Until: base.AddDate(0, 0, 2)}, 2, []int{0, 1}},
+		{"page", HistoryQuery{Target: "org", Limit: 2, Offset: 2}, 5, []int{2, 3}},
+		{"past end", HistoryQuery{Target: "org", Offset: 10}, 5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs, total, err := h.Runs(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var errs []int
+			for _, r := range runs {
+				errs = append(errs, r.Errors)
+			}
+			if total != tt.wantTotal || len(errs) != len(tt.wantErrs) {
+				t.Fatalf("Runs = %v (total %d), want %v (total %d)", errs, total, tt.wantErrs, tt.wantTotal)
+			}
+			for i := range errs {
+				if errs[i] != tt.wantErrs[i] {
+					t.Errorf("Runs = %v, want %v", errs, tt.wantErrs)
+					break
+				}
+			}
+		})
+	}
+
+	runs, _, _ := h.Runs(HistoryQuery{Target: "org", Limit: 1})
+	if !runs[0].Time.Equal(base) {
+		t.Errorf("run time = %v, want %v", runs[0].Time, base)
+	}
+}
+
+func TestHistoryImport(t *testing.T) {
+	statsPath, statePath := setupReportFixture(t)
+	oldHeader := filepath.Join(filepath.Dir(statsPath), "ghorg_stats_new_header_abc.csv")
+	if err := os.WriteFile(oldHeader, []byte("datetime,cloneTarget,totalCount\n2025-12-01 08:00:00,org,3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := openTestHistory(t)
+	for _, path := range []string{statsPath, oldHeader} {
+		n, err := h.ImportStatsCSV(path)
+		if err != nil {
+			t.Fatalf("ImportStatsCSV(%s) failed: %v", path, err)
+		}
+		if n == 0 {
+			t.Errorf("Expected runs imported from %s", path)
+		}
+	}
+	if n, err := h.ImportStatsCSV(statsPath); err != nil || n != 0 {
+		t.Errorf("second import = %d, %v; want 0 new runs", n, err)
+	}
+
+	runs, total, err := h.Runs(HistoryQuery{})
+	if err != nil || total != 4 {
+		t.Fatalf("Runs = %d, %v; want 4", total, err)
+	}
+	if runs[0].Total != 3 || runs[1].DurationSeconds != 90 || runs[2].DirSizeMB != 120.5 {
+		t.Errorf("imported runs = %+v", runs)
+	}
+
+	state, err := LoadState(statePath, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := h.ImportState(state); err != nil || n != 3 {
+		t.Errorf("ImportState = %d, %v; want 3", n, err)
+	}
+	if n, err := h.ImportState(state); err != nil || n != 0 {
+		t.Errorf("second ImportState = %d, %v; want 0", n, err)
+	}
+}
+
+func TestParseHistoryQuery(t *testing.T) {
+	q, err := parseHistoryQuery(url.Values{"since": {"2026-01-02"}, "until": {"2026-01-05"}, "limit": {"10"}, "offset": {"20"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Since.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Since = %v", q.Since)
+	}
+	if !q.Until.Equal(time.Date(2026, 1, 6, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Until = %v, want the end of 2026-01-05", q.Until)
+	}
+	if q.Limit != 10 || q.Offset != 20 {
+		t.Errorf("Limit/Offset = %d/%d", q.Limit, q.Offset)
+	}
+
+	for _, bad := range []url.Values{{"since": {"yesterday"}}, {"limit": {"-1"}}, {"offset": {"x"}}} {
+		if _, err := parseHistoryQuery(bad); err == nil {
+			t.Errorf("parseHistoryQuery(%v) should fail", bad)
+		}
+	}
+}
+
+func TestHandleStats(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	statsPath, _ := setupReportFixture(t)
+	os.Setenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO", filepath.Dir(statsPath))
+
+	get := func(query string) (*httptest.ResponseRecorder, []map[string]string) {
+		rec := httptest.NewRecorder()
+		newServerConfigFromEnv().handleStats(rec, httptest.NewRequest(http.MethodGet, "/stats"+query, nil))
+		var body []map[string]string
+		if rec.Code == http.StatusOK && rec.Body.Len() > 0 {
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+		}
+		return rec, body
+	}
+
+	if rec, _ := get(""); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("status without stats enabled = %d", rec.Code)
+	}
+
+	// Falls back to the CSV.
+	os.Setenv("GHORG_STATS_ENABLED", "true")
+	rec, body := get("?since=2026-01-02&limit=1")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Total-Count") != "2" || len(body) != 1 || body[0]["datetime"] != "2026-01-02 10:00:00" {
+		t.Errorf("CSV stats = %d %q %v", rec.Code, rec.Header().Get("X-Total-Count"), body)
+	}
+
+	// Uses the history store once enabled.
+	os.Setenv("GHORG_STATS_HISTORY", "true")
+	if err := recordHistory(statsRun{Time: time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local), Target: "db-only", Total: 7}, []RepoEvent{
+		repoEvent(RepoEventCloned, scm.Repo{Name: "api", URL: "https://example.com/db-only/api"}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rec, body = get("?target=db-only")
+	if rec.Code != http.StatusOK || len(body) != 1 || body[0]["totalCount"] != "7" {
+		t.Errorf("history stats = %d %v", rec.Code, body)
+	}
+
+	if rec, _ := get("?until=never"); rec.Code != http.StatusBadRequest {
+		t.Errorf("status with invalid until = %d, want 400", rec.Code)
+	}
+}
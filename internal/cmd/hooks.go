@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// Hook points, also passed to hooks as GHORG_HOOK.
+const (
+	HookPreRun              = "pre-run"
+	HookPostClone           = "post-clone"
+	HookPostPullWithChanges = "post-pull-with-changes"
+	HookOnError             = "on-error"
+	HookPostRun             = "post-run"
+)
+
+// Repo statuses passed to hooks as GHORG_HOOK_STATUS. Run hooks get
+// RecloneStatusSuccess or RecloneStatusFail.
+const (
+	HookStatusCloned = "cloned"
+	HookStatusPulled = "pulled"
+	HookStatusError  = "error"
+)
+
+const (
+	defaultHookTimeout     = 5 * time.Minute
+	defaultHookConcurrency = 4
+	// hookOutputTail is how much of a failed hook's output is reported.
+	hookOutputTail = 500
+)
+
+// hookEnvVars maps every hook point to the environment variable holding its
+// command.
+var hookEnvVars = map[string]string{
+	HookPreRun:              "GHORG_HOOKS_PRE_RUN",
+	HookPostClone:           "GHORG_HOOKS_POST_CLONE",
+	HookPostPullWithChanges: "GHORG_HOOKS_POST_PULL_WITH_CHANGES",
+	HookOnError:             "GHORG_HOOKS_ON_ERROR",
+	HookPostRun:             "GHORG_HOOKS_POST_RUN",
+}
+
+// hookOrder lists the hook points in the order they run.
+var hookOrder = []string{HookPreRun, HookPostClone, HookPostPullWithChanges, HookOnError, HookPostRun}
+
+// configuredHooks returns the comma separated hook points that have a
+// command, for the config summary.
+func configuredHooks() string {
+	var hooks []string
+	for _, hook := range hookOrder {
+		if strings.TrimSpace(os.Getenv(hookEnvVars[hook])) != "" {
+			hooks = append(hooks, hook)
+		}
+	}
+	return strings.Join(hooks, ", ")
+}
+
+// runHooks runs the shell commands configured for the hook points of a clone
+// run. Repo hooks run in the repo's directory and at most concurrency of them
+// run at once; run hooks run in the clone directory.
+type runHooks struct {
+	commands  map[string]string
+	timeout   time.Duration
+	slots     chan struct{}
+	scm       string
+	target    string
+	outputDir string
+}
+
+// newHooksFromEnv builds runHooks from the GHORG_HOOKS_* env vars. Returns nil
+// and no error when no hook is configured.
+func newHooksFromEnv() (*runHooks, error) {
+	commands := make(map[string]string)
+	for hook, envVar := range hookEnvVars {
+		if command := strings.TrimSpace(os.Getenv(envVar)); command != "" {
+			commands[hook] = command
+		}
+	}
+	if len(commands) == 0 {
+		return nil, nil
+	}
+
+	timeout := defaultHookTimeout
+	if value := os.Getenv("GHORG_HOOKS_TIMEOUT"); value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid GHORG_HOOKS_TIMEOUT: %s", value)
+		}
+	}
+	concurrency := defaultHookConcurrency
+	if value := os.Getenv("GHORG_HOOKS_CONCURRENCY"); value != "" {
+		var err error
+		if concurrency, err = strconv.Atoi(value); err != nil || concurrency <= 0 {
+			return nil, fmt.Errorf("invalid GHORG_HOOKS_CONCURRENCY: %s", value)
+		}
+	}
+
+	return &runHooks{
+		commands:  commands,
+		timeout:   timeout,
+		slots:     make(chan struct{}, concurrency),
+		scm:       strings.ToLower(os.Getenv("GHORG_SCM_TYPE")),
+		target:    targetCloneSource,
+		outputDir: outputDirAbsolutePath,
+	}, nil
+}
+
+// has reports whether a command is configured for hook.
+func (h *runHooks) has(hook string) bool {
+	return h != nil && h.commands[hook] != ""
+}
+
+// runRepo runs the repo hook for repo, waiting for a free slot first.
+// status is one of the HookStatus constants, message the error of on-error.
+func (h *runHooks) runRepo(hook string, repo scm.Repo, status, oldSHA, newSHA, message string) error {
+	if !h.has(hook) {
+		return nil
+	}
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	dir := repo.HostPath
+	if _, err := os.Stat(dir); err != nil {
+		dir = h.outputDir
+	}
+	return h.run(hook, dir, []string{
+		"GHORG_HOOK_REPO_NAME=" + repo.Name,
+		"GHORG_HOOK_REPO_URL=" + repo.URL,
+		"GHORG_HOOK_REPO_PATH=" + repo.HostPath,
+		"GHORG_HOOK_REPO_BRANCH=" + repo.CloneBranch,
+		"GHORG_HOOK_OLD_SHA=" + oldSHA,
+		"GHORG_HOOK_NEW_SHA=" + newSHA,
+		"GHORG_HOOK_NEW_COMMITS=" + strconv.Itoa(repo.Commits.CountDiff),
+		"GHORG_HOOK_STATUS=" + status,
+		"GHORG_HOOK_ERROR=" + message,
+	})
+}
+
+// runPreRun runs the pre-run hook before the repoCount repos of the run are
+// processed.
+func (h *runHooks) runPreRun(repoCount int) error {
+	if !h.has(HookPreRun) {
+		return nil
+	}
+	return h.run(HookPreRun, h.outputDir, []string{
+		"GHORG_HOOK_REPO_COUNT=" + strconv.Itoa(repoCount),
+	})
+}
+
+// runPostRun runs the post-run hook with the stats of the finished run.
+func (h *runHooks) runPostRun(stats CloneStats) error {
+	if !h.has(HookPostRun) {
+		return nil
+	}
+	status := RecloneStatusSuccess
+	if len(stats.CloneErrors) > 0 {
+		status = RecloneStatusFail
+	}
+	return h.run(HookPostRun, h.outputDir, []string{
+		"GHORG_HOOK_STATUS=" + status,
+		"GHORG_HOOK_CLONED=" + strconv.Itoa(stats.CloneCount),
+		"GHORG_HOOK_PULLED=" + strconv.Itoa(stats.PulledCount),
+		"GHORG_HOOK_NEW_COMMITS=" + strconv.Itoa(stats.NewCommits),
+		"GHORG_HOOK_INFOS=" + strconv.Itoa(len(stats.CloneInfos)),
+		"GHORG_HOOK_ERRORS=" + strconv.Itoa(len(stats.CloneErrors)),
+	})
+}
+
+// run runs the command of hook with sh -c in dir. The hook inherits the
+// environment of ghorg plus GHORG_HOOK, the run's GHORG_HOOK_SCM,
+// GHORG_HOOK_TARGET and GHORG_HOOK_OUTPUT_DIR, and env. Its output is
+// captured so it never mixes with a run report on stdout.
+func (h *runHooks) run(hook, dir string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.commands[hook])
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GHORG_HOOK="+hook,
+		"GHORG_HOOK_SCM="+h.scm,
+		"GHORG_HOOK_TARGET="+h.target,
+		"GHORG_HOOK_OUTPUT_DIR="+h.outputDir,
+	)
+	cmd.Env = append(cmd.Env, env...)
+	// Don't wait forever on children of a killed hook that still hold the output pipe.
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if os.Getenv("GHORG_DEBUG") != "" && len(output) > 0 {
+		colorlog.PrintSubtleInfo(fmt.Sprintf("%s hook output:\n%s", hook, output))
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s hook timed out after %s", hook, h.timeout)
+	}
+	if err != nil {
+		tail := strings.TrimSpace(string(output))
+		if len(tail) > hookOutputTail {
+			tail = "..." + tail[len(tail)-hookOutputTail:]
+		}
+		if tail == "" {
+			return fmt.Errorf("%s hook failed: %w", hook, err)
+		}
+		return fmt.Errorf("%s hook failed: %w, output: %s", hook, err, tail)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// shaSequenceGit returns the next SHA of shas from every HeadSHA call.
+type shaSequenceGit struct {
+	*ExtendedMockGitClient
+	shas  []string
+	calls *atomic.Int32
+}
+
+func (g shaSequenceGit) HeadSHA(repo scm.Repo) (string, error) {
+	i := int(g.calls.Add(1)) - 1
+	return g.shas[min(i, len(g.shas)-1)], nil
+}
+
+// hookLogCommand appends the hook point and the given GHORG_HOOK_* variables
+// to log, one line per run.
+func hookLogCommand(log string, vars ...string) string {
+	fields := []string{"$GHORG_HOOK"}
+	for _, v := range vars {
+		fields = append(fields, v+"=$"+v)
+	}
+	return `echo "` + strings.Join(fields, " ") + `" >> ` + log
+}
+
+func readHookLog(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestNewHooksFromEnv(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	hooks, err := newHooksFromEnv()
+	if err != nil || hooks != nil {
+		t.Fatalf("newHooksFromEnv() = %v, %v; want nil when no hook is set", hooks, err)
+	}
+	if hooks.has(HookPostClone) {
+		t.Error("nil hooks should have no hook")
+	}
+	if err := hooks.runPreRun(1); err != nil {
+		t.Errorf("runPreRun on nil hooks: %v", err)
+	}
+
+	os.Setenv("GHORG_HOOKS_POST_CLONE", "true")
+	hooks, err = newHooksFromEnv()
+	if err != nil {
+		t.Fatalf("newHooksFromEnv: %v", err)
+	}
+	if !hooks.has(HookPostClone) || hooks.has(HookOnError) {
+		t.Errorf("unexpected hooks: %v", hooks.commands)
+	}
+	if hooks.timeout != defaultHookTimeout || cap(hooks.slots) != defaultHookConcurrency {
+		t.Errorf("timeout = %s, concurrency = %d; want defaults", hooks.timeout, cap(hooks.slots))
+	}
+
+	for envVar, value := range map[string]string{"GHORG_HOOKS_TIMEOUT": "soon", "GHORG_HOOKS_CONCURRENCY": "0"} {
+		os.Setenv(envVar, value)
+		if _, err := newHooksFromEnv(); err == nil || !strings.Contains(err.Error(), envVar) {
+			t.Errorf("expected error for %s=%s, got %v", envVar, value, err)
+		}
+		os.Unsetenv(envVar)
+	}
+}
+
+func TestRunHooks_Run(t *testing.T) {
+	dir := t.TempDir()
+	hooks := &runHooks{
+		commands: map[string]string{
+			HookPreRun:  `test "$GHORG_HOOK_REPO_COUNT" = 3 && test "$GHORG_HOOK_TARGET" = org && test "$PWD" = "$GHORG_HOOK_OUTPUT_DIR"`,
+			HookPostRun: `echo "some output"; echo "the reason" >&2; exit 3`,
+			HookOnError: `sleep 5`,
+		},
+		timeout:   200 * time.Millisecond,
+		slots:     make(chan struct{}, 1),
+		target:    "org",
+		outputDir: dir,
+	}
+
+	if err := hooks.runPreRun(3); err != nil {
+		t.Errorf("pre-run hook did not get the expected environment: %v", err)
+	}
+
+	err := hooks.runPostRun(CloneStats{})
+	if err == nil || !strings.Contains(err.Error(), "post-run hook failed") || !strings.Contains(err.Error(), "the reason") {
+		t.Errorf("expected failure with the hook output, got %v", err)
+	}
+
+	start := time.Now()
+	err = hooks.runRepo(HookOnError, scm.Repo{Name: "app"}, HookStatusError, "", "", "boom")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("hook ran for %s after its timeout", elapsed)
+	}
+}
+
+func TestRunHooks_Concurrency(t *testing.T) {
+	dir := t.TempDir()
+	hooks := &runHooks{
+		// mkdir is atomic: a second hook running at the same time fails.
+		commands:  map[string]string{HookPostClone: `mkdir "$GHORG_HOOK_OUTPUT_DIR/lock" && sleep 0.1 && rmdir "$GHORG_HOOK_OUTPUT_DIR/lock"`},
+		timeout:   10 * time.Second,
+		slots:     make(chan struct{}, 1),
+		outputDir: dir,
+	}
+
+	errs := make(chan error, 4)
+	for range 4 {
+		go func() { errs <- hooks.runRepo(HookPostClone, scm.Repo{}, HookStatusCloned, "", "", "") }()
+	}
+	for range 4 {
+		if err := <-errs; err != nil {
+			t.Errorf("hooks overlapped with a concurrency of 1: %v", err)
+		}
+	}
+}
+
+func TestProcessRepository_Hooks(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	outputDirAbsolutePath = t.TempDir()
+	targetCloneSource = "org"
+	log := filepath.Join(t.TempDir(), "hooks.log")
+	vars := []string{"GHORG_HOOK_REPO_NAME", "GHORG_HOOK_STATUS", "GHORG_HOOK_OLD_SHA", "GHORG_HOOK_NEW_SHA"}
+	os.Setenv("GHORG_HOOKS_POST_CLONE", hookLogCommand(log, vars...))
+	os.Setenv("GHORG_HOOKS_POST_PULL_WITH_CHANGES", hookLogCommand(log, vars...))
+	os.Setenv("GHORG_HOOKS_ON_ERROR", hookLogCommand(log, "GHORG_HOOK_REPO_NAME", "GHORG_HOOK_STATUS", "GHORG_HOOK_ERROR"))
+
+	newProcessor := func(g *ExtendedMockGitClient, shas ...string) *RepositoryProcessor {
+		hooks, err := newHooksFromEnv()
+		if err != nil {
+			t.Fatalf("newHooksFromEnv: %v", err)
+		}
+		processor := NewRepositoryProcessor(shaSequenceGit{g, shas, &atomic.Int32{}})
+		processor.SetHooks(hooks)
+		return processor
+	}
+	process := func(processor *RepositoryProcessor, name string) {
+		repo := scm.Repo{Name: name, URL: "https://github.com/org/" + name, CloneBranch: "main"}
+		processor.ProcessRepository(&repo, make(map[string]bool), false, name, 0)
+	}
+
+	t.Run("post-clone", func(t *testing.T) {
+		os.Remove(log)
+		process(newProcessor(NewExtendedMockGit(), "bbb"), "new")
+		want := []string{"post-clone GHORG_HOOK_REPO_NAME=new GHORG_HOOK_STATUS=cloned GHORG_HOOK_OLD_SHA= GHORG_HOOK_NEW_SHA=bbb"}
+		if got := readHookLog(t, log); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("hook log = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("post-pull-with-changes", func(t *testing.T) {
+		os.Remove(log)
+		for _, name := range []string{"changed", "unchanged"} {
+			if err := os.MkdirAll(filepath.Join(outputDirAbsolutePath, name), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		process(newProcessor(NewExtendedMockGit(), "aaa", "bbb"), "changed")
+		process(newProcessor(NewExtendedMockGit(), "aaa", "aaa"), "unchanged")
+		want := []string{"post-pull-with-changes GHORG_HOOK_REPO_NAME=changed GHORG_HOOK_STATUS=pulled GHORG_HOOK_OLD_SHA=aaa GHORG_HOOK_NEW_SHA=bbb"}
+		if got := readHookLog(t, log); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("hook log = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("on-error", func(t *testing.T) {
+		os.Remove(log)
+		g := NewExtendedMockGit()
+		g.shouldFailClone = true
+		process(newProcessor(g, ""), "broken")
+		got := readHookLog(t, log)
+		if len(got) != 1 || !strings.HasPrefix(got[0], "on-error GHORG_HOOK_REPO_NAME=broken GHORG_HOOK_STATUS=error GHORG_HOOK_ERROR=") || !strings.Contains(got[0], "mock clone error") {
+			t.Errorf("hook log = %q", got)
+		}
+	})
+
+	t.Run("failing hook is an info", func(t *testing.T) {
+		os.Setenv("GHORG_HOOKS_POST_CLONE", "echo nope; exit 1")
+		defer os.Setenv("GHORG_HOOKS_POST_CLONE", hookLogCommand(log, vars...))
+
+		processor := newProcessor(NewExtendedMockGit(), "bbb")
+		process(processor, "other")
+		stats := processor.GetStats()
+		if stats.CloneCount != 1 || len(stats.CloneErrors) != 0 {
+			t.Errorf("a failing hook should not fail the clone: %+v", stats)
+		}
+		if len(stats.CloneInfos) != 1 || !strings.Contains(stats.CloneInfos[0], "post-clone hook failed") || !strings.Contains(stats.CloneInfos[0], "nope") {
+			t.Errorf("CloneInfos = %q", stats.CloneInfos)
+		}
+	})
+}
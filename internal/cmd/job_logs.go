@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JobLogsDirName is the default directory of the reclone server's job logs,
+// kept next to the job file.
+const JobLogsDirName = "reclone-job-logs"
+
+// defaultJobLogsMaxSizeMB is how much disk the job logs may use by default.
+const defaultJobLogsMaxSizeMB = 100
+
+// jobLogs keeps one log file per job. Followers of a log wait on Changed,
+// which is closed whenever a log is written or a job changes state.
+type jobLogs struct {
+	dir     string
+	maxSize int64 // bytes, 0 keeps every log
+
+	mu      sync.Mutex
+	active  map[string]bool // jobs whose log is still being written
+	changed chan struct{}
+}
+
+func newJobLogs(dir string, maxSize int64) (*jobLogs, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &jobLogs{dir: dir, maxSize: maxSize, active: map[string]bool{}, changed: make(chan struct{})}, nil
+}
+
+// getGhorgJobLogsDir returns where the reclone server keeps job logs.
+func getGhorgJobLogsDir() string {
+	if dir := os.Getenv("GHORG_RECLONE_SERVER_LOGS_PATH"); dir != "" {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(getGhorgJobsFilePath()), JobLogsDirName)
+}
+
+// getJobLogsMaxSize returns GHORG_RECLONE_SERVER_LOGS_MAX_SIZE_MB in bytes.
+func getJobLogsMaxSize() int64 {
+	mb, err := strconv.Atoi(os.Getenv("GHORG_RECLONE_SERVER_LOGS_MAX_SIZE_MB"))
+	if err != nil || mb < 0 {
+		mb = defaultJobLogsMaxSizeMB
+	}
+	return int64(mb) << 20
+}
+
+func (l *jobLogs) path(id string) string {
+	return filepath.Join(l.dir, id+".log")
+}
+
+// Changed returns a channel closed on the next log write or job state change.
+func (l *jobLogs) Changed() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.changed
+}
+
+// notify wakes everyone waiting on Changed.
+func (l *jobLogs) notify() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Create starts the log of job id, replacing any previous one.
+func (l *jobLogs) Create(id string) (*jobLog, error) {
+	f, err := os.OpenFile(l.path(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	l.active[id] = true
+	l.mu.Unlock()
+	l.notify()
+	return &jobLog{logs: l, id: id, f: f}, nil
+}
+
+// Remove deletes the log of job id.
+func (l *jobLogs) Remove(id string) {
+	if l == nil {
+		return
+	}
+	if err := os.Remove(l.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Error removing job log %s: %s\n", l.path(id), err)
+	}
+}
+
+// Rotate removes the oldest finished job logs until the logs use at most
+// maxSize bytes. Logs that are still being written are never removed.
+func (l *jobLogs) Rotate() error {
+	if l == nil || l.maxSize == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+
+	type logFile struct {
+		id   string
+		size int64
+		mod  int64
+	}
+	var files []logFile
+	var total int64
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".log")
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{id: id, size: info.Size(), mod: info.ModTime().UnixNano()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod < files[j].mod })
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, f := range files {
+		if total <= l.maxSize {
+			break
+		}
+		if l.active[f.id] {
+			continue
+		}
+		if err := os.Remove(l.path(f.id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		total -= f.size
+	}
+	return nil
+}
+
+// read returns the complete lines of the log of job id from offset on.
+func (l *jobLogs) read(id string, offset int64) ([]byte, error) {
+	f, err := os.Open(l.path(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return data[:bytes.LastIndexByte(data, '\n')+1], nil
+}
+
+// jobLog is the log of one running job. Output is written line by line with
+// tokens scrubbed, so a token split across two writes is still caught.
+type jobLog struct {
+	logs *jobLogs
+	id   string
+
+	mu      sync.Mutex
+	f       *os.File
+	partial []byte
+}
+
+func (j *jobLog) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.partial = append(j.partial, p...)
+	i := bytes.LastIndexByte(j.partial, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	err := j.writeLines(j.partial[:i+1])
+	j.partial = append(j.partial[:0], j.partial[i+1:]...)
+	return len(p), err
+}
+
+// Close writes any unterminated last line and closes the log.
+func (j *jobLog) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var err error
+	if len(j.partial) > 0 {
+		err = j.writeLines(append(j.partial, '\n'))
+		j.partial = nil
+	}
+	if cerr := j.f.Close(); err == nil {
+		err = cerr
+	}
+
+	j.logs.mu.Lock()
+	delete(j.logs.active, j.id)
+	j.logs.mu.Unlock()
+	j.logs.notify()
+	return err
+}
+
+func (j *jobLog) writeLines(b []byte) error {
+	var sb strings.Builder
+	for line := range strings.Lines(string(b)) {
+		sb.WriteString(sanitizeLogLine(strings.TrimSuffix(line, "\n")))
+		sb.WriteByte('\n')
+	}
+	_, err := j.f.WriteString(sb.String())
+	j.logs.notify()
+	return err
+}
+
+// urlCredentials matches the user info of http(s) URLs, where clone URLs
+// carry their token.
+var urlCredentials = regexp.MustCompile(`(https?://)[^/\s@]+@`)
+
+// sanitizeLogLine scrubs tokens from a log line: token flags the same way
+// sanitizeCmd does for reclone commands, and credentials in clone URLs.
+func sanitizeLogLine(line string) string {
+	sanitized := sanitizeCmd(line)
+	if !strings.HasSuffix(line, " ") {
+		// sanitizeCmd leaves a space after a token that ended the line
+		sanitized = strings.TrimSuffix(sanitized, " ")
+	}
+	return scrubURLCredentials(sanitized)
+}
+
+// scrubURLCredentials masks the credentials of the http(s) URLs in s.
+func scrubURLCredentials(s string) string {
+	return urlCredentials.ReplaceAllString(s, "${1}XXXXXXX@")
+}
+
+// jobLogEnd is the data of the end event of a job log stream.
+type jobLogEnd struct {
+	State    string `json:"state"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+}
+
+// handleLogs streams the log of the job named by the id path value until
+// the job finishes: as Server-Sent Events when the client accepts
+// text/event-stream, as chunked plain text otherwise. A queued job's
+// stream waits for the job to start. Every event carries the log offset as
+// its ID, so a reconnecting client resumes with Last-Event-ID.
+func (q *jobQueue) handleLogs(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := q.Get(id); !ok || q.logs == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	var offset int64
+	if sse {
+		if last, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64); err == nil && last > 0 {
+			offset = last
+		}
+	}
+	flusher, _ := w.(http.Flusher)
+	started := false
+
+	for {
+		// Take the channel before reading so no change is missed.
+		changed := q.logs.Changed()
+		job, ok := q.Get(id)
+		if !ok {
+			return
+		}
+		data, err := q.logs.read(id, offset)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			if !started {
+				http.Error(w, "Unable to read job log", http.StatusInternalServerError)
+			}
+			return
+		}
+		finished := job.FinishedAt != nil
+		if errors.Is(err, os.ErrNotExist) && finished && !started {
+			http.Error(w, "Job log is no longer retained", http.StatusGone)
+			return
+		}
+
+		if !started {
+			if sse {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+			} else {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			}
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		if sse {
+			for line := range strings.Lines(string(data)) {
+				offset += int64(len(line))
+				line = strings.ReplaceAll(strings.TrimSuffix(line, "\n"), "\r", "")
+				fmt.Fprintf(w, "id: %d\ndata: %s\n\n", offset, line)
+			}
+		} else {
+			_, _ = w.Write(data)
+			offset += int64(len(data))
+		}
+
+		if finished {
+			if sse {
+				end, _ := json.Marshal(jobLogEnd{State: job.State, ExitCode: job.ExitCode})
+				fmt.Fprintf(w, "event: end\ndata: %s\n\n", end)
+			}
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
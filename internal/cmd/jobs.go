@@ -0,0 +1,455 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blairham/ghorg/internal/configs"
+)
+
+// JobsFileName is the default name of the reclone server's job file, kept
+// next to reclone.yaml.
+const JobsFileName = "reclone-jobs.json"
+
+// Job states.
+const (
+	JobStateQueued    = "queued"
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateFailed    = "failed"
+)
+
+// maxFinishedJobs is how many finished jobs are kept in the job history.
+const maxFinishedJobs = 100
+
+// Job is a reclone run queued on the reclone server. A job either runs the
+// reclone entries named by Keys, or updates the single repo a webhook
+// reported in every entry that clones it.
+type Job struct {
+	ID         string          `json:"id"`
+	Keys       []string        `json:"keys,omitempty"`
+	Webhook    *WebhookEvent   `json:"webhook,omitempty"`
+	State      string          `json:"state"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	ExitCode   *int            `json:"exit_code,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Summary    *JobSummary     `json:"summary,omitempty"`
+	Results    []RecloneResult `json:"results,omitempty"`
+}
+
+// JobSummary totals the results of the reclone entries a job ran.
+type JobSummary struct {
+	Entries         int     `json:"entries"`
+	Succeeded       int     `json:"succeeded"`
+	Failed          int     `json:"failed"`
+	Cloned          int     `json:"cloned"`
+	Pulled          int     `json:"pulled"`
+	NewCommits      int     `json:"new_commits"`
+	RepoErrors      int     `json:"repo_errors"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// newJobSummary totals results.
+func newJobSummary(results []RecloneResult) *JobSummary {
+	s := &JobSummary{Entries: len(results)}
+	for _, res := range results {
+		if res.Status == RecloneStatusSuccess {
+			s.Succeeded++
+		} else {
+			s.Failed++
+		}
+		s.DurationSeconds += res.DurationSeconds
+		if res.Report == nil {
+			continue
+		}
+		s.Cloned += res.Report.Summary.CloneCount
+		s.Pulled += res.Report.Summary.PulledCount
+		s.NewCommits += res.Report.Summary.NewCommits
+		s.RepoErrors += len(res.Report.Summary.CloneErrors)
+	}
+	return s
+}
+
+// jobRunner runs job, writing the run's output to log.
+type jobRunner func(job Job, log io.Writer) ([]RecloneResult, error)
+
+// jobQueue runs queued reclone jobs one at a time. Every change is written
+// to path so queued jobs survive a restart.
+type jobQueue struct {
+	path        string
+	recloneFile string // located when the queue is created
+	run         jobRunner
+	metrics     *recloneMetrics
+	logs        *jobLogs
+
+	mu   sync.Mutex
+	jobs []*Job // oldest first
+	wake chan struct{}
+}
+
+// newJobQueue loads the jobs persisted at path. Queued jobs are run again,
+// jobs that were running when the server stopped are marked failed.
+func newJobQueue(path string, run jobRunner) (*jobQueue, error) {
+	q := &jobQueue{path: path, run: run, recloneFile: configs.GhorgReCloneLocation(), wake: make(chan struct{}, 1)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &q.jobs); err != nil {
+		return nil, fmt.Errorf("parsing job file %s: %w", path, err)
+	}
+
+	interrupted := false
+	for _, job := range q.jobs {
+		if job.State == JobStateRunning {
+			q.finish(job, nil, errors.New("the reclone server stopped while the job was running"))
+			interrupted = true
+		}
+	}
+	if interrupted {
+		if err := q.save(); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// getGhorgJobsFilePath returns where the reclone server persists its jobs.
+func getGhorgJobsFilePath() string {
+	if path := os.Getenv("GHORG_RECLONE_SERVER_JOBS_PATH"); path != "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configs.GhorgReCloneLocation()), JobsFileName)
+}
+
+// normalizeJobKeys sorts keys and drops duplicates. No keys means every
+// reclone entry.
+func normalizeJobKeys(keys []string) []string {
+	if len(keys) == 0 {
+		return nil
+	}
+	keys = slices.Clone(keys)
+	sort.Strings(keys)
+	return slices.Compact(keys)
+}
+
+// Enqueue queues a job for keys. When a job for the same keys is already
+// queued that job is returned instead, with created false.
+func (q *jobQueue) Enqueue(keys []string) (job Job, created bool, err error) {
+	return q.enqueue(normalizeJobKeys(keys), nil)
+}
+
+// EnqueueWebhook queues a job for a webhook event. When a job for the same
+// event is already queued that job is returned instead, with created false.
+func (q *jobQueue) EnqueueWebhook(event WebhookEvent) (job Job, created bool, err error) {
+	return q.enqueue(nil, &event)
+}
+
+func (q *jobQueue) enqueue(keys []string, webhook *WebhookEvent) (Job, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, j := range q.jobs {
+		if j.State == JobStateQueued && slices.Equal(j.Keys, keys) && sameWebhookEvent(j.Webhook, webhook) {
+			q.metrics.Trigger(false)
+			return *j, false, nil
+		}
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, false, err
+	}
+	j := &Job{ID: id, Keys: keys, Webhook: webhook, State: JobStateQueued, CreatedAt: time.Now().UTC()}
+	q.jobs = append(q.jobs, j)
+	if err := q.save(); err != nil {
+		q.jobs = q.jobs[:len(q.jobs)-1]
+		return Job{}, false, err
+	}
+
+	q.metrics.Trigger(true)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return *j, true, nil
+}
+
+// Get returns the job with id.
+func (q *jobQueue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.ID == id {
+			return *j, true
+		}
+	}
+	return Job{}, false
+}
+
+// List returns every job newest first, without per-entry results.
+func (q *jobQueue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, 0, len(q.jobs))
+	for i := len(q.jobs) - 1; i >= 0; i-- {
+		j := *q.jobs[i]
+		j.Results = nil
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
+
+// Work runs queued jobs as they arrive. It never returns.
+func (q *jobQueue) Work() {
+	for {
+		for q.runNext() {
+		}
+		<-q.wake
+	}
+}
+
+// runNext runs the oldest queued job, returning false when none is queued.
+func (q *jobQueue) runNext() bool {
+	q.mu.Lock()
+	var job *Job
+	for _, j := range q.jobs {
+		if j.State == JobStateQueued {
+			job = j
+			break
+		}
+	}
+	if job == nil {
+		q.mu.Unlock()
+		return false
+	}
+	startedAt := time.Now().UTC()
+	job.State = JobStateRunning
+	job.StartedAt = &startedAt
+	q.saveOrLog()
+	run := *job
+	q.mu.Unlock()
+	q.logs.notify()
+
+	var log io.Writer = io.Discard
+	var jl *jobLog
+	if q.logs != nil {
+		var err error
+		if jl, err = q.logs.Create(run.ID); err != nil {
+			fmt.Printf("Error creating job log: %s\n", err)
+		} else {
+			log = jl
+		}
+	}
+
+	results, err := q.run(run, log)
+
+	if jl != nil {
+		if err := jl.Close(); err != nil {
+			fmt.Printf("Error writing job log: %s\n", err)
+		}
+	}
+	q.mu.Lock()
+	q.finish(job, results, err)
+	q.prune()
+	q.saveOrLog()
+	q.mu.Unlock()
+
+	if err := q.logs.Rotate(); err != nil {
+		fmt.Printf("Error rotating job logs: %s\n", err)
+	}
+	q.logs.notify()
+	return true
+}
+
+// finish records the outcome of job. The caller must hold q.mu or own q.
+func (q *jobQueue) finish(job *Job, results []RecloneResult, err error) {
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	job.Summary = newJobSummary(results)
+
+	// Keep the run report summaries but not every repo's outcome, which
+	// would make the job file grow with the size of the orgs.
+	job.Results = make([]RecloneResult, len(results))
+	for i, res := range results {
+		if res.Report != nil {
+			report := *res.Report
+			report.Repos = nil
+			res.Report = &report
+		}
+		job.Results[i] = res
+	}
+	if err == nil {
+		if failed := failedReclone(results); failed != nil {
+			err = fmt.Errorf("reclone %s failed: %s", failed.Key, failed.Error)
+		}
+	}
+
+	exitCode := 0
+	job.State = JobStateSucceeded
+	if err != nil {
+		exitCode = 1
+		job.State = JobStateFailed
+		job.Error = err.Error()
+	}
+	job.ExitCode = &exitCode
+}
+
+// prune drops the oldest finished jobs beyond maxFinishedJobs, and their
+// logs.
+func (q *jobQueue) prune() {
+	finished := 0
+	for _, j := range q.jobs {
+		if j.FinishedAt != nil {
+			finished++
+		}
+	}
+	kept := q.jobs[:0]
+	for _, j := range q.jobs {
+		if j.FinishedAt != nil && finished > maxFinishedJobs {
+			finished--
+			q.logs.Remove(j.ID)
+			continue
+		}
+		kept = append(kept, j)
+	}
+	q.jobs = kept
+}
+
+// save writes every job to q.path. The caller must hold q.mu.
+func (q *jobQueue) save() error {
+	data, err := json.MarshalIndent(q.jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(q.path, data)
+}
+
+func (q *jobQueue) saveOrLog() {
+	if err := q.save(); err != nil {
+		fmt.Printf("Error saving job file %s: %s\n", q.path, err)
+	}
+}
+
+// newJobID returns a random job ID.
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// jobRequest is the optional body of POST /jobs.
+type jobRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// handleCreate queues a job for the keys in the JSON body or the key query
+// parameters, every entry when there are none. It answers 202 with the new
+// job, or 200 with the queued job it was deduplicated with.
+func (q *jobQueue) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req jobRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid job request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	keys := append(req.Keys, r.URL.Query()["key"]...)
+
+	job, created, ok := q.enqueueRequest(w, keys)
+	if !ok {
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, job)
+}
+
+// handleTrigger queues a job for the reclone key in the cmd query parameter,
+// every entry when it is empty. It always answers 200 with the job.
+func (q *jobQueue) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	var keys []string
+	if userCmd := r.URL.Query().Get("cmd"); userCmd != "" {
+		keys = []string{userCmd}
+	}
+	if job, _, ok := q.enqueueRequest(w, keys); ok {
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+// enqueueRequest checks keys exist in reclone.yaml and queues a job for
+// them, setting the Location header. It writes the error response itself
+// and returns false when the job could not be queued.
+func (q *jobQueue) enqueueRequest(w http.ResponseWriter, keys []string) (Job, bool, bool) {
+	reclones, err := loadReclones(q.recloneFile)
+	if err != nil {
+		http.Error(w, "Unable to read reclone.yaml", http.StatusInternalServerError)
+		return Job{}, false, false
+	}
+	if _, err := recloneKeys(reclones, keys); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return Job{}, false, false
+	}
+
+	job, created, err := q.Enqueue(keys)
+	if err != nil {
+		http.Error(w, "Unable to queue job", http.StatusInternalServerError)
+		return Job{}, false, false
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	return job, created, true
+}
+
+// handleGet returns the job named by the id path value.
+func (q *jobQueue) handleGet(w http.ResponseWriter, r *http.Request) {
+	job, ok := q.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleList returns the job history newest first, optionally only the
+// jobs in the state query parameter.
+func (q *jobQueue) handleList(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	jobs := q.List()
+	if state != "" {
+		jobs = slices.DeleteFunc(jobs, func(j Job) bool { return j.State != state })
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// writeJSON writes v as the JSON response body with status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Unable to encode JSON", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeJobRunner records the keys of every run and returns results for them.
+type fakeJobRunner struct {
+	runs [][]string
+	fail string
+}
+
+func (f *fakeJobRunner) run(job Job, log io.Writer) ([]RecloneResult, error) {
+	f.runs = append(f.runs, job.Keys)
+	if f.fail != "" {
+		return []RecloneResult{{Key: f.fail, Status: RecloneStatusFail, Error: "exit status 1"}}, nil
+	}
+	return []RecloneResult{{
+		Key:             "work",
+		Status:          RecloneStatusSuccess,
+		DurationSeconds: 2,
+		Report: &RunReport{
+			Summary: CloneStats{CloneCount: 1, PulledCount: 3, NewCommits: 7, CloneErrors: []string{"boom"}},
+			Repos:   []RepoEvent{{Event: RepoEventCloned, Name: "api"}},
+		},
+	}}, nil
+}
+
+func TestJobQueueRunsAndDeduplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), JobsFileName)
+	runner := &fakeJobRunner{}
+	q, err := newJobQueue(path, runner.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, created, err := q.Enqueue([]string{"work", "personal", "work"})
+	if err != nil || !created {
+		t.Fatalf("Enqueue = %v, %v; want created", created, err)
+	}
+	if strings.Join(first.Keys, ",") != "personal,work" {
+		t.Errorf("Keys = %v, want sorted and deduplicated", first.Keys)
+	}
+	dup, created, _ := q.Enqueue([]string{"personal", "work"})
+	if created || dup.ID != first.ID {
+		t.Errorf("Enqueue of the same keys = %s (created %v), want %s", dup.ID, created, first.ID)
+	}
+	all, _, _ := q.Enqueue(nil)
+
+	for q.runNext() {
+	}
+	if len(runner.runs) != 2 || runner.runs[1] != nil {
+		t.Errorf("runs = %v, want the keyed job then every entry", runner.runs)
+	}
+
+	job, ok := q.Get(first.ID)
+	if !ok || job.State != JobStateSucceeded || job.ExitCode == nil || *job.ExitCode != 0 {
+		t.Fatalf("job = %+v, want succeeded with exit code 0", job)
+	}
+	if job.StartedAt == nil || job.FinishedAt == nil || job.FinishedAt.Before(*job.StartedAt) {
+		t.Errorf("timestamps = %v %v", job.StartedAt, job.FinishedAt)
+	}
+	want := JobSummary{Entries: 1, Succeeded: 1, Cloned: 1, Pulled: 3, NewCommits: 7, RepoErrors: 1, DurationSeconds: 2}
+	if *job.Summary != want {
+		t.Errorf("Summary = %+v, want %+v", *job.Summary, want)
+	}
+	if len(job.Results) != 1 || job.Results[0].Report.Repos != nil {
+		t.Errorf("Results = %+v, want one result without per-repo outcomes", job.Results)
+	}
+
+	list := q.List()
+	if len(list) != 2 || list[0].ID != all.ID || list[0].Results != nil {
+		t.Errorf("List = %+v, want newest first without results", list)
+	}
+
+	// A job for the same keys can be queued again once the first one ran.
+	if _, created, _ := q.Enqueue([]string{"work", "personal"}); !created {
+		t.Error("Expected a new job once the previous one finished")
+	}
+}
+
+func TestJobQueueFailedJob(t *testing.T) {
+	q, err := newJobQueue(filepath.Join(t.TempDir(), JobsFileName), (&fakeJobRunner{fail: "work"}).run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, _, _ := q.Enqueue([]string{"work"})
+	q.runNext()
+
+	job, _ := q.Get(queued.ID)
+	if job.State != JobStateFailed || *job.ExitCode != 1 || !strings.Contains(job.Error, "reclone work failed") || job.Summary.Failed != 1 {
+		t.Errorf("job = %+v, want failed", job)
+	}
+
+	q.run = func(Job, io.Writer) ([]RecloneResult, error) { return nil, errors.New("reclone.yaml missing") }
+	queued, _, _ = q.Enqueue(nil)
+	q.runNext()
+	if job, _ := q.Get(queued.ID); job.State != JobStateFailed || job.Error != "reclone.yaml missing" {
+		t.Errorf("job = %+v, want failed with the run error", job)
+	}
+}
+
+func TestJobQueuePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), JobsFileName)
+	runner := &fakeJobRunner{}
+	q, err := newJobQueue(path, runner.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, _, _ := q.Enqueue([]string{"done"})
+	q.runNext()
+	queued, _, _ := q.Enqueue([]string{"queued"})
+
+	// Simulate a server that stopped while a job was running.
+	running, _, _ := q.Enqueue([]string{"running"})
+	q.mu.Lock()
+	q.jobs[len(q.jobs)-1].State = JobStateRunning
+	if err := q.save(); err != nil {
+		t.Fatal(err)
+	}
+	q.mu.Unlock()
+
+	restarted, err := newJobQueue(path, runner.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := restarted.Get(done.ID); job.State != JobStateSucceeded {
+		t.Errorf("finished job = %+v after restart", job)
+	}
+	if job, _ := restarted.Get(running.ID); job.State != JobStateFailed || job.Error == "" {
+		t.Errorf("interrupted job = %+v, want failed", job)
+	}
+	if _, created, _ := restarted.Enqueue([]string{"queued"}); created {
+		t.Error("queued job should still deduplicate after restart")
+	}
+	restarted.runNext()
+	if job, _ := restarted.Get(queued.ID); job.State != JobStateSucceeded {
+		t.Errorf("queued job = %+v, want run after restart", job)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newJobQueue(path, runner.run); err == nil {
+		t.Error("Expected an error for a corrupt job file")
+	}
+}
+
+func TestJobQueuePrunesHistory(t *testing.T) {
+	q, err := newJobQueue(filepath.Join(t.TempDir(), JobsFileName), (&fakeJobRunner{}).run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range maxFinishedJobs + 5 {
+		if _, _, err := q.Enqueue(nil); err != nil {
+			t.Fatal(err)
+		}
+		q.runNext()
+	}
+	if n := len(q.List()); n != maxFinishedJobs {
+		t.Errorf("kept %d jobs, want %d", n, maxFinishedJobs)
+	}
+}
+
+func TestJobHandlers(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	dir := t.TempDir()
+	reclonePath := filepath.Join(dir, "reclone.yaml")
+	if err := os.WriteFile(reclonePath, []byte("work:\n  cmd: ghorg clone work\npersonal:\n  cmd: ghorg clone me\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GHORG_RECLONE_PATH", reclonePath)
+
+	q, err := newJobQueue(getGhorgJobsFilePath(), (&fakeJobRunner{}).run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(q.path) != dir {
+		t.Errorf("job file %s should default to the reclone.yaml directory", q.path)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/trigger/reclone", q.handleTrigger)
+	mux.HandleFunc("POST /jobs", q.handleCreate)
+	mux.HandleFunc("GET /jobs", q.handleList)
+	mux.HandleFunc("GET /jobs/{id}", q.handleGet)
+
+	do := func(method, target, body string) (*httptest.ResponseRecorder, Job) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var job Job
+		if rec.Code < http.StatusMultipleChoices {
+			if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+				t.Fatalf("%s %s: invalid JSON %q", method, target, rec.Body.String())
+			}
+		}
+		return rec, job
+	}
+
+	rec, job := do(http.MethodPost, "/jobs", `{"keys": ["work"]}`)
+	if rec.Code != http.StatusAccepted || job.State != JobStateQueued || rec.Header().Get("Location") != "/jobs/"+job.ID {
+		t.Fatalf("POST /jobs = %d %+v, Location %q", rec.Code, job, rec.Header().Get("Location"))
+	}
+	if rec, dup := do(http.MethodPost, "/jobs?key=work", ""); rec.Code != http.StatusOK || dup.ID != job.ID {
+		t.Errorf("duplicate POST /jobs = %d %s, want 200 %s", rec.Code, dup.ID, job.ID)
+	}
+	if rec, trig := do(http.MethodGet, "/trigger/reclone?cmd=work", ""); rec.Code != http.StatusOK || trig.ID != job.ID {
+		t.Errorf("trigger = %d %s, want 200 with the queued job", rec.Code, trig.ID)
+	}
+	if rec, _ := do(http.MethodPost, "/jobs", `{"keys": ["missing"]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /jobs with an unknown key = %d, want 404", rec.Code)
+	}
+	if rec, _ := do(http.MethodPost, "/jobs", `{"keys":`); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /jobs with invalid JSON = %d, want 400", rec.Code)
+	}
+
+	q.runNext()
+	rec, got := do(http.MethodGet, "/jobs/"+job.ID, "")
+	if rec.Code != http.StatusOK || got.State != JobStateSucceeded || got.Summary == nil {
+		t.Errorf("GET /jobs/{id} = %d %+v", rec.Code, got)
+	}
+	if rec, _ := do(http.MethodGet, "/jobs/nope", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET unknown job = %d, want 404", rec.Code)
+	}
+
+	do(http.MethodPost, "/jobs", "")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs?state=queued", nil))
+	var list []Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].State != JobStateQueued || list[0].Keys != nil {
+		t.Errorf("GET /jobs?state=queued = %+v, want the queued job for every entry", list)
+	}
+}
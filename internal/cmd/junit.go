@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// JUnit XML report written by --junit-report. Each processed repo is a test
+// case and each clone run (or reclone entry) is a test suite.
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// junitRunCase is the name of the test case holding errors that cannot be
+// attributed to a single repo, e.g. prune failures.
+const junitRunCase = "ghorg clone"
+
+// newJUnitSuite builds the test suite for one clone run from the outcome of
+// every processed repo and the run stats. Errors become failures, infos are
+// attached as system-out and skipped or protected repos are skipped.
+func newJUnitSuite(name string, startedAt time.Time, outcomes []RepoEvent, stats CloneStats) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      name,
+		Time:      float64(stats.TotalDurationSeconds),
+		Timestamp: startedAt.UTC().Format(time.RFC3339),
+	}
+
+	usedErrors := make([]bool, len(stats.CloneErrors))
+	usedInfos := make([]bool, len(stats.CloneInfos))
+
+	for _, ev := range outcomes {
+		tc := junitTestCase{
+			Name:      ev.Name,
+			ClassName: name,
+			Time:      ev.DurationSeconds,
+		}
+
+		errs := messagesFor(ev.URL, stats.CloneErrors, usedErrors)
+		infos := messagesFor(ev.URL, stats.CloneInfos, usedInfos)
+		tc.SystemOut = strings.Join(infos, "\n")
+
+		switch {
+		case ev.Event == RepoEventSkipped || ev.Event == RepoEventProtected:
+			tc.Skipped = &junitSkipped{Message: fmt.Sprintf("%s: %s", ev.Event, ev.Message)}
+		case len(errs) > 0:
+			tc.Failure = &junitFailure{Message: errs[0], Type: "CloneError", Text: strings.Join(errs, "\n")}
+		case ev.Event == RepoEventError:
+			// Failures reported as infos, e.g. empty repos or wikis without content.
+			tc.Failure = &junitFailure{Message: ev.Message, Type: "CloneError", Text: ev.Message}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	var unmatched []string
+	for i, msg := range stats.CloneErrors {
+		if !usedErrors[i] {
+			unmatched = append(unmatched, msg)
+		}
+	}
+	if len(unmatched) > 0 {
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			Name:      junitRunCase,
+			ClassName: name,
+			Failure:   &junitFailure{Message: unmatched[0], Type: "CloneError", Text: strings.Join(unmatched, "\n")},
+		})
+	}
+
+	var runInfos []string
+	for i, msg := range stats.CloneInfos {
+		if !usedInfos[i] {
+			runInfos = append(runInfos, msg)
+		}
+	}
+	suite.SystemOut = strings.Join(runInfos, "\n")
+
+	suite.count()
+	return suite
+}
+
+// newJUnitFailedSuite returns a suite with a single failing test case, used
+// when a clone run ended before it could write its own report.
+func newJUnitFailedSuite(name string, startedAt time.Time, duration time.Duration, err error) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      name,
+		Time:      duration.Seconds(),
+		Timestamp: startedAt.UTC().Format(time.RFC3339),
+		TestCases: []junitTestCase{{
+			Name:      junitRunCase,
+			ClassName: name,
+			Time:      duration.Seconds(),
+			Failure:   &junitFailure{Message: err.Error(), Type: "RunError", Text: err.Error()},
+		}},
+	}
+	suite.count()
+	return suite
+}
+
+// messagesFor returns the messages that mention url and marks them used.
+func messagesFor(url string, messages []string, used []bool) []string {
+	if url == "" {
+		return nil
+	}
+	var out []string
+	for i, msg := range messages {
+		if strings.Contains(msg, url) {
+			out = append(out, msg)
+			used[i] = true
+		}
+	}
+	return out
+}
+
+func (s *junitTestSuite) count() {
+	s.Tests, s.Failures, s.Skipped = len(s.TestCases), 0, 0
+	for _, tc := range s.TestCases {
+		if tc.Failure != nil {
+			s.Failures++
+		}
+		if tc.Skipped != nil {
+			s.Skipped++
+		}
+	}
+}
+
+// writeJUnitReport writes suites to path as a <testsuites> document.
+func writeJUnitReport(path string, suites []junitTestSuite) error {
+	doc := junitTestSuites{Name: "ghorg", Suites: suites}
+	for _, s := range suites {
+		doc.Tests += s.Tests
+		doc.Failures += s.Failures
+		doc.Skipped += s.Skipped
+		doc.Time += s.Time
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal junit report: %w", err)
+	}
+	return writeFileAtomic(path, append([]byte(xml.Header), append(data, '\n')...))
+}
+
+// readJUnitReport returns the suites of a report written by writeJUnitReport.
+func readJUnitReport(path string) ([]junitTestSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc junitTestSuites
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse junit report %s: %w", path, err)
+	}
+	return doc.Suites, nil
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+func TestNewJUnitSuite(t *testing.T) {
+	outcomes := []RepoEvent{
+		{Event: RepoEventCloned, Name: "api", URL: "https://example.com/org/api", DurationSeconds: 1.5},
+		{Event: RepoEventError, Name: "broken", URL: "https://example.com/org/broken"},
+		{Event: RepoEventSkipped, Name: "old", URL: "https://example.com/org/old", Message: "archived"},
+		{Event: RepoEventProtected, Name: "dirty", URL: "https://example.com/org/dirty", Message: "uncommitted changes"},
+		{Event: RepoEventError, Name: "empty", URL: "https://example.com/org/empty", Message: "repo is empty"},
+	}
+	stats := CloneStats{
+		TotalDurationSeconds: 42,
+		CloneErrors: []string{
+			"Problem trying to clone Repo: https://example.com/org/broken Error: boom",
+			"Problem pruning /tmp/org/gone",
+		},
+		CloneInfos: []string{
+			"Could not fetch remotes in Repo: https://example.com/org/api",
+			"Skipping prune, nothing to do",
+		},
+	}
+
+	suite := newJUnitSuite("org", time.Now(), outcomes, stats)
+
+	if suite.Name != "org" || suite.Time != 42 {
+		t.Errorf("suite = %s/%v, want org/42", suite.Name, suite.Time)
+	}
+	if suite.Tests != 6 || suite.Failures != 3 || suite.Skipped != 2 {
+		t.Errorf("counts = %d tests, %d failures, %d skipped; want 6, 3, 2", suite.Tests, suite.Failures, suite.Skipped)
+	}
+
+	cases := make(map[string]junitTestCase)
+	for _, tc := range suite.TestCases {
+		cases[tc.Name] = tc
+	}
+	if tc := cases["api"]; tc.Failure != nil || !strings.Contains(tc.SystemOut, "Could not fetch") || tc.Time != 1.5 {
+		t.Errorf("api case = %+v, want passing with info in system-out", tc)
+	}
+	if tc := cases["broken"]; tc.Failure == nil || !strings.Contains(tc.Failure.Message, "boom") {
+		t.Errorf("broken case = %+v, want failure from CloneErrors", tc)
+	}
+	if tc := cases["empty"]; tc.Failure == nil || tc.Failure.Message != "repo is empty" {
+		t.Errorf("empty case = %+v, want failure from event message", tc)
+	}
+	if tc := cases["old"]; tc.Skipped == nil {
+		t.Errorf("old case = %+v, want skipped", tc)
+	}
+	if tc := cases["dirty"]; tc.Skipped == nil || !strings.Contains(tc.Skipped.Message, "protected") {
+		t.Errorf("dirty case = %+v, want skipped as protected", tc)
+	}
+	if tc := cases[junitRunCase]; tc.Failure == nil || !strings.Contains(tc.Failure.Message, "pruning") {
+		t.Errorf("run case = %+v, want failure for unattributed errors", tc)
+	}
+	if suite.SystemOut != "Skipping prune, nothing to do" {
+		t.Errorf("suite system-out = %q, want unattributed infos", suite.SystemOut)
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	suites := []junitTestSuite{
+		newJUnitFailedSuite("a", time.Now(), time.Second, errors.New("exit status 1")),
+		newJUnitSuite("b", time.Now(), []RepoEvent{{Event: RepoEventPulled, Name: "api"}}, CloneStats{TotalDurationSeconds: 3}),
+	}
+	if err := writeJUnitReport(path, suites); err != nil {
+		t.Fatalf("writeJUnitReport failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc junitTestSuites
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, data)
+	}
+	if doc.Tests != 2 || doc.Failures != 1 || doc.Time != 4 || len(doc.Suites) != 2 {
+		t.Errorf("testsuites = %+v, want 2 tests, 1 failure, 4s over 2 suites", doc)
+	}
+
+	read, err := readJUnitReport(path)
+	if err != nil || len(read) != 2 || read[1].TestCases[0].Name != "api" {
+		t.Errorf("readJUnitReport = %+v, %v", read, err)
+	}
+}
+
+func TestRecloneJUnitAddEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reclone.xml")
+	junit := &recloneJUnit{path: path}
+
+	// An entry whose clone run wrote its own report.
+	child := newJUnitSuite("my-org", time.Now(), []RepoEvent{{Event: RepoEventCloned, Name: "api"}}, CloneStats{})
+	if err := writeJUnitReport(junit.entryPath("work"), []junitTestSuite{child}); err != nil {
+		t.Fatal(err)
+	}
+	junit.addEntry("work", time.Now(), nil)
+
+	// An entry whose clone run died before writing one.
+	junit.addEntry("personal", time.Now(), errors.New("exit status 2"))
+
+	suites, err := readJUnitReport(path)
+	if err != nil {
+		t.Fatalf("readJUnitReport failed: %v", err)
+	}
+	if len(suites) != 2 {
+		t.Fatalf("Expected 2 suites, got %d", len(suites))
+	}
+	if suites[0].Name != "work" || suites[0].TestCases[0].ClassName != "work" || suites[0].Failures != 0 {
+		t.Errorf("first suite = %+v, want passing suite renamed to work", suites[0])
+	}
+	if suites[1].Name != "personal" || suites[1].Failures != 1 || suites[1].TestCases[0].Failure.Message != "exit status 2" {
+		t.Errorf("second suite = %+v, want single failure", suites[1])
+	}
+	if _, err := os.Stat(junit.entryPath("work")); !os.IsNotExist(err) {
+		t.Errorf("Expected entry report to be removed, stat err = %v", err)
+	}
+
+	// A nil collector is a no-op.
+	var none *recloneJUnit
+	none.addEntry("work", time.Now(), nil)
+}
+
+func TestRunReporterCollectOnly(t *testing.T) {
+	var out bytes.Buffer
+	reporter := &runReporter{out: &out}
+	reporter.Emit(repoEvent(RepoEventQueued, scm.Repo{Name: "api"}))
+	reporter.Emit(repoEvent(RepoEventCloned, scm.Repo{Name: "api"}))
+
+	if err := reporter.Finish(RunReport{}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing written without a format, got %q", out.String())
+	}
+	if outcomes := reporter.Outcomes(); len(outcomes) != 1 || outcomes[0].Event != RepoEventCloned {
+		t.Errorf("Outcomes = %+v, want the cloned outcome", outcomes)
+	}
+}
+
+func TestRecloneResultsAdd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reclone.json")
+	results := &recloneResults{path: path}
+
+	results.add(RecloneResult{Key: "work", Status: RecloneStatusSuccess, Report: &RunReport{Target: "my-org"}})
+	results.add(RecloneResult{Key: "personal", Status: RecloneStatusFail, Error: "exit status 2"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []RecloneResult
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid results file: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(got))
+	}
+	if got[0].Key != "work" || got[0].Report == nil || got[0].Report.Target != "my-org" {
+		t.Errorf("first result = %+v, want work with its run report", got[0])
+	}
+	if got[1].Key != "personal" || got[1].Status != RecloneStatusFail || got[1].Report != nil {
+		t.Errorf("second result = %+v, want failure without a report", got[1])
+	}
+
+	var none *recloneResults
+	none.add(RecloneResult{Key: "work"})
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// localRepo is a git repository found on disk below a ghorg directory.
+type localRepo struct {
+	// Name is the path of the repository relative to the directory that was walked.
+	Name string
+	// Path is the absolute path of the repository.
+	Path string
+}
+
+// LocalFilterFlags are the repo selection flags shared by commands that
+// operate on existing clones. They map to the same environment variables as
+// the clone flags so RepositoryFilter picks them up.
+type LocalFilterFlags struct {
+	MatchPrefix        string `long:"match-prefix" description:"GHORG_MATCH_PREFIX - Only include repos with matching prefix, can be a comma separated list"`
+	ExcludeMatchPrefix string `long:"exclude-match-prefix" description:"GHORG_EXCLUDE_MATCH_PREFIX - Exclude repos with matching prefix, can be a comma separated list"`
+	MatchRegex         string `long:"match-regex" description:"GHORG_MATCH_REGEX - Only include repos whose name matches the regex provided"`
+	ExcludeMatchRegex  string `long:"exclude-match-regex" description:"GHORG_EXCLUDE_MATCH_REGEX - Exclude repos whose name matches the regex provided"`
+	GhorgIgnorePath    string `long:"ghorgignore-path" description:"GHORG_IGNORE_PATH - If you want to set a path other than $HOME/.config/ghorg/ghorgignore for your ghorgignore"`
+	GhorgOnlyPath      string `long:"ghorgonly-path" description:"GHORG_ONLY_PATH - If you want to set a path other than $HOME/.config/ghorg/ghorgonly for your ghorgonly"`
+	TargetReposPath    string `long:"target-repos-path" description:"GHORG_TARGET_REPOS_PATH - Path to file with list of repo names to include, one repo name per line"`
+}
+
+// apply sets the environment variables read by RepositoryFilter.
+func (f LocalFilterFlags) apply() {
+	mappings := map[string]string{
+		"GHORG_MATCH_PREFIX":         f.MatchPrefix,
+		"GHORG_EXCLUDE_MATCH_PREFIX": f.ExcludeMatchPrefix,
+		"GHORG_MATCH_REGEX":          f.MatchRegex,
+		"GHORG_EXCLUDE_MATCH_REGEX":  f.ExcludeMatchRegex,
+		"GHORG_IGNORE_PATH":          f.GhorgIgnorePath,
+		"GHORG_ONLY_PATH":            f.GhorgOnlyPath,
+		"GHORG_TARGET_REPOS_PATH":    f.TargetReposPath,
+	}
+	for envVar, value := range mappings {
+		if value != "" {
+			os.Setenv(envVar, value)
+		}
+	}
+}
+
+// resolveGhorgDir returns the directory a local command should operate on.
+// With no argument it is GHORG_ABSOLUTE_PATH_TO_CLONE_TO. An argument may be an
+// existing path or the name of a clone directory inside the ghorg home, using
+// the same dash to underscore fallback as ghorg ls.
+func resolveGhorgDir(arg string) (string, error) {
+	home := os.Getenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO")
+	if arg == "" {
+		if home == "" {
+			return "", fmt.Errorf("GHORG_ABSOLUTE_PATH_TO_CLONE_TO is not set")
+		}
+		return filepath.Clean(home), nil
+	}
+
+	candidates := []string{
+		filepath.Join(home, arg),
+		filepath.Join(home, strings.ReplaceAll(arg, "-", "_")),
+	}
+	if filepath.IsAbs(arg) {
+		candidates = append([]string{arg}, candidates...)
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return filepath.Clean(candidate), nil
+		}
+	}
+	return "", fmt.Errorf("no clones found at %s", filepath.Join(home, arg))
+}
+
+// findLocalRepos walks root and returns every non-bare git repository below it,
+// sorted by name. Hidden directories are not descended into and nested
+// repositories (e.g. submodules) are not reported separately.
+func findLocalRepos(root string) ([]localRepo, error) {
+	var repos []localRepo
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if _, statErr := os.Stat(filepath.Join(path, ".git")); statErr != nil {
+			return nil
+		}
+
+		name, relErr := filepath.Rel(root, path)
+		if relErr != nil || name == "." {
+			name = filepath.Base(path)
+		}
+		repos = append(repos, localRepo{Name: filepath.ToSlash(name), Path: path})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+	return repos, nil
+}
+
+// scmRepo returns the minimal scm.Repo needed to run Gitter operations on r.
+func (r localRepo) scmRepo() scm.Repo {
+	return scm.Repo{Name: r.Name, HostPath: r.Path}
+}
+
+// filterLocalRepos applies the RepositoryFilter selection (regex, prefix,
+// target repos, ghorgonly and ghorgignore) to repos found on disk. Name based
+// filters match the repository directory name, ghorgonly and ghorgignore match
+// the origin URL.
+func filterLocalRepos(g git.Gitter, repos []localRepo) []localRepo {
+	byPath := make(map[string]localRepo, len(repos))
+	targets := make([]scm.Repo, 0, len(repos))
+	for _, r := range repos {
+		repo := scm.Repo{
+			Name:     filepath.Base(r.Path),
+			Path:     r.Name,
+			HostPath: r.Path,
+			URL:      r.Path,
+		}
+		if remote, err := g.GetRemoteURL(repo, "origin"); err == nil && remote != "" {
+			repo.URL = remote
+		}
+		byPath[r.Path] = r
+		targets = append(targets, repo)
+	}
+
+	targets = NewRepositoryFilter().ApplyAllFilters(targets)
+
+	filtered := make([]localRepo, 0, len(targets))
+	for _, repo := range targets {
+		filtered = append(filtered, byPath[repo.HostPath])
+	}
+	return filtered
+}
+
+// clonedBranches returns the branch ghorg last checked out for each repo,
+// keyed by host path, as recorded in the state manifests found in dirs.
+func clonedBranches(dirs ...string) map[string]string {
+	branches := make(map[string]string)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		state, err := LoadState(filepath.Join(dir, StateFileName), "", "")
+		if err != nil {
+			continue
+		}
+		for _, entry := range state.Repos {
+			if entry.HostPath != "" && entry.LastBranch != "" {
+				branches[filepath.Clean(entry.HostPath)] = entry.LastBranch
+			}
+		}
+	}
+	return branches
+}
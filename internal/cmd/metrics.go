@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// recloneMetrics aggregates the results of reclone runs triggered by the
+// reclone server and exposes them in the Prometheus text format on /metrics.
+// Everything is fed from RecloneResult values, never from log output.
+type recloneMetrics struct {
+	mu sync.Mutex
+
+	running  bool
+	triggers map[string]float64 // by result: queued or deduplicated
+
+	runs             map[[2]string]float64 // by key and status
+	durationSum      map[string]float64
+	durationCount    map[string]float64
+	lastDuration     map[string]float64
+	lastRun          map[string]float64
+	lastSuccess      map[string]float64
+	reposCloned      map[string]float64
+	reposPulled      map[string]float64
+	newCommits       map[string]float64
+	repoErrors       map[[2]string]float64 // by key and repo URL
+	apiRequests      map[string]float64
+	apiErrors        map[string]float64
+	apiSeconds       map[string]float64
+	apiRateRemaining map[string]float64
+}
+
+func newRecloneMetrics() *recloneMetrics {
+	return &recloneMetrics{
+		triggers:         map[string]float64{},
+		runs:             map[[2]string]float64{},
+		durationSum:      map[string]float64{},
+		durationCount:    map[string]float64{},
+		lastDuration:     map[string]float64{},
+		lastRun:          map[string]float64{},
+		lastSuccess:      map[string]float64{},
+		reposCloned:      map[string]float64{},
+		reposPulled:      map[string]float64{},
+		newCommits:       map[string]float64{},
+		repoErrors:       map[[2]string]float64{},
+		apiRequests:      map[string]float64{},
+		apiErrors:        map[string]float64{},
+		apiSeconds:       map[string]float64{},
+		apiRateRemaining: map[string]float64{},
+	}
+}
+
+// Trigger counts a reclone request that queued a new job, or was
+// deduplicated with a job already queued.
+func (m *recloneMetrics) Trigger(queued bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if queued {
+		m.triggers["queued"]++
+	} else {
+		m.triggers["deduplicated"]++
+	}
+}
+
+// SetRunning records whether a reclone is in progress.
+func (m *recloneMetrics) SetRunning(running bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running = running
+}
+
+// Observe adds the results of one reclone run.
+func (m *recloneMetrics) Observe(results []RecloneResult) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, res := range results {
+		m.runs[[2]string{res.Key, res.Status}]++
+		m.durationSum[res.Key] += res.DurationSeconds
+		m.durationCount[res.Key]++
+		m.lastDuration[res.Key] = res.DurationSeconds
+		m.lastRun[res.Key] = unixSeconds(res)
+		if res.Status == RecloneStatusSuccess {
+			m.lastSuccess[res.Key] = unixSeconds(res)
+		}
+
+		if res.Report == nil {
+			continue
+		}
+		m.reposCloned[res.Key] += float64(res.Report.Summary.CloneCount)
+		m.reposPulled[res.Key] += float64(res.Report.Summary.PulledCount)
+		m.newCommits[res.Key] += float64(res.Report.Summary.NewCommits)
+		for _, repo := range res.Report.Repos {
+			if repo.Event == RepoEventError {
+				m.repoErrors[[2]string{res.Key, repo.URL}]++
+			}
+		}
+		for _, api := range res.Report.API {
+			m.apiRequests[api.SCM] += float64(api.Requests)
+			m.apiErrors[api.SCM] += float64(api.Errors)
+			m.apiSeconds[api.SCM] += api.Seconds
+			if api.RateLimitRemaining != nil {
+				m.apiRateRemaining[api.SCM] = float64(*api.RateLimitRemaining)
+			}
+		}
+	}
+}
+
+func unixSeconds(res RecloneResult) float64 {
+	return float64(res.FinishedAt.UnixNano()) / 1e9
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format.
+func (m *recloneMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sb strings.Builder
+	running := 0.0
+	if m.running {
+		running = 1
+	}
+	writeMetric(&sb, "ghorg_reclone_running", "gauge", "Whether a reclone triggered by the server is in progress.", nil, map[string]float64{"": running})
+	writeMetric(&sb, "ghorg_reclone_triggers_total", "counter", "Reclone requests by result, queued as a new job or deduplicated.", []string{"result"}, m.triggers)
+	writeMetric(&sb, "ghorg_reclone_runs_total", "counter", "Reclone entry runs by key and status.", []string{"key", "status"}, pairSeries(m.runs))
+	writeMetric(&sb, "ghorg_reclone_run_duration_seconds", "summary", "Duration of reclone entry runs.", []string{"key"}, nil)
+	writeSeries(&sb, "ghorg_reclone_run_duration_seconds_sum", []string{"key"}, m.durationSum)
+	writeSeries(&sb, "ghorg_reclone_run_duration_seconds_count", []string{"key"}, m.durationCount)
+	writeMetric(&sb, "ghorg_reclone_last_run_duration_seconds", "gauge", "Duration of the last run of a reclone entry.", []string{"key"}, m.lastDuration)
+	writeMetric(&sb, "ghorg_reclone_last_run_timestamp_seconds", "gauge", "Unix time the last run of a reclone entry finished.", []string{"key"}, m.lastRun)
+	writeMetric(&sb, "ghorg_reclone_last_success_timestamp_seconds", "gauge", "Unix time the last successful run of a reclone entry finished.", []string{"key"}, m.lastSuccess)
+	writeMetric(&sb, "ghorg_reclone_repos_cloned_total", "counter", "Repos newly cloned by reclone entry.", []string{"key"}, m.reposCloned)
+	writeMetric(&sb, "ghorg_reclone_repos_pulled_total", "counter", "Existing repos pulled by reclone entry.", []string{"key"}, m.reposPulled)
+	writeMetric(&sb, "ghorg_reclone_new_commits_total", "counter", "New commits pulled by reclone entry.", []string{"key"}, m.newCommits)
+	writeMetric(&sb, "ghorg_reclone_repo_errors_total", "counter", "Repos that failed to clone or pull, by reclone entry and repo.", []string{"key", "repo"}, pairSeries(m.repoErrors))
+	writeMetric(&sb, "ghorg_scm_api_pagination_seconds", "summary", "Latency of paginated SCM API requests made while listing repos.", []string{"scm"}, nil)
+	writeSeries(&sb, "ghorg_scm_api_pagination_seconds_sum", []string{"scm"}, m.apiSeconds)
+	writeSeries(&sb, "ghorg_scm_api_pagination_seconds_count", []string{"scm"}, m.apiRequests)
+	writeMetric(&sb, "ghorg_scm_api_errors_total", "counter", "SCM API requests that failed.", []string{"scm"}, m.apiErrors)
+	writeMetric(&sb, "ghorg_scm_api_rate_limit_remaining", "gauge", "Remaining SCM API rate limit reported by the last request.", []string{"scm"}, m.apiRateRemaining)
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func (m *recloneMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// pairSeries flattens two label values into one series key.
+func pairSeries(m map[[2]string]float64) map[string]float64 {
+	series := make(map[string]float64, len(m))
+	for labels, v := range m {
+		series[labels[0]+"\x00"+labels[1]] = v
+	}
+	return series
+}
+
+// writeMetric writes the HELP and TYPE lines of name followed by its series.
+func writeMetric(sb *strings.Builder, name, kind, help string, labels []string, series map[string]float64) {
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	writeSeries(sb, name, labels, series)
+}
+
+// writeSeries writes one sample per series, sorted by label values. Series
+// keys hold the label values separated by NUL.
+func writeSeries(sb *strings.Builder, name string, labels []string, series map[string]float64) {
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		sb.WriteString(name)
+		if len(labels) > 0 {
+			values := strings.Split(k, "\x00")
+			pairs := make([]string, len(labels))
+			for i, label := range labels {
+				pairs[i] = fmt.Sprintf("%s=\"%s\"", label, escapeLabelValue(values[i]))
+			}
+			sb.WriteString("{" + strings.Join(pairs, ",") + "}")
+		}
+		fmt.Fprintf(sb, " %g\n", series[k])
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
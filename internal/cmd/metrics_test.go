@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+func TestRecloneMetrics(t *testing.T) {
+	remaining := 4321
+	finished := time.Unix(1767261600, 0)
+	m := newRecloneMetrics()
+	m.Trigger(true)
+	m.Trigger(false)
+	m.Observe([]RecloneResult{
+		{
+			Key: "work", Status: RecloneStatusSuccess, FinishedAt: finished, DurationSeconds: 12.5,
+			Report: &RunReport{
+				Summary: CloneStats{CloneCount: 2, PulledCount: 8, NewCommits: 30},
+				Repos: []RepoEvent{
+					{Event: RepoEventCloned, URL: "https://github.com/org/api"},
+					{Event: RepoEventError, URL: `https://github.com/org/"quoted"`},
+				},
+				API: []scm.APIStat{{SCM: "github", Requests: 3, Seconds: 1.5, RateLimitRemaining: &remaining}},
+			},
+		},
+		{Key: "personal", Status: RecloneStatusFail, FinishedAt: finished, DurationSeconds: 1, Error: "exit status 1"},
+	})
+	m.Observe([]RecloneResult{{Key: "work", Status: RecloneStatusSuccess, FinishedAt: finished, DurationSeconds: 7.5}})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE ghorg_reclone_runs_total counter\n",
+		`ghorg_reclone_triggers_total{result="deduplicated"} 1`,
+		`ghorg_reclone_runs_total{key="work",status="success"} 2`,
+		`ghorg_reclone_runs_total{key="personal",status="fail"} 1`,
+		`ghorg_reclone_run_duration_seconds_sum{key="work"} 20`,
+		`ghorg_reclone_run_duration_seconds_count{key="work"} 2`,
+		`ghorg_reclone_last_run_duration_seconds{key="work"} 7.5`,
+		`ghorg_reclone_last_success_timestamp_seconds{key="work"} 1.7672616e+09`,
+		`ghorg_reclone_repos_cloned_total{key="work"} 2`,
+		`ghorg_reclone_repos_pulled_total{key="work"} 8`,
+		`ghorg_reclone_new_commits_total{key="work"} 30`,
+		`ghorg_reclone_repo_errors_total{key="work",repo="https://github.com/org/\"quoted\""} 1`,
+		`ghorg_scm_api_pagination_seconds_count{scm="github"} 3`,
+		`ghorg_scm_api_rate_limit_remaining{scm="github"} 4321`,
+		"ghorg_reclone_running 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `last_success_timestamp_seconds{key="personal"}`) {
+		t.Error("a failed entry should not set its last success timestamp")
+	}
+}
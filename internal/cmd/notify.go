@@ -0,0 +1,477 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+)
+
+// Conditions of GHORG_NOTIFY_ON.
+const (
+	NotifyOnAlways  = "always"
+	NotifyOnFailure = "failure"
+	NotifyOnChange  = "change"
+)
+
+const (
+	defaultSMTPPort = "587"
+	// notifyTimeout bounds the delivery to a single sink.
+	notifyTimeout = 30 * time.Second
+	// notifyResponseTail is how much of a failed response body is reported.
+	notifyResponseTail = 200
+)
+
+// defaultNotifyTemplate renders the text of a Notification when
+// GHORG_NOTIFY_TEMPLATE is not set.
+const defaultNotifyTemplate = `{{.Title}}
+Cloned: {{.Summary.CloneCount}}, Updated: {{.Summary.PulledCount}}, New commits: {{.Summary.NewCommits}}, Infos: {{len .Summary.CloneInfos}}, Errors: {{len .Errors}}
+{{- if .NewRepos}}
+
+New repos:
+{{- range .NewRepos}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- if .PrunedRepos}}
+
+Pruned repos:
+{{- range .PrunedRepos}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- if .Errors}}
+
+Errors:
+{{- range .Errors}}
+- {{.}}
+{{- end}}
+{{- end}}
+`
+
+// Notification is the run summary sent to every notification sink. The
+// generic webhook receives it as JSON; Text is the rendered template.
+type Notification struct {
+	Title       string     `json:"title"`
+	Text        string     `json:"text"`
+	Status      string     `json:"status"`
+	Command     string     `json:"command"`
+	Entry       string     `json:"entry,omitempty"`
+	SCM         string     `json:"scm,omitempty"`
+	Target      string     `json:"target,omitempty"`
+	OutputDir   string     `json:"output_dir,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  time.Time  `json:"finished_at"`
+	Summary     CloneStats `json:"summary"`
+	NewRepos    []string   `json:"new_repos"`
+	PrunedRepos []string   `json:"pruned_repos"`
+	Errors      []string   `json:"errors"`
+}
+
+// failed reports whether the run had errors.
+func (n Notification) failed() bool {
+	return n.Status == RecloneStatusFail
+}
+
+// changed reports whether repos appeared or were pruned in the run.
+func (n Notification) changed() bool {
+	return len(n.NewRepos) > 0 || len(n.PrunedRepos) > 0
+}
+
+// newRunNotification summarizes the clone run of report. command is clone or
+// reclone, entry the reclone.yaml entry that ran, if any.
+func newRunNotification(report *RunReport, command, entry string) Notification {
+	n := Notification{
+		Command:     command,
+		Entry:       entry,
+		Status:      RecloneStatusSuccess,
+		NewRepos:    []string{},
+		PrunedRepos: []string{},
+		Errors:      []string{},
+	}
+	if report != nil {
+		n.SCM = report.SCM
+		n.Target = report.Target
+		n.OutputDir = report.OutputDir
+		n.StartedAt = report.StartedAt
+		n.FinishedAt = report.FinishedAt
+		n.Summary = report.Summary
+		for _, ev := range report.Repos {
+			if ev.Event == RepoEventCloned {
+				n.NewRepos = append(n.NewRepos, ev.Name)
+			}
+		}
+		n.PrunedRepos = append(n.PrunedRepos, report.Pruned...)
+		n.Errors = append(n.Errors, report.Summary.CloneErrors...)
+	}
+	if len(n.Errors) > 0 {
+		n.Status = RecloneStatusFail
+	}
+
+	subject := n.Target
+	if entry != "" {
+		subject = entry
+	}
+	outcome := "succeeded"
+	if n.failed() {
+		outcome = "failed"
+	}
+	n.Title = fmt.Sprintf("ghorg %s %s %s", command, subject, outcome)
+	return n
+}
+
+// notifySink delivers a rendered Notification.
+type notifySink interface {
+	name() string
+	send(ctx context.Context, n Notification) error
+}
+
+// notifier sends run summaries to the sinks configured with GHORG_NOTIFY_*.
+type notifier struct {
+	sinks    []notifySink
+	on       []string
+	template *template.Template
+	client   *http.Client
+}
+
+// newNotifierFromEnv builds a notifier from the GHORG_NOTIFY_* env vars.
+// Returns nil and no error when no sink is configured.
+func newNotifierFromEnv() (*notifier, error) {
+	n := &notifier{client: &http.Client{Timeout: notifyTimeout}}
+	if webhookURL := os.Getenv("GHORG_NOTIFY_WEBHOOK_URL"); webhookURL != "" {
+		n.sinks = append(n.sinks, &webhookNotifySink{url: webhookURL, client: n.client})
+	}
+	if webhookURL := os.Getenv("GHORG_NOTIFY_SLACK_WEBHOOK_URL"); webhookURL != "" {
+		n.sinks = append(n.sinks, &slackNotifySink{url: webhookURL, client: n.client})
+	}
+	if webhookURL := os.Getenv("GHORG_NOTIFY_TEAMS_WEBHOOK_URL"); webhookURL != "" {
+		n.sinks = append(n.sinks, &teamsNotifySink{url: webhookURL, client: n.client})
+	}
+	if host := os.Getenv("GHORG_NOTIFY_SMTP_HOST"); host != "" {
+		sink, err := newSMTPNotifySinkFromEnv(host)
+		if err != nil {
+			return nil, err
+		}
+		n.sinks = append(n.sinks, sink)
+	}
+	if len(n.sinks) == 0 {
+		return nil, nil
+	}
+
+	on := os.Getenv("GHORG_NOTIFY_ON")
+	if on == "" {
+		on = NotifyOnAlways
+	}
+	for condition := range strings.SplitSeq(on, ",") {
+		condition = strings.TrimSpace(condition)
+		if condition != NotifyOnAlways && condition != NotifyOnFailure && condition != NotifyOnChange {
+			return nil, fmt.Errorf("invalid GHORG_NOTIFY_ON %q, expected a comma separated list of %s, %s and %s", on, NotifyOnAlways, NotifyOnFailure, NotifyOnChange)
+		}
+		n.on = append(n.on, condition)
+	}
+
+	text := os.Getenv("GHORG_NOTIFY_TEMPLATE")
+	if path := os.Getenv("GHORG_NOTIFY_TEMPLATE_FILE"); path != "" {
+		if text != "" {
+			return nil, errors.New("set only one of GHORG_NOTIFY_TEMPLATE and GHORG_NOTIFY_TEMPLATE_FILE")
+		}
+		expanded, err := homedir.Expand(path)
+		if err != nil {
+			return nil, fmt.Errorf("GHORG_NOTIFY_TEMPLATE_FILE: %w", err)
+		}
+		data, err := os.ReadFile(expanded)
+		if err != nil {
+			return nil, fmt.Errorf("GHORG_NOTIFY_TEMPLATE_FILE: %w", err)
+		}
+		text = string(data)
+	}
+	if text == "" {
+		text = defaultNotifyTemplate
+	}
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification template: %w", err)
+	}
+	n.template = tmpl
+	return n, nil
+}
+
+// shouldSend reports whether note meets one of the GHORG_NOTIFY_ON conditions.
+func (n *notifier) shouldSend(note Notification) bool {
+	return slices.Contains(n.on, NotifyOnAlways) ||
+		(slices.Contains(n.on, NotifyOnFailure) && note.failed()) ||
+		(slices.Contains(n.on, NotifyOnChange) && note.changed())
+}
+
+// Send renders the text of note and delivers it to every sink, if note meets
+// the configured conditions. A failing sink does not stop the others.
+func (n *notifier) Send(note Notification) error {
+	if n == nil || !n.shouldSend(note) {
+		return nil
+	}
+
+	var text strings.Builder
+	if err := n.template.Execute(&text, note); err != nil {
+		return fmt.Errorf("could not render notification: %w", err)
+	}
+	note.Text = strings.TrimSpace(text.String())
+
+	var errs []error
+	for _, sink := range n.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		if err := sink.send(ctx, note); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.name(), err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// sendNotification sends note with the sinks configured in the environment,
+// printing any problem. Notifications never change the outcome of a run.
+func sendNotification(note Notification) {
+	n, err := newNotifierFromEnv()
+	if err == nil {
+		err = n.Send(note)
+	}
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Could not send notification: %v", err))
+	}
+}
+
+// notifyCloneRun sends the summary of a ghorg clone run. Runs of reclone
+// entries are left to reclone, which notifies once per entry.
+func notifyCloneRun(report *RunReport) {
+	if os.Getenv("GHORG_RECLONE_RUNNING") == "true" {
+		return
+	}
+	sendNotification(newRunNotification(report, "clone", ""))
+}
+
+// notifyReclone sends the summary of a reclone entry, including entries that
+// failed before their clone could run.
+func notifyReclone(result RecloneResult) {
+	note := newRunNotification(result.Report, "reclone", result.Key)
+	if result.Status == RecloneStatusFail {
+		note.Status = RecloneStatusFail
+		note.Title = fmt.Sprintf("ghorg reclone %s failed", result.Key)
+		if result.Report == nil {
+			note.StartedAt, note.FinishedAt = result.StartedAt, result.FinishedAt
+		}
+		note.Errors = append(note.Errors, result.Error)
+	}
+	sendNotification(note)
+}
+
+// postNotifyJSON posts payload as JSON to url and fails on a non-2xx status.
+func postNotifyJSON(ctx context.Context, client *http.Client, endpoint string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ghorg/"+GetVersion())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// the URL may hold a token, only report what went wrong
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, notifyResponseTail))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// webhookNotifySink posts the Notification as JSON.
+type webhookNotifySink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookNotifySink) name() string { return "webhook" }
+
+func (s *webhookNotifySink) send(ctx context.Context, n Notification) error {
+	return postNotifyJSON(ctx, s.client, s.url, n)
+}
+
+// slackNotifySink posts to a Slack incoming webhook, or any service that
+// accepts Slack's {"text": ...} payload such as Mattermost or Rocket.Chat.
+type slackNotifySink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slackNotifySink) name() string { return "slack" }
+
+func (s *slackNotifySink) send(ctx context.Context, n Notification) error {
+	return postNotifyJSON(ctx, s.client, s.url, map[string]string{"text": n.Text})
+}
+
+// teamsNotifySink posts an Adaptive Card to a Microsoft Teams workflow
+// webhook.
+type teamsNotifySink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *teamsNotifySink) name() string { return "teams" }
+
+func (s *teamsNotifySink) send(ctx context.Context, n Notification) error {
+	title, text, _ := strings.Cut(n.Text, "\n")
+	color := "Good"
+	if n.failed() {
+		color = "Attention"
+	}
+	card := map[string]any{
+		"type":    "AdaptiveCard",
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"version": "1.4",
+		"body": []map[string]any{
+			{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+			{"type": "TextBlock", "text": strings.TrimSpace(text), "wrap": true},
+		},
+	}
+	return postNotifyJSON(ctx, s.client, s.url, map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{
+			{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	})
+}
+
+// smtpNotifySink emails the Notification as plain text, using STARTTLS when
+// the server offers it and implicit TLS on port 465.
+type smtpNotifySink struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// newSMTPNotifySinkFromEnv builds the SMTP sink for host from the
+// GHORG_NOTIFY_SMTP_* env vars.
+func newSMTPNotifySinkFromEnv(host string) (*smtpNotifySink, error) {
+	s := &smtpNotifySink{
+		host:     host,
+		port:     os.Getenv("GHORG_NOTIFY_SMTP_PORT"),
+		username: os.Getenv("GHORG_NOTIFY_SMTP_USERNAME"),
+		password: os.Getenv("GHORG_NOTIFY_SMTP_PASSWORD"),
+		from:     os.Getenv("GHORG_NOTIFY_SMTP_FROM"),
+	}
+	if s.port == "" {
+		s.port = defaultSMTPPort
+	}
+	if _, err := strconv.Atoi(s.port); err != nil {
+		return nil, fmt.Errorf("invalid GHORG_NOTIFY_SMTP_PORT: %s", s.port)
+	}
+	for to := range strings.SplitSeq(os.Getenv("GHORG_NOTIFY_SMTP_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			s.to = append(s.to, to)
+		}
+	}
+	if s.from == "" || len(s.to) == 0 {
+		return nil, errors.New("GHORG_NOTIFY_SMTP_FROM and GHORG_NOTIFY_SMTP_TO are required with GHORG_NOTIFY_SMTP_HOST")
+	}
+	return s, nil
+}
+
+func (s *smtpNotifySink) name() string { return "smtp" }
+
+func (s *smtpNotifySink) send(ctx context.Context, n Notification) error {
+	addr := net.JoinHostPort(s.host, s.port)
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if s.port == "465" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && s.port != "465" {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(n)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message returns the RFC 5322 message for n.
+func (s *smtpNotifySink) message(n Notification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(n.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(n.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
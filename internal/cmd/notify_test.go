@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStandIn is a minimal SMTP server that accepts every message and sends
+// the DATA of each one to messages.
+type smtpStandIn struct {
+	addr     string
+	messages chan string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpStandIn{addr: ln.Addr().String(), messages: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.Fields(line + " x")[0]); verb {
+		case "EHLO", "HELO", "MAIL", "RCPT":
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.messages <- data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func testRunReport() *RunReport {
+	return &RunReport{
+		SCM:       "github",
+		Target:    "org",
+		OutputDir: "/tmp/org",
+		Summary:   CloneStats{CloneCount: 1, PulledCount: 2, CloneErrors: []string{"Problem cloning broken"}, CloneInfos: []string{}},
+		Repos: []RepoEvent{
+			{Event: RepoEventCloned, Name: "new-repo"},
+			{Event: RepoEventPulled, Name: "old-repo"},
+			{Event: RepoEventError, Name: "broken"},
+		},
+		Pruned: []string{"gone"},
+	}
+}
+
+func TestNewRunNotification(t *testing.T) {
+	n := newRunNotification(testRunReport(), "clone", "")
+	if n.Title != "ghorg clone org failed" || n.Status != RecloneStatusFail {
+		t.Errorf("title = %q, status = %q", n.Title, n.Status)
+	}
+	if strings.Join(n.NewRepos, ",") != "new-repo" || strings.Join(n.PrunedRepos, ",") != "gone" || len(n.Errors) != 1 {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+
+	n = newRunNotification(&RunReport{Target: "org"}, "reclone", "nightly")
+	if n.Title != "ghorg reclone nightly succeeded" || n.failed() || n.changed() {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+}
+
+func TestNewNotifierFromEnv(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	n, err := newNotifierFromEnv()
+	if err != nil || n != nil {
+		t.Fatalf("newNotifierFromEnv() = %v, %v; want nil without sinks", n, err)
+	}
+	if err := n.Send(Notification{}); err != nil {
+		t.Errorf("Send on nil notifier: %v", err)
+	}
+
+	os.Setenv("GHORG_NOTIFY_WEBHOOK_URL", "http://127.0.0.1:1/hook")
+	tests := []struct {
+		env, value, wantErr string
+	}{
+		{"GHORG_NOTIFY_ON", "sometimes", "invalid GHORG_NOTIFY_ON"},
+		{"GHORG_NOTIFY_TEMPLATE", "{{.Title", "invalid notification template"},
+		{"GHORG_NOTIFY_TEMPLATE_FILE", filepath.Join(t.TempDir(), "missing.tmpl"), "GHORG_NOTIFY_TEMPLATE_FILE"},
+		{"GHORG_NOTIFY_SMTP_HOST", "localhost", "GHORG_NOTIFY_SMTP_FROM and GHORG_NOTIFY_SMTP_TO are required"},
+	}
+	for _, tt := range tests {
+		os.Setenv(tt.env, tt.value)
+		if _, err := newNotifierFromEnv(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s=%s: expected error containing %q, got %v", tt.env, tt.value, tt.wantErr, err)
+		}
+		os.Unsetenv(tt.env)
+	}
+}
+
+func TestNotifier_Conditions(t *testing.T) {
+	failed := Notification{Status: RecloneStatusFail}
+	changed := Notification{Status: RecloneStatusSuccess, NewRepos: []string{"a"}}
+	quiet := Notification{Status: RecloneStatusSuccess}
+
+	tests := []struct {
+		on   []string
+		want [3]bool
+	}{
+		{[]string{NotifyOnAlways}, [3]bool{true, true, true}},
+		{[]string{NotifyOnFailure}, [3]bool{true, false, false}},
+		{[]string{NotifyOnChange}, [3]bool{false, true, false}},
+		{[]string{NotifyOnFailure, NotifyOnChange}, [3]bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		n := &notifier{on: tt.on}
+		got := [3]bool{n.shouldSend(failed), n.shouldSend(changed), n.shouldSend(quiet)}
+		if got != tt.want {
+			t.Errorf("on %v: shouldSend(failed, changed, quiet) = %v, want %v", tt.on, got, tt.want)
+		}
+	}
+}
+
+func TestNotifier_Sinks(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	bodies := make(map[string]map[string]any)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s: Content-Type = %q", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+		}
+		bodies[r.URL.Path] = body
+	}))
+	defer server.Close()
+	smtpServer := newSMTPStandIn(t)
+	host, port, _ := net.SplitHostPort(smtpServer.addr)
+
+	os.Setenv("GHORG_NOTIFY_WEBHOOK_URL", server.URL+"/generic")
+	os.Setenv("GHORG_NOTIFY_SLACK_WEBHOOK_URL", server.URL+"/slack")
+	os.Setenv("GHORG_NOTIFY_TEAMS_WEBHOOK_URL", server.URL+"/teams")
+	os.Setenv("GHORG_NOTIFY_SMTP_HOST", host)
+	os.Setenv("GHORG_NOTIFY_SMTP_PORT", port)
+	os.Setenv("GHORG_NOTIFY_SMTP_FROM", "ghorg@example.com")
+	os.Setenv("GHORG_NOTIFY_SMTP_TO", "a@example.com, b@example.com")
+
+	n, err := newNotifierFromEnv()
+	if err != nil {
+		t.Fatalf("newNotifierFromEnv: %v", err)
+	}
+	if err := n.Send(newRunNotification(testRunReport(), "clone", "")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	generic := bodies["/generic"]
+	if generic["status"] != "fail" || generic["target"] != "org" || !strings.Contains(generic["text"].(string), "- new-repo") {
+		t.Errorf("generic webhook body = %v", generic)
+	}
+	if pruned, _ := generic["pruned_repos"].([]any); len(pruned) != 1 || pruned[0] != "gone" {
+		t.Errorf("pruned_repos = %v", generic["pruned_repos"])
+	}
+
+	slack := bodies["/slack"]
+	if text, _ := slack["text"].(string); !strings.HasPrefix(text, "ghorg clone org failed\n") || !strings.Contains(text, "Errors:\n- Problem cloning broken") {
+		t.Errorf("slack text = %q", slack["text"])
+	}
+
+	teams, _ := json.Marshal(bodies["/teams"])
+	if !strings.Contains(string(teams), "application/vnd.microsoft.card.adaptive") || !strings.Contains(string(teams), `"text":"ghorg clone org failed"`) {
+		t.Errorf("teams body = %s", teams)
+	}
+
+	select {
+	case msg := <-smtpServer.messages:
+		for _, want := range []string{"Subject: ghorg clone org failed\r\n", "To: a@example.com, b@example.com\r\n", "Pruned repos:\r\n- gone\r\n"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("email does not contain %q:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+	}
+}
+
+func TestNotifier_SinkErrors(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	os.Setenv("GHORG_NOTIFY_SLACK_WEBHOOK_URL", server.URL+"/services/s3cr3t")
+	os.Setenv("GHORG_NOTIFY_WEBHOOK_URL", "http://127.0.0.1:1/s3cr3t")
+	os.Setenv("GHORG_NOTIFY_TEMPLATE", "{{.Title}} with {{len .NewRepos}} new")
+
+	n, err := newNotifierFromEnv()
+	if err != nil {
+		t.Fatalf("newNotifierFromEnv: %v", err)
+	}
+	err = n.Send(Notification{Title: "t", Status: RecloneStatusSuccess})
+	if err == nil || !strings.Contains(err.Error(), "slack: unexpected status 403 Forbidden: invalid_token") || !strings.Contains(err.Error(), "webhook:") {
+		t.Errorf("expected errors of both sinks, got %v", err)
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("error leaks the webhook URL: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("slack sink called %d times, want 1", calls)
+	}
+}
+
+func TestNotifyReclone(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	received := make(chan Notification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		_ = json.NewDecoder(r.Body).Decode(&n)
+		received <- n
+	}))
+	defer server.Close()
+	os.Setenv("GHORG_NOTIFY_WEBHOOK_URL", server.URL)
+	os.Setenv("GHORG_NOTIFY_ON", NotifyOnFailure)
+
+	notifyReclone(finishReclone(RecloneResult{Key: "nightly", StartedAt: time.Now()}, nil))
+	notifyReclone(finishReclone(RecloneResult{Key: "nightly", StartedAt: time.Now()}, os.ErrNotExist))
+
+	select {
+	case n := <-received:
+		if n.Title != "ghorg reclone nightly failed" || n.Entry != "nightly" || len(n.Errors) != 1 || n.Errors[0] != os.ErrNotExist.Error() {
+			t.Errorf("unexpected notification: %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification for the failed entry")
+	}
+	select {
+	case n := <-received:
+		t.Errorf("unexpected second notification: %+v", n)
+	default:
+	}
+
+	// clones run by reclone leave notifying to reclone
+	os.Setenv("GHORG_NOTIFY_ON", NotifyOnAlways)
+	os.Setenv("GHORG_RECLONE_RUNNING", "true")
+	notifyCloneRun(testRunReport())
+	select {
+	case n := <-received:
+		t.Errorf("clone run by reclone notified: %+v", n)
+	default:
+	}
+}
@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// Categories of the local clones in a prune plan.
+const (
+	// PruneRemoteDeleted clones have no repo on the remote anymore.
+	PruneRemoteDeleted = "remote-deleted"
+	// PruneRenamed clones belong to a repo that was renamed or transferred,
+	// recognized by the provider ID in the state manifest.
+	PruneRenamed = "renamed"
+	// PruneFilteredOut clones belong to a repo that still exists but is
+	// excluded by the filters, e.g. --skip-archived or --match-regex.
+	PruneFilteredOut = "filtered-out"
+	// PruneUntouched clones have no local branches, changes or commits of
+	// their own, only listed with --prune-untouched.
+	PruneUntouched = "untouched"
+)
+
+// Actions of the items in a prune plan.
+const (
+	PruneActionPrune = "prune"
+	PruneActionMove  = "move"
+	PruneActionKeep  = "keep"
+)
+
+// PrunePlan is what ghorg prune would do to the clones of a target. It is
+// written by --plan-file and executed unchanged by --apply.
+type PrunePlan struct {
+	SCM       string    `json:"scm"`
+	Target    string    `json:"target"`
+	OutputDir string    `json:"output_dir"`
+	StateFile string    `json:"state_file,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// LocalClones is the number of clones found, for GHORG_PRUNE_MAX_PERCENT.
+	LocalClones int             `json:"local_clones"`
+	Items       []PrunePlanItem `json:"items"`
+}
+
+// PrunePlanItem is a local clone the plan prunes, moves or keeps.
+type PrunePlanItem struct {
+	// Path is the path of the clone relative to OutputDir, with forward slashes.
+	Path     string `json:"path"`
+	Category string `json:"category"`
+	Action   string `json:"action"`
+	// NewPath is where a renamed repo is moved to.
+	NewPath string `json:"new_path,omitempty"`
+	// URL and ID are those of the repo on the remote, if it still exists.
+	URL    string `json:"url,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// count returns the number of items with action.
+func (p *PrunePlan) count(action string) int {
+	n := 0
+	for _, item := range p.Items {
+		if item.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// prunePlanPath returns the path below the clone directory that clone uses for repo.
+func prunePlanPath(repo scm.Repo) string {
+	slug := resolveRepoSlug(&repo)
+	if repo.Path != "" && os.Getenv("GHORG_PRESERVE_DIRECTORY_STRUCTURE") == "true" {
+		slug = repo.Path
+	}
+	return strings.TrimPrefix(filepath.ToSlash(slug), "/")
+}
+
+// buildPrunePlan compares the clones in outputDirAbsolutePath with remote,
+// every repo of the target, and targets, the repos left after filtering.
+// Clones with local changes or unpushed commits are kept.
+func buildPrunePlan(g git.Gitter, remote, targets []scm.Repo, state *StateManifest) (*PrunePlan, error) {
+	local, err := getRelativePathRepositories(outputDirAbsolutePath)
+	if err != nil {
+		return nil, err
+	}
+
+	targetByPath := make(map[string]scm.Repo, len(targets))
+	for _, repo := range targets {
+		targetByPath[prunePlanPath(repo)] = repo
+	}
+	remoteByPath := make(map[string]scm.Repo, len(remote))
+	remoteByID := make(map[string]scm.Repo, len(remote))
+	for _, repo := range remote {
+		remoteByPath[prunePlanPath(repo)] = repo
+		if id := stateRepoID(repo); id != "" {
+			remoteByID[id] = repo
+		}
+	}
+
+	plan := &PrunePlan{
+		SCM:         strings.ToLower(os.Getenv("GHORG_SCM_TYPE")),
+		Target:      targetCloneSource,
+		OutputDir:   outputDirAbsolutePath,
+		StateFile:   getGhorgStateFilePath(),
+		CreatedAt:   time.Now().UTC(),
+		LocalClones: len(local),
+	}
+	processor := NewRepositoryProcessor(g)
+	for _, rel := range local {
+		rel = filepath.ToSlash(rel)
+		hostPath := filepath.Join(outputDirAbsolutePath, filepath.FromSlash(rel))
+
+		if target, ok := targetByPath[rel]; ok || sliceContainsNamedRepo(targets, rel) {
+			target.HostPath = hostPath
+			if os.Getenv("GHORG_PRUNE_UNTOUCHED") == "true" && processor.shouldPruneUntouched(&target) {
+				plan.Items = append(plan.Items, PrunePlanItem{Path: rel, Category: PruneUntouched, Action: PruneActionPrune, URL: target.URL})
+			}
+			continue
+		}
+
+		var item PrunePlanItem
+		if repo, ok := remoteByID[state.IDAt(hostPath)]; ok && prunePlanPath(repo) != rel {
+			newPath := prunePlanPath(repo)
+			item = PrunePlanItem{Path: rel, Category: PruneRenamed, Action: PruneActionMove, NewPath: newPath, URL: repo.URL, ID: repo.ID}
+			if _, isTarget := targetByPath[newPath]; !isTarget {
+				item.Category, item.Action, item.NewPath = PruneFilteredOut, PruneActionPrune, ""
+				item.Reason = "renamed to " + newPath
+			} else if _, err := os.Stat(filepath.Join(outputDirAbsolutePath, filepath.FromSlash(newPath))); err == nil {
+				item.Action = PruneActionKeep
+				item.Reason = newPath + " already exists"
+			}
+		} else if repo, ok := remoteByPath[rel]; ok || sliceContainsNamedRepo(remote, rel) {
+			item = PrunePlanItem{Path: rel, Category: PruneFilteredOut, Action: PruneActionPrune, URL: repo.URL}
+		} else {
+			item = PrunePlanItem{Path: rel, Category: PruneRemoteDeleted, Action: PruneActionPrune}
+		}
+
+		if item.Action == PruneActionPrune {
+			if err := processor.checkPruneSafe(scm.Repo{Name: filepath.Base(hostPath), HostPath: hostPath}); err != nil {
+				item.Action = PruneActionKeep
+				item.Reason = err.Error()
+			}
+		}
+		plan.Items = append(plan.Items, item)
+	}
+	return plan, nil
+}
+
+// printPrunePlan prints plan as a table followed by a summary.
+func printPrunePlan(plan *PrunePlan) {
+	if len(plan.Items) == 0 {
+		colorlog.PrintSuccess(fmt.Sprintf("Nothing to prune in %s, all %d local clones were found on the remote", plan.OutputDir, plan.LocalClones))
+		return
+	}
+
+	colorlog.PrintInfo(fmt.Sprintf("Prune plan for %s (%d local clones):", plan.OutputDir, plan.LocalClones))
+	for _, category := range []string{PruneRemoteDeleted, PruneRenamed, PruneFilteredOut, PruneUntouched} {
+		var lines []string
+		for _, item := range plan.Items {
+			if item.Category != category {
+				continue
+			}
+			line := fmt.Sprintf("  %-6s %s", item.Action, item.Path)
+			if item.NewPath != "" {
+				line += " -> " + item.NewPath
+			}
+			if item.Reason != "" {
+				line += " (" + item.Reason + ")"
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(colorlog.Output(), "\n%s (%d):\n%s\n", category, len(lines), strings.Join(lines, "\n"))
+	}
+	fmt.Fprintln(colorlog.Output())
+	colorlog.PrintInfo(fmt.Sprintf("Plan: %d to prune, %d to move, %d to keep", plan.count(PruneActionPrune), plan.count(PruneActionMove), plan.count(PruneActionKeep)))
+	if err := checkPruneThreshold(plan.count(PruneActionPrune), plan.LocalClones); err != nil {
+		colorlog.PrintError(fmt.Sprintf("This plan will not be applied, %v", err))
+	}
+}
+
+func writePrunePlan(path string, plan *PrunePlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+func readPrunePlan(path string) (*PrunePlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan PrunePlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("parsing prune plan %s: %w", path, err)
+	}
+	if !filepath.IsAbs(plan.OutputDir) {
+		return nil, fmt.Errorf("prune plan %s has no absolute output_dir", path)
+	}
+	return &plan, nil
+}
+
+// planItemPath returns the absolute path of rel below the plan's output
+// directory, refusing paths that leave it or point into the trash.
+func (p *PrunePlan) planItemPath(rel string) (string, error) {
+	abs := filepath.Join(p.OutputDir, filepath.FromSlash(rel))
+	inside, err := filepath.Rel(p.OutputDir, abs)
+	if err != nil || inside == "." || strings.HasPrefix(inside, "..") || strings.HasPrefix(filepath.ToSlash(inside), TrashDirName+"/") {
+		return "", fmt.Errorf("%s is not a clone inside %s", rel, p.OutputDir)
+	}
+	return abs, nil
+}
+
+// applyPrunePlan prunes and moves the clones as listed in plan. Clones that
+// changed since the plan was made, e.g. got local changes or were removed,
+// are skipped. It returns the paths of the pruned clones.
+func applyPrunePlan(g git.Gitter, plan *PrunePlan) ([]string, error) {
+	if err := checkPruneThreshold(plan.count(PruneActionPrune), plan.LocalClones); err != nil {
+		return nil, fmt.Errorf("not pruning, %w", err)
+	}
+
+	trash := newTrashFromEnv(plan.OutputDir)
+	if trash != nil {
+		expireTrashFromEnv(plan.OutputDir)
+	}
+	var state *StateManifest
+	if plan.count(PruneActionMove) > 0 && plan.StateFile != "" {
+		var err error
+		if state, err = LoadState(plan.StateFile, plan.SCM, plan.Target); err != nil {
+			return nil, fmt.Errorf("could not load state file %s: %w", plan.StateFile, err)
+		}
+	}
+
+	processor := NewRepositoryProcessor(g)
+	var pruned []string
+	var errs []error
+	moved := 0
+	for _, item := range plan.Items {
+		if item.Action != PruneActionPrune && item.Action != PruneActionMove {
+			continue
+		}
+		src, err := plan.planItemPath(item.Path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !isGitRepository(src) {
+			colorlog.PrintInfo(fmt.Sprintf("Skipping %s, it is no longer a clone", src))
+			continue
+		}
+
+		if item.Action == PruneActionMove {
+			if err := movePlannedRepo(g, plan, state, item, src); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			moved++
+			continue
+		}
+
+		if err := processor.checkPruneSafe(scm.Repo{Name: filepath.Base(src), HostPath: src}); err != nil {
+			colorlog.PrintWarning(fmt.Sprintf("Not pruning %s (%v)", src, err))
+			continue
+		}
+		if err := trash.discard(src, item.Category); err != nil {
+			errs = append(errs, fmt.Errorf("could not prune %s: %w", src, err))
+			continue
+		}
+		colorlog.PrintSuccess(fmt.Sprintf("Successfully %s %s", pruneVerb(trash), src))
+		pruned = append(pruned, item.Path)
+	}
+
+	if moved > 0 && state != nil {
+		if err := SaveState(plan.StateFile, state); err != nil {
+			errs = append(errs, fmt.Errorf("could not write state file %s: %w", plan.StateFile, err))
+		}
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// movePlannedRepo moves the clone of a renamed repo from src to its new path
+// and points its origin at the new URL, like clone does.
+func movePlannedRepo(g git.Gitter, plan *PrunePlan, state *StateManifest, item PrunePlanItem, src string) error {
+	dest, err := plan.planItemPath(item.NewPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("not moving %s, %s already exists", src, dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dest); err != nil {
+		return fmt.Errorf("could not move %s: %w", src, err)
+	}
+
+	repo := scm.Repo{Name: filepath.Base(dest), URL: item.URL, ID: item.ID, HostPath: dest}
+	if oldURL, _, ok := state.FindByID(item.ID); ok {
+		state.Move(oldURL, repo)
+	}
+	if item.URL != "" {
+		if err := g.SetOrigin(repo); err != nil {
+			colorlog.PrintInfo(fmt.Sprintf("Problem updating the remote of renamed repo %s, error: %v", item.URL, err))
+		}
+	}
+	colorlog.PrintSuccess(fmt.Sprintf("Moved %s to %s, the repo was renamed or transferred", src, dest))
+	return nil
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/cli"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+type PruneCommand struct {
+	UI cli.Ui
+}
+
+// PruneFlags are the clone flags, which select the remote, the clone
+// directory and the filters, plus the flags of the prune steps.
+type PruneFlags struct {
+	CloneFlags
+
+	PlanFile string `long:"plan-file" description:"Write the plan to this file, or with --apply execute the plan in it"`
+	Apply    bool   `long:"apply" description:"Execute the plan, prompting first unless --prune-no-confirm is set"`
+	Undo     bool   `long:"undo" description:"Restore the repos moved into .ghorg-trash by the most recent prune"`
+}
+
+func (c *PruneCommand) Help() string {
+	return `Usage: ghorg prune [options] <org/user>
+
+Compare the local clones of an org or user with the repos on the remote and
+print a plan of what to prune, without cloning or pulling anything. It takes
+the same flags and configuration as ghorg clone, so the clone directory,
+filters and state file are the ones clone uses.
+
+The plan separates the local clones that are:
+  remote-deleted  no longer found on the remote, pruned
+  renamed         renamed or transferred according to the provider ID in
+                  _ghorg_state.json, moved to their new directory
+  filtered-out    still on the remote but excluded by the filters, e.g.
+                  --skip-archived or --match-regex, pruned
+  untouched       without local branches, changes or commits of their own,
+                  pruned with --prune-untouched only
+
+Clones with uncommitted changes or unpushed commits are never pruned. With
+--prune-trash pruned clones are moved into .ghorg-trash, see ghorg trash.
+
+Options:
+  --plan-file          Write the plan to this file, or with --apply execute
+                       the plan in it
+  --apply              Execute the plan, prompting first unless
+                       --prune-no-confirm is set
+  --undo               Restore the repos moved into .ghorg-trash by the most
+                       recent prune
+  --prune-untouched    Also plan to prune untouched clones
+  --prune-trash        Move pruned clones into .ghorg-trash
+  --prune-max-percent  Refuse plans that prune more than this % of the clones
+
+See ghorg clone --help for the remaining flags.
+
+Examples:
+  ghorg prune kubernetes                                   # Print the plan
+  ghorg prune --skip-archived --plan-file plan.json kubernetes
+  ghorg prune --apply --plan-file plan.json                # Execute exactly that plan
+  ghorg prune --apply --prune-trash --prune-no-confirm kubernetes
+  ghorg prune --undo kubernetes                            # Restore the last prune
+`
+}
+
+func (c *PruneCommand) Synopsis() string {
+	return "Plan and apply the pruning of local clones without cloning"
+}
+
+func (c *PruneCommand) Run(args []string) int {
+	var opts PruneFlags
+	parser := flags.NewParser(&opts, flags.Default)
+	remaining, err := parser.ParseArgs(args)
+	if err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(c.Help())
+			return 0
+		}
+		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
+		return 1
+	}
+
+	// An existing plan carries its clone directory, only the flags changing
+	// how it is applied are needed.
+	if opts.Apply && opts.PlanFile != "" {
+		applyConfigFlags(&opts.CloneFlags)
+		applyStringFlags(&opts.CloneFlags)
+		applyBoolFlags(&opts.CloneFlags)
+
+		plan, err := readPrunePlan(opts.PlanFile)
+		if err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+		outputDirAbsolutePath = plan.OutputDir
+		targetCloneSource = plan.Target
+		return c.apply(plan)
+	}
+
+	remaining, err = applyCloneFlags(&opts.CloneFlags, remaining)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	setCloneTarget(remaining)
+
+	if opts.Undo {
+		return c.undo()
+	}
+
+	if err := validateConfig(); err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	if os.Getenv("GHORG_GITHUB_USER_GISTS") == "true" {
+		colorlog.PrintError("ghorg prune does not support GHORG_GITHUB_USER_GISTS")
+		return 1
+	}
+
+	remote, err := listPruneRemote()
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Could not list the repos of %s: %v", targetCloneSource, err))
+		return 1
+	}
+	if len(remote) == 0 {
+		// An empty listing would prune everything, usually it means missing permissions.
+		colorlog.PrintError("No repos found for " + os.Getenv("GHORG_SCM_TYPE") + " " + os.Getenv("GHORG_CLONE_TYPE") + ": " + targetCloneSource + ", not planning to prune every clone")
+		return 1
+	}
+	targets := NewRepositoryFilter().ApplyAllFilters(remote)
+
+	statePath := getGhorgStateFilePath()
+	state, err := LoadState(statePath, strings.ToLower(os.Getenv("GHORG_SCM_TYPE")), targetCloneSource)
+	if err != nil {
+		colorlog.PrintInfo(fmt.Sprintf("Could not load state file %s, renamed repos are not detected: %v", statePath, err))
+		state = nil
+	}
+
+	plan, err := buildPrunePlan(git.NewGit(), remote, targets, state)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	if opts.PlanFile != "" {
+		if err := writePrunePlan(opts.PlanFile, plan); err != nil {
+			colorlog.PrintError(fmt.Sprintf("Could not write prune plan %s: %v", opts.PlanFile, err))
+			return 1
+		}
+	}
+	if !opts.Apply {
+		printPrunePlan(plan)
+		if opts.PlanFile != "" {
+			colorlog.PrintSubtleInfo(fmt.Sprintf("Wrote the plan to %s, execute it with ghorg prune --apply --plan-file %s", opts.PlanFile, opts.PlanFile))
+		}
+		return 0
+	}
+	return c.apply(plan)
+}
+
+// listPruneRemote returns every repo of the org or user on the remote.
+func listPruneRemote() ([]scm.Repo, error) {
+	client, err := scm.GetClient(strings.ToLower(os.Getenv("GHORG_SCM_TYPE")))
+	if err != nil {
+		return nil, err
+	}
+	if os.Getenv("GHORG_CLONE_TYPE") == "user" {
+		return client.GetUserRepos(targetCloneSource)
+	}
+	return client.GetOrgRepos(targetCloneSource)
+}
+
+// apply prints plan and executes it after confirmation.
+func (c *PruneCommand) apply(plan *PrunePlan) int {
+	printPrunePlan(plan)
+	if plan.count(PruneActionPrune)+plan.count(PruneActionMove) == 0 {
+		return 0
+	}
+	if os.Getenv("GHORG_PRUNE_NO_CONFIRM") != "true" && !interactiveYesNoPrompt("Apply this plan?") {
+		colorlog.PrintInfo("Not applying the plan")
+		return 0
+	}
+
+	pruned, err := applyPrunePlan(git.NewGit(), plan)
+	colorlog.PrintSuccess(fmt.Sprintf("Pruned %d clones of %s", len(pruned), plan.OutputDir))
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	return 0
+}
+
+// undo restores the most recent trash batch of the clone directory.
+func (c *PruneCommand) undo() int {
+	batches, err := listTrash(outputDirAbsolutePath)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	if len(batches) == 0 {
+		colorlog.PrintError(fmt.Sprintf("No pruned repos in %s to restore, only prunes with --prune-trash can be undone", outputDirAbsolutePath))
+		return 1
+	}
+
+	restored, err := restoreFromTrash(outputDirAbsolutePath, batches, "", nil)
+	for _, p := range restored {
+		colorlog.PrintSuccess(fmt.Sprintf("Restored %s", p))
+	}
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	return 0
+}
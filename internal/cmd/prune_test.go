@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+func testPruneRepo(name, id string) scm.Repo {
+	return scm.Repo{Name: name, ID: id, URL: "https://github.com/org/" + name + ".git", CloneURL: "https://github.com/org/" + name + ".git"}
+}
+
+func TestBuildPrunePlan(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedDir, savedTarget := outputDirAbsolutePath, targetCloneSource
+	defer func() { outputDirAbsolutePath, targetCloneSource = savedDir, savedTarget }()
+	root := setupExecFixture(t, "keep", "gone", "archived", "old-name", "local-work", "broken", "taken", "moved-away", "taken-new")
+	outputDirAbsolutePath, targetCloneSource = root, "org"
+	os.Setenv("GHORG_SCM_TYPE", "github")
+
+	remote := []scm.Repo{
+		testPruneRepo("keep", "1"),
+		testPruneRepo("archived", "2"),
+		testPruneRepo("new-name", "3"),
+		testPruneRepo("taken-new", "4"),
+		testPruneRepo("filtered-new", "5"),
+	}
+	targets := []scm.Repo{remote[0], remote[2], remote[3]}
+
+	state := NewStateManifest("github", "org")
+	for rel, repo := range map[string]scm.Repo{"old-name": testPruneRepo("old-name", "3"), "taken": testPruneRepo("taken", "4"), "moved-away": testPruneRepo("moved-away", "5")} {
+		repo.HostPath = filepath.Join(root, rel)
+		state.Record(repo, StateStatusOK, "", "")
+	}
+
+	g := dirtyMockGit{dirty: map[string]bool{filepath.Join(root, "local-work"): true}, broken: map[string]bool{filepath.Join(root, "broken"): true}}
+	plan, err := buildPrunePlan(g, remote, targets, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.LocalClones != 9 || plan.Target != "org" || plan.OutputDir != root {
+		t.Errorf("unexpected plan header: %+v", plan)
+	}
+
+	got := make(map[string]PrunePlanItem)
+	for _, item := range plan.Items {
+		got[item.Path] = item
+	}
+	want := map[string]PrunePlanItem{
+		"gone":       {Path: "gone", Category: PruneRemoteDeleted, Action: PruneActionPrune},
+		"archived":   {Path: "archived", Category: PruneFilteredOut, Action: PruneActionPrune, URL: "https://github.com/org/archived.git"},
+		"old-name":   {Path: "old-name", Category: PruneRenamed, Action: PruneActionMove, NewPath: "new-name", URL: "https://github.com/org/new-name.git", ID: "3"},
+		"local-work": {Path: "local-work", Category: PruneRemoteDeleted, Action: PruneActionKeep, Reason: "has local changes"},
+		"broken":     {Path: "broken", Category: PruneRemoteDeleted, Action: PruneActionKeep, Reason: "could not check for local changes: not a git repository"},
+		"taken":      {Path: "taken", Category: PruneRenamed, Action: PruneActionKeep, NewPath: "taken-new", URL: "https://github.com/org/taken-new.git", ID: "4", Reason: "taken-new already exists"},
+		"moved-away": {Path: "moved-away", Category: PruneFilteredOut, Action: PruneActionPrune, URL: "https://github.com/org/filtered-new.git", ID: "5", Reason: "renamed to filtered-new"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("plan has %d items, want %d: %+v", len(got), len(want), plan.Items)
+	}
+	for path, w := range want {
+		if got[path] != w {
+			t.Errorf("%s:\n got %+v\nwant %+v", path, got[path], w)
+		}
+	}
+
+	os.Setenv("GHORG_PRUNE_UNTOUCHED", "true")
+	plan, err = buildPrunePlan(NewMockGit(), remote, targets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var untouched []string
+	for _, item := range plan.Items {
+		if item.Category == PruneUntouched {
+			untouched = append(untouched, item.Path)
+		}
+	}
+	// the mock reports one branch without changes or commits for every clone
+	if strings.Join(untouched, ",") != "keep,taken-new" {
+		t.Errorf("untouched = %v", untouched)
+	}
+}
+
+func TestApplyPrunePlan(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedDir := outputDirAbsolutePath
+	defer func() { outputDirAbsolutePath = savedDir }()
+	root := setupExecFixture(t, "gone", "old-name", "now-dirty", "now-broken", "keep")
+	outputDirAbsolutePath = root
+	os.Setenv("GHORG_PRUNE_TRASH", "true")
+
+	statePath := filepath.Join(t.TempDir(), StateFileName)
+	state := NewStateManifest("github", "org")
+	old := testPruneRepo("old-name", "3")
+	old.HostPath = filepath.Join(root, "old-name")
+	state.Record(old, StateStatusOK, "abc", "")
+	if err := SaveState(statePath, state); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := &PrunePlan{
+		SCM: "github", Target: "org", OutputDir: root, StateFile: statePath, LocalClones: 5,
+		Items: []PrunePlanItem{
+			{Path: "gone", Category: PruneRemoteDeleted, Action: PruneActionPrune},
+			{Path: "old-name", Category: PruneRenamed, Action: PruneActionMove, NewPath: "new-name", URL: "https://github.com/org/new-name.git", ID: "3"},
+			{Path: "now-dirty", Category: PruneRemoteDeleted, Action: PruneActionPrune},
+			{Path: "now-broken", Category: PruneRemoteDeleted, Action: PruneActionPrune},
+			{Path: "keep", Category: PruneRemoteDeleted, Action: PruneActionKeep},
+			{Path: "../outside", Category: PruneRemoteDeleted, Action: PruneActionPrune},
+		},
+	}
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	if err := writePrunePlan(planFile, plan); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := readPrunePlan(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := dirtyMockGit{dirty: map[string]bool{filepath.Join(root, "now-dirty"): true}, broken: map[string]bool{filepath.Join(root, "now-broken"): true}}
+	pruned, err := applyPrunePlan(g, plan)
+	if err == nil || !strings.Contains(err.Error(), "../outside is not a clone inside") {
+		t.Errorf("expected an error for the path outside of the clone directory, got %v", err)
+	}
+	if strings.Join(pruned, ",") != "gone" {
+		t.Errorf("pruned = %v, want [gone]", pruned)
+	}
+	for _, p := range []string{"new-name", "now-dirty", "now-broken", "keep"} {
+		if !isGitRepository(filepath.Join(root, p)) {
+			t.Errorf("%s should be a clone after applying the plan", p)
+		}
+	}
+	if batches, _ := listTrash(root); len(batches) != 1 || batches[0].Repos[0].Path != "gone" || batches[0].Repos[0].Reason != PruneRemoteDeleted {
+		t.Errorf("trash = %+v", batches)
+	}
+
+	saved, err := LoadState(statePath, "github", "org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := saved.Repos["https://github.com/org/new-name.git"]; !ok || entry.HostPath != filepath.Join(root, "new-name") || entry.LastSHA != "abc" {
+		t.Errorf("state was not moved to the new name: %+v", saved.Repos)
+	}
+
+	os.Setenv("GHORG_PRUNE_MAX_PERCENT", "10")
+	if err := os.MkdirAll(filepath.Join(root, "gone", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := applyPrunePlan(g, plan); err == nil || !strings.Contains(err.Error(), "GHORG_PRUNE_MAX_PERCENT") {
+		t.Errorf("expected the threshold to stop the plan, got %v", err)
+	}
+	if !isGitRepository(filepath.Join(root, "gone")) {
+		t.Error("a plan above the threshold pruned a clone")
+	}
+}
+
+func TestPruneFlags(t *testing.T) {
+	var opts PruneFlags
+	rest, err := flags.NewParser(&opts, flags.Default).ParseArgs([]string{"--scm", "gitlab", "--prune-trash", "--plan-file", "plan.json", "--apply", "my-org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.SCMType != "gitlab" || !opts.PruneTrash || opts.PlanFile != "plan.json" || !opts.Apply || len(rest) != 1 || rest[0] != "my-org" {
+		t.Errorf("unexpected flags %+v, args %v", opts, rest)
+	}
+}
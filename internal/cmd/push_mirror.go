@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// pushMirror replicates every cloned or pulled repository to a second remote.
+// The destination URL is rendered per repo from GHORG_PUSH_MIRROR_TO.
+type pushMirror struct {
+	urlTemplate *template.Template
+	owner       string
+	target      string
+	token       string
+	creator     scm.RepoCreator
+}
+
+// pushMirrorTemplateData is the data available to the GHORG_PUSH_MIRROR_TO template.
+type pushMirrorTemplateData struct {
+	Name   string
+	Path   string
+	Owner  string
+	Target string
+}
+
+// newPushMirrorFromEnv builds a pushMirror from the GHORG_PUSH_MIRROR_* env vars.
+// Returns nil and no error when push mirroring is not configured.
+func newPushMirrorFromEnv() (*pushMirror, error) {
+	rawTemplate := os.Getenv("GHORG_PUSH_MIRROR_TO")
+	if rawTemplate == "" {
+		return nil, nil
+	}
+
+	// A plain base URL is shorthand for <base>/<repo name>.git
+	if !strings.Contains(rawTemplate, "{{") {
+		rawTemplate = strings.TrimSuffix(rawTemplate, "/") + "/{{.Name}}.git"
+	}
+
+	tmpl, err := template.New("push-mirror").Option("missingkey=error").Parse(rawTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GHORG_PUSH_MIRROR_TO template: %w", err)
+	}
+
+	pm := &pushMirror{
+		urlTemplate: tmpl,
+		owner:       os.Getenv("GHORG_PUSH_MIRROR_OWNER"),
+		target:      targetCloneSource,
+		token:       os.Getenv("GHORG_PUSH_MIRROR_TOKEN"),
+	}
+	if pm.owner == "" {
+		pm.owner = targetCloneSource
+	}
+
+	if scmType := os.Getenv("GHORG_PUSH_MIRROR_SCM_TYPE"); scmType != "" {
+		creator, err := scm.NewRepoCreator(strings.ToLower(scmType), os.Getenv("GHORG_PUSH_MIRROR_BASE_URL"), pm.token)
+		if err != nil {
+			return nil, err
+		}
+		pm.creator = creator
+	}
+
+	return pm, nil
+}
+
+// shouldMirror reports whether repo is a regular repository that can be
+// mirrored. Wikis, snippets and gists have no standalone destination.
+func (pm *pushMirror) shouldMirror(repo scm.Repo) bool {
+	return !repo.IsWiki && !repo.IsGitLabSnippet && !repo.IsGitHubGist
+}
+
+// destination renders the mirror URL for repo. The URL never contains the
+// push mirror token, which is passed to the push separately, so it is safe to
+// log or persist in the state manifest.
+func (pm *pushMirror) destination(repo scm.Repo) (string, error) {
+	data := pushMirrorTemplateData{
+		Name:   repo.Name,
+		Path:   repo.Path,
+		Owner:  pm.owner,
+		Target: pm.target,
+	}
+	if data.Path == "" {
+		data.Path = repo.Name
+	}
+
+	var buf bytes.Buffer
+	if err := pm.urlTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render push mirror url: %w", err)
+	}
+	rendered := strings.TrimSpace(buf.String())
+
+	u, err := url.Parse(rendered)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User == nil {
+		// ssh remotes and local paths are used as-is
+		return rendered, nil
+	}
+	// Keep a user name, git needs it to pick the credentials, but never a password
+	u.User = url.User(u.User.Username())
+	return u.String(), nil
+}
+
+// mirrorRepoPath returns the owner and name of the repository a mirror URL
+// points at, the last two segments of its path. It is what gets created on
+// the mirror, so the created repository is always the one pushed to.
+func mirrorRepoPath(mirrorURL string) (owner, name string, err error) {
+	repoPath := mirrorURL
+	if u, parseErr := url.Parse(mirrorURL); parseErr == nil && u.Scheme != "" && u.Host != "" {
+		repoPath = u.Path
+	} else if host, after, found := strings.Cut(mirrorURL, ":"); found && !strings.Contains(host, "/") {
+		// scp-like ssh remote, user@host:owner/name.git
+		repoPath = after
+	}
+
+	segments := strings.Split(strings.Trim(filepath.ToSlash(repoPath), "/"), "/")
+	if len(segments) < 2 || segments[len(segments)-2] == "" || segments[len(segments)-1] == "" {
+		return "", "", fmt.Errorf("push mirror url %s has no owner/name path", mirrorURL)
+	}
+	return segments[len(segments)-2], strings.TrimSuffix(segments[len(segments)-1], ".git"), nil
+}
+
+// push creates the destination repository when a creator is configured and
+// pushes all branches and tags of repo to it. It returns the mirror URL,
+// which never contains the token.
+func (pm *pushMirror) push(g git.Gitter, repo scm.Repo) (string, error) {
+	mirrorURL, err := pm.destination(repo)
+	if err != nil {
+		return "", err
+	}
+
+	if pm.creator != nil {
+		owner, name, err := mirrorRepoPath(mirrorURL)
+		if err != nil {
+			return mirrorURL, err
+		}
+		if err := pm.creator.EnsureRepo(owner, name); err != nil {
+			return mirrorURL, err
+		}
+	}
+
+	if err := g.PushMirror(repo, mirrorURL, pm.token); err != nil {
+		return mirrorURL, err
+	}
+
+	return mirrorURL, nil
+}
@@ -2,51 +2,77 @@ package cmd
 
 import (
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"slices"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/cli"
 	"github.com/jessevdk/go-flags"
 
 	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/configs"
+	"github.com/blairham/ghorg/internal/cron"
 )
 
-var (
-	recloneRunning bool
-	recloneMutex   sync.Mutex
-)
+// CronStateFileName is the default name of the file reclone-cron records the
+// last run of every entry in, kept next to reclone.yaml.
+const CronStateFileName = "reclone-cron-state.json"
+
+// cronQueueRetryInterval is how often queued entries check whether the
+// reclone they wait for has finished.
+const cronQueueRetryInterval = 30 * time.Second
+
+// cronListRuns is the number of upcoming runs --list shows per entry.
+const cronListRuns = 3
 
 type RecloneCronCommand struct {
 	UI cli.Ui
 }
 
 type RecloneCronFlags struct {
-	Minutes string `short:"m" long:"minutes" description:"GHORG_CRON_TIMER_MINUTES - Number of minutes to run the reclone command on a cron"`
+	Minutes  string `short:"m" long:"minutes" description:"GHORG_CRON_TIMER_MINUTES - Number of minutes to run the reclone command on a cron"`
+	Schedule string `short:"s" long:"schedule" description:"GHORG_CRON_SCHEDULE - Cron expression to run reclone entries without their own schedule on, overrides --minutes"`
+	Timezone string `long:"timezone" description:"GHORG_CRON_TIMEZONE - Time zone cron expressions are evaluated in (default local time)"`
+	Jitter   string `long:"jitter" description:"GHORG_CRON_JITTER - Maximum random delay added to every scheduled run, e.g. 5m"`
+	CatchUp  bool   `long:"catch-up" description:"GHORG_CRON_CATCH_UP - Run entries that missed a scheduled run while the cron was down once at startup"`
+	List     bool   `long:"list" description:"List the schedule and next run times of every reclone entry and exit"`
 }
 
 func (c *RecloneCronCommand) Help() string {
 	return `Usage: ghorg reclone-cron [options]
 
-Simple cron that will trigger your reclone command at specified minute intervals indefinitely.
+Cron that triggers your reclone entries on a schedule indefinitely. Entries
+with a schedule in reclone.yaml run on it, every other entry runs on
+--schedule, or every --minutes when no schedule is set.
 
 Options:
   -m, --minutes    Number of minutes between reclone runs
+  -s, --schedule   Cron expression, e.g. "0 2 * * *" or "@daily"
+  --timezone       Time zone cron expressions are evaluated in, e.g. Europe/Berlin
+  --jitter         Maximum random delay added to every scheduled run, e.g. 5m
+  --catch-up       Run entries that missed a run while the cron was down at startup
+  --list           List every entry's schedule and next run times
 
 Examples:
   ghorg reclone-cron --minutes 60
   ghorg reclone-cron -m 30
+  ghorg reclone-cron --schedule "0 2 * * *" --timezone America/New_York
+  ghorg reclone-cron --jitter 5m --catch-up
+  ghorg reclone-cron --list
 
-Read the documentation and examples in the Readme under Reclone Server heading.
+Read the documentation and examples in the Readme under Reclone Cron Command heading.
 `
 }
 
 func (c *RecloneCronCommand) Synopsis() string {
-	return "Simple cron that triggers reclone at specified intervals"
+	return "Cron that triggers reclone entries on their schedules"
 }
 
 func (c *RecloneCronCommand) Run(args []string) int {
@@ -67,57 +93,400 @@ func (c *RecloneCronCommand) Run(args []string) int {
 		os.Setenv("GHORG_CRON_TIMER_MINUTES", opts.Minutes)
 	}
 
+	if opts.Schedule != "" {
+		os.Setenv("GHORG_CRON_SCHEDULE", opts.Schedule)
+	}
+
+	if opts.Timezone != "" {
+		os.Setenv("GHORG_CRON_TIMEZONE", opts.Timezone)
+	}
+
+	if opts.Jitter != "" {
+		os.Setenv("GHORG_CRON_JITTER", opts.Jitter)
+	}
+
+	if opts.CatchUp {
+		os.Setenv("GHORG_CRON_CATCH_UP", "true")
+	}
+
+	if opts.List {
+		return listReCloneCron(time.Now())
+	}
+
 	startReCloneCron()
 	return 0
 }
 
-func startReCloneCron() {
-	cronTimer := os.Getenv("GHORG_CRON_TIMER_MINUTES")
-	if cronTimer == "" {
-		colorlog.PrintInfo("GHORG_CRON_TIMER_MINUTES is not set. Cron job will not start.")
-		return
+// cronSchedule is when a reclone entry runs.
+type cronSchedule interface {
+	// Next returns the first run after t, or the zero time for none.
+	Next(t time.Time) time.Time
+}
+
+// intervalSchedule runs every GHORG_CRON_TIMER_MINUTES.
+type intervalSchedule struct {
+	every time.Duration
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.every)
+}
+
+// cronEntry is a reclone.yaml entry and the schedule it runs on.
+type cronEntry struct {
+	key      string
+	spec     string
+	schedule cronSchedule
+	// due is the scheduled time of the next run and at that time plus jitter.
+	due, at time.Time
+}
+
+// cronEntries returns every reclone entry that has a schedule, sorted by key.
+// An entry without a schedule of its own falls back to GHORG_CRON_SCHEDULE,
+// then to GHORG_CRON_TIMER_MINUTES.
+func cronEntries(reclones map[string]ReClone) ([]*cronEntry, error) {
+	loc := time.Local
+	if tz := os.Getenv("GHORG_CRON_TIMEZONE"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("invalid GHORG_CRON_TIMEZONE %q: %w", tz, err)
+		}
+	}
+
+	var defaultSpec string
+	var defaultSchedule cronSchedule
+	if spec := os.Getenv("GHORG_CRON_SCHEDULE"); spec != "" {
+		schedule, err := cron.Parse(spec, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GHORG_CRON_SCHEDULE: %w", err)
+		}
+		defaultSpec, defaultSchedule = spec, schedule
+	} else if minutes := os.Getenv("GHORG_CRON_TIMER_MINUTES"); minutes != "" {
+		n, err := strconv.Atoi(minutes)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid GHORG_CRON_TIMER_MINUTES: %s", minutes)
+		}
+		defaultSpec = fmt.Sprintf("every %d minutes", n)
+		defaultSchedule = intervalSchedule{every: time.Duration(n) * time.Minute}
+	}
+
+	keys, _ := recloneKeys(reclones, nil)
+	var entries []*cronEntry
+	for _, key := range keys {
+		entry := &cronEntry{key: key, spec: defaultSpec, schedule: defaultSchedule}
+		if spec := reclones[key].Schedule; spec != "" {
+			schedule, err := cron.Parse(spec, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid schedule of reclone entry %s: %w", key, err)
+			}
+			entry.spec, entry.schedule = spec, schedule
+		}
+		if entry.schedule != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// getCronJitter returns GHORG_CRON_JITTER, 0 when unset.
+func getCronJitter() (time.Duration, error) {
+	value := os.Getenv("GHORG_CRON_JITTER")
+	if value == "" {
+		return 0, nil
+	}
+	jitter, err := time.ParseDuration(value)
+	if err != nil || jitter < 0 {
+		return 0, fmt.Errorf("invalid GHORG_CRON_JITTER: %s", value)
+	}
+	return jitter, nil
+}
+
+// getCronStateFilePath returns the path of the file reclone-cron records the
+// last run of every entry in.
+func getCronStateFilePath() string {
+	if path := os.Getenv("GHORG_CRON_STATE_PATH"); path != "" {
+		return path
 	}
+	return filepath.Join(filepath.Dir(configs.GhorgReCloneLocation()), CronStateFileName)
+}
 
-	colorlog.PrintInfo("Cron activated and will first run after " + cronTimer + " minutes ")
+// cronState maps reclone entries to the start of their last cron run.
+type cronState map[string]time.Time
 
-	minutes, err := strconv.Atoi(cronTimer)
+// loadCronState reads the state file at path. A missing file is an empty
+// state.
+func loadCronState(path string) (cronState, error) {
+	state := cronState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
 	if err != nil {
-		colorlog.PrintError("Invalid GHORG_CRON_TIMER_MINUTES: " + cronTimer)
-		return
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return state, nil
+}
+
+func (s cronState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
 	}
+	return writeFileAtomic(path, data)
+}
+
+// missedRun returns the first run of entry after its last run in state that
+// is before now, or the zero time when it has not missed a run.
+func (s cronState) missedRun(entry *cronEntry, now time.Time) time.Time {
+	last, ok := s[entry.key]
+	if !ok {
+		return time.Time{}
+	}
+	if next := entry.schedule.Next(last); !next.IsZero() && next.Before(now) {
+		return next
+	}
+	return time.Time{}
+}
+
+// cronScheduler tracks when every entry runs next and the entries that are
+// due but wait for a running reclone to finish.
+type cronScheduler struct {
+	entries []*cronEntry
+	jitter  time.Duration
+	queued  []string
+}
+
+// newCronScheduler schedules the first run of every entry after now. With
+// catchUp, entries that missed a run according to state are due at now.
+func newCronScheduler(entries []*cronEntry, state cronState, now time.Time, jitter time.Duration, catchUp bool) *cronScheduler {
+	s := &cronScheduler{entries: entries, jitter: jitter}
+	for _, entry := range entries {
+		if missed := state.missedRun(entry, now); catchUp && !missed.IsZero() {
+			entry.due, entry.at = missed, now
+			continue
+		}
+		s.schedule(entry, entry.schedule.Next(now))
+	}
+	return s
+}
 
-	ticker := time.NewTicker(time.Duration(minutes) * time.Minute)
-	defer ticker.Stop()
+func (s *cronScheduler) schedule(entry *cronEntry, due time.Time) {
+	entry.due, entry.at = due, due
+	if !due.IsZero() && s.jitter > 0 {
+		entry.at = due.Add(rand.N(s.jitter))
+	}
+}
+
+// nextAt returns the earliest time an entry is due at, or the zero time when
+// no entry runs again.
+func (s *cronScheduler) nextAt() time.Time {
+	var next time.Time
+	for _, entry := range s.entries {
+		if !entry.at.IsZero() && (next.IsZero() || entry.at.Before(next)) {
+			next = entry.at
+		}
+	}
+	return next
+}
 
-	for range ticker.C {
-		recloneMutex.Lock()
-		if recloneRunning {
-			recloneMutex.Unlock()
+// popDue returns the keys of the entries due at now and schedules their next
+// run. A run whose time has already passed again is skipped.
+func (s *cronScheduler) popDue(now time.Time) []string {
+	var keys []string
+	for _, entry := range s.entries {
+		if entry.at.IsZero() || entry.at.After(now) {
 			continue
 		}
-		recloneRunning = true
-		recloneMutex.Unlock()
+		keys = append(keys, entry.key)
+		next := entry.schedule.Next(entry.due)
+		if !next.IsZero() && !next.After(now) {
+			next = entry.schedule.Next(now)
+		}
+		s.schedule(entry, next)
+	}
+	return keys
+}
+
+// queue adds keys to the entries waiting to run and returns the ones that
+// were not waiting already.
+func (s *cronScheduler) queue(keys []string) []string {
+	var added []string
+	for _, key := range keys {
+		if !slices.Contains(s.queued, key) {
+			s.queued = append(s.queued, key)
+			added = append(added, key)
+		}
+	}
+	return added
+}
+
+// takeQueued returns the entries waiting to run and empties the queue.
+func (s *cronScheduler) takeQueued() []string {
+	keys := s.queued
+	s.queued = nil
+	return keys
+}
+
+// loadReCloneCron reads reclone.yaml, the scheduled entries and their state.
+func loadReCloneCron() ([]*cronEntry, cronState, error) {
+	reclones, err := loadReclones(configs.GhorgReCloneLocation())
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := cronEntries(reclones)
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := loadCronState(getCronStateFilePath())
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, state, nil
+}
+
+func startReCloneCron() {
+	entries, state, err := loadReCloneCron()
+	if err != nil {
+		colorlog.PrintError(err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		colorlog.PrintInfo("No reclone entry has a schedule and GHORG_CRON_SCHEDULE and GHORG_CRON_TIMER_MINUTES are not set. Cron job will not start.")
+		return
+	}
+	jitter, err := getCronJitter()
+	if err != nil {
+		colorlog.PrintError(err.Error())
+		return
+	}
+
+	scheduler := newCronScheduler(entries, state, time.Now(), jitter, os.Getenv("GHORG_CRON_CATCH_UP") == "true")
+	for _, entry := range entries {
+		colorlog.PrintInfo(fmt.Sprintf("Cron activated for %s (%s), first run at %s", entry.key, entry.spec, formatCronTime(entry.at)))
+	}
 
-		colorlog.PrintInfo("Starting reclone cron, time: " + time.Now().Format(time.RFC1123))
-		cmd := exec.Command("ghorg", "reclone")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	statePath := getCronStateFilePath()
+	// finished wakes the loop when one of its reclones is done, so entries
+	// that queued up behind it start right away
+	finished := make(chan struct{}, 1)
+	for {
+		next := scheduler.nextAt()
+		if next.IsZero() && len(scheduler.queued) == 0 {
+			colorlog.PrintInfo("No reclone entry is scheduled to run again. Cron job is stopping.")
+			return
+		}
+		wait := time.Until(next)
+		if len(scheduler.queued) > 0 && (next.IsZero() || wait > cronQueueRetryInterval) {
+			// a reclone started elsewhere, e.g. by the server, does not wake the loop
+			wait = cronQueueRetryInterval
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-finished:
+			timer.Stop()
+		}
 
-		if err := cmd.Start(); err != nil {
-			colorlog.PrintError("Failed to start ghorg reclone: " + err.Error())
-			recloneMutex.Lock()
-			recloneRunning = false
-			recloneMutex.Unlock()
+		added := scheduler.queue(scheduler.popDue(time.Now()))
+		if len(scheduler.queued) == 0 {
+			continue
+		}
+		if !recloneMu.TryLock() {
+			if len(added) > 0 {
+				colorlog.PrintInfo(fmt.Sprintf("Queued reclone cron of %s until the running reclone finishes", strings.Join(added, ", ")))
+			}
 			continue
 		}
 
+		keys := scheduler.takeQueued()
+		colorlog.PrintInfo(fmt.Sprintf("Starting reclone cron of %s, time: %s", strings.Join(keys, ", "), time.Now().Format(time.RFC1123)))
 		go func() {
-			if err := cmd.Wait(); err != nil {
-				colorlog.PrintError("ghorg reclone command failed: " + err.Error())
+			defer func() {
+				recloneMu.Unlock()
+				select {
+				case finished <- struct{}{}:
+				default:
+				}
+			}()
+			results, err := runRecloneKeys(keys, colorlog.Output())
+			if err != nil {
+				colorlog.PrintError("ghorg reclone failed: " + err.Error())
+				return
+			}
+			recordCronRuns(statePath, results)
+			if failed := failedReclone(results); failed != nil {
+				colorlog.PrintError(fmt.Sprintf("ghorg reclone %s failed: %s", failed.Key, failed.Error))
 			}
-			recloneMutex.Lock()
-			recloneRunning = false
-			recloneMutex.Unlock()
 		}()
 	}
 }
+
+// recordCronRuns saves the start of every entry in results as its last run.
+// The caller must hold recloneMu.
+func recordCronRuns(path string, results []RecloneResult) {
+	state, err := loadCronState(path)
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Unable to read reclone cron state: %v", err))
+		state = cronState{}
+	}
+	for _, result := range results {
+		state[result.Key] = result.StartedAt
+	}
+	if err := state.save(path); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Unable to save reclone cron state: %v", err))
+	}
+}
+
+// listReCloneCron prints the schedule, last run and next runs of every
+// scheduled reclone entry.
+func listReCloneCron(now time.Time) int {
+	entries, state, err := loadReCloneCron()
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("ERROR: %v", err))
+		return 1
+	}
+	jitter, err := getCronJitter()
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("ERROR: %v", err))
+		return 1
+	}
+	if len(entries) == 0 {
+		colorlog.PrintInfo("No reclone entry has a schedule and GHORG_CRON_SCHEDULE and GHORG_CRON_TIMER_MINUTES are not set.")
+		return 0
+	}
+
+	catchUp := os.Getenv("GHORG_CRON_CATCH_UP") == "true"
+	for _, entry := range entries {
+		colorlog.PrintInfo(fmt.Sprintf("- %s", entry.key))
+		colorlog.PrintSubtleInfo(fmt.Sprintf("    schedule: %s", entry.spec))
+		if last, ok := state[entry.key]; ok {
+			colorlog.PrintSubtleInfo(fmt.Sprintf("    last run: %s", formatCronTime(last)))
+		}
+		if missed := state.missedRun(entry, now); !missed.IsZero() {
+			if catchUp {
+				colorlog.PrintSubtleInfo(fmt.Sprintf("    missed run: %s, runs at startup", formatCronTime(missed)))
+			} else {
+				colorlog.PrintSubtleInfo(fmt.Sprintf("    missed run: %s", formatCronTime(missed)))
+			}
+		}
+		next := now
+		for range cronListRuns {
+			if next = entry.schedule.Next(next); next.IsZero() {
+				break
+			}
+			colorlog.PrintSubtleInfo(fmt.Sprintf("    next run: %s", formatCronTime(next)))
+		}
+		fmt.Println("")
+	}
+	if jitter > 0 {
+		colorlog.PrintSubtleInfo(fmt.Sprintf("Every run starts up to %s after its scheduled time.", jitter))
+	}
+	return 0
+}
+
+func formatCronTime(t time.Time) string {
+	return t.Format(time.RFC1123)
+}
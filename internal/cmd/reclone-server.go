@@ -2,19 +2,20 @@ package cmd
 
 import (
 	_ "embed"
-	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
-	"os/exec"
-	"sync"
+	"strconv"
 
 	"github.com/hashicorp/cli"
 	"github.com/jessevdk/go-flags"
 
 	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/configs"
 )
 
 type RecloneServerCommand struct {
@@ -22,7 +23,11 @@ type RecloneServerCommand struct {
 }
 
 type RecloneServerFlags struct {
-	Port string `short:"p" long:"port" description:"GHORG_RECLONE_SERVER_PORT - Specify the port the reclone server will run on"`
+	Port       string `short:"p" long:"port" description:"GHORG_RECLONE_SERVER_PORT - Specify the port the reclone server will run on"`
+	TokensFile string `long:"tokens-file" description:"GHORG_RECLONE_SERVER_TOKENS_FILE - File of bearer tokens, one 'name scope token' per line"`
+	TLSCert    string `long:"tls-cert" description:"GHORG_RECLONE_SERVER_TLS_CERT - Certificate to serve HTTPS with"`
+	TLSKey     string `long:"tls-key" description:"GHORG_RECLONE_SERVER_TLS_KEY - Private key of the certificate"`
+	TLSCA      string `long:"tls-client-ca" description:"GHORG_RECLONE_SERVER_TLS_CLIENT_CA - CA bundle to verify client certificates against"`
 }
 
 func (c *RecloneServerCommand) Help() string {
@@ -31,15 +36,39 @@ func (c *RecloneServerCommand) Help() string {
 Server allowing you to trigger ad hoc reclone commands via HTTP requests.
 
 Options:
-  -p, --port    Port for the reclone server
+  -p, --port         Port for the reclone server
+  --tokens-file      File of bearer tokens, one "name scope token" per line
+  --tls-cert         Certificate to serve HTTPS with
+  --tls-key          Private key of the certificate
+  --tls-client-ca    CA bundle to verify client certificates against (mTLS)
+
+Authentication:
+  Set GHORG_RECLONE_SERVER_READ_TOKENS, GHORG_RECLONE_SERVER_TRIGGER_TOKENS or
+  --tokens-file to require "Authorization: Bearer <token>" on every endpoint
+  except /health. The read scope can view jobs, logs, stats, the report and
+  metrics, the trigger scope can also queue jobs.
+
+Webhooks:
+  Set GHORG_RECLONE_SERVER_WEBHOOK_SECRET and point GitHub, GitLab, Gitea or
+  Bitbucket webhooks at /webhooks/<scm> to update only the pushed repo in
+  every reclone entry that clones it. Created repos are cloned, renamed repos
+  moved and deleted repos pruned for entries with --prune --prune-no-confirm.
 
 Examples:
   ghorg reclone-server --port 8080
   ghorg reclone-server -p 9000
+  ghorg reclone-server --tokens-file tokens.txt --tls-cert server.pem --tls-key server-key.pem
 
 Endpoints:
-  /trigger/reclone?cmd=<reclone-key>   Trigger a reclone
-  /stats                                View stats (requires GHORG_STATS_ENABLED=true)
+  /trigger/reclone?cmd=<reclone-key>   Queue a reclone job
+  POST /jobs                            Queue a reclone job, optionally {"keys": [...]}
+  GET /jobs?state=<state>               List jobs, newest first
+  GET /jobs/<id>                        Job state, timestamps, exit code and summary
+  GET /jobs/<id>/logs                   Stream a job's log, as Server-Sent Events with Accept: text/event-stream
+  POST /webhooks/<scm>                  Receive a github, gitlab, gitea or bitbucket webhook
+  /stats?since=&until=&limit=&offset=   View stats (requires GHORG_STATS_ENABLED=true or GHORG_STATS_HISTORY=true)
+  /report?target=<org>                  HTML dashboard of clone history, see ghorg report
+  /metrics                              Prometheus metrics of reclone runs and SCM API usage
   /health                               Health check
 
 Read the documentation and examples in the Readme under Reclone Server heading.
@@ -67,111 +96,199 @@ func (c *RecloneServerCommand) Run(args []string) int {
 	if opts.Port != "" {
 		os.Setenv("GHORG_RECLONE_SERVER_PORT", opts.Port)
 	}
+	if opts.TokensFile != "" {
+		os.Setenv("GHORG_RECLONE_SERVER_TOKENS_FILE", opts.TokensFile)
+	}
+	if opts.TLSCert != "" {
+		os.Setenv("GHORG_RECLONE_SERVER_TLS_CERT", opts.TLSCert)
+	}
+	if opts.TLSKey != "" {
+		os.Setenv("GHORG_RECLONE_SERVER_TLS_KEY", opts.TLSKey)
+	}
+	if opts.TLSCA != "" {
+		os.Setenv("GHORG_RECLONE_SERVER_TLS_CLIENT_CA", opts.TLSCA)
+	}
 
 	startReCloneServer()
 	return 0
 }
 
+// serverConfig is the configuration the HTTP handlers of the reclone server
+// read, taken from the environment when the server starts. Jobs run their
+// reclone entries in-process with the entry's environment, which must not
+// change what the handlers serve.
+type serverConfig struct {
+	statsEnabled bool
+	statsHistory bool
+	statsFile    string
+	stateFile    string
+	historyDB    string
+}
+
+func newServerConfigFromEnv() serverConfig {
+	return serverConfig{
+		statsEnabled: os.Getenv("GHORG_STATS_ENABLED") == "true",
+		statsHistory: os.Getenv("GHORG_STATS_HISTORY") == "true",
+		statsFile:    getGhorgStatsFilePath(),
+		stateFile:    getGhorgStateFilePath(),
+		historyDB:    getGhorgHistoryDBPath(),
+	}
+}
+
+// handleStats returns clone runs as JSON, keyed by the _ghorg_stats.csv
+// header. Runs come from the history store when GHORG_STATS_HISTORY is
+// enabled, otherwise from the stats CSV. The number of runs matching the
+// filters before pagination is returned in X-Total-Count.
+func (c serverConfig) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !c.statsEnabled && !c.statsHistory {
+		http.Error(w, "Stats collection is not enabled. Please set GHORG_STATS_ENABLED=true or use --stats-enabled flag", http.StatusPreconditionRequired)
+		return
+	}
+
+	q, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	historyDB := ""
+	if c.statsHistory {
+		historyDB = c.historyDB
+	}
+	runs, total, err := queryStats(q, historyDB, c.statsFile)
+	if errors.Is(err, errNoStats) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Unable to read stats", http.StatusInternalServerError)
+		return
+	}
+
+	jsonData := make([]map[string]string, 0, len(runs))
+	for _, run := range runs {
+		jsonData = append(jsonData, run.csvRecord())
+	}
+	jsonBytes, err := json.Marshal(jsonData)
+	if err != nil {
+		http.Error(w, "Unable to encode JSON", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	_, _ = w.Write(jsonBytes)
+}
+
+// runServerReclone runs job, the reclone entries named by its keys or every
+// entry when it has none, or its webhook event, and feeds the result of
+// every entry that ran into metrics. The output of the run is written to log
+// as well.
+func runServerReclone(job Job, metrics *recloneMetrics, log io.Writer) ([]RecloneResult, error) {
+	recloneMu.Lock()
+	defer recloneMu.Unlock()
+	// Commands of exec: secret references run again for every job.
+	configs.ResetSecretCache()
+
+	metrics.SetRunning(true)
+	defer metrics.SetRunning(false)
+
+	out := io.MultiWriter(colorlog.Output(), log)
+	var results []RecloneResult
+	var err error
+	if job.Webhook != nil {
+		results, err = runWebhookEvent(*job.Webhook, out)
+	} else {
+		results, err = runRecloneKeys(job.Keys, out)
+	}
+	metrics.Observe(results)
+	return results, err
+}
+
 func startReCloneServer() {
-	var mu sync.Mutex
+	if err := configs.ResolveSecretRefs("notify", "reclone"); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error resolving secret references: %s", err))
+		return
+	}
+
+	config := newServerConfigFromEnv()
+	metrics := newRecloneMetrics()
 	serverPort := os.Getenv("GHORG_RECLONE_SERVER_PORT")
 	if serverPort != "" && serverPort[0] != ':' {
 		serverPort = ":" + serverPort
 	}
 
-	http.HandleFunc("/trigger/reclone", func(w http.ResponseWriter, r *http.Request) {
-		userCmd := r.URL.Query().Get("cmd")
+	jobsPath := getGhorgJobsFilePath()
+	jobs, err := newJobQueue(jobsPath, func(job Job, log io.Writer) ([]RecloneResult, error) {
+		return runServerReclone(job, metrics, log)
+	})
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error loading jobs from %s: %s", jobsPath, err))
+		return
+	}
+	logsDir := getGhorgJobLogsDir()
+	logs, err := newJobLogs(logsDir, getJobLogsMaxSize())
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error creating job log directory %s: %s", logsDir, err))
+		return
+	}
+	jobs.metrics = metrics
+	jobs.logs = logs
 
-		if !mu.TryLock() {
-			http.Error(w, "Server is busy, please try again later", http.StatusTooManyRequests)
-			return
-		}
+	auth, err := loadServerAuth()
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error loading reclone server authentication: %s", err))
+		return
+	}
+	certFile, keyFile, tlsConfig, err := serverTLS()
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error loading reclone server TLS: %s", err))
+		return
+	}
+	go jobs.Work()
 
-		// Signal channel to notify when the command has started
-		started := make(chan struct{})
-
-		go func() {
-			defer mu.Unlock()
-			var cmd *exec.Cmd
-			if userCmd == "" {
-				cmd = exec.Command("ghorg", "reclone")
-			} else {
-				cmd = exec.Command("ghorg", "reclone", userCmd)
-			}
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-
-			// Notify that the command has started
-			close(started)
-
-			if err := cmd.Run(); err != nil {
-				fmt.Printf("Error running command: %s\n", err)
-			}
-		}()
-
-		// Wait for the command to start before responding
-		<-started
-		w.WriteHeader(http.StatusOK)
-	})
+	http.HandleFunc("/trigger/reclone", requireScope(ScopeTrigger, jobs.handleTrigger))
 
-	http.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
-		if os.Getenv("GHORG_STATS_ENABLED") != "true" {
-			http.Error(w, "Stats collection is not enabled. Please set GHORG_STATS_ENABLED=true or use --stats-enabled flag", http.StatusPreconditionRequired)
-			return
-		}
+	http.HandleFunc("POST /jobs", requireScope(ScopeTrigger, jobs.handleCreate))
 
-		statsFilePath := getGhorgStatsFilePath()
-		fileExists := true
+	http.HandleFunc("GET /jobs", requireScope(ScopeRead, jobs.handleList))
 
-		if _, err := os.Stat(statsFilePath); os.IsNotExist(err) {
-			fileExists = false
-		}
+	http.HandleFunc("GET /jobs/{id}", requireScope(ScopeRead, jobs.handleGet))
 
-		if fileExists {
-			file, err := os.Open(statsFilePath)
-			if err != nil {
-				http.Error(w, "Unable to open file", http.StatusInternalServerError)
-				return
-			}
-			defer file.Close()
-
-			reader := csv.NewReader(file)
-			records, err := reader.ReadAll()
-			if err != nil {
-				http.Error(w, "Unable to read CSV file", http.StatusInternalServerError)
-				return
-			}
-
-			var jsonData []map[string]string
-			headers := records[0]
-			for _, row := range records[1:] {
-				rowData := make(map[string]string)
-				for i, value := range row {
-					rowData[headers[i]] = value
-				}
-				jsonData = append(jsonData, rowData)
-			}
-
-			jsonBytes, err := json.Marshal(jsonData)
-			if err != nil {
-				http.Error(w, "Unable to encode JSON", http.StatusInternalServerError)
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json")
-			_, _ = w.Write(jsonBytes)
-			return
-		}
+	http.HandleFunc("GET /jobs/{id}/logs", requireScope(ScopeRead, jobs.handleLogs))
 
-		w.WriteHeader(http.StatusOK)
-	})
+	// Webhooks authenticate by their signature instead of a bearer token.
+	http.HandleFunc("POST /webhooks/{scm}", newWebhookReceiver(jobs).handle)
+
+	http.HandleFunc("/stats", requireScope(ScopeRead, config.handleStats))
+
+	http.HandleFunc("/report", requireScope(ScopeRead, config.handleReport))
+
+	http.HandleFunc("/metrics", requireScope(ScopeRead, metrics.ServeHTTP))
 
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	colorlog.PrintInfo("Starting reclone server on " + serverPort)
-	if err := http.ListenAndServe(serverPort, nil); err != nil {
+	// Requests are logged to the server's own stdout, not into the log of
+	// the job running at the time.
+	server := &http.Server{
+		Addr:      serverPort,
+		Handler:   auth.Handler(http.DefaultServeMux, log.New(os.Stdout, "", log.LstdFlags)),
+		TLSConfig: tlsConfig,
+	}
+	if !auth.Enabled() {
+		colorlog.PrintWarning("Reclone server authentication is disabled, anyone who can reach the server can trigger reclones")
+	}
+
+	if certFile != "" {
+		colorlog.PrintInfo("Starting reclone server with TLS on " + serverPort)
+		err = server.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		colorlog.PrintInfo("Starting reclone server on " + serverPort)
+		err = server.ListenAndServe()
+	}
+	if err != nil {
 		fmt.Printf("Error starting server: %s\n", err)
 	}
 }
@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/cli"
 	"github.com/jessevdk/go-flags"
@@ -13,6 +18,7 @@ import (
 
 	"github.com/blairham/ghorg/internal/colorlog"
 	"github.com/blairham/ghorg/internal/configs"
+	"github.com/blairham/ghorg/internal/scm"
 )
 
 type RecloneCommand struct {
@@ -24,12 +30,26 @@ type RecloneFlags struct {
 	Quiet         bool   `long:"quiet" description:"GHORG_RECLONE_QUIET - Quiet logging output"`
 	List          bool   `long:"list" description:"Prints reclone commands and optional descriptions to stdout then will exit 0. Does not obsfucate tokens, and is only available as a commandline argument"`
 	EnvConfigOnly bool   `long:"env-config-only" description:"GHORG_RECLONE_ENV_CONFIG_ONLY - Only use environment variables to set the configuration for all reclones"`
+	JUnitReport   string `long:"junit-report" description:"GHORG_JUNIT_REPORT - Write a JUnit XML report to this path with one test suite per reclone entry"`
+	OutputFile    string `long:"output-file" description:"GHORG_OUTPUT_FILE - Write a JSON array with the result and run report of every reclone entry to this path"`
+	Tags          string `long:"tags" description:"Only run reclone entries with at least one of these comma separated tags"`
 }
 
+// ReClone is an entry of reclone.yaml. It is either a ghorg clone command in
+// Cmd, or the structured form built from SCM, Target, CloneType, TokenRef,
+// Config and Filters.
 type ReClone struct {
-	Cmd            string `yaml:"cmd"`
-	Description    string `yaml:"description"`
-	PostExecScript string `yaml:"post_exec_script"` // optional
+	Cmd            string            `yaml:"cmd"`
+	Description    string            `yaml:"description"`
+	PostExecScript string            `yaml:"post_exec_script"` // optional
+	Schedule       string            `yaml:"schedule"`         // optional, used by reclone-cron
+	Tags           []string          `yaml:"tags"`             // optional
+	SCM            string            `yaml:"scm"`
+	Target         string            `yaml:"target"`
+	CloneType      string            `yaml:"clone_type"`
+	TokenRef       string            `yaml:"token_ref"` // env:<VARIABLE>, file:<path> or exec:<command>
+	Config         map[string]string `yaml:"config"`    // registry dot-keys
+	Filters        map[string]string `yaml:"filters"`   // keys of the filter section
 }
 
 func (c *RecloneCommand) Help() string {
@@ -43,11 +63,17 @@ Options:
   --quiet                 Quiet logging output
   --list                  List available reclone commands
   --env-config-only       Only use environment variables for configuration
+  --junit-report          Write a JUnit XML report, one test suite per entry
+  --output-file           Write the result of every entry as JSON
+  --tags                  Only run entries with one of these comma separated tags
 
 Examples:
   ghorg reclone                    # Run all configured reclones
   ghorg reclone my-org            # Run specific reclone
   ghorg reclone --list            # List all configured reclones
+  ghorg reclone --tags=nightly    # Run every entry tagged nightly
+  ghorg reclone --junit-report=reclone.xml  # Report every entry as a test suite
+  ghorg reclone --output-file=reclone.json  # Write every entry's run report
 
 See https://github.com/blairham/ghorg#reclone-command for setup and additional information.
 `
@@ -83,68 +109,185 @@ func (c *RecloneCommand) Run(args []string) int {
 		os.Setenv("GHORG_RECLONE_ENV_CONFIG_ONLY", "true")
 	}
 
-	path := configs.GhorgReCloneLocation()
-	yamlBytes, err := os.ReadFile(path)
-	if err != nil {
-		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: parsing reclone.yaml, error: %v", err))
-	}
-
-	mapOfReClones := make(map[string]ReClone)
+	// Read before runReClone unsets the GHORG_ envs of the parent process.
+	junit, results := newRecloneOutputs(opts.JUnitReport, opts.OutputFile)
 
-	err = yaml.Unmarshal(yamlBytes, &mapOfReClones)
+	mapOfReClones, err := loadReclones(configs.GhorgReCloneLocation())
 	if err != nil {
-		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: unmarshaling reclone.yaml, error:%v", err))
+		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: %v", err))
 	}
 
 	if opts.List {
 		colorlog.PrintInfo("**************************************************************")
 		colorlog.PrintInfo("**** Available reclone commands and optional descriptions ****")
 		colorlog.PrintInfo("**************************************************************")
-		fmt.Println("")
-		for key, value := range mapOfReClones {
+		fmt.Fprintln(colorlog.Output(), "")
+		listKeys, _ := recloneKeys(mapOfReClones, nil)
+		listKeys, _ = filterRecloneTags(mapOfReClones, listKeys, splitRecloneTags(opts.Tags))
+		for _, key := range listKeys {
+			value := mapOfReClones[key]
 			colorlog.PrintInfo(fmt.Sprintf("- %s", key))
 			if value.Description != "" {
 				colorlog.PrintSubtleInfo(fmt.Sprintf("    description: %s", value.Description))
 			}
-			colorlog.PrintSubtleInfo(fmt.Sprintf("    cmd: %s", value.Cmd))
-			fmt.Println("")
+			if value.Cmd != "" {
+				colorlog.PrintSubtleInfo(fmt.Sprintf("    cmd: %s", value.Cmd))
+			} else {
+				colorlog.PrintSubtleInfo(fmt.Sprintf("    clone: %s", value.safeCommand()))
+			}
+			if value.Schedule != "" {
+				colorlog.PrintSubtleInfo(fmt.Sprintf("    schedule: %s", value.Schedule))
+			}
+			if len(value.Tags) > 0 {
+				colorlog.PrintSubtleInfo(fmt.Sprintf("    tags: %s", strings.Join(value.Tags, ", ")))
+			}
+			fmt.Fprintln(colorlog.Output(), "")
 		}
 		return 0
 	}
 
-	if len(remaining) == 0 {
-		for rcIdentifier, reclone := range mapOfReClones {
-			runReClone(reclone, rcIdentifier)
+	keys, err := recloneKeys(mapOfReClones, remaining)
+	if err != nil {
+		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: %v", err))
+	}
+	keys, err = filterRecloneTags(mapOfReClones, keys, splitRecloneTags(opts.Tags))
+	if err != nil {
+		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: %v", err))
+	}
+
+	if err := configs.ResolveSecretRefs("notify"); err != nil {
+		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: %v", err))
+	}
+
+	ran := runReclones(mapOfReClones, keys, junit, results, colorlog.Output())
+	if failed := failedReclone(ran); failed != nil {
+		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: Running reclone %s: %s", failed.Key, failed.Error))
+	}
+
+	printFinalOutput(keys)
+	return 0
+}
+
+// loadReclones reads the entries of reclone.yaml at path and validates them
+// against the configs registry.
+func loadReclones(path string) (map[string]ReClone, error) {
+	yamlBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reclone.yaml, error: %w", err)
+	}
+
+	mapOfReClones := make(map[string]ReClone)
+	if err := yaml.UnmarshalStrict(yamlBytes, &mapOfReClones); err != nil {
+		return nil, fmt.Errorf("unmarshaling reclone.yaml, error: %w", err)
+	}
+
+	keys, _ := recloneKeys(mapOfReClones, nil)
+	var errs []error
+	for _, key := range keys {
+		for _, err := range mapOfReClones[key].validate() {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
 		}
-	} else {
-		for _, rcIdentifier := range remaining {
-			if _, ok := mapOfReClones[rcIdentifier]; !ok {
-				colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: The key %v was not found in reclone.yaml", rcIdentifier))
-			} else {
-				runReClone(mapOfReClones[rcIdentifier], rcIdentifier)
-			}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid reclone.yaml:\n%w", errors.Join(errs...))
+	}
+	return mapOfReClones, nil
+}
+
+// recloneKeys returns the entries to run: every entry sorted by key when
+// requested is empty, otherwise requested after checking each one exists.
+func recloneKeys(reclones map[string]ReClone, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		keys := make([]string, 0, len(reclones))
+		for key := range reclones {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+	for _, key := range requested {
+		if _, ok := reclones[key]; !ok {
+			return nil, fmt.Errorf("the key %v was not found in reclone.yaml", key)
 		}
 	}
+	return requested, nil
+}
 
-	printFinalOutput(remaining, mapOfReClones)
-	return 0
+// newRecloneOutputs returns the JUnit report and results file collectors,
+// falling back to GHORG_JUNIT_REPORT and GHORG_OUTPUT_FILE. Either is nil
+// when no path is set.
+func newRecloneOutputs(junitPath, outputFile string) (*recloneJUnit, *recloneResults) {
+	if junitPath == "" {
+		junitPath = os.Getenv("GHORG_JUNIT_REPORT")
+	}
+	if outputFile == "" {
+		outputFile = os.Getenv("GHORG_OUTPUT_FILE")
+	}
+
+	var junit *recloneJUnit
+	if junitPath != "" {
+		junit = &recloneJUnit{path: junitPath}
+	}
+	var results *recloneResults
+	if outputFile != "" {
+		results = &recloneResults{path: outputFile}
+	}
+	return junit, results
+}
+
+// runRecloneKeys runs the reclone.yaml entries named by keys, or every entry
+// when keys is empty, for the reclone server and cron. The caller must hold
+// recloneMu.
+func runRecloneKeys(keys []string, out io.Writer) ([]RecloneResult, error) {
+	reclones, err := loadReclones(configs.GhorgReCloneLocation())
+	if err != nil {
+		return nil, err
+	}
+	keys, err = recloneKeys(reclones, keys)
+	if err != nil {
+		return nil, err
+	}
+	junit, results := newRecloneOutputs("", "")
+	return runReclones(reclones, keys, junit, results, out), nil
+}
+
+// runReclones runs the entries named by keys in order, stopping at the first
+// one that fails, and returns the result of every entry that ran. A
+// notification is sent for every entry. The output of the entries is written
+// to out.
+func runReclones(reclones map[string]ReClone, keys []string, junit *recloneJUnit, results *recloneResults, out io.Writer) []RecloneResult {
+	var ran []RecloneResult
+	for _, key := range keys {
+		result := runReClone(reclones[key], key, junit, out)
+		results.add(result)
+		notifyReclone(result)
+		ran = append(ran, result)
+		if result.Status == RecloneStatusFail {
+			break
+		}
+	}
+	return ran
+}
+
+// failedReclone returns the failed result in results, or nil.
+func failedReclone(results []RecloneResult) *RecloneResult {
+	for i := range results {
+		if results[i].Status == RecloneStatusFail {
+			return &results[i]
+		}
+	}
+	return nil
 }
 
 func isQuietReClone() bool {
 	return os.Getenv("GHORG_RECLONE_QUIET") == "true"
 }
 
-func printFinalOutput(argz []string, reCloneMap map[string]ReClone) {
-	fmt.Println("")
+func printFinalOutput(keys []string) {
+	fmt.Fprintln(colorlog.Output(), "")
 	colorlog.PrintSuccess("Completed! The following reclones were ran successfully...")
-	if len(argz) == 0 {
-		for key := range reCloneMap {
-			colorlog.PrintSuccess(fmt.Sprintf("  * %v", key))
-		}
-	} else {
-		for _, arg := range argz {
-			colorlog.PrintSuccess(fmt.Sprintf("  * %v", arg))
-		}
+	for _, key := range keys {
+		colorlog.PrintSuccess(fmt.Sprintf("  * %v", key))
 	}
 }
 
@@ -207,88 +350,249 @@ func splitCommandArgs(cmd string) []string {
 	return args
 }
 
-func runReClone(rc ReClone, rcIdentifier string) {
-	// make sure command starts with ghorg clone
-	splitCommand := splitCommandArgs(rc.Cmd)
-	ghorg, clone, remainingCommand := splitCommand[0], splitCommand[1], splitCommand[1:]
+// recloneJUnit merges the JUnit reports written by each reclone entry into a
+// single report with one test suite per entry. It is rewritten after every
+// entry so it is complete even when a failing entry stops the reclone.
+type recloneJUnit struct {
+	path   string
+	suites []junitTestSuite
+}
+
+// entryPath returns where the clone run of rcIdentifier writes its own report.
+func (j *recloneJUnit) entryPath(rcIdentifier string) string {
+	return fmt.Sprintf("%s.%s.tmp", j.path, rcIdentifier)
+}
+
+// addEntry merges the report of rcIdentifier, or a single failure when the
+// clone run exited without writing one, and rewrites the combined report.
+func (j *recloneJUnit) addEntry(rcIdentifier string, startedAt time.Time, runErr error) {
+	if j == nil {
+		return
+	}
+
+	entryPath := j.entryPath(rcIdentifier)
+	suites, err := readJUnitReport(entryPath)
+	_ = os.Remove(entryPath)
+	if err != nil || len(suites) == 0 {
+		if runErr == nil {
+			runErr = fmt.Errorf("ghorg clone did not write a JUnit report: %v", err)
+		}
+		suites = []junitTestSuite{newJUnitFailedSuite(rcIdentifier, startedAt, time.Since(startedAt), runErr)}
+	}
+	for i := range suites {
+		suites[i].Name = rcIdentifier
+		for k := range suites[i].TestCases {
+			suites[i].TestCases[k].ClassName = rcIdentifier
+		}
+	}
+	j.suites = append(j.suites, suites...)
+
+	if err := writeJUnitReport(j.path, j.suites); err != nil {
+		colorlog.PrintError(fmt.Sprintf("ERROR: Writing JUnit report %s: %v", j.path, err))
+	}
+}
+
+// Status of a reclone entry, also passed to its post_exec_script.
+const (
+	RecloneStatusSuccess = "success"
+	RecloneStatusFail    = "fail"
+)
+
+// RecloneResult is the outcome of one reclone entry. Report is the run report
+// of its clone run, nil when the run exited before writing one.
+type RecloneResult struct {
+	Key             string     `json:"key"`
+	Status          string     `json:"status"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      time.Time  `json:"finished_at"`
+	DurationSeconds float64    `json:"duration_seconds"`
+	Error           string     `json:"error,omitempty"`
+	Report          *RunReport `json:"report,omitempty"`
+}
+
+// recloneResults collects the result of every reclone entry into a JSON
+// array. Like recloneJUnit it is rewritten after every entry.
+type recloneResults struct {
+	path    string
+	results []RecloneResult
+}
+
+// add appends result and rewrites the results file.
+func (r *recloneResults) add(result RecloneResult) {
+	if r == nil {
+		return
+	}
+	r.results = append(r.results, result)
+
+	data, err := json.MarshalIndent(r.results, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(r.path, data)
+	}
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("ERROR: Writing reclone results %s: %v", r.path, err))
+	}
+}
+
+// recloneMu serializes reclones started by the reclone server and cron.
+// Entries run in-process and clone configuration lives in the process
+// environment, so only one reclone may run at a time.
+var recloneMu sync.Mutex
 
-	if ghorg != "ghorg" || clone != "clone" {
-		colorlog.PrintErrorAndExit("ERROR: Only ghorg clone commands are permitted in your reclone.yaml")
+// runReClone runs one reclone.yaml entry in-process and returns its result.
+func runReClone(rc ReClone, rcIdentifier string, junit *recloneJUnit, out io.Writer) RecloneResult {
+	defer colorlog.SetOutput(out)()
+	startedAt := time.Now()
+	result := RecloneResult{Key: rcIdentifier, StartedAt: startedAt.UTC()}
+
+	cloneArgs, cloneEnv, err := rc.cloneCommand()
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("ERROR: %s: %v", rcIdentifier, err))
+		junit.addEntry(rcIdentifier, startedAt, err)
+		return finishReclone(result, err)
 	}
 
-	safeToLogCmd := sanitizeCmd(strings.Clone(rc.Cmd))
+	safeToLogCmd := rc.safeCommand()
 
 	if !isQuietReClone() {
-		fmt.Println("")
+		fmt.Fprintln(colorlog.Output(), "")
 		colorlog.PrintInfo(fmt.Sprintf("Running reclone: %v", rcIdentifier))
 		if rc.Description != "" {
 			colorlog.PrintInfo(fmt.Sprintf("Description: %v", rc.Description))
-			fmt.Println("")
+			fmt.Fprintln(colorlog.Output(), "")
 		}
 		colorlog.PrintInfo(fmt.Sprintf("> %v", safeToLogCmd))
 	}
 
-	ghorgClone := exec.Command("ghorg", remainingCommand...)
+	if junit != nil {
+		cloneArgs = append(cloneArgs, "--junit-report="+junit.entryPath(rcIdentifier))
+	}
+
+	exitCode, report := runCloneInProcess(cloneArgs, cloneEnv, out)
+	result.Report = report
+
+	status := RecloneStatusSuccess
+	if exitCode != 0 {
+		err = fmt.Errorf("ghorg clone exited with status %d", exitCode)
+		status = RecloneStatusFail
+	}
+	junit.addEntry(rcIdentifier, startedAt, err)
+
+	if rc.PostExecScript != "" {
+		postCmd := exec.Command(rc.PostExecScript, status, rcIdentifier)
+		postCmd.Stdout = out
+		postCmd.Stderr = out
+		errPost := postCmd.Run()
+		if errPost != nil {
+			colorlog.PrintError(fmt.Sprintf("ERROR: Running post_exec_script %s: %v", rc.PostExecScript, errPost))
+		}
+	}
+
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("ERROR: Running ghorg clone cmd: %v, err: %v", safeToLogCmd, err))
+	}
+	return finishReclone(result, err)
+}
+
+// finishReclone completes result with the finish time and the outcome err.
+func finishReclone(result RecloneResult, err error) RecloneResult {
+	finishedAt := time.Now().UTC()
+	result.FinishedAt = finishedAt
+	result.DurationSeconds = finishedAt.Sub(result.StartedAt).Seconds()
+	result.Status = RecloneStatusSuccess
+	if err != nil {
+		result.Status = RecloneStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runCloneInProcess runs ghorg clone with args and env the way a new ghorg
+// process would, writing its output to out. It returns the exit code of the
+// clone and its run report, nil when the clone stopped before finishing.
+func runCloneInProcess(args []string, env map[string]string, out io.Writer) (int, *RunReport) {
+	return runInProcess(env, out, func() int {
+		return (&CloneCommand{}).Run(args)
+	})
+}
+
+// runInProcess runs a reclone entry's clone the way a new ghorg process
+// would: GHORG_ environment variables are cleared unless
+// GHORG_RECLONE_ENV_CONFIG_ONLY is set, env is set on top, and the
+// configuration is loaded again before run is called. The output of run is
+// written to out, or discarded for a quiet reclone. The environment and
+// configuration are restored afterwards so nothing the entry sets leaks into
+// the caller or the next entry. It returns the exit code of run and the run
+// report it left.
+//
+// The entry's environment and configuration are process-wide while run
+// runs, so in the reclone server and cron the caller must hold recloneMu.
+// Anything else running meanwhile sees the entry's settings, which is why the
+// server's handlers read the serverConfig taken at startup. An Exit in run is
+// only caught on the calling goroutine; on the clone's worker goroutines it
+// still ends the process, so code run there returns errors instead.
+func runInProcess(env map[string]string, out io.Writer, run func() int) (int, *RunReport) {
+	defer restoreEnv(os.Environ())
+	savedConfig, savedConfigEnv := k, configEnv
+	defer func() { k, configEnv = savedConfig, savedConfigEnv }()
 
 	if os.Getenv("GHORG_CONFIG") == "none" {
 		os.Setenv("GHORG_CONFIG", "")
 	}
-
 	os.Setenv("GHORG_RECLONE_RUNNING", "true")
-	defer os.Setenv("GHORG_RECLONE_RUNNING", "false")
 
 	if os.Getenv("GHORG_RECLONE_ENV_CONFIG_ONLY") == "false" {
-		// have to unset all ghorg envs because root command will set them on initialization of ghorg cmd
+		// unset all ghorg envs so the configuration is loaded from conf.yaml
+		// and the entry's flags only, like in a new ghorg process
 		for _, e := range os.Environ() {
-			keyValue := strings.SplitN(e, "=", 2)
-			env := keyValue[0]
-			ghorgEnv := strings.HasPrefix(env, "GHORG_")
+			env, _, _ := strings.Cut(e, "=")
 
 			// skip global flags and reclone flags which are set in the conf.yaml
 			if env == "GHORG_COLOR" || env == "GHORG_CONFIG" || env == "GHORG_RECLONE_QUIET" || env == "GHORG_RECLONE_PATH" || env == "GHORG_RECLONE_RUNNING" {
 				continue
 			}
-			if ghorgEnv {
+			if strings.HasPrefix(env, "GHORG_") {
 				os.Unsetenv(env)
 			}
 		}
 	}
 
-	// Connect ghorgClone's stdout and stderr to the current process's stdout and stderr
-	if !isQuietReClone() {
-		ghorgClone.Stdout = os.Stdout
-		ghorgClone.Stderr = os.Stderr
-	} else {
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+
+	if isQuietReClone() {
+		out = io.Discard
 		spinningSpinner.Start()
 		defer spinningSpinner.Stop()
-		ghorgClone.Stdout = nil
-		ghorgClone.Stderr = nil
 	}
 
-	err := ghorgClone.Start()
-	if err != nil {
-		spinningSpinner.Stop()
-		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: Starting ghorg clone cmd: %v, err: %v", safeToLogCmd, err))
-	}
+	defer colorlog.SetOutput(out)()
+	lastRunReport = nil
+	scm.ResetAPIStats()
+	exitCode := colorlog.CatchExit(func() int {
+		InitConfig()
+		return run()
+	})
+	return exitCode, lastRunReport
+}
 
-	err = ghorgClone.Wait()
-	status := "success"
-	if err != nil {
-		status = "fail"
+// restoreEnv resets the process environment to env, a snapshot taken with
+// os.Environ.
+func restoreEnv(env []string) {
+	want := make(map[string]string, len(env))
+	for _, e := range env {
+		key, value, _ := strings.Cut(e, "=")
+		want[key] = value
 	}
-
-	if rc.PostExecScript != "" {
-		postCmd := exec.Command(rc.PostExecScript, status, rcIdentifier)
-		postCmd.Stdout = os.Stdout
-		postCmd.Stderr = os.Stderr
-		errPost := postCmd.Run()
-		if errPost != nil {
-			colorlog.PrintError(fmt.Sprintf("ERROR: Running post_exec_script %s: %v", rc.PostExecScript, errPost))
+	for _, e := range os.Environ() {
+		key, _, _ := strings.Cut(e, "=")
+		if _, ok := want[key]; !ok {
+			os.Unsetenv(key)
 		}
 	}
-
-	if err != nil {
-		spinningSpinner.Stop()
-		colorlog.PrintErrorAndExit(fmt.Sprintf("ERROR: Running ghorg clone cmd: %v, err: %v", safeToLogCmd, err))
+	for key, value := range want {
+		if current, ok := os.LookupEnv(key); !ok || current != value {
+			os.Setenv(key, value)
+		}
 	}
 }
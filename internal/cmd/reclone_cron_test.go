@@ -3,7 +3,9 @@ package cmd
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/cli"
 )
@@ -96,6 +98,10 @@ func TestRecloneCronCommand_SetsEnvironmentVariable(t *testing.T) {
 		ErrorWriter: &buf,
 	}
 
+	defer UnsetEnv("GHORG_CRON_SCHEDULE")()
+	defer UnsetEnv("GHORG_RECLONE_PATH")()
+	os.Setenv("GHORG_RECLONE_PATH", writeCronReclones(t, "a:\n  cmd: ghorg clone a\n"))
+
 	cmd := &RecloneCronCommand{UI: ui}
 
 	// Run in a goroutine since it blocks, but we're testing flag parsing
@@ -121,8 +127,12 @@ func TestStartReCloneCron_NoTimerSet(t *testing.T) {
 		}
 	}()
 
+	defer UnsetEnv("GHORG_CRON_SCHEDULE")()
+	defer UnsetEnv("GHORG_RECLONE_PATH")()
+	os.Setenv("GHORG_RECLONE_PATH", writeCronReclones(t, "a:\n  cmd: ghorg clone a\n"))
+
 	// This should return immediately without starting a cron
-	// since GHORG_CRON_TIMER_MINUTES is not set
+	// since no entry has a schedule and GHORG_CRON_TIMER_MINUTES is not set
 	startReCloneCron()
 
 	// If we reach here, the function returned properly
@@ -141,9 +151,234 @@ func TestStartReCloneCron_InvalidTimer(t *testing.T) {
 		}
 	}()
 
+	defer UnsetEnv("GHORG_CRON_SCHEDULE")()
+	defer UnsetEnv("GHORG_RECLONE_PATH")()
+	os.Setenv("GHORG_RECLONE_PATH", writeCronReclones(t, "a:\n  cmd: ghorg clone a\n"))
+
 	// This should return immediately with an error message
 	// since the timer value is invalid
 	startReCloneCron()
 
 	// If we reach here, the function handled the invalid value properly
 }
+
+func TestRecloneCronFlags_ParseSchedule(t *testing.T) {
+	var opts RecloneCronFlags
+	parser := createTestParser(&opts)
+	_, err := parser.ParseArgs([]string{"-s", "0 2 * * *", "--timezone", "UTC", "--jitter", "5m", "--catch-up", "--list"})
+	if err != nil {
+		t.Fatalf("Failed to parse args: %v", err)
+	}
+
+	if opts.Schedule != "0 2 * * *" || opts.Timezone != "UTC" || opts.Jitter != "5m" || !opts.CatchUp || !opts.List {
+		t.Errorf("unexpected flags: %+v", opts)
+	}
+}
+
+// writeCronReclones writes a reclone.yaml with content and returns its path.
+func writeCronReclones(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "reclone.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing reclone.yaml: %v", err)
+	}
+	return path
+}
+
+func TestCronEntries(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	reclones := map[string]ReClone{
+		"critical": {Cmd: "ghorg clone a", Schedule: "*/15 * * * *"},
+		"monorepo": {Cmd: "ghorg clone b", Schedule: "@daily"},
+		"rest":     {Cmd: "ghorg clone c"},
+	}
+	from := time.Date(2026, time.March, 14, 10, 7, 0, 0, time.UTC)
+
+	t.Run("entries without a schedule are skipped", func(t *testing.T) {
+		entries, err := cronEntries(reclones)
+		if err != nil {
+			t.Fatalf("cronEntries: %v", err)
+		}
+		if len(entries) != 2 || entries[0].key != "critical" || entries[1].key != "monorepo" {
+			t.Fatalf("unexpected entries: %+v", entries)
+		}
+	})
+
+	t.Run("minutes fallback", func(t *testing.T) {
+		os.Setenv("GHORG_CRON_TIMER_MINUTES", "30")
+		defer os.Unsetenv("GHORG_CRON_TIMER_MINUTES")
+
+		entries, err := cronEntries(reclones)
+		if err != nil {
+			t.Fatalf("cronEntries: %v", err)
+		}
+		if len(entries) != 3 || entries[2].key != "rest" {
+			t.Fatalf("unexpected entries: %+v", entries)
+		}
+		if next := entries[2].schedule.Next(from); !next.Equal(from.Add(30 * time.Minute)) {
+			t.Errorf("expected rest to run every 30 minutes, next run %v", next)
+		}
+	})
+
+	t.Run("schedule fallback and time zone", func(t *testing.T) {
+		defer UnsetEnv("GHORG_CRON_")()
+		os.Setenv("GHORG_CRON_TIMER_MINUTES", "30")
+		os.Setenv("GHORG_CRON_SCHEDULE", "0 2 * * *")
+		os.Setenv("GHORG_CRON_TIMEZONE", "UTC")
+
+		entries, err := cronEntries(reclones)
+		if err != nil {
+			t.Fatalf("cronEntries: %v", err)
+		}
+		expected := time.Date(2026, time.March, 15, 2, 0, 0, 0, time.UTC)
+		if next := entries[2].schedule.Next(from); !next.Equal(expected) {
+			t.Errorf("expected %v, got %v", expected, next)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for env, value := range map[string]string{
+			"GHORG_CRON_TIMER_MINUTES": "0",
+			"GHORG_CRON_SCHEDULE":      "every day",
+			"GHORG_CRON_TIMEZONE":      "Nowhere/Nope",
+		} {
+			os.Setenv(env, value)
+			if _, err := cronEntries(reclones); err == nil {
+				t.Errorf("expected error for %s=%s", env, value)
+			}
+			os.Unsetenv(env)
+		}
+
+		bad := map[string]ReClone{"bad": {Cmd: "ghorg clone a", Schedule: "* * *"}}
+		if _, err := cronEntries(bad); err == nil {
+			t.Error("expected error for an invalid entry schedule")
+		}
+	})
+}
+
+func TestCronScheduler(t *testing.T) {
+	every := func(key string, d time.Duration) *cronEntry {
+		return &cronEntry{key: key, schedule: intervalSchedule{every: d}}
+	}
+	now := time.Date(2026, time.March, 14, 10, 0, 0, 0, time.UTC)
+
+	t.Run("due entries run and are rescheduled", func(t *testing.T) {
+		s := newCronScheduler([]*cronEntry{every("a", 15*time.Minute), every("b", time.Hour)}, cronState{}, now, 0, false)
+
+		if next := s.nextAt(); !next.Equal(now.Add(15 * time.Minute)) {
+			t.Fatalf("expected first run at %v, got %v", now.Add(15*time.Minute), next)
+		}
+		if keys := s.popDue(now.Add(14 * time.Minute)); len(keys) != 0 {
+			t.Errorf("expected nothing due, got %v", keys)
+		}
+		for i := 1; i <= 3; i++ {
+			if keys := s.popDue(now.Add(time.Duration(i) * 15 * time.Minute)); len(keys) != 1 || keys[0] != "a" {
+				t.Errorf("run %d: expected [a], got %v", i, keys)
+			}
+		}
+		if keys := s.popDue(now.Add(time.Hour)); len(keys) != 2 {
+			t.Errorf("expected [a b], got %v", keys)
+		}
+	})
+
+	t.Run("late runs skip passed times", func(t *testing.T) {
+		s := newCronScheduler([]*cronEntry{every("a", 15*time.Minute)}, cronState{}, now, 0, false)
+		if keys := s.popDue(now.Add(50 * time.Minute)); len(keys) != 1 {
+			t.Fatalf("expected [a], got %v", keys)
+		}
+		if next := s.nextAt(); !next.Equal(now.Add(65 * time.Minute)) {
+			t.Errorf("expected next run at %v, got %v", now.Add(65*time.Minute), next)
+		}
+	})
+
+	t.Run("catch up", func(t *testing.T) {
+		state := cronState{"a": now.Add(-2 * time.Hour), "b": now.Add(-10 * time.Minute)}
+		entries := []*cronEntry{every("a", time.Hour), every("b", time.Hour), every("c", time.Hour)}
+
+		s := newCronScheduler(entries, state, now, 0, true)
+		if keys := s.popDue(now); len(keys) != 1 || keys[0] != "a" {
+			t.Errorf("expected only a to catch up, got %v", keys)
+		}
+
+		entries = []*cronEntry{every("a", time.Hour)}
+		s = newCronScheduler(entries, state, now, 0, false)
+		if keys := s.popDue(now); len(keys) != 0 {
+			t.Errorf("expected no catch up without catchUp, got %v", keys)
+		}
+	})
+
+	t.Run("queued behind a running reclone", func(t *testing.T) {
+		s := newCronScheduler([]*cronEntry{every("nightly", time.Hour), every("quick", 15*time.Minute)}, cronState{}, now, 0, false)
+
+		// quick is due twice while nightly holds the lock, it waits once
+		if added := s.queue(s.popDue(now.Add(15 * time.Minute))); len(added) != 1 || added[0] != "quick" {
+			t.Errorf("expected quick to be queued, got %v", added)
+		}
+		if added := s.queue(s.popDue(now.Add(30 * time.Minute))); len(added) != 0 {
+			t.Errorf("expected quick to be queued only once, got %v", added)
+		}
+		if keys := s.takeQueued(); len(keys) != 1 || keys[0] != "quick" {
+			t.Errorf("expected [quick] to run, got %v", keys)
+		}
+		if keys := s.takeQueued(); len(keys) != 0 {
+			t.Errorf("expected an empty queue after taking it, got %v", keys)
+		}
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		jitter := 5 * time.Minute
+		for range 50 {
+			s := newCronScheduler([]*cronEntry{every("a", time.Hour)}, cronState{}, now, jitter, false)
+			at := s.nextAt()
+			if at.Before(now.Add(time.Hour)) || !at.Before(now.Add(time.Hour+jitter)) {
+				t.Fatalf("run at %v outside jitter window", at)
+			}
+		}
+	})
+}
+
+func TestCronState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", CronStateFileName)
+
+	state, err := loadCronState(path)
+	if err != nil || len(state) != 0 {
+		t.Fatalf("expected empty state for a missing file, got %v, %v", state, err)
+	}
+
+	started := time.Date(2026, time.March, 14, 10, 0, 0, 0, time.UTC)
+	recordCronRuns(path, []RecloneResult{{Key: "a", StartedAt: started}})
+	recordCronRuns(path, []RecloneResult{{Key: "b", StartedAt: started.Add(time.Hour)}})
+
+	state, err = loadCronState(path)
+	if err != nil {
+		t.Fatalf("loadCronState: %v", err)
+	}
+	if !state["a"].Equal(started) || !state["b"].Equal(started.Add(time.Hour)) {
+		t.Errorf("unexpected state: %v", state)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCronState(path); err == nil {
+		t.Error("expected error for an invalid state file")
+	}
+}
+
+func TestListReCloneCron(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	dir := t.TempDir()
+	os.Setenv("GHORG_CRON_STATE_PATH", filepath.Join(dir, CronStateFileName))
+	os.Setenv("GHORG_CRON_JITTER", "5m")
+
+	os.Setenv("GHORG_RECLONE_PATH", writeCronReclones(t, "a:\n  cmd: ghorg clone a\n  schedule: \"@hourly\"\n"))
+	if code := listReCloneCron(time.Now()); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+
+	os.Setenv("GHORG_CRON_JITTER", "soon")
+	if code := listReCloneCron(time.Now()); code != 1 {
+		t.Errorf("expected exit code 1 for an invalid jitter, got %d", code)
+	}
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/blairham/ghorg/internal/configs"
+	"github.com/blairham/ghorg/internal/cron"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// recloneFilterSection is the registry section the filters of a structured
+// reclone entry are keys of.
+const recloneFilterSection = "filter"
+
+// isStructured reports whether rc sets any field of the structured form.
+func (rc ReClone) isStructured() bool {
+	return rc.SCM != "" || rc.Target != "" || rc.CloneType != "" || rc.TokenRef != "" || len(rc.Config) > 0 || len(rc.Filters) > 0
+}
+
+// validate checks rc against the configs registry and returns every problem
+// found.
+func (rc ReClone) validate() []error {
+	var errs []error
+	switch {
+	case rc.Cmd != "" && rc.isStructured():
+		errs = append(errs, errors.New("cmd cannot be combined with scm, target, clone_type, token_ref, config or filters"))
+	case rc.Cmd == "" && rc.Target == "":
+		errs = append(errs, errors.New("either cmd or target is required"))
+	}
+
+	if rc.SCM != "" && !slices.Contains(scm.SupportedClients(), rc.SCM) {
+		supported := scm.SupportedClients()
+		sort.Strings(supported)
+		errs = append(errs, fmt.Errorf("unknown scm %q, supported are %s", rc.SCM, strings.Join(supported, ", ")))
+	}
+	if rc.CloneType != "" && rc.CloneType != "org" && rc.CloneType != "user" {
+		errs = append(errs, fmt.Errorf("clone_type must be org or user, got %q", rc.CloneType))
+	}
+	if rc.TokenRef != "" {
+		if !configs.IsSecretRef(rc.TokenRef) {
+			errs = append(errs, fmt.Errorf("invalid token_ref %q, expected env:<VARIABLE>, file:<path> or exec:<command>", rc.TokenRef))
+		}
+	}
+
+	for _, key := range sortedKeys(rc.Config) {
+		if err := validateRecloneConfig(key, rc.Config[key]); err != nil {
+			errs = append(errs, fmt.Errorf("config: %w", err))
+		}
+	}
+	for _, key := range sortedKeys(rc.Filters) {
+		if configs.LookupByDot(recloneFilterKey(key)) == nil {
+			errs = append(errs, fmt.Errorf("filters: unknown filter %q, filters are %s", key, strings.Join(sectionKeys(recloneFilterSection), ", ")))
+			continue
+		}
+		if err := validateRecloneConfig(recloneFilterKey(key), rc.Filters[key]); err != nil {
+			errs = append(errs, fmt.Errorf("filters: %w", err))
+		}
+	}
+
+	if rc.Schedule != "" {
+		if _, err := cron.Parse(rc.Schedule, nil); err != nil {
+			errs = append(errs, fmt.Errorf("schedule: %w", err))
+		}
+	}
+	if slices.Contains(rc.Tags, "") {
+		errs = append(errs, errors.New("tags cannot be empty"))
+	}
+	return errs
+}
+
+// validateRecloneConfig checks that key is a registry dot-key an entry may
+// override and that value suits it.
+func validateRecloneConfig(key, value string) error {
+	ck := configs.LookupByDot(key)
+	if ck == nil {
+		section, _, _ := strings.Cut(key, ".")
+		if keys := sectionKeys(section); len(keys) > 0 {
+			return fmt.Errorf("unknown key %q, keys in %s are %s", key, section, strings.Join(keys, ", "))
+		}
+		return fmt.Errorf("unknown key %q, sections are %s", key, strings.Join(configs.Sections(), ", "))
+	}
+	switch {
+	case ck.DotNotation == "scm.type":
+		return errors.New("set scm.type with scm instead")
+	case ck.DotNotation == "clone.type":
+		return errors.New("set clone.type with clone_type instead")
+	case ck.Section() == "reclone":
+		return fmt.Errorf("%s configures reclone itself and cannot be set per entry", key)
+	case ck.IsBool && value != "true" && value != "false":
+		return fmt.Errorf("%s must be true or false, got %q", key, value)
+	}
+	return nil
+}
+
+// recloneFilterKey returns the registry dot-key of the filter name, which
+// may be written with underscores like the other fields of an entry.
+func recloneFilterKey(name string) string {
+	return recloneFilterSection + "." + strings.ReplaceAll(name, "_", "-")
+}
+
+// sectionKeys returns the names of the registry keys in section.
+func sectionKeys(section string) []string {
+	var keys []string
+	for _, ck := range configs.AllKeys {
+		if ck.Section() == section {
+			keys = append(keys, strings.TrimPrefix(ck.DotNotation, section+"."))
+		}
+	}
+	return keys
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// cloneCommand returns the ghorg clone arguments of rc and the environment
+// variables its config and filters set. Tokens referenced by token_ref are
+// resolved here, before the environment is cleared for the entry.
+func (rc ReClone) cloneCommand() ([]string, map[string]string, error) {
+	if rc.Cmd != "" {
+		splitCommand := splitCommandArgs(rc.Cmd)
+		if len(splitCommand) < 2 || splitCommand[0] != "ghorg" || splitCommand[1] != "clone" {
+			return nil, nil, errors.New("only ghorg clone commands are permitted in your reclone.yaml")
+		}
+		return splitCommand[2:], nil, nil
+	}
+
+	args := []string{rc.Target}
+	if rc.SCM != "" {
+		args = append(args, "--scm="+rc.SCM)
+	}
+	if rc.CloneType != "" {
+		args = append(args, "--clone-type="+rc.CloneType)
+	}
+	if rc.TokenRef != "" {
+		token, err := configs.ResolveSecretRef(rc.TokenRef)
+		if err != nil {
+			return nil, nil, fmt.Errorf("token_ref %w", err)
+		}
+		args = append(args, "--token="+token)
+	}
+
+	env := make(map[string]string, len(rc.Config)+len(rc.Filters))
+	for key, value := range rc.Config {
+		env[configs.DotToEnvVar(key)] = value
+	}
+	for key, value := range rc.Filters {
+		env[configs.DotToEnvVar(recloneFilterKey(key))] = value
+	}
+	for envVar, value := range env {
+		switch envVar {
+		case "GHORG_SCM_BASE_URL":
+			env[envVar] = configs.EnsureTrailingSlashOnURL(value)
+		case "GHORG_ABSOLUTE_PATH_TO_CLONE_TO":
+			env[envVar] = configs.EnsureTrailingSlashOnFilePath(value)
+		}
+	}
+	return args, env, nil
+}
+
+// safeCommand returns the command of rc to log, with tokens and secret
+// config values replaced by XXXXXXX.
+func (rc ReClone) safeCommand() string {
+	if rc.Cmd != "" {
+		return sanitizeCmd(strings.Clone(rc.Cmd))
+	}
+
+	parts := []string{"ghorg", "clone", rc.Target}
+	if rc.SCM != "" {
+		parts = append(parts, "--scm="+rc.SCM)
+	}
+	if rc.CloneType != "" {
+		parts = append(parts, "--clone-type="+rc.CloneType)
+	}
+	if rc.TokenRef != "" {
+		parts = append(parts, "--token="+rc.TokenRef)
+	}
+
+	var settings []string
+	for _, key := range sortedKeys(rc.Config) {
+		value := rc.Config[key]
+		if ck := configs.LookupByDot(key); ck != nil && ck.IsSecret && !configs.IsSecretRef(value) {
+			value = "XXXXXXX"
+		}
+		settings = append(settings, key+"="+value)
+	}
+	for _, key := range sortedKeys(rc.Filters) {
+		settings = append(settings, recloneFilterKey(key)+"="+rc.Filters[key])
+	}
+	if len(settings) > 0 {
+		parts = append(parts, "("+strings.Join(settings, ", ")+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// hasAnyTag reports whether rc has at least one of tags.
+func (rc ReClone) hasAnyTag(tags []string) bool {
+	for _, tag := range tags {
+		if slices.Contains(rc.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRecloneTags returns the keys of the entries in keys that have at
+// least one of tags, or keys when tags is empty.
+func filterRecloneTags(reclones map[string]ReClone, keys, tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return keys, nil
+	}
+	var tagged []string
+	for _, key := range keys {
+		if reclones[key].hasAnyTag(tags) {
+			tagged = append(tagged, key)
+		}
+	}
+	if len(tagged) == 0 {
+		return nil, fmt.Errorf("no reclone entry is tagged %s", strings.Join(tags, " or "))
+	}
+	return tagged, nil
+}
+
+// splitRecloneTags splits a comma separated list of tags.
+func splitRecloneTags(list string) []string {
+	var tags []string
+	for tag := range strings.SplitSeq(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+)
+
+func TestReCloneValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rc      ReClone
+		wantErr []string
+	}{
+		{"legacy", ReClone{Cmd: "ghorg clone kubernetes", Tags: []string{"nightly"}, Schedule: "@daily"}, nil},
+		{"structured", ReClone{
+			SCM:       "gitlab",
+			Target:    "gitlab-examples",
+			CloneType: "org",
+			TokenRef:  "env:GITLAB_TOKEN",
+			Config:    map[string]string{"clone.branch": "main", "clone.preserve-dir": "true"},
+			Filters:   map[string]string{"match_regex": "^sig-", "skip-archived": "true"},
+		}, nil},
+		{"empty", ReClone{Description: "nothing"}, []string{"either cmd or target is required"}},
+		{"both forms", ReClone{Cmd: "ghorg clone a", Target: "a"}, []string{"cmd cannot be combined"}},
+		{"bad fields", ReClone{
+			SCM:       "svn",
+			Target:    "a",
+			CloneType: "team",
+			TokenRef:  "vault:secret",
+			Schedule:  "every day",
+			Tags:      []string{""},
+		}, []string{`unknown scm "svn"`, "clone_type must be org or user", "invalid token_ref", "schedule:", "tags cannot be empty"}},
+		{"bad config", ReClone{Target: "a", Config: map[string]string{
+			"clone.brnch":     "main",
+			"nope.key":        "x",
+			"scm.type":        "gitlab",
+			"reclone.quiet":   "true",
+			"clone.wiki":      "yes",
+			"github.token":    "abc",
+			"filter.topics":   "k8s",
+			"core.output-dir": "out",
+		}}, []string{
+			`unknown key "clone.brnch", keys in clone are`,
+			"clone.wiki must be true or false",
+			`unknown key "nope.key", sections are`,
+			"reclone.quiet configures reclone itself",
+			"set scm.type with scm instead",
+		}},
+		{"bad filters", ReClone{Target: "a", Filters: map[string]string{"language": "go", "skip_forks": "1"}}, []string{
+			`unknown filter "language", filters are skip-archived`,
+			"filter.skip-forks must be true or false",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.rc.validate()
+			if len(errs) != len(tt.wantErr) {
+				t.Fatalf("validate() = %v, want %d errors", errs, len(tt.wantErr))
+			}
+			for i, want := range tt.wantErr {
+				if !strings.Contains(errs[i].Error(), want) {
+					t.Errorf("error %d = %q, want it to contain %q", i, errs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadReclones(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "reclone.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	reclones, err := loadReclones(write(`
+legacy:
+  cmd: "ghorg clone kubernetes --token=XXXXXXX"
+structured:
+  scm: github
+  target: kubernetes
+  token_ref: env:GITHUB_TOKEN
+  config:
+    clone.branch: main
+    clone.depth: 1
+  filters:
+    skip_archived: true
+  tags: [nightly, k8s]
+`))
+	if err != nil {
+		t.Fatalf("loadReclones: %v", err)
+	}
+	structured := reclones["structured"]
+	if structured.Config["clone.depth"] != "1" || structured.Filters["skip_archived"] != "true" || !slices.Equal(structured.Tags, []string{"nightly", "k8s"}) {
+		t.Errorf("unexpected entry: %+v", structured)
+	}
+
+	if _, err := loadReclones(filepath.Join("..", "..", "sample-reclone.yaml")); err != nil {
+		t.Errorf("sample-reclone.yaml: %v", err)
+	}
+
+	_, err = loadReclones(write(`
+a:
+  target: a
+  clone_typ: org
+`))
+	if err == nil || !strings.Contains(err.Error(), "clone_typ") {
+		t.Errorf("expected error for an unknown field, got %v", err)
+	}
+
+	_, err = loadReclones(write(`
+a:
+  target: a
+  clone_type: team
+b:
+  description: nothing
+`))
+	if err == nil || !strings.Contains(err.Error(), "a: clone_type must be org or user") || !strings.Contains(err.Error(), "b: either cmd or target is required") {
+		t.Errorf("expected errors for both entries, got %v", err)
+	}
+}
+
+func TestReCloneCloneCommand(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	os.Setenv("GHORG_TEST_TOKEN", "env-token")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("legacy", func(t *testing.T) {
+		args, env, err := ReClone{Cmd: `ghorg clone kubernetes --match-regex "^sig-"`}.cloneCommand()
+		if err != nil || !slices.Equal(args, []string{"kubernetes", "--match-regex", "^sig-"}) || env != nil {
+			t.Errorf("cloneCommand() = %v, %v, %v", args, env, err)
+		}
+		if _, _, err := (ReClone{Cmd: "git clone x"}).cloneCommand(); err == nil {
+			t.Error("expected error for a command other than ghorg clone")
+		}
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		rc := ReClone{
+			SCM:       "github",
+			Target:    "kubernetes",
+			CloneType: "org",
+			TokenRef:  "env:GHORG_TEST_TOKEN",
+			Config:    map[string]string{"clone.branch": "main", "core.path": "/tmp/ghorg", "mirror.token": "secret"},
+			Filters:   map[string]string{"match_regex": "^sig-"},
+		}
+		args, env, err := rc.cloneCommand()
+		if err != nil {
+			t.Fatalf("cloneCommand: %v", err)
+		}
+		if !slices.Equal(args, []string{"kubernetes", "--scm=github", "--clone-type=org", "--token=env-token"}) {
+			t.Errorf("args = %v", args)
+		}
+		want := map[string]string{
+			"GHORG_BRANCH":                    "main",
+			"GHORG_ABSOLUTE_PATH_TO_CLONE_TO": "/tmp/ghorg/",
+			"GHORG_PUSH_MIRROR_TOKEN":         "secret",
+			"GHORG_MATCH_REGEX":               "^sig-",
+		}
+		for envVar, value := range want {
+			if env[envVar] != value {
+				t.Errorf("env[%s] = %q, want %q", envVar, env[envVar], value)
+			}
+		}
+
+		safe := rc.safeCommand()
+		if strings.Contains(safe, "env-token") || strings.Contains(safe, "secret") {
+			t.Errorf("safeCommand() leaks a secret: %s", safe)
+		}
+		if !strings.Contains(safe, "--token=env:GHORG_TEST_TOKEN") || !strings.Contains(safe, "filter.match-regex=^sig-") {
+			t.Errorf("safeCommand() = %s", safe)
+		}
+	})
+
+	t.Run("token refs", func(t *testing.T) {
+		for ref, want := range map[string]string{"file:" + tokenFile: "file-token", "exec:echo exec-token": "exec-token"} {
+			args, _, err := ReClone{Target: "kubernetes", TokenRef: ref}.cloneCommand()
+			if err != nil || !slices.Contains(args, "--token="+want) {
+				t.Errorf("cloneCommand() with %s = %v, %v; want --token=%s", ref, args, err, want)
+			}
+		}
+		_, _, err := ReClone{Target: "kubernetes", TokenRef: "env:GHORG_TEST_MISSING"}.cloneCommand()
+		if err == nil || !strings.HasPrefix(err.Error(), "token_ref env:GHORG_TEST_MISSING: ") {
+			t.Errorf("expected a token_ref error, got %v", err)
+		}
+	})
+}
+
+func TestRunInProcessSetsEntryEnv(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	os.Setenv("GHORG_CONFIG", filepath.Join(t.TempDir(), "conf.yaml"))
+	os.Setenv("GHORG_RECLONE_ENV_CONFIG_ONLY", "false")
+	os.Setenv("GHORG_BRANCH", "caller")
+
+	exitCode, _ := runInProcess(map[string]string{"GHORG_BRANCH": "entry"}, io.Discard, func() int {
+		if os.Getenv("GHORG_BRANCH") != "entry" {
+			return 1
+		}
+		return 0
+	})
+	if exitCode != 0 {
+		t.Error("entry env was not set while the entry ran")
+	}
+	if got := os.Getenv("GHORG_BRANCH"); got != "caller" {
+		t.Errorf("GHORG_BRANCH = %q after the entry, want caller", got)
+	}
+}
+
+func TestRunInProcessWritesToOut(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	os.Setenv("GHORG_CONFIG", filepath.Join(t.TempDir(), "conf.yaml"))
+	stdout, stderr := os.Stdout, os.Stderr
+
+	var out strings.Builder
+	runInProcess(nil, &out, func() int {
+		if os.Stdout != stdout || os.Stderr != stderr {
+			t.Error("the process streams were replaced while the entry ran")
+		}
+		colorlog.PrintSuccess("cloned the entry")
+		return 0
+	})
+	if out.String() != "cloned the entry\n" {
+		t.Errorf("out = %q, want the output of the entry", out.String())
+	}
+	if colorlog.Output() != os.Stdout {
+		t.Error("the output was not restored after the entry")
+	}
+
+	out.Reset()
+	os.Setenv("GHORG_RECLONE_QUIET", "true")
+	runInProcess(nil, &out, func() int {
+		colorlog.PrintSuccess("cloned quietly")
+		return 0
+	})
+	if out.Len() != 0 {
+		t.Errorf("out = %q, want nothing from a quiet entry", out.String())
+	}
+}
+
+func TestFilterRecloneTags(t *testing.T) {
+	reclones := map[string]ReClone{
+		"a": {Cmd: "ghorg clone a", Tags: []string{"nightly"}},
+		"b": {Cmd: "ghorg clone b", Tags: []string{"weekly", "archive"}},
+		"c": {Cmd: "ghorg clone c"},
+	}
+	keys := []string{"a", "b", "c"}
+
+	tests := []struct {
+		tags string
+		want []string
+	}{
+		{"", []string{"a", "b", "c"}},
+		{"nightly", []string{"a"}},
+		{"nightly, archive", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		got, err := filterRecloneTags(reclones, keys, splitRecloneTags(tt.tags))
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("filterRecloneTags(%q) = %v, %v; want %v", tt.tags, got, err, tt.want)
+		}
+	}
+
+	if _, err := filterRecloneTags(reclones, keys, []string{"hourly"}); err == nil {
+		t.Error("expected error when no entry has the tag")
+	}
+}
@@ -1,6 +1,12 @@
 package cmd
 
-import "testing"
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func Test_sanitizeCmd(t *testing.T) {
 	type args struct {
@@ -50,3 +56,91 @@ func Test_sanitizeCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestRunReCloneInProcess(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	os.Setenv("GHORG_CONFIG", filepath.Join(t.TempDir(), "conf.yaml"))
+	os.Setenv("GHORG_RECLONE_ENV_CONFIG_ONLY", "false")
+	os.Setenv("GHORG_RECLONE_QUIET", "true")
+	os.Setenv("GHORG_SCM_TYPE", "github")
+	os.Setenv("GHORG_CALLER_ONLY", "kept")
+
+	tests := []struct {
+		name    string
+		cmd     string
+		wantErr string
+	}{
+		{"not a clone", "git clone https://example.com/repo", "only ghorg clone commands are permitted"},
+		{"flag error", "ghorg clone --scm=gitlab", "exited with status 1"},
+		// Exits inside the clone stop the entry, not the process.
+		{"exit", "ghorg clone me --scm=gitlab --clone-type=user --github-user-gists --token=abc", "exited with status 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runReClone(ReClone{Cmd: tt.cmd}, "work", nil, io.Discard)
+			if result.Key != "work" || result.Status != RecloneStatusFail || !strings.Contains(result.Error, tt.wantErr) {
+				t.Errorf("result = %+v, want failure with %q", result, tt.wantErr)
+			}
+			if result.FinishedAt.Before(result.StartedAt) {
+				t.Errorf("FinishedAt %v is before StartedAt %v", result.FinishedAt, result.StartedAt)
+			}
+
+			// The entry's configuration does not leak into the caller.
+			if got := os.Getenv("GHORG_SCM_TYPE"); got != "github" {
+				t.Errorf("GHORG_SCM_TYPE = %q after the entry, want github", got)
+			}
+			if got := os.Getenv("GHORG_CALLER_ONLY"); got != "kept" {
+				t.Errorf("GHORG_CALLER_ONLY = %q after the entry, want kept", got)
+			}
+			for _, env := range []string{"GHORG_GITHUB_USER_GISTS", "GHORG_RECLONE_RUNNING", "GHORG_CLONE_TYPE"} {
+				if v, ok := os.LookupEnv(env); ok {
+					t.Errorf("%s = %q leaked from the entry", env, v)
+				}
+			}
+		})
+	}
+}
+
+func TestRunReclonesStopsAtFailure(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	os.Setenv("GHORG_RECLONE_QUIET", "true")
+	reclones := map[string]ReClone{
+		"a": {Cmd: "ghorg ls"},
+		"b": {Cmd: "ghorg ls"},
+	}
+
+	keys, err := recloneKeys(reclones, nil)
+	if err != nil || strings.Join(keys, ",") != "a,b" {
+		t.Fatalf("recloneKeys = %v, %v; want a,b", keys, err)
+	}
+	if _, err := recloneKeys(reclones, []string{"missing"}); err == nil {
+		t.Error("recloneKeys should fail for a missing key")
+	}
+
+	results := runReclones(reclones, keys, nil, nil, io.Discard)
+	if len(results) != 1 || results[0].Key != "a" {
+		t.Fatalf("results = %+v, want only a", results)
+	}
+	if failed := failedReclone(results); failed == nil || failed.Key != "a" {
+		t.Errorf("failedReclone = %+v, want a", failed)
+	}
+}
+
+func TestRestoreEnv(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	os.Setenv("GHORG_KEEP", "1")
+	os.Setenv("GHORG_CHANGE", "before")
+
+	snapshot := os.Environ()
+	os.Setenv("GHORG_CHANGE", "after")
+	os.Setenv("GHORG_NEW", "1")
+	os.Unsetenv("GHORG_KEEP")
+	restoreEnv(snapshot)
+
+	if os.Getenv("GHORG_KEEP") != "1" || os.Getenv("GHORG_CHANGE") != "before" {
+		t.Errorf("restoreEnv did not restore values: KEEP=%q CHANGE=%q", os.Getenv("GHORG_KEEP"), os.Getenv("GHORG_CHANGE"))
+	}
+	if _, ok := os.LookupEnv("GHORG_NEW"); ok {
+		t.Error("restoreEnv should unset variables missing from the snapshot")
+	}
+}
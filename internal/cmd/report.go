@@ -0,0 +1,511 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/cli"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+)
+
+type ReportCommand struct {
+	UI cli.Ui
+}
+
+type ReportFlags struct {
+	OutputFile string `short:"o" long:"output-file" default:"ghorg-report.html" description:"Path to write the HTML report to, - for stdout"`
+	Target     string `long:"target" description:"Only include runs and repos of this clone target (org or user)"`
+	Top        int    `long:"top" default:"10" description:"Number of repos listed in the slowest and most failing tables"`
+	StatsFile  string `long:"stats-file" description:"Path to _ghorg_stats.csv (default GHORG_ABSOLUTE_PATH_TO_CLONE_TO/_ghorg_stats.csv)"`
+	StateFile  string `long:"state-file" description:"Path to _ghorg_state.json (default GHORG_ABSOLUTE_PATH_TO_CLONE_TO/_ghorg_state.json)"`
+}
+
+func (c *ReportCommand) Help() string {
+	return `Usage: ghorg report [options]
+
+Generate a self-contained HTML dashboard from the clone history in
+_ghorg_stats.csv (written by ghorg clone --stats-enabled) and the per-repo
+state in _ghorg_state.json. The page shows run durations, new commits, error
+rates and disk usage over time, and the slowest and most failing repos.
+
+The same page is served by ghorg reclone-server at /report.
+
+Options:
+  -o, --output-file   Path to write the HTML report to, - for stdout (default ghorg-report.html)
+  --target            Only include runs and repos of this clone target
+  --top               Number of repos in the slowest and most failing tables (default 10)
+  --stats-file        Path to _ghorg_stats.csv
+  --state-file        Path to _ghorg_state.json
+
+Examples:
+  ghorg report
+  ghorg report --target kubernetes -o kubernetes.html
+  ghorg report -o - > /var/www/ghorg/index.html
+`
+}
+
+func (c *ReportCommand) Synopsis() string {
+	return "Generate an HTML dashboard of clone history"
+}
+
+func (c *ReportCommand) Run(args []string) int {
+	var opts ReportFlags
+	parser := flags.NewParser(&opts, flags.Default)
+	_, err := parser.ParseArgs(args)
+	if err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(c.Help())
+			return 0
+		}
+		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
+		return 1
+	}
+
+	statsPath := opts.StatsFile
+	if statsPath == "" {
+		statsPath = getGhorgStatsFilePath()
+	}
+	statePath := opts.StateFile
+	if statePath == "" {
+		statePath = getGhorgStateFilePath()
+	}
+
+	data, err := buildReportData(statsPath, statePath, opts.Target, opts.Top)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+
+	if opts.OutputFile == "-" {
+		if err := renderReport(os.Stdout, data); err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+		return 0
+	}
+
+	var sb strings.Builder
+	if err := renderReport(&sb, data); err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	if err := writeFileAtomic(opts.OutputFile, []byte(sb.String())); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error writing report: %v", err))
+		return 1
+	}
+	colorlog.PrintSuccess(fmt.Sprintf("Wrote report of %d runs to %s", len(data.Runs), opts.OutputFile))
+	return 0
+}
+
+// statsRun is one row of _ghorg_stats.csv.
+type statsRun struct {
+	Time            time.Time
+	ClonePath       string
+	SCM             string
+	CloneType       string
+	Target          string
+	Total           int
+	NewClones       int
+	Pulled          int
+	DirSizeMB       float64
+	NewCommits      int
+	Synced          int
+	Infos           int
+	Errors          int
+	UpdateRemote    int
+	Prune           int
+	HasCollisions   bool
+	GhorgIgnore     bool
+	GhorgOnly       bool
+	DurationSeconds int
+	Version         string
+}
+
+// ErrorRate is the percentage of repos in the run that ended in an error.
+func (r statsRun) ErrorRate() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Total) * 100
+}
+
+// reportRepo is a repo entry of _ghorg_state.json.
+type reportRepo struct {
+	Name            string
+	URL             string
+	LastStatus      string
+	LastError       string
+	DurationSeconds float64
+	FailureCount    int
+}
+
+type reportChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+// reportData is everything rendered by the report template.
+type reportData struct {
+	GeneratedAt time.Time
+	Target      string
+	Runs        []statsRun
+	RecentRuns  []statsRun
+	Charts      []reportChart
+	RepoCount   int
+	Slowest     []reportRepo
+	MostFailing []reportRepo
+}
+
+// LastRun returns the most recent run, or a zero run when there are none.
+func (d reportData) LastRun() statsRun {
+	if len(d.Runs) == 0 {
+		return statsRun{}
+	}
+	return d.Runs[len(d.Runs)-1]
+}
+
+// buildReportData reads the stats CSV and state file. Either may be missing,
+// but not both. An empty target includes every target.
+func buildReportData(statsPath, statePath, target string, top int) (reportData, error) {
+	data := reportData{GeneratedAt: time.Now(), Target: target}
+
+	runs, statsErr := readStatsRuns(statsPath, target)
+	if statsErr != nil && !errors.Is(statsErr, os.ErrNotExist) {
+		return data, statsErr
+	}
+	data.Runs = runs
+
+	_, stateStatErr := os.Stat(statePath)
+	if errors.Is(statsErr, os.ErrNotExist) && errors.Is(stateStatErr, os.ErrNotExist) {
+		return data, fmt.Errorf("no clone history found at %s, run ghorg clone with --stats-enabled first", statsPath)
+	}
+
+	state, err := LoadState(statePath, "", "")
+	if err != nil {
+		return data, err
+	}
+
+	var repos []reportRepo
+	for url, r := range state.Repos {
+		if target != "" && !repoInTarget(url, r.HostPath, target) {
+			continue
+		}
+		repos = append(repos, reportRepo{
+			Name:            r.Name,
+			URL:             url,
+			LastStatus:      r.LastStatus,
+			LastError:       r.LastError,
+			DurationSeconds: r.LastDurationSeconds,
+			FailureCount:    r.FailureCount,
+		})
+	}
+	data.RepoCount = len(repos)
+	data.Slowest = topRepos(repos, top, func(r reportRepo) float64 { return r.DurationSeconds })
+	data.MostFailing = topRepos(repos, top, func(r reportRepo) float64 { return float64(r.FailureCount) })
+
+	data.RecentRuns = append([]statsRun{}, runs[max(0, len(runs)-20):]...)
+	for i, j := 0, len(data.RecentRuns)-1; i < j; i, j = i+1, j-1 {
+		data.RecentRuns[i], data.RecentRuns[j] = data.RecentRuns[j], data.RecentRuns[i]
+	}
+
+	data.Charts = []reportChart{
+		{"Run duration (seconds)", svgChart(runs, func(r statsRun) float64 { return float64(r.DurationSeconds) }, "s", false)},
+		{"New commits per run", svgChart(runs, func(r statsRun) float64 { return float64(r.NewCommits) }, " commits", true)},
+		{"Error rate (%)", svgChart(runs, statsRun.ErrorRate, "%", false)},
+		{"Disk usage (MB)", svgChart(runs, func(r statsRun) float64 { return r.DirSizeMB }, " MB", false)},
+	}
+	return data, nil
+}
+
+// repoInTarget reports whether a state entry belongs to target, judged by the
+// owner segment of its URL or a directory of its clone path.
+func repoInTarget(url, hostPath, target string) bool {
+	target = strings.ToLower(target)
+	for _, s := range [][]string{strings.Split(strings.ToLower(url), "/"), strings.Split(strings.ToLower(hostPath), string(os.PathSeparator))} {
+		for _, seg := range s {
+			if seg == target || seg == strings.ReplaceAll(target, "-", "_") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// topRepos returns the n repos with the highest non-zero key, highest first.
+func topRepos(repos []reportRepo, n int, key func(reportRepo) float64) []reportRepo {
+	var out []reportRepo
+	for _, r := range repos {
+		if key(r) > 0 {
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if key(out[i]) != key(out[j]) {
+			return key(out[i]) > key(out[j])
+		}
+		return out[i].Name < out[j].Name
+	})
+	if n >= 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// readStatsRuns parses _ghorg_stats.csv, oldest run first. Columns are looked
+// up by header name so files written by older versions can still be read.
+func readStatsRuns(path, target string) ([]statsRun, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read stats file %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	col := make(map[string]int)
+	for i, name := range records[0] {
+		col[name] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	atoi := func(row []string, name string) int {
+		n, _ := strconv.Atoi(field(row, name))
+		return n
+	}
+	atob := func(row []string, name string) bool {
+		b, _ := strconv.ParseBool(field(row, name))
+		return b
+	}
+
+	var runs []statsRun
+	for _, row := range records[1:] {
+		if target != "" && !strings.EqualFold(field(row, "cloneTarget"), target) {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", field(row, "datetime"), time.Local)
+		if err != nil {
+			continue
+		}
+		size, _ := strconv.ParseFloat(field(row, "dirSizeInMB"), 64)
+		runs = append(runs, statsRun{
+			Time:            t,
+			ClonePath:       field(row, "clonePath"),
+			SCM:             field(row, "scm"),
+			CloneType:       field(row, "cloneType"),
+			Target:          field(row, "cloneTarget"),
+			Total:           atoi(row, "totalCount"),
+			NewClones:       atoi(row, "newClonesCount"),
+			Pulled:          atoi(row, "existingResourcesPulledCount"),
+			DirSizeMB:       size,
+			NewCommits:      atoi(row, "newCommits"),
+			Synced:          atoi(row, "syncedCount"),
+			Infos:           atoi(row, "cloneInfosCount"),
+			Errors:          atoi(row, "cloneErrorsCount"),
+			UpdateRemote:    atoi(row, "updateRemoteCount"),
+			Prune:           atoi(row, "pruneCount"),
+			HasCollisions:   atob(row, "hasCollisions"),
+			GhorgIgnore:     atob(row, "ghorgignore"),
+			GhorgOnly:       atob(row, "ghorgonly"),
+			DurationSeconds: atoi(row, "totalDurationSeconds"),
+			Version:         field(row, "ghorgVersion"),
+		})
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, nil
+}
+
+const (
+	chartWidth   = 640
+	chartHeight  = 180
+	chartPadLeft = 48
+	chartPad     = 16
+)
+
+// svgChart renders value over all runs as an inline SVG line or bar chart.
+// Every point carries a tooltip with the run time, target and value.
+func svgChart(runs []statsRun, value func(statsRun) float64, unit string, bars bool) template.HTML {
+	if len(runs) == 0 {
+		return template.HTML(`<p class="empty">No runs recorded yet.</p>`)
+	}
+
+	maxValue := 0.0
+	for _, r := range runs {
+		maxValue = max(maxValue, value(r))
+	}
+	if maxValue == 0 {
+		maxValue = 1
+	}
+
+	plotW := float64(chartWidth - chartPadLeft - chartPad)
+	plotH := float64(chartHeight - 2*chartPad)
+	step := plotW / float64(max(1, len(runs)-1))
+	if bars {
+		step = plotW / float64(len(runs))
+	}
+	x := func(i int) float64 {
+		if bars {
+			return chartPadLeft + step*float64(i)
+		}
+		if len(runs) == 1 {
+			return chartPadLeft + plotW/2
+		}
+		return chartPadLeft + step*float64(i)
+	}
+	y := func(v float64) float64 { return chartPad + plotH - v/maxValue*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" role="img" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<line class="axis" x1="%d" y1="%d" x2="%d" y2="%d"/>`, chartPadLeft, chartHeight-chartPad, chartWidth-chartPad, chartHeight-chartPad)
+	fmt.Fprintf(&b, `<text class="label" x="%d" y="%d" text-anchor="end">%s</text>`, chartPadLeft-6, chartPad+4, formatChartValue(maxValue))
+	fmt.Fprintf(&b, `<text class="label" x="%d" y="%d" text-anchor="end">0</text>`, chartPadLeft-6, chartHeight-chartPad)
+
+	var points []string
+	for i, r := range runs {
+		v := value(r)
+		tooltip := template.HTMLEscapeString(fmt.Sprintf("%s %s: %s%s", r.Time.Format("2006-01-02 15:04"), r.Target, formatChartValue(v), unit))
+		if bars {
+			fmt.Fprintf(&b, `<rect class="bar" x="%.1f" y="%.1f" width="%.1f" height="%.1f"><title>%s</title></rect>`,
+				x(i)+step*0.1, y(v), step*0.8, chartPad+plotH-y(v), tooltip)
+			continue
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(i), y(v)))
+		fmt.Fprintf(&b, `<circle class="point" cx="%.1f" cy="%.1f" r="3"><title>%s</title></circle>`, x(i), y(v), tooltip)
+	}
+	if len(points) > 1 {
+		fmt.Fprintf(&b, `<polyline class="line" points="%s"/>`, strings.Join(points, " "))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func formatChartValue(v float64) string {
+	if v == float64(int64(v)) {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+// renderReport writes the dashboard for data as a single HTML page with no
+// external assets.
+func renderReport(w io.Writer, data reportData) error {
+	if err := reportTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("render report: %w", err)
+	}
+	return nil
+}
+
+// handleReport serves the dashboard for the configured ghorg directory,
+// optionally filtered with ?target=.
+func (c serverConfig) handleReport(w http.ResponseWriter, r *http.Request) {
+	data, err := buildReportData(c.statsFile, c.stateFile, r.URL.Query().Get("target"), 10)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := renderReport(w, data); err != nil {
+		http.Error(w, "Unable to render report", http.StatusInternalServerError)
+	}
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": func(s float64) string {
+		return (time.Duration(s * float64(time.Second))).Round(100 * time.Millisecond).String()
+	},
+	"percent": func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) + "%" },
+}).Parse(reportHTML))
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>ghorg report{{if .Target}} - {{.Target}}{{end}}</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem auto; max-width: 1100px; padding: 0 1rem; color: #1f2328; background: #fff; }
+  h1 { margin-bottom: 0.2rem; }
+  .meta { color: #656d76; margin-top: 0; }
+  .cards { display: grid; grid-template-columns: repeat(auto-fit, minmax(160px, 1fr)); gap: 1rem; margin: 1.5rem 0; }
+  .card { border: 1px solid #d0d7de; border-radius: 6px; padding: 0.8rem 1rem; }
+  .card .value { font-size: 1.6rem; font-weight: 600; }
+  .card .name { color: #656d76; font-size: 0.85rem; }
+  .charts { display: grid; grid-template-columns: repeat(auto-fit, minmax(480px, 1fr)); gap: 1rem; }
+  .chart { border: 1px solid #d0d7de; border-radius: 6px; padding: 0.5rem 1rem; }
+  .chart h3 { margin: 0.3rem 0; font-size: 1rem; }
+  svg { width: 100%; height: auto; }
+  .axis { stroke: #d0d7de; }
+  .label { fill: #656d76; font-size: 11px; }
+  .line { fill: none; stroke: #0969da; stroke-width: 2; }
+  .point { fill: #0969da; }
+  .bar { fill: #1a7f37; }
+  table { border-collapse: collapse; width: 100%; margin-bottom: 2rem; font-size: 0.9rem; }
+  th, td { text-align: left; padding: 0.4rem 0.6rem; border-bottom: 1px solid #d0d7de; vertical-align: top; }
+  th { background: #f6f8fa; }
+  td.num { text-align: right; font-variant-numeric: tabular-nums; }
+  .error { color: #cf222e; }
+  .empty { color: #656d76; }
+</style>
+</head>
+<body>
+<h1>ghorg report{{if .Target}}: {{.Target}}{{end}}</h1>
+<p class="meta">Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}} from {{len .Runs}} runs and {{.RepoCount}} repos.</p>
+
+{{if .Runs}}{{with .LastRun}}
+<div class="cards">
+  <div class="card"><div class="value">{{.Time.Format "Jan 2 15:04"}}</div><div class="name">Last run ({{.Target}})</div></div>
+  <div class="card"><div class="value">{{.DurationSeconds}}s</div><div class="name">Last run duration</div></div>
+  <div class="card"><div class="value">{{.Total}}</div><div class="name">Repos in last run</div></div>
+  <div class="card"><div class="value">{{.NewCommits}}</div><div class="name">New commits in last run</div></div>
+  <div class="card"><div class="value{{if .Errors}} error{{end}}">{{percent .ErrorRate}}</div><div class="name">Last run error rate</div></div>
+</div>
+{{end}}{{end}}
+
+<h2>History</h2>
+<div class="charts">
+{{range .Charts}}  <div class="chart"><h3>{{.Title}}</h3>{{.SVG}}</div>
+{{end}}</div>
+
+<h2>Slowest repos</h2>
+{{if .Slowest}}<table>
+  <tr><th>Repo</th><th>Last status</th><th class="num">Last duration</th></tr>
+{{range .Slowest}}  <tr><td title="{{.URL}}">{{.Name}}</td><td>{{.LastStatus}}</td><td class="num">{{seconds .DurationSeconds}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No repo durations recorded yet.</p>{{end}}
+
+<h2>Most failing repos</h2>
+{{if .MostFailing}}<table>
+  <tr><th>Repo</th><th class="num">Failures</th><th>Last status</th><th>Last error</th></tr>
+{{range .MostFailing}}  <tr><td title="{{.URL}}">{{.Name}}</td><td class="num">{{.FailureCount}}</td><td>{{.LastStatus}}</td><td class="error">{{.LastError}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No failures recorded.</p>{{end}}
+
+<h2>Recent runs</h2>
+{{if .RecentRuns}}<table>
+  <tr><th>Time</th><th>SCM</th><th>Target</th><th class="num">Repos</th><th class="num">New</th><th class="num">Pulled</th><th class="num">New commits</th><th class="num">Errors</th><th class="num">Duration</th><th class="num">Disk (MB)</th></tr>
+{{range .RecentRuns}}  <tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.SCM}}</td><td>{{.Target}}</td><td class="num">{{.Total}}</td><td class="num">{{.NewClones}}</td><td class="num">{{.Pulled}}</td><td class="num">{{.NewCommits}}</td><td class="num{{if .Errors}} error{{end}}">{{.Errors}}</td><td class="num">{{.DurationSeconds}}s</td><td class="num">{{printf "%.2f" .DirSizeMB}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No runs recorded yet, run ghorg clone with --stats-enabled.</p>{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+const testStatsCSV = `datetime,clonePath,scm,cloneType,cloneTarget,totalCount,newClonesCount,existingResourcesPulledCount,dirSizeInMB,newCommits,syncedCount,cloneInfosCount,cloneErrorsCount,updateRemoteCount,pruneCount,hasCollisions,ghorgignore,ghorgonly,totalDurationSeconds,ghorgVersion
+2026-01-02 10:00:00,/ghorg/org,github,org,org,10,2,8,120.50,30,0,0,1,0,0,false,false,false,42,v1
+2026-01-01 10:00:00,/ghorg/org,github,org,org,8,8,0,100.00,0,0,0,0,0,0,false,false,false,90,v1
+2026-01-03 10:00:00,/ghorg/other,gitlab,group,other,4,0,4,10.00,5,0,0,2,0,0,false,false,false,7,v1
+`
+
+// setupReportFixture writes a stats CSV and a state file to a temp dir.
+func setupReportFixture(t *testing.T) (statsPath, statePath string) {
+	t.Helper()
+	dir := t.TempDir()
+	statsPath = filepath.Join(dir, "_ghorg_stats.csv")
+	statePath = filepath.Join(dir, StateFileName)
+	if err := os.WriteFile(statsPath, []byte(testStatsCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := NewStateManifest("github", "org")
+	repos := []struct {
+		name     string
+		duration time.Duration
+		failures int
+	}{
+		{"fast", time.Second, 0},
+		{"slow", 30 * time.Second, 1},
+		{"flaky", 5 * time.Second, 3},
+	}
+	for _, r := range repos {
+		repo := scm.Repo{Name: r.name, URL: "https://github.com/org/" + r.name, HostPath: "/ghorg/org/" + r.name}
+		state.Record(repo, StateStatusOK, "sha", "")
+		for range r.failures {
+			state.Record(repo, StateStatusError, "", "Problem trying to clone Repo: "+repo.URL)
+		}
+		state.RecordDuration(repo.URL, r.duration)
+	}
+	if err := SaveState(statePath, state); err != nil {
+		t.Fatal(err)
+	}
+	return statsPath, statePath
+}
+
+func TestBuildReportData(t *testing.T) {
+	statsPath, statePath := setupReportFixture(t)
+
+	data, err := buildReportData(statsPath, statePath, "", 2)
+	if err != nil {
+		t.Fatalf("buildReportData failed: %v", err)
+	}
+	if len(data.Runs) != 3 || data.Runs[0].DurationSeconds != 90 || data.LastRun().Target != "other" {
+		t.Errorf("runs = %+v, want 3 runs sorted oldest first", data.Runs)
+	}
+	if data.Runs[1].ErrorRate() != 10 || data.Runs[1].DirSizeMB != 120.5 {
+		t.Errorf("second run = %+v, want 10%% error rate and 120.5 MB", data.Runs[1])
+	}
+	if data.RecentRuns[0].Target != "other" {
+		t.Errorf("recent runs should be newest first, got %+v", data.RecentRuns)
+	}
+	if len(data.Slowest) != 2 || data.Slowest[0].Name != "slow" || data.Slowest[1].Name != "flaky" {
+		t.Errorf("slowest = %+v, want slow then flaky", data.Slowest)
+	}
+	if len(data.MostFailing) != 2 || data.MostFailing[0].Name != "flaky" || data.MostFailing[0].FailureCount != 3 {
+		t.Errorf("most failing = %+v, want flaky with 3 failures first", data.MostFailing)
+	}
+
+	filtered, err := buildReportData(statsPath, statePath, "other", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered.Runs) != 1 || filtered.RepoCount != 0 {
+		t.Errorf("filtered report = %d runs, %d repos; want 1 and 0", len(filtered.Runs), filtered.RepoCount)
+	}
+}
+
+func TestBuildReportDataMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := buildReportData(filepath.Join(dir, "_ghorg_stats.csv"), filepath.Join(dir, StateFileName), "", 10)
+	if err == nil {
+		t.Fatal("Expected an error without stats or state")
+	}
+
+	// State alone is enough.
+	_, statePath := setupReportFixture(t)
+	data, err := buildReportData(filepath.Join(dir, "_ghorg_stats.csv"), statePath, "", 10)
+	if err != nil || len(data.Runs) != 0 || data.RepoCount != 3 {
+		t.Errorf("buildReportData = %d runs, %d repos, %v; want 0, 3, nil", len(data.Runs), data.RepoCount, err)
+	}
+}
+
+func TestRenderReport(t *testing.T) {
+	statsPath, statePath := setupReportFixture(t)
+	data, err := buildReportData(statsPath, statePath, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if err := renderReport(&sb, data); err != nil {
+		t.Fatalf("renderReport failed: %v", err)
+	}
+	page := sb.String()
+
+	for _, want := range []string{"<svg", "<polyline", "<rect class=\"bar\"", "Run duration", "Disk usage", "flaky", "2026-01-03 10:00:00"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+	for _, external := range []string{"<script", "<link", "<img"} {
+		if strings.Contains(page, external) {
+			t.Errorf("report should be self-contained, found %q", external)
+		}
+	}
+}
+
+func TestSvgChartEscapesLabels(t *testing.T) {
+	runs := []statsRun{{Time: time.Now(), Target: "<script>", DurationSeconds: 1}}
+	svg := string(svgChart(runs, func(r statsRun) float64 { return float64(r.DurationSeconds) }, "s", false))
+	if strings.Contains(svg, "<script>") {
+		t.Errorf("chart labels must be escaped: %s", svg)
+	}
+}
+
+func TestHandleReport(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	statsPath, _ := setupReportFixture(t)
+	os.Setenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO", filepath.Dir(statsPath))
+
+	config := newServerConfigFromEnv()
+	// A job running an entry with another clone directory doesn't change
+	// what the server reports.
+	os.Setenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO", t.TempDir())
+
+	rec := httptest.NewRecorder()
+	config.handleReport(rec, httptest.NewRequest(http.MethodGet, "/report?target=org", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "ghorg report: org") {
+		t.Error("Expected report filtered to org")
+	}
+
+	rec = httptest.NewRecorder()
+	newServerConfigFromEnv().handleReport(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status without history = %d, want 404", rec.Code)
+	}
+}
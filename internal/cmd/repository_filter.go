@@ -12,7 +12,11 @@ import (
 )
 
 // RepositoryFilter handles filtering of repositories based on various criteria
-type RepositoryFilter struct{}
+type RepositoryFilter struct {
+	// statePath is the state manifest --retry-failed reads, the one of the
+	// clone configured in the environment when empty.
+	statePath string
+}
 
 // NewRepositoryFilter creates a new repository filter
 func NewRepositoryFilter() *RepositoryFilter {
@@ -69,7 +73,10 @@ func (rf *RepositoryFilter) ApplyAllFilters(cloneTargets []scm.Repo) []scm.Repo
 // status in _ghorg_state.json was an error. If the state file is missing or
 // unreadable, returns the input unchanged so a fresh run isn't blocked.
 func (rf *RepositoryFilter) FilterByRetryFailed(repos []scm.Repo) []scm.Repo {
-	statePath := getGhorgStateFilePath()
+	statePath := rf.statePath
+	if statePath == "" {
+		statePath = getGhorgStateFilePath()
+	}
 	state, err := LoadState(statePath, os.Getenv("GHORG_SCM_TYPE"), targetCloneSource)
 	if err != nil {
 		colorlog.PrintInfo(fmt.Sprintf("--retry-failed: could not read %s (%v); cloning all repos", statePath, err))
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -29,36 +30,46 @@ func applyCloneDelay(repoURL string) {
 
 // RepositoryProcessor handles the processing of individual repositories
 type RepositoryProcessor struct {
-	git            git.Gitter
-	stats          *CloneStats
-	state          *StateManifest
-	mutex          *sync.RWMutex
+	git         git.Gitter
+	stats       *CloneStats
+	state       *StateManifest
+	mirror      *pushMirror
+	searchIndex *SearchIndex
+	reporter    *runReporter
+	hooks       *runHooks
+	outputDir   string
+	mutex       *sync.RWMutex
+	// renameMu serializes moving renamed repos so a clone is never moved
+	// while another repo checks who its directory belongs to.
+	renameMu       *sync.Mutex
 	untouchedRepos []string
 	protectedRepos []string
 }
 
 // CloneStats tracks statistics during clone operations
 type CloneStats struct {
-	CloneCount           int
-	PulledCount          int
-	SkippedCount         int
-	ProtectedCount       int
-	UpdateRemoteCount    int
-	NewCommits           int
-	UntouchedPrunes      int
-	SyncedCount          int
-	TotalDurationSeconds int
-	CloneInfos           []string
-	CloneErrors          []string
-	CloneSkipped         []string
+	CloneCount           int      `json:"clone_count"`
+	PulledCount          int      `json:"pulled_count"`
+	SkippedCount         int      `json:"skipped_count"`
+	ProtectedCount       int      `json:"protected_count"`
+	RenamedCount         int      `json:"renamed_count"`
+	UpdateRemoteCount    int      `json:"update_remote_count"`
+	NewCommits           int      `json:"new_commits"`
+	UntouchedPrunes      int      `json:"untouched_prunes"`
+	SyncedCount          int      `json:"synced_count"`
+	TotalDurationSeconds int      `json:"total_duration_seconds"`
+	CloneInfos           []string `json:"clone_infos"`
+	CloneErrors          []string `json:"clone_errors"`
+	CloneSkipped         []string `json:"clone_skipped"`
 }
 
 // NewRepositoryProcessor creates a new repository processor
 func NewRepositoryProcessor(git git.Gitter) *RepositoryProcessor {
 	return &RepositoryProcessor{
-		git:   git,
-		stats: &CloneStats{},
-		mutex: &sync.RWMutex{},
+		git:      git,
+		stats:    &CloneStats{},
+		mutex:    &sync.RWMutex{},
+		renameMu: &sync.Mutex{},
 	}
 }
 
@@ -77,6 +88,140 @@ func (rp *RepositoryProcessor) State() *StateManifest {
 	return rp.state
 }
 
+// SetPushMirror attaches a push mirror. Every successfully cloned or pulled
+// repository is pushed to it. Pass nil to disable push mirroring (the default).
+func (rp *RepositoryProcessor) SetPushMirror(mirror *pushMirror) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	rp.mirror = mirror
+}
+
+// SetSearchIndex attaches a search index. The shard of every successfully
+// cloned or pulled repository is rebuilt when its HEAD changed since the
+// previous run. Pass nil to disable indexing (the default).
+func (rp *RepositoryProcessor) SetSearchIndex(ix *SearchIndex) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	rp.searchIndex = ix
+}
+
+// SetReporter attaches a run reporter that receives an event for every repo
+// queued and every repo outcome. Pass nil to disable reporting (the default).
+func (rp *RepositoryProcessor) SetReporter(reporter *runReporter) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	rp.reporter = reporter
+}
+
+// SetOutputDir sets the directory repos are cloned into. By default it is
+// outputDirAbsolutePath at the time each repo is processed.
+func (rp *RepositoryProcessor) SetOutputDir(dir string) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	rp.outputDir = dir
+}
+
+// cloneDir returns the directory repos are cloned into.
+func (rp *RepositoryProcessor) cloneDir() string {
+	rp.mutex.RLock()
+	defer rp.mutex.RUnlock()
+	if rp.outputDir != "" {
+		return rp.outputDir
+	}
+	return outputDirAbsolutePath
+}
+
+// SetHooks attaches the hooks run for every repo and around the run. Pass nil
+// to disable hooks (the default).
+func (rp *RepositoryProcessor) SetHooks(hooks *runHooks) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	rp.hooks = hooks
+}
+
+// getHooks returns the attached hooks, or nil.
+func (rp *RepositoryProcessor) getHooks() *runHooks {
+	rp.mutex.RLock()
+	defer rp.mutex.RUnlock()
+	return rp.hooks
+}
+
+// RunPreRunHook runs the pre-run hook, if any, before the repoCount repos of
+// the run are processed. An error means the run should not go ahead.
+func (rp *RepositoryProcessor) RunPreRunHook(repoCount int) error {
+	return rp.getHooks().runPreRun(repoCount)
+}
+
+// RunPostRunHook runs the post-run hook, if any, with the stats of the run.
+// Failures are printed, they do not change the outcome of the run.
+func (rp *RepositoryProcessor) RunPostRunHook() {
+	if err := rp.getHooks().runPostRun(rp.GetStats()); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Problem running hook, error: %v", err))
+	}
+}
+
+// runRepoHook runs hook for repo, if configured. Failures are reported as
+// infos, they never fail the clone.
+func (rp *RepositoryProcessor) runRepoHook(hook string, repo *scm.Repo, status, oldSHA, newSHA, message string) {
+	if err := rp.getHooks().runRepo(hook, *repo, status, oldSHA, newSHA, message); err != nil {
+		rp.addInfo(fmt.Sprintf("Problem running hook for %s, error: %v", repo.URL, err))
+	}
+}
+
+// runSuccessHooks runs the post-clone hook for a new clone of repo, or the
+// post-pull-with-changes hook when pulling moved HEAD away from shaBefore.
+func (rp *RepositoryProcessor) runSuccessHooks(repo *scm.Repo, pulled bool, shaBefore string) {
+	hooks := rp.getHooks()
+	hook, status := HookPostClone, HookStatusCloned
+	if pulled {
+		hook, status = HookPostPullWithChanges, HookStatusPulled
+	}
+	if !hooks.has(hook) {
+		return
+	}
+
+	newSHA, err := rp.git.HeadSHA(*repo)
+	if pulled {
+		changed := repo.Commits.CountDiff > 0
+		if err == nil && shaBefore != "" {
+			changed = newSHA != shaBefore
+		}
+		if !changed {
+			return
+		}
+	}
+	rp.runRepoHook(hook, repo, status, shaBefore, newSHA, "")
+}
+
+// reportQueued emits the queued event for repo.
+func (rp *RepositoryProcessor) reportQueued(repo scm.Repo) {
+	rp.mutex.RLock()
+	reporter := rp.reporter
+	rp.mutex.RUnlock()
+	reporter.Emit(repoEvent(RepoEventQueued, repo))
+}
+
+// report emits the outcome of repo to the attached reporter, if any.
+// shaBefore is the HEAD of an existing clone before it was updated.
+func (rp *RepositoryProcessor) report(event string, repo *scm.Repo, start time.Time, shaBefore, message string) {
+	rp.mutex.RLock()
+	reporter := rp.reporter
+	rp.mutex.RUnlock()
+	if reporter == nil {
+		return
+	}
+
+	ev := repoEvent(event, *repo)
+	ev.DurationSeconds = time.Since(start).Seconds()
+	ev.SHABefore = shaBefore
+	ev.Message = message
+	if event == RepoEventCloned || event == RepoEventPulled || event == RepoEventRenamed {
+		ev.SHAAfter, _ = rp.git.HeadSHA(*repo)
+		ev.NewCommits = repo.Commits.CountDiff
+	}
+	reporter.Emit(ev)
+}
+
 // findLastMessageFor returns the most recent error or info message that
 // references repo.URL, or an empty string. Caller must NOT hold rp.mutex.
 func (rp *RepositoryProcessor) findLastMessageFor(repoURL string) string {
@@ -95,9 +240,10 @@ func (rp *RepositoryProcessor) findLastMessageFor(repoURL string) string {
 	return ""
 }
 
-// recordOutcome records the per-repo outcome to the state manifest if one is
-// attached. Best-effort; any HEAD-SHA read errors are ignored.
-func (rp *RepositoryProcessor) recordOutcome(repo *scm.Repo, status string) {
+// recordOutcome records the per-repo outcome and the time since start to the
+// state manifest if one is attached. Best-effort; any HEAD-SHA read errors are
+// ignored.
+func (rp *RepositoryProcessor) recordOutcome(repo *scm.Repo, status string, start time.Time) {
 	rp.mutex.RLock()
 	state := rp.state
 	rp.mutex.RUnlock()
@@ -112,6 +258,7 @@ func (rp *RepositoryProcessor) recordOutcome(repo *scm.Repo, status string) {
 		errStr = rp.findLastMessageFor(repo.URL)
 	}
 	state.Record(*repo, status, sha, errStr)
+	state.RecordDuration(repo.URL, time.Since(start))
 }
 
 // ProcessRepository handles the cloning or updating of a single repository
@@ -135,15 +282,34 @@ func (rp *RepositoryProcessor) ProcessRepository(repo *scm.Repo, repoNameWithCol
 	// Apply clone delay if configured (before any repository operations)
 	applyCloneDelay(repo.URL)
 
+	start := time.Now()
+
+	renamedFrom, err := rp.moveRenamedRepo(repo)
+	if err != nil {
+		rp.addError(fmt.Sprintf("Problem cloning %s: %v", repo.URL, err))
+		message := rp.findLastMessageFor(repo.URL)
+		// not recorded in the state, its entry belongs to the other repo
+		rp.report(RepoEventError, repo, start, "", message)
+		rp.runRepoHook(HookOnError, repo, HookStatusError, "", "", message)
+		return
+	}
+
 	// Determine if this repo exists locally
 	repoWillBePulled := repoExistsLocally(*repo)
 	var action string
 
+	var shaBefore string
+	hooks := rp.getHooks()
+	if repoWillBePulled && (rp.reporting() || hooks.has(HookPostPullWithChanges) || hooks.has(HookOnError)) {
+		shaBefore, _ = rp.git.HeadSHA(*repo)
+	}
+
 	// Protect local: skip repos with uncommitted changes or unpushed commits
 	if repoWillBePulled && os.Getenv("GHORG_PROTECT_LOCAL") == "true" {
 		if rp.hasLocalChangesForProtect(*repo) {
 			colorlog.PrintWarning(fmt.Sprintf("Protected %s (has local changes or unpushed commits)", repo.URL))
 			rp.addProtected(fmt.Sprintf("%s: has local changes or unpushed commits", repo.URL))
+			rp.report(RepoEventProtected, repo, start, shaBefore, "has local changes or unpushed commits")
 			return
 		}
 	} else if repoWillBePulled {
@@ -152,6 +318,7 @@ func (rp *RepositoryProcessor) ProcessRepository(repo *scm.Repo, repoNameWithCol
 		if statusErr == nil && status != "" {
 			colorlog.PrintWarning(fmt.Sprintf("Skipped %s (has local changes)", repo.URL))
 			rp.addSkipped(fmt.Sprintf("%s: has uncommitted local changes", repo.URL))
+			rp.report(RepoEventSkipped, repo, start, shaBefore, "has uncommitted local changes")
 			return
 		}
 	}
@@ -169,7 +336,10 @@ func (rp *RepositoryProcessor) ProcessRepository(repo *scm.Repo, repoNameWithCol
 	if repoWillBePulled {
 		success := rp.handleExistingRepository(repo, &action)
 		if !success {
-			rp.recordOutcome(repo, StateStatusError)
+			message := rp.findLastMessageFor(repo.URL)
+			rp.recordOutcome(repo, StateStatusError, start)
+			rp.report(RepoEventError, repo, start, shaBefore, message)
+			rp.runRepoHook(HookOnError, repo, HookStatusError, shaBefore, "", message)
 			return
 		}
 		// Restore original branch if protect-local and we were on a different branch
@@ -181,12 +351,26 @@ func (rp *RepositoryProcessor) ProcessRepository(repo *scm.Repo, repoNameWithCol
 	} else {
 		success := rp.handleNewRepository(repo, &action)
 		if !success {
-			rp.recordOutcome(repo, StateStatusError)
+			message := rp.findLastMessageFor(repo.URL)
+			rp.recordOutcome(repo, StateStatusError, start)
+			rp.report(RepoEventError, repo, start, "", message)
+			rp.runRepoHook(HookOnError, repo, HookStatusError, "", "", message)
 			return
 		}
 	}
 
-	rp.recordOutcome(repo, StateStatusOK)
+	// A failed mirror push is recorded as a failure, not as cloned or pulled
+	if err := rp.pushToMirror(repo); err != nil {
+		rp.uncount(repoWillBePulled)
+		message := rp.findLastMessageFor(repo.URL)
+		rp.recordOutcome(repo, StateStatusError, start)
+		rp.report(RepoEventError, repo, start, shaBefore, message)
+		rp.runRepoHook(HookOnError, repo, HookStatusError, shaBefore, "", message)
+		return
+	}
+
+	prevSHA := rp.State().LastSHA(repo.URL)
+	rp.recordOutcome(repo, StateStatusOK, start)
 
 	// Print unified success message (matching original behavior)
 	if repo.SyncedDefaultBranch {
@@ -200,6 +384,140 @@ func (rp *RepositoryProcessor) ProcessRepository(repo *scm.Repo, repoNameWithCol
 	} else {
 		colorlog.PrintSuccess(fmt.Sprintf("Success %s %s, branch: %s", action, repo.URL, repo.CloneBranch))
 	}
+
+	rp.updateSearchIndex(repo, prevSHA)
+	rp.runSuccessHooks(repo, repoWillBePulled, shaBefore)
+
+	switch {
+	case renamedFrom != "":
+		rp.report(RepoEventRenamed, repo, start, shaBefore, "renamed from "+renamedFrom)
+	case repoWillBePulled:
+		rp.report(RepoEventPulled, repo, start, shaBefore, "")
+	default:
+		rp.report(RepoEventCloned, repo, start, "", "")
+	}
+}
+
+// moveRenamedRepo moves the local clone of a repo that was renamed or
+// transferred since it was last recorded in the state manifest to
+// repo.HostPath and points its origin at the new URL, so it is pulled instead
+// of cloned again and keeps its local branches. It returns the URL the repo
+// was recorded under, or an empty string when nothing was moved. An error
+// means repo.HostPath holds the clone of a different repo.
+func (rp *RepositoryProcessor) moveRenamedRepo(repo *scm.Repo) (string, error) {
+	state := rp.State()
+	id := stateRepoID(*repo)
+	if state == nil || id == "" {
+		return "", nil
+	}
+	rp.renameMu.Lock()
+	defer rp.renameMu.Unlock()
+
+	if repoExistsLocally(*repo) {
+		if other := state.IDAt(repo.HostPath); other != "" && other != id {
+			return "", fmt.Errorf("%s holds the clone of a different repo (ID %s) that was renamed, transferred or deleted, move or remove it", repo.HostPath, other)
+		}
+		return "", nil
+	}
+
+	oldURL, entry, ok := state.FindByID(id)
+	if !ok || entry.HostPath == "" || entry.HostPath == repo.HostPath || !repoExistsLocally(scm.Repo{HostPath: entry.HostPath}) {
+		return "", nil
+	}
+	// Safeguard: only move clones from within the clone directory
+	if rel, err := filepath.Rel(rp.cloneDir(), entry.HostPath); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(repo.HostPath), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(entry.HostPath, repo.HostPath); err != nil {
+		return "", fmt.Errorf("could not move the clone of the renamed repo from %s: %w", entry.HostPath, err)
+	}
+	state.Move(oldURL, *repo)
+	if err := rp.git.SetOrigin(*repo); err != nil {
+		rp.addInfo(fmt.Sprintf("Problem updating the remote of renamed repo %s, error: %v", repo.URL, err))
+	}
+
+	rp.mutex.Lock()
+	rp.stats.RenamedCount++
+	rp.mutex.Unlock()
+	colorlog.PrintSuccess(fmt.Sprintf("Moved %s to %s, the repo was renamed or transferred", entry.HostPath, repo.HostPath))
+	return oldURL, nil
+}
+
+// reporting reports whether a run reporter is attached.
+func (rp *RepositoryProcessor) reporting() bool {
+	rp.mutex.RLock()
+	defer rp.mutex.RUnlock()
+	return rp.reporter != nil
+}
+
+// pushToMirror pushes repo to the attached push mirror, if any, and records
+// the result in the state manifest. Failures are added as clone errors and
+// returned, so the repo's outcome is recorded as failed.
+func (rp *RepositoryProcessor) pushToMirror(repo *scm.Repo) error {
+	rp.mutex.RLock()
+	mirror := rp.mirror
+	state := rp.state
+	rp.mutex.RUnlock()
+	if mirror == nil || !mirror.shouldMirror(*repo) {
+		return nil
+	}
+
+	mirrorURL, err := mirror.push(rp.git, *repo)
+	if err != nil {
+		errStr := fmt.Sprintf("Problem pushing %s to mirror %s, error: %v", repo.URL, mirrorURL, err)
+		rp.addError(errStr)
+		state.RecordMirror(*repo, mirrorURL, StateStatusError, errStr)
+		return err
+	}
+
+	state.RecordMirror(*repo, mirrorURL, StateStatusOK, "")
+	colorlog.PrintSubtleInfo(fmt.Sprintf("Mirrored %s to %s", repo.URL, mirrorURL))
+	return nil
+}
+
+// uncount takes back the clone or pull that handleNewRepository or
+// handleExistingRepository counted for a repo that failed afterwards.
+func (rp *RepositoryProcessor) uncount(pulled bool) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if !pulled {
+		rp.stats.CloneCount--
+		return
+	}
+	rp.stats.PulledCount--
+	if os.Getenv("GHORG_BACKUP") == "true" {
+		rp.stats.UpdateRemoteCount--
+	}
+}
+
+// updateSearchIndex rebuilds the search index shard of repo, if an index is
+// attached, when HEAD moved away from prevSHA. Failures are reported as infos,
+// they never fail the clone.
+func (rp *RepositoryProcessor) updateSearchIndex(repo *scm.Repo, prevSHA string) {
+	rp.mutex.RLock()
+	ix := rp.searchIndex
+	rp.mutex.RUnlock()
+	if ix == nil {
+		return
+	}
+
+	newSHA, err := rp.git.HeadSHA(*repo)
+	if err != nil {
+		rp.addInfo(fmt.Sprintf("Could not update search index for %s, error: %v", repo.URL, err))
+		return
+	}
+	rebuilt, err := ix.Update(*repo, prevSHA, newSHA)
+	if err != nil {
+		rp.addInfo(fmt.Sprintf("Could not update search index for %s, error: %v", repo.URL, err))
+		return
+	}
+	if rebuilt && os.Getenv("GHORG_DEBUG") != "" {
+		colorlog.PrintSubtleInfo(fmt.Sprintf("Indexed %s at %s", repo.URL, newSHA))
+	}
 }
 
 // handleNameCollisions manages repository name collisions
@@ -256,14 +574,14 @@ func (rp *RepositoryProcessor) addSuffixesIfNeeded(repo scm.Repo, repoSlug strin
 // buildHostPath constructs the final host path for the repository
 func (rp *RepositoryProcessor) buildHostPath(repo scm.Repo, repoSlug string) string {
 	if repo.IsGitLabRootLevelSnippet {
-		return filepath.Join(outputDirAbsolutePath, "_ghorg_root_level_snippets", repo.GitLabSnippetInfo.Title+"-"+repo.GitLabSnippetInfo.ID)
+		return filepath.Join(rp.cloneDir(), "_ghorg_root_level_snippets", repo.GitLabSnippetInfo.Title+"-"+repo.GitLabSnippetInfo.ID)
 	}
 
 	if repo.IsGitLabSnippet {
-		return filepath.Join(outputDirAbsolutePath, repoSlug, repo.GitLabSnippetInfo.Title+"-"+repo.GitLabSnippetInfo.ID)
+		return filepath.Join(rp.cloneDir(), repoSlug, repo.GitLabSnippetInfo.Title+"-"+repo.GitLabSnippetInfo.ID)
 	}
 
-	return filepath.Join(outputDirAbsolutePath, repoSlug)
+	return filepath.Join(rp.cloneDir(), repoSlug)
 }
 
 // shouldPruneUntouched determines if a repository should be pruned as untouched
@@ -646,6 +964,35 @@ func (rp *RepositoryProcessor) hasLocalChangesForProtect(repo scm.Repo) bool {
 	return hasUnpushed
 }
 
+// checkPruneSafe returns why a clone must not be pruned, or nil when it can
+// be. Unlike hasLocalChangesForProtect it fails closed: a clone that can't be
+// checked is kept. Every local branch is checked, not only HEAD, so branches
+// that were never pushed are kept as well.
+func (rp *RepositoryProcessor) checkPruneSafe(repo scm.Repo) error {
+	// Backup clones are bare mirrors of the remote, without a working tree
+	// or local work to lose.
+	if os.Getenv("GHORG_BACKUP") == "true" {
+		return nil
+	}
+
+	status, err := rp.git.ShortStatus(repo)
+	if err != nil {
+		return fmt.Errorf("could not check for local changes: %w", err)
+	}
+	if status != "" {
+		return errors.New("has local changes")
+	}
+
+	hasUnpushed, err := rp.git.HasUnpushedBranches(repo)
+	if err != nil {
+		return fmt.Errorf("could not check for unpushed commits: %w", err)
+	}
+	if hasUnpushed {
+		return errors.New("has unpushed commits")
+	}
+	return nil
+}
+
 // addProtected adds a protected message to the stats in a thread-safe manner
 func (rp *RepositoryProcessor) addProtected(msg string) {
 	rp.mutex.Lock()
@@ -672,6 +1019,7 @@ func (rp *RepositoryProcessor) GetStats() CloneStats {
 		PulledCount:          rp.stats.PulledCount,
 		SkippedCount:         rp.stats.SkippedCount,
 		ProtectedCount:       rp.stats.ProtectedCount,
+		RenamedCount:         rp.stats.RenamedCount,
 		UpdateRemoteCount:    rp.stats.UpdateRemoteCount,
 		NewCommits:           rp.stats.NewCommits,
 		UntouchedPrunes:      rp.stats.UntouchedPrunes,
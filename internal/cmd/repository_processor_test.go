@@ -3,9 +3,12 @@ package cmd
 import (
 	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/blairham/ghorg/internal/git"
 	"github.com/blairham/ghorg/internal/scm"
 )
 
@@ -812,3 +815,96 @@ func TestProcessRepository_StateNilDoesNotPanic(t *testing.T) {
 	}
 	processor.ProcessRepository(&repo, make(map[string]bool), false, "no-state", 0)
 }
+
+func TestProcessRepository_MovesRenamedRepo(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	os.Setenv("GHORG_GIT_BACKEND", git.BackendExec)
+
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, work, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(work, "README.md"), []byte("# app\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, work, "add", ".")
+	runTestGit(t, work, "commit", "-m", "initial")
+	renamed := filepath.Join(root, "new-name.git")
+	runTestGit(t, root, "clone", "--bare", work, renamed)
+
+	// The local clone from before the rename, with a local branch.
+	outputDirAbsolutePath = filepath.Join(root, "clones")
+	oldDir := filepath.Join(outputDirAbsolutePath, "old-name")
+	runTestGit(t, root, "clone", renamed, oldDir)
+	runTestGit(t, oldDir, "remote", "set-url", "origin", filepath.Join(root, "old-name.git"))
+	runTestGit(t, oldDir, "branch", "feature")
+
+	state := NewStateManifest("github", "org")
+	oldURL := filepath.Join(root, "old-name.git")
+	state.Record(scm.Repo{Name: "old-name", ID: "42", URL: oldURL, HostPath: oldDir}, StateStatusOK, "", "")
+
+	processor := NewRepositoryProcessor(git.NewGit())
+	processor.SetState(state)
+	reporter := &runReporter{}
+	processor.SetReporter(reporter)
+
+	repo := scm.Repo{Name: "new-name", ID: "42", URL: renamed, CloneURL: renamed, CloneBranch: "main"}
+	processor.ProcessRepository(&repo, make(map[string]bool), false, "new-name", 0)
+
+	stats := processor.GetStats()
+	if len(stats.CloneErrors) != 0 || stats.CloneCount != 0 || stats.RenamedCount != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	newDir := filepath.Join(outputDirAbsolutePath, "new-name")
+	if _, err := os.Stat(oldDir); !os.IsNotExist(err) {
+		t.Error("old clone directory still exists")
+	}
+	out, err := exec.Command("git", "-C", newDir, "remote", "get-url", "origin").Output()
+	if err != nil || strings.TrimSpace(string(out)) != renamed {
+		t.Errorf("origin = %q, %v; want %s", out, err, renamed)
+	}
+	if err := exec.Command("git", "-C", newDir, "rev-parse", "--verify", "refs/heads/feature").Run(); err != nil {
+		t.Error("local branch lost in the move")
+	}
+
+	outcomes := reporter.Outcomes()
+	if len(outcomes) != 1 || outcomes[0].Event != RepoEventRenamed || outcomes[0].Message != "renamed from "+oldURL {
+		t.Errorf("outcomes = %+v, want renamed", outcomes)
+	}
+	if _, ok := state.Repos[oldURL]; ok {
+		t.Error("state still has the old URL")
+	}
+	if entry := state.Repos[renamed]; entry.ID != "42" || entry.HostPath != newDir || entry.LastStatus != StateStatusOK {
+		t.Errorf("state entry = %+v", entry)
+	}
+}
+
+func TestProcessRepository_DirectoryOfOtherRepo(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	outputDirAbsolutePath = t.TempDir()
+	dir := filepath.Join(outputDirAbsolutePath, "app")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	url := "https://github.com/org/app"
+	state := NewStateManifest("github", "org")
+	state.Record(scm.Repo{Name: "app", ID: "1", URL: url, HostPath: dir}, StateStatusOK, "sha", "")
+
+	processor := NewRepositoryProcessor(NewExtendedMockGit())
+	processor.SetState(state)
+
+	// a new repo took the name of one that was renamed or transferred
+	repo := scm.Repo{Name: "app", ID: "2", URL: url, CloneBranch: "main"}
+	processor.ProcessRepository(&repo, make(map[string]bool), false, "app", 0)
+
+	stats := processor.GetStats()
+	if stats.PulledCount != 0 || len(stats.CloneErrors) != 1 || !strings.Contains(stats.CloneErrors[0], "holds the clone of a different repo (ID 1)") {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if state.Repos[url].ID != "1" {
+		t.Error("state entry of the other repo was overwritten")
+	}
+}
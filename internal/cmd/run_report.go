@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// Machine-readable output formats selected with --output / GHORG_OUTPUT.
+const (
+	OutputFormatJSON   = "json"
+	OutputFormatNDJSON = "ndjson"
+)
+
+// Per-repo events emitted by RepositoryProcessor.
+const (
+	RepoEventQueued    = "queued"
+	RepoEventCloned    = "cloned"
+	RepoEventPulled    = "pulled"
+	RepoEventSkipped   = "skipped"
+	RepoEventProtected = "protected"
+	RepoEventError     = "error"
+	RepoEventPruned    = "pruned"
+	RepoEventRenamed   = "renamed"
+)
+
+// RepoEvent is a single per-repo event. Every event except queued is the
+// final outcome of the repo in this run.
+type RepoEvent struct {
+	Event           string    `json:"event"`
+	Time            time.Time `json:"time"`
+	Name            string    `json:"name"`
+	URL             string    `json:"url"`
+	Path            string    `json:"path,omitempty"`
+	Branch          string    `json:"branch,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	NewCommits      int       `json:"new_commits,omitempty"`
+	SHABefore       string    `json:"sha_before,omitempty"`
+	SHAAfter        string    `json:"sha_after,omitempty"`
+	Message         string    `json:"message,omitempty"`
+}
+
+// RunReport is the document written by --output=json when the run finishes,
+// and the last line of --output=ndjson with event "summary".
+type RunReport struct {
+	Event      string        `json:"event,omitempty"`
+	SCM        string        `json:"scm"`
+	Target     string        `json:"target"`
+	OutputDir  string        `json:"output_dir"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Summary    CloneStats    `json:"summary"`
+	Repos      []RepoEvent   `json:"repos"`
+	Pruned     []string      `json:"pruned,omitempty"`
+	API        []scm.APIStat `json:"api,omitempty"`
+}
+
+// runReporter writes the machine-readable run report. It is safe for
+// concurrent use by the processor's goroutines.
+type runReporter struct {
+	format string
+	out    io.Writer
+
+	mu    sync.Mutex
+	repos []RepoEvent
+}
+
+// newRunReporter returns a reporter writing format to out, or nil when format
+// is empty.
+func newRunReporter(format string, out io.Writer) *runReporter {
+	if format == "" {
+		return nil
+	}
+	return &runReporter{format: format, out: out}
+}
+
+// Emit records ev and, in ndjson mode, writes it immediately.
+func (r *runReporter) Emit(ev RepoEvent) {
+	if r == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ev.Event != RepoEventQueued {
+		r.repos = append(r.repos, ev)
+	}
+	if r.format == OutputFormatNDJSON {
+		_ = json.NewEncoder(r.out).Encode(ev)
+	}
+}
+
+// Finish writes the run summary together with the outcome of every repo. A
+// reporter without a format only collects outcomes and writes nothing.
+func (r *runReporter) Finish(report RunReport) error {
+	if r == nil || r.format == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report.Repos = append([]RepoEvent{}, r.repos...)
+
+	if r.format == OutputFormatNDJSON {
+		report.Event = "summary"
+		return json.NewEncoder(r.out).Encode(report)
+	}
+	enc := json.NewEncoder(r.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// Outcomes returns the final event of every repo processed so far.
+func (r *runReporter) Outcomes() []RepoEvent {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RepoEvent{}, r.repos...)
+}
+
+// repoEvent returns the event for repo, filling in the fields common to all
+// event types.
+func repoEvent(event string, repo scm.Repo) RepoEvent {
+	return RepoEvent{
+		Event:  event,
+		Name:   repo.Name,
+		URL:    repo.URL,
+		Path:   repo.HostPath,
+		Branch: repo.CloneBranch,
+	}
+}
+
+// redirectHumanOutput sends the human readable log lines to stderr so they
+// do not mix with the run report. It returns the output they were written
+// to before, which the report is written to, and a func that restores it.
+func redirectHumanOutput() (io.Writer, func()) {
+	out := colorlog.Output()
+	return out, colorlog.SetOutput(os.Stderr)
+}
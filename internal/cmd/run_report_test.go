@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// headMockGit returns heads[n] on the nth HeadSHA call, repeating the last one.
+type headMockGit struct {
+	*ExtendedMockGitClient
+	heads []string
+	calls int
+}
+
+func (g *headMockGit) HeadSHA(repo scm.Repo) (string, error) {
+	sha := g.heads[min(g.calls, len(g.heads)-1)]
+	g.calls++
+	return sha, nil
+}
+
+func decodeNDJSON(t *testing.T, data []byte) []map[string]any {
+	t.Helper()
+	var events []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var ev map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestProcessRepository_ReportsEvents(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	dir := t.TempDir()
+	outputDirAbsolutePath = dir
+
+	var out bytes.Buffer
+	reporter := newRunReporter(OutputFormatNDJSON, &out)
+
+	// An existing clone that is pulled from old to new.
+	existing := filepath.Join(dir, "pulled")
+	if err := os.MkdirAll(existing, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pullGit := &headMockGit{ExtendedMockGitClient: NewExtendedMockGit(), heads: []string{"old", "new"}}
+	pullProcessor := NewRepositoryProcessor(pullGit)
+	pullProcessor.SetReporter(reporter)
+	pulled := scm.Repo{Name: "pulled", URL: "https://example.com/org/pulled", CloneBranch: "main"}
+	pullProcessor.reportQueued(pulled)
+	pullProcessor.ProcessRepository(&pulled, map[string]bool{}, false, "pulled", 0)
+
+	// A clone that fails.
+	failGit := NewExtendedMockGit()
+	failGit.shouldFailClone = true
+	failProcessor := NewRepositoryProcessor(failGit)
+	failProcessor.SetReporter(reporter)
+	broken := scm.Repo{Name: "broken", URL: "https://example.com/org/broken", CloneBranch: "main"}
+	failProcessor.ProcessRepository(&broken, map[string]bool{}, false, "broken", 0)
+
+	events := decodeNDJSON(t, out.Bytes())
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d: %s", len(events), out.String())
+	}
+
+	if events[0]["event"] != RepoEventQueued || events[0]["name"] != "pulled" {
+		t.Errorf("first event = %v, want queued pulled", events[0])
+	}
+
+	ev := events[1]
+	if ev["event"] != RepoEventPulled || ev["sha_before"] != "old" || ev["sha_after"] != "new" {
+		t.Errorf("pull event = %v, want pulled old..new", ev)
+	}
+	if ev["new_commits"] != float64(2) || ev["path"] != existing {
+		t.Errorf("pull event = %v, want 2 new commits at %s", ev, existing)
+	}
+	if _, ok := ev["duration_seconds"]; !ok {
+		t.Errorf("pull event has no duration: %v", ev)
+	}
+
+	ev = events[2]
+	if ev["event"] != RepoEventError || ev["message"] == nil {
+		t.Errorf("error event = %v, want error with message", ev)
+	}
+}
+
+func TestRunReporterJSONSummary(t *testing.T) {
+	var out bytes.Buffer
+	reporter := newRunReporter(OutputFormatJSON, &out)
+
+	repo := scm.Repo{Name: "api", URL: "https://example.com/org/api"}
+	reporter.Emit(repoEvent(RepoEventQueued, repo))
+	if out.Len() != 0 {
+		t.Fatalf("json mode should not stream events, got %q", out.String())
+	}
+	reporter.Emit(repoEvent(RepoEventCloned, repo))
+
+	err := reporter.Finish(RunReport{
+		SCM:     "github",
+		Target:  "org",
+		Summary: CloneStats{CloneCount: 1, CloneErrors: []string{}},
+	})
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	var report RunReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON report: %v\n%s", err, out.String())
+	}
+	if report.Summary.CloneCount != 1 || report.Target != "org" {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Repos) != 1 || report.Repos[0].Event != RepoEventCloned {
+		t.Errorf("report repos = %+v, want only the cloned outcome", report.Repos)
+	}
+}
+
+func TestRunReporterDisabled(t *testing.T) {
+	reporter := newRunReporter("", &bytes.Buffer{})
+	if reporter != nil {
+		t.Fatal("Expected no reporter without an output format")
+	}
+	// A nil reporter is a no-op.
+	reporter.Emit(RepoEvent{Event: RepoEventCloned})
+	if err := reporter.Finish(RunReport{}); err != nil {
+		t.Errorf("Finish on nil reporter = %v", err)
+	}
+}
+
+func TestRedirectHumanOutput(t *testing.T) {
+	var job strings.Builder
+	defer colorlog.SetOutput(&job)()
+
+	out, restore := redirectHumanOutput()
+	if out != &job || colorlog.Output() != os.Stderr {
+		t.Error("Expected the log output to be redirected to stderr")
+	}
+	restore()
+	if colorlog.Output() != &job || os.Stdout == os.Stderr {
+		t.Error("Expected the log output to be restored")
+	}
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/cli"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+)
+
+type SearchCommand struct {
+	UI cli.Ui
+}
+
+type SearchFlags struct {
+	IgnoreCase       bool `short:"i" long:"ignore-case" description:"Match case insensitively"`
+	FixedStrings     bool `short:"F" long:"fixed-strings" description:"Treat the pattern as a literal string instead of a regex"`
+	FilesWithMatches bool `short:"l" long:"files-with-matches" description:"Only print the paths of files that contain a match"`
+	JSON             bool `long:"json" description:"Print matches grouped by repo as JSON"`
+}
+
+func (c *SearchCommand) Help() string {
+	return `Usage: ghorg search [options] <pattern> [dir]
+
+Search cloned repos using the trigram index maintained by ghorg clone
+--search-index (GHORG_SEARCH_INDEX). The index narrows the files read to those
+that can contain the pattern, which is much faster than ghorg grep on large
+mirrors. If dir is given only repos below it are searched.
+
+The index reflects each repo's HEAD as of the last clone run. Files changed
+locally since then are still matched against their current content.
+
+Files larger than GHORG_SEARCH_INDEX_MAX_FILE_SIZE_KB (default 1024, 0 for no
+limit) are left out of the index and never searched. Their number is printed
+to stderr after the results; raise the limit and run ghorg clone again to
+index them.
+
+Options:
+  -i, --ignore-case          Match case insensitively
+  -F, --fixed-strings        Treat the pattern as a literal string
+  -l, --files-with-matches   Only print the paths of matching files
+  --json                     Print matches as JSON
+
+Examples:
+  ghorg search 'func NewClient' kubernetes
+  ghorg search -i -F 'x-api-key'
+  ghorg search --json 'TODO\(\w+\)' | jq '.[].name'
+`
+}
+
+func (c *SearchCommand) Synopsis() string {
+	return "Search cloned repos using the prebuilt search index"
+}
+
+func (c *SearchCommand) Run(args []string) int {
+	var opts SearchFlags
+	parser := flags.NewParser(&opts, flags.Default)
+	remaining, err := parser.ParseArgs(args)
+	if err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(c.Help())
+			return 0
+		}
+		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
+		return 1
+	}
+
+	if len(remaining) == 0 {
+		colorlog.PrintError("You must provide a pattern to search for, e.g. ghorg search TODO my-org")
+		return 1
+	}
+
+	pattern := remaining[0]
+	re, err := compileGrepPattern(pattern, opts.IgnoreCase, opts.FixedStrings)
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Invalid pattern: %v", err))
+		return 1
+	}
+
+	var under string
+	if len(remaining) > 1 {
+		under, err = resolveGhorgDir(remaining[1])
+		if err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+	}
+
+	ix, err := findSearchIndex(under)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+
+	results, err := ix.Search(re, requiredLiterals(pattern, opts.FixedStrings), under)
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error searching index: %v", err))
+		return 1
+	}
+
+	if opts.JSON {
+		if err := writeGrepJSON(os.Stdout, results); err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+	} else {
+		cwd, _ := os.Getwd()
+		writeGrepText(os.Stdout, results, cwd, opts.FilesWithMatches)
+	}
+
+	if skipped := ix.Skipped(under); skipped > 0 {
+		fmt.Fprintf(os.Stderr, "%d files larger than the search index size limit were not searched, see GHORG_SEARCH_INDEX_MAX_FILE_SIZE_KB\n", skipped)
+	}
+
+	for _, r := range results {
+		if len(r.Matches) > 0 {
+			return 0
+		}
+	}
+	return 1
+}
+
+// findSearchIndex loads the search index that covers dir. The index is kept
+// next to _ghorg_state.json, so dir itself is tried first (for clones made with
+// --path) and then GHORG_ABSOLUTE_PATH_TO_CLONE_TO.
+func findSearchIndex(dir string) (*SearchIndex, error) {
+	var candidates []string
+	if dir != "" {
+		candidates = append(candidates, filepath.Join(dir, SearchIndexDirName))
+	}
+	candidates = append(candidates, filepath.Join(filepath.Dir(getGhorgStateFilePath()), SearchIndexDirName))
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(filepath.Join(candidate, searchIndexManifestName)); err != nil {
+			continue
+		}
+		return LoadSearchIndex(candidate)
+	}
+	return nil, fmt.Errorf("no search index found, run ghorg clone with --search-index first")
+}
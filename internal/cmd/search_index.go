@@ -0,0 +1,445 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// SearchIndexDirName is the directory holding the trigram search index,
+// written alongside _ghorg_state.json.
+const SearchIndexDirName = "_ghorg_search_index"
+
+const (
+	searchIndexSchemaVersion = 1
+	searchIndexManifestName  = "manifest.json"
+	// defaultSearchIndexMaxFileSizeKB is the size of the largest file indexed
+	// by default. Larger files are almost always generated or vendored.
+	defaultSearchIndexMaxFileSizeKB = 1024
+)
+
+// SearchIndexEntry records which commit of a repo its shard was built from.
+type SearchIndexEntry struct {
+	Name      string    `json:"name"`
+	HostPath  string    `json:"host_path"`
+	SHA       string    `json:"sha"`
+	Shard     string    `json:"shard"`
+	Files     int       `json:"files"`
+	IndexedAt time.Time `json:"indexed_at"`
+	// Skipped counts the files left out for being larger than MaxFileSize,
+	// the size limit in bytes the shard was built with (0 for none).
+	Skipped     int   `json:"skipped,omitempty"`
+	MaxFileSize int64 `json:"max_file_size,omitempty"`
+}
+
+// SearchIndex is an on-disk trigram index over the tracked files of cloned
+// repos. Each repo has its own shard so a run only rewrites the shards of repos
+// whose HEAD changed. The manifest maps host paths to shards.
+type SearchIndex struct {
+	mu          sync.Mutex
+	dir         string
+	maxFileSize int64
+
+	Version int                         `json:"version"`
+	Repos   map[string]SearchIndexEntry `json:"repos"`
+}
+
+// searchShard is the index of a single repo. Trigrams maps each lowercased
+// trigram to the sorted ids of the files containing it.
+type searchShard struct {
+	Files    []string
+	Trigrams map[uint32][]uint32
+}
+
+// NewSearchIndex returns an empty index stored in dir.
+func NewSearchIndex(dir string) *SearchIndex {
+	return &SearchIndex{
+		dir:         dir,
+		maxFileSize: getSearchIndexMaxFileSize(),
+		Version:     searchIndexSchemaVersion,
+		Repos:       make(map[string]SearchIndexEntry),
+	}
+}
+
+// getSearchIndexMaxFileSize returns GHORG_SEARCH_INDEX_MAX_FILE_SIZE_KB in
+// bytes, 0 indexes every file.
+func getSearchIndexMaxFileSize() int64 {
+	kb, err := strconv.Atoi(os.Getenv("GHORG_SEARCH_INDEX_MAX_FILE_SIZE_KB"))
+	if err != nil || kb < 0 {
+		kb = defaultSearchIndexMaxFileSizeKB
+	}
+	return int64(kb) << 10
+}
+
+// LoadSearchIndex reads the index manifest in dir. A missing manifest returns
+// an empty index, the first indexing run is a normal case.
+func LoadSearchIndex(dir string) (*SearchIndex, error) {
+	ix := NewSearchIndex(dir)
+
+	data, err := os.ReadFile(filepath.Join(dir, searchIndexManifestName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ix, nil
+		}
+		return nil, fmt.Errorf("read search index manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, ix); err != nil {
+		return nil, fmt.Errorf("parse search index manifest: %w", err)
+	}
+	if ix.Version != searchIndexSchemaVersion {
+		return nil, fmt.Errorf("unsupported search index version %d in %s (expected %d)", ix.Version, dir, searchIndexSchemaVersion)
+	}
+	if ix.Repos == nil {
+		ix.Repos = make(map[string]SearchIndexEntry)
+	}
+	return ix, nil
+}
+
+// Update rebuilds the shard of repo unless its HEAD is unchanged since the
+// previous run and the shard was built from that commit with the current size
+// limit. It reports whether the shard was rebuilt. Safe for concurrent callers.
+func (ix *SearchIndex) Update(repo scm.Repo, prevSHA, newSHA string) (bool, error) {
+	hostPath := filepath.Clean(repo.HostPath)
+
+	ix.mu.Lock()
+	entry, ok := ix.Repos[hostPath]
+	ix.mu.Unlock()
+	if ok && newSHA != "" && prevSHA == newSHA && entry.SHA == newSHA && entry.MaxFileSize == ix.maxFileSize {
+		if _, err := os.Stat(filepath.Join(ix.dir, entry.Shard)); err == nil {
+			return false, nil
+		}
+	}
+
+	shard, skipped, err := buildSearchShard(hostPath, ix.maxFileSize)
+	if err != nil {
+		return false, err
+	}
+
+	shardName := searchShardName(hostPath)
+	if err := writeSearchShard(filepath.Join(ix.dir, shardName), shard); err != nil {
+		return false, err
+	}
+
+	ix.mu.Lock()
+	ix.Repos[hostPath] = SearchIndexEntry{
+		Name:        repo.Name,
+		HostPath:    hostPath,
+		SHA:         newSHA,
+		Shard:       shardName,
+		Files:       len(shard.Files),
+		IndexedAt:   time.Now().UTC(),
+		Skipped:     skipped,
+		MaxFileSize: ix.maxFileSize,
+	}
+	ix.mu.Unlock()
+	return true, nil
+}
+
+// Save writes the manifest. Entries whose clone no longer exists on disk, e.g.
+// after a prune, are dropped together with their shard.
+func (ix *SearchIndex) Save() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	for hostPath, entry := range ix.Repos {
+		if _, err := os.Stat(hostPath); errors.Is(err, os.ErrNotExist) {
+			os.Remove(filepath.Join(ix.dir, entry.Shard))
+			delete(ix.Repos, hostPath)
+		}
+	}
+
+	data, err := json.MarshalIndent(ix, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal search index manifest: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(ix.dir, searchIndexManifestName), data)
+}
+
+// Search returns the matches of re in every indexed repo below under (all
+// repos when under is empty). literals are substrings every match must
+// contain, they narrow the files read to those containing all their trigrams.
+func (ix *SearchIndex) Search(re *regexp.Regexp, literals []string, under string) ([]GrepResult, error) {
+	entries := make([]SearchIndexEntry, 0, len(ix.Repos))
+	for _, entry := range ix.Repos {
+		if under == "" || isPathWithin(entry.HostPath, under) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].HostPath < entries[j].HostPath })
+
+	results := make([]GrepResult, 0, len(entries))
+	for _, entry := range entries {
+		shard, err := readSearchShard(filepath.Join(ix.dir, entry.Shard))
+		if err != nil {
+			return nil, fmt.Errorf("read shard for %s: %w", entry.Name, err)
+		}
+
+		name := entry.Name
+		if under != "" {
+			if rel, err := filepath.Rel(under, entry.HostPath); err == nil && rel != "." {
+				name = filepath.ToSlash(rel)
+			}
+		}
+		result := GrepResult{Name: name, Path: entry.HostPath, Matches: []GrepMatch{}}
+
+		for _, id := range shard.candidates(literals) {
+			file := shard.Files[id]
+			matches, err := grepFile(filepath.Join(entry.HostPath, filepath.FromSlash(file)), re)
+			if err != nil {
+				continue
+			}
+			for _, m := range matches {
+				m.File = file
+				result.Matches = append(result.Matches, m)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Skipped returns the number of files below under (all repos when under is
+// empty) that were left out of the index for being too large.
+func (ix *SearchIndex) Skipped(under string) int {
+	skipped := 0
+	for _, entry := range ix.Repos {
+		if under == "" || isPathWithin(entry.HostPath, under) {
+			skipped += entry.Skipped
+		}
+	}
+	return skipped
+}
+
+// buildSearchShard indexes the tracked text files of the repo at hostPath and
+// counts the files skipped for being larger than maxFileSize (0 for no limit).
+func buildSearchShard(hostPath string, maxFileSize int64) (*searchShard, int, error) {
+	files, err := git.TrackedFiles(hostPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Strings(files)
+
+	shard := &searchShard{Trigrams: make(map[uint32][]uint32)}
+	seen := make(map[uint32]struct{})
+	skipped := 0
+	for _, file := range files {
+		path := filepath.Join(hostPath, filepath.FromSlash(file))
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if maxFileSize > 0 && info.Size() > maxFileSize {
+			skipped++
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || isBinary(data) {
+			continue
+		}
+
+		id := uint32(len(shard.Files))
+		shard.Files = append(shard.Files, file)
+		clear(seen)
+		forEachTrigram(data, func(t uint32) {
+			if _, ok := seen[t]; ok {
+				return
+			}
+			seen[t] = struct{}{}
+			shard.Trigrams[t] = append(shard.Trigrams[t], id)
+		})
+	}
+	return shard, skipped, nil
+}
+
+// candidates returns the ids of the files that contain every trigram of every
+// literal. With no usable literal every file is a candidate.
+func (s *searchShard) candidates(literals []string) []uint32 {
+	var result []uint32
+	filtered := false
+	for _, lit := range literals {
+		forEachTrigram([]byte(lit), func(t uint32) {
+			posting := s.Trigrams[t]
+			if !filtered {
+				result = append([]uint32(nil), posting...)
+				filtered = true
+				return
+			}
+			result = intersectSorted(result, posting)
+		})
+	}
+	if filtered {
+		return result
+	}
+
+	all := make([]uint32, len(s.Files))
+	for i := range all {
+		all[i] = uint32(i)
+	}
+	return all
+}
+
+// forEachTrigram calls fn with every ASCII lowercased trigram of data.
+func forEachTrigram(data []byte, fn func(uint32)) {
+	if len(data) < 3 {
+		return
+	}
+	lower := func(b byte) uint32 {
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		return uint32(b)
+	}
+	t := lower(data[0])<<8 | lower(data[1])
+	for _, b := range data[2:] {
+		t = (t<<8 | lower(b)) & 0xFFFFFF
+		fn(t)
+	}
+}
+
+func intersectSorted(a, b []uint32) []uint32 {
+	out := a[:0]
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// requiredLiterals returns substrings that every match of pattern must
+// contain. Only literals that are concatenated at the top level of the regex
+// are used, anything more complex falls back to scanning every indexed file.
+func requiredLiterals(pattern string, fixed bool) []string {
+	if fixed {
+		return []string{pattern}
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	re = re.Simplify()
+
+	var literals []string
+	var walk func(*syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpLiteral:
+			lit := string(re.Rune)
+			if re.Flags&syntax.FoldCase != 0 {
+				// The index folds ASCII case only.
+				if !isASCII(lit) {
+					return
+				}
+				lit = strings.ToLower(lit)
+			}
+			if len(lit) >= 3 {
+				literals = append(literals, lit)
+			}
+		case syntax.OpConcat:
+			for _, sub := range re.Sub {
+				walk(sub)
+			}
+		case syntax.OpCapture:
+			walk(re.Sub[0])
+		}
+	}
+	walk(re)
+	return literals
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isPathWithin reports whether path is dir or below it.
+func isPathWithin(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// searchShardName returns a stable file name for the shard of hostPath.
+func searchShardName(hostPath string) string {
+	sum := sha1.Sum([]byte(hostPath))
+	return hex.EncodeToString(sum[:]) + ".idx"
+}
+
+func writeSearchShard(path string, shard *searchShard) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(shard); err != nil {
+		return fmt.Errorf("encode shard: %w", err)
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("write shard: %w", err)
+	}
+	return nil
+}
+
+func readSearchShard(path string) (*searchShard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	shard := &searchShard{}
+	if err := gob.NewDecoder(f).Decode(shard); err != nil {
+		return nil, err
+	}
+	return shard, nil
+}
+
+// writeFileAtomic writes data to path via a temp file and rename.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ghorg-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
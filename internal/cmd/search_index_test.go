@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+func TestSearchIndexUpdateAndSearch(t *testing.T) {
+	org := setupGrepFixture(t)
+	api := filepath.Join(org, "api")
+	g := git.NewExecGit()
+	repo := scm.Repo{Name: "api", URL: "https://example.com/org/api", HostPath: api}
+
+	sha, err := g.HeadSHA(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ix := NewSearchIndex(filepath.Join(t.TempDir(), SearchIndexDirName))
+	if rebuilt, err := ix.Update(repo, "", sha); err != nil || !rebuilt {
+		t.Fatalf("first Update = %v, %v; want rebuilt", rebuilt, err)
+	}
+	if rebuilt, err := ix.Update(repo, sha, sha); err != nil || rebuilt {
+		t.Errorf("Update with unchanged HEAD = %v, %v; want skipped", rebuilt, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(api, "src", "new.go"), []byte("package main // needle\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, api, "add", "src/new.go")
+	runTestGit(t, api, "commit", "-m", "add needle")
+	newSHA, _ := g.HeadSHA(repo)
+	if rebuilt, err := ix.Update(repo, sha, newSHA); err != nil || !rebuilt {
+		t.Fatalf("Update after commit = %v, %v; want rebuilt", rebuilt, err)
+	}
+
+	if err := ix.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadSearchIndex(ix.dir)
+	if err != nil {
+		t.Fatalf("LoadSearchIndex failed: %v", err)
+	}
+	if entry := loaded.Repos[api]; entry.SHA != newSHA || entry.Files != 3 {
+		t.Errorf("manifest entry = %+v, want sha %s and 3 files", entry, newSHA)
+	}
+
+	tests := []struct {
+		pattern    string
+		ignoreCase bool
+		fixed      bool
+		want       []string
+	}{
+		{"NEEDLE", true, true, []string{"src/new.go:1"}},
+		{"(?i)todo: api", false, false, []string{"src/main.go:3"}},
+		{"needle|cleanup", false, false, []string{"src/main.go:3", "src/new.go:1"}},
+		{"not present", false, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re, err := compileGrepPattern(tt.pattern, tt.ignoreCase, tt.fixed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := loaded.Search(re, requiredLiterals(tt.pattern, tt.fixed), "")
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			var got []string
+			for _, r := range results {
+				for _, m := range r.Matches {
+					got = append(got, fmt.Sprintf("%s:%d", m.File, m.Line))
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Search(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndexSaveDropsRemovedClones(t *testing.T) {
+	org := setupGrepFixture(t)
+	ix := NewSearchIndex(filepath.Join(t.TempDir(), SearchIndexDirName))
+	for _, name := range []string{"api", "web"} {
+		repo := scm.Repo{Name: name, HostPath: filepath.Join(org, name)}
+		if _, err := ix.Update(repo, "", "sha-"+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shard := filepath.Join(ix.dir, ix.Repos[filepath.Join(org, "web")].Shard)
+	if err := os.RemoveAll(filepath.Join(org, "web")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ix.Repos[filepath.Join(org, "web")]; ok {
+		t.Error("Expected removed clone to be dropped from the manifest")
+	}
+	if _, err := os.Stat(shard); !os.IsNotExist(err) {
+		t.Errorf("Expected shard of removed clone to be deleted, stat err = %v", err)
+	}
+}
+
+func TestSearchIndexMaxFileSize(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	org := setupGrepFixture(t)
+	api := filepath.Join(org, "api")
+	if err := os.WriteFile(filepath.Join(api, "big.txt"), []byte(strings.Repeat("needle ", 300)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, api, "add", "big.txt")
+	runTestGit(t, api, "commit", "-m", "add big file")
+	repo := scm.Repo{Name: "api", HostPath: api}
+	dir := filepath.Join(t.TempDir(), SearchIndexDirName)
+	re, _ := compileGrepPattern("needle", false, true)
+
+	os.Setenv("GHORG_SEARCH_INDEX_MAX_FILE_SIZE_KB", "1")
+	ix := NewSearchIndex(dir)
+	if _, err := ix.Update(repo, "", "sha"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ix.Skipped(""); got != 1 {
+		t.Errorf("Skipped() = %d, want 1", got)
+	}
+	if results, _ := ix.Search(re, []string{"needle"}, ""); len(results[0].Matches) != 0 {
+		t.Errorf("Search found %v in a file over the limit", results[0].Matches)
+	}
+
+	// Raising the limit rebuilds the shard even though HEAD is unchanged.
+	os.Setenv("GHORG_SEARCH_INDEX_MAX_FILE_SIZE_KB", "0")
+	ix.maxFileSize = getSearchIndexMaxFileSize()
+	if rebuilt, err := ix.Update(repo, "sha", "sha"); err != nil || !rebuilt {
+		t.Fatalf("Update after raising the limit = %v, %v; want rebuilt", rebuilt, err)
+	}
+	if got := ix.Skipped(""); got != 0 {
+		t.Errorf("Skipped() = %d, want 0", got)
+	}
+	if results, _ := ix.Search(re, []string{"needle"}, ""); len(results[0].Matches) != 1 {
+		t.Errorf("Search matches = %v, want the big file", results[0].Matches)
+	}
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		pattern string
+		fixed   bool
+		want    []string
+	}{
+		{"a.b(c", true, []string{"a.b(c"}},
+		{`func\s+NewClient`, false, []string{"func", "NewClient"}},
+		{"(?i)deprecated", false, []string{"deprecated"}},
+		{"foo|bar", false, nil},
+		{"ab", false, nil},
+		{"(?i)größe", false, nil},
+	}
+	for _, tt := range tests {
+		got := requiredLiterals(tt.pattern, tt.fixed)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("requiredLiterals(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestProcessRepository_UpdatesSearchIndex(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	os.Setenv("GHORG_GIT_BACKEND", git.BackendExec)
+
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, work, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(work, "main.go"), []byte("package main // findme\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, work, "add", ".")
+	runTestGit(t, work, "commit", "-m", "initial")
+
+	outputDirAbsolutePath = filepath.Join(root, "clones")
+	ix := NewSearchIndex(filepath.Join(root, SearchIndexDirName))
+
+	processor := NewRepositoryProcessor(git.NewGit())
+	processor.SetState(NewStateManifest("github", "org"))
+	processor.SetSearchIndex(ix)
+
+	repo := scm.Repo{Name: "app", URL: work, CloneURL: work, CloneBranch: "main"}
+	processor.ProcessRepository(&repo, make(map[string]bool), false, "app", 0)
+
+	if errs := processor.GetStats().CloneErrors; len(errs) != 0 {
+		t.Fatalf("Unexpected clone errors: %v", errs)
+	}
+
+	results, err := ix.Search(regexp.MustCompile("findme"), []string{"findme"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 1 {
+		t.Errorf("Expected one match in the freshly cloned repo, got %+v", results)
+	}
+}
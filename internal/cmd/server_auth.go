@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scopes of reclone server callers. The trigger scope includes read.
+const (
+	ScopeRead    = "read"
+	ScopeTrigger = "trigger"
+)
+
+// serverCaller is an authenticated reclone server caller.
+type serverCaller struct {
+	Name  string
+	Scope string
+}
+
+// allows reports whether the caller may use endpoints requiring scope.
+func (c *serverCaller) allows(scope string) bool {
+	return c.Scope == ScopeTrigger || c.Scope == scope
+}
+
+// anonymousCaller is every caller while authentication is disabled.
+var anonymousCaller = &serverCaller{Name: "anonymous", Scope: ScopeTrigger}
+
+type serverCallerKey struct{}
+
+// serverToken is a bearer token accepted by the reclone server. Only its
+// hash is kept, so tokens of any length compare in constant time.
+type serverToken struct {
+	name  string
+	scope string
+	hash  [sha256.Size]byte
+}
+
+// serverAuth authenticates reclone server callers by bearer token or by a
+// client certificate verified against the client CA.
+type serverAuth struct {
+	tokens    []serverToken
+	certScope string // scope of verified client certificates, empty without mTLS
+}
+
+// loadServerAuth reads the tokens and client certificate scope from
+// GHORG_RECLONE_SERVER_READ_TOKENS, GHORG_RECLONE_SERVER_TRIGGER_TOKENS,
+// GHORG_RECLONE_SERVER_TOKENS_FILE and GHORG_RECLONE_SERVER_TLS_CLIENT_CA.
+func loadServerAuth() (*serverAuth, error) {
+	a := &serverAuth{}
+	for _, s := range []struct{ env, scope string }{
+		{"GHORG_RECLONE_SERVER_READ_TOKENS", ScopeRead},
+		{"GHORG_RECLONE_SERVER_TRIGGER_TOKENS", ScopeTrigger},
+	} {
+		for token := range strings.SplitSeq(os.Getenv(s.env), ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				a.addToken("", s.scope, token)
+			}
+		}
+	}
+
+	if path := os.Getenv("GHORG_RECLONE_SERVER_TOKENS_FILE"); path != "" {
+		if err := a.loadTokensFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if os.Getenv("GHORG_RECLONE_SERVER_TLS_CLIENT_CA") != "" {
+		a.certScope = os.Getenv("GHORG_RECLONE_SERVER_TLS_CLIENT_SCOPE")
+		if a.certScope == "" {
+			a.certScope = ScopeRead
+		}
+		if !validScope(a.certScope) {
+			return nil, fmt.Errorf("GHORG_RECLONE_SERVER_TLS_CLIENT_SCOPE must be %s or %s, got %q", ScopeRead, ScopeTrigger, a.certScope)
+		}
+	}
+	return a, nil
+}
+
+func validScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeTrigger
+}
+
+// addToken accepts token with scope. Unnamed tokens are identified in the
+// request log by the start of their hash.
+func (a *serverAuth) addToken(name, scope, token string) {
+	hash := sha256.Sum256([]byte(token))
+	if name == "" {
+		name = "token-" + hex.EncodeToString(hash[:4])
+	}
+	a.tokens = append(a.tokens, serverToken{name: name, scope: scope, hash: hash})
+}
+
+// loadTokensFile reads tokens from path, one "name scope token" per line.
+// Empty lines and lines starting with # are skipped.
+func (a *serverAuth) loadTokensFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading tokens file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !validScope(fields[1]) {
+			return fmt.Errorf("%s:%d: expected \"name %s|%s token\"", path, n, ScopeRead, ScopeTrigger)
+		}
+		a.addToken(fields[0], fields[1], fields[2])
+	}
+	return scanner.Err()
+}
+
+// Enabled reports whether callers have to authenticate.
+func (a *serverAuth) Enabled() bool {
+	return len(a.tokens) > 0 || a.certScope != ""
+}
+
+// authenticate returns the caller of r, nil when r carries no valid
+// credentials. A bearer token takes precedence over a client certificate.
+func (a *serverAuth) authenticate(r *http.Request) *serverCaller {
+	if !a.Enabled() {
+		return anonymousCaller
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		hash := sha256.Sum256([]byte(strings.TrimSpace(token)))
+		var match *serverToken
+		// Compare against every token so the time taken does not reveal
+		// which one matched.
+		for i := range a.tokens {
+			if subtle.ConstantTimeCompare(hash[:], a.tokens[i].hash[:]) == 1 {
+				match = &a.tokens[i]
+			}
+		}
+		if match == nil {
+			return nil
+		}
+		return &serverCaller{Name: match.name, Scope: match.scope}
+	}
+
+	if a.certScope != "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return &serverCaller{Name: "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName, Scope: a.certScope}
+	}
+	return nil
+}
+
+// Handler authenticates every request before passing it to next and logs
+// it with its caller. Requests with invalid credentials still reach next,
+// without a caller, so that endpoints which require none keep working.
+func (a *serverAuth) Handler(next http.Handler, logger *log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		caller := a.authenticate(r)
+		if caller != nil {
+			r = r.WithContext(context.WithValue(r.Context(), serverCallerKey{}, caller))
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		name := "-"
+		if caller != nil {
+			name = caller.Name
+		}
+		logger.Printf("%s %s %s %d %s caller=%s", r.RemoteAddr, r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond), name)
+	})
+}
+
+// requireScope only lets callers with scope use h. Other callers get 401
+// without valid credentials and 403 with too narrow a scope.
+func requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, _ := r.Context().Value(serverCallerKey{}).(*serverCaller)
+		if caller == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ghorg"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !caller.allows(scope) {
+			http.Error(w, fmt.Sprintf("Forbidden: requires the %s scope", scope), http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streamed responses such as job logs through.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// serverTLS returns the certificate and key the reclone server serves
+// HTTPS with, and its TLS config. Both paths are empty for plain HTTP.
+// With GHORG_RECLONE_SERVER_TLS_CLIENT_CA client certificates are
+// verified against that CA bundle.
+func serverTLS() (certFile, keyFile string, config *tls.Config, err error) {
+	certFile = os.Getenv("GHORG_RECLONE_SERVER_TLS_CERT")
+	keyFile = os.Getenv("GHORG_RECLONE_SERVER_TLS_KEY")
+	clientCA := os.Getenv("GHORG_RECLONE_SERVER_TLS_CLIENT_CA")
+
+	if (certFile == "") != (keyFile == "") {
+		return "", "", nil, errors.New("GHORG_RECLONE_SERVER_TLS_CERT and GHORG_RECLONE_SERVER_TLS_KEY must be set together")
+	}
+	if certFile == "" {
+		if clientCA != "" {
+			return "", "", nil, errors.New("GHORG_RECLONE_SERVER_TLS_CLIENT_CA requires GHORG_RECLONE_SERVER_TLS_CERT and GHORG_RECLONE_SERVER_TLS_KEY")
+		}
+		return "", "", nil, nil
+	}
+
+	config = &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return "", "", nil, fmt.Errorf("no certificates found in client CA %s", clientCA)
+		}
+		config.ClientCAs = pool
+		// Callers may still authenticate with a bearer token instead.
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return certFile, keyFile, config, nil
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadServerAuth(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+
+	if a, err := loadServerAuth(); err != nil || a.Enabled() {
+		t.Fatalf("loadServerAuth without config = %v, %v; want disabled", a, err)
+	}
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokensFile, []byte("# CI\nci trigger ci-secret\n\ngrafana read grafana-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GHORG_RECLONE_SERVER_READ_TOKENS", "r1, r2")
+	os.Setenv("GHORG_RECLONE_SERVER_TRIGGER_TOKENS", "t1")
+	os.Setenv("GHORG_RECLONE_SERVER_TOKENS_FILE", tokensFile)
+	a, err := loadServerAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tok := range a.tokens {
+		got = append(got, tok.scope)
+	}
+	if strings.Join(got, ",") != "read,read,trigger,trigger,read" || a.tokens[3].name != "ci" || !strings.HasPrefix(a.tokens[0].name, "token-") {
+		t.Errorf("tokens = %+v", a.tokens)
+	}
+
+	if err := os.WriteFile(tokensFile, []byte("ci admin ci-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadServerAuth(); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("loadServerAuth with an unknown scope = %v, want an error for line 1", err)
+	}
+
+	os.Unsetenv("GHORG_RECLONE_SERVER_TOKENS_FILE")
+	os.Setenv("GHORG_RECLONE_SERVER_TLS_CLIENT_CA", "ca.pem")
+	if a, err := loadServerAuth(); err != nil || a.certScope != ScopeRead {
+		t.Errorf("client certificate scope = %q, %v; want read by default", a.certScope, err)
+	}
+	os.Setenv("GHORG_RECLONE_SERVER_TLS_CLIENT_SCOPE", "admin")
+	if _, err := loadServerAuth(); err == nil {
+		t.Error("Expected an error for an unknown client certificate scope")
+	}
+}
+
+// newAuthTestHandler serves a read and a trigger route and an open /health
+// behind auth, logging requests to the returned buffer.
+func newAuthTestHandler(auth *serverAuth) (http.Handler, *bytes.Buffer) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", requireScope(ScopeRead, ok))
+	mux.HandleFunc("POST /jobs", requireScope(ScopeTrigger, ok))
+	mux.HandleFunc("/health", ok)
+	var logs bytes.Buffer
+	return auth.Handler(mux, log.New(&logs, "", 0)), &logs
+}
+
+func TestServerAuthScopes(t *testing.T) {
+	auth := &serverAuth{}
+	auth.addToken("grafana", ScopeRead, "read-secret")
+	auth.addToken("ci", ScopeTrigger, "trigger-secret")
+	auth.certScope = ScopeRead
+	handler, logs := newAuthTestHandler(auth)
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "backup-host"}}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		cert   bool
+		want   int
+		caller string
+	}{
+		{"no credentials", http.MethodGet, "/jobs", "", false, http.StatusUnauthorized, "-"},
+		{"wrong token", http.MethodGet, "/jobs", "nope", false, http.StatusUnauthorized, "-"},
+		{"read token reads", http.MethodGet, "/jobs", "read-secret", false, http.StatusOK, "grafana"},
+		{"read token triggers", http.MethodPost, "/jobs", "read-secret", false, http.StatusForbidden, "grafana"},
+		{"trigger token triggers", http.MethodPost, "/jobs", "trigger-secret", false, http.StatusOK, "ci"},
+		{"trigger token reads", http.MethodGet, "/jobs", "trigger-secret", false, http.StatusOK, "ci"},
+		{"client certificate", http.MethodGet, "/jobs", "", true, http.StatusOK, "cert:backup-host"},
+		{"wrong token with certificate", http.MethodGet, "/jobs", "nope", true, http.StatusUnauthorized, "-"},
+		{"health is open", http.MethodGet, "/health", "", false, http.StatusOK, "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.cert {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+			if line := logs.String(); !strings.HasSuffix(line, " caller="+tt.caller+"\n") || strings.Contains(line, "secret") {
+				t.Errorf("request log = %q, want caller=%s without the token", line, tt.caller)
+			}
+		})
+	}
+}
+
+func TestServerAuthDisabled(t *testing.T) {
+	handler, logs := newAuthTestHandler(&serverAuth{})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(logs.String(), "POST /jobs 200") || !strings.HasSuffix(logs.String(), "caller=anonymous\n") {
+		t.Errorf("status = %d, log = %q; want open access", rec.Code, logs.String())
+	}
+}
+
+// writeTestCert writes a PEM certificate and key signed by parent, or
+// self-signed when parent is nil, and returns them.
+func writeTestCert(t *testing.T, dir, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent, parentKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath, keyPath := filepath.Join(dir, cn+".pem"), filepath.Join(dir, cn+"-key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, key, certPath, keyPath
+}
+
+func TestServerTLS(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	dir := t.TempDir()
+	ca, caKey, caPath, _ := writeTestCert(t, dir, "ghorg-ca", nil, nil)
+	_, _, serverCert, serverKey := writeTestCert(t, dir, "server", ca, caKey)
+	_, _, clientCert, clientKey := writeTestCert(t, dir, "ci-runner", ca, caKey)
+
+	if certFile, _, config, err := serverTLS(); certFile != "" || config != nil || err != nil {
+		t.Errorf("serverTLS without config = %q, %v, %v; want plain HTTP", certFile, config, err)
+	}
+	os.Setenv("GHORG_RECLONE_SERVER_TLS_CLIENT_CA", caPath)
+	if _, _, _, err := serverTLS(); err == nil {
+		t.Error("Expected an error for a client CA without a certificate")
+	}
+	os.Setenv("GHORG_RECLONE_SERVER_TLS_CERT", serverCert)
+	if _, _, _, err := serverTLS(); err == nil {
+		t.Error("Expected an error for a certificate without a key")
+	}
+	os.Setenv("GHORG_RECLONE_SERVER_TLS_KEY", serverKey)
+	os.Setenv("GHORG_RECLONE_SERVER_TLS_CLIENT_CA", serverKey)
+	if _, _, _, err := serverTLS(); err == nil {
+		t.Error("Expected an error for a client CA without certificates")
+	}
+	os.Setenv("GHORG_RECLONE_SERVER_TLS_CLIENT_CA", caPath)
+
+	certFile, keyFile, config, err := serverTLS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := loadServerAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.addToken("ci", ScopeTrigger, "trigger-secret")
+	handler, logs := newAuthTestHandler(auth)
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = config
+	srv.TLS.Certificates = []tls.Certificate{pair}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+	clientPair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client(clientPair).Get(srv.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(logs.String(), "caller=cert:ci-runner") {
+		t.Errorf("GET /jobs with a client certificate = %d, log %q", resp.StatusCode, logs.String())
+	}
+	resp, err = client(clientPair).Post(srv.URL+"/jobs", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("POST /jobs with a read client certificate = %d, want 403", resp.StatusCode)
+	}
+
+	// Without a client certificate a bearer token still works.
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/jobs", nil)
+	req.Header.Set("Authorization", "Bearer trigger-secret")
+	resp, err = client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("POST /jobs with a token over TLS = %d", resp.StatusCode)
+	}
+	resp, err = client().Get(srv.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /jobs without credentials = %d, want 401", resp.StatusCode)
+	}
+}
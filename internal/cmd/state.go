@@ -28,12 +28,22 @@ const (
 // RepoState is the per-repository entry in the state manifest.
 type RepoState struct {
 	Name       string    `json:"name"`
+	ID         string    `json:"id,omitempty"`
 	HostPath   string    `json:"host_path"`
 	LastSHA    string    `json:"last_sha,omitempty"`
 	LastBranch string    `json:"last_branch,omitempty"`
 	LastStatus string    `json:"last_status"`
 	LastError  string    `json:"last_error,omitempty"`
 	LastSeenAt time.Time `json:"last_seen_at"`
+
+	// Run history used by ghorg report.
+	LastDurationSeconds float64 `json:"last_duration_seconds,omitempty"`
+	FailureCount        int     `json:"failure_count,omitempty"`
+
+	// Push mirror outcome, only set when push mirroring is configured.
+	MirrorURL    string `json:"mirror_url,omitempty"`
+	MirrorStatus string `json:"mirror_status,omitempty"`
+	MirrorError  string `json:"mirror_error,omitempty"`
 }
 
 // StateManifest is a JSON file recording the last-known state of every repo
@@ -132,20 +142,89 @@ func (m *StateManifest) Record(repo scm.Repo, status, sha, errStr string) {
 	prev := m.Repos[repo.URL]
 	entry := RepoState{
 		Name:       repo.Name,
+		ID:         stateRepoID(repo),
 		HostPath:   repo.HostPath,
 		LastSHA:    sha,
 		LastBranch: repo.CloneBranch,
 		LastStatus: status,
 		LastError:  errStr,
 		LastSeenAt: time.Now().UTC(),
+
+		LastDurationSeconds: prev.LastDurationSeconds,
+		FailureCount:        prev.FailureCount,
+
+		MirrorURL:    prev.MirrorURL,
+		MirrorStatus: prev.MirrorStatus,
+		MirrorError:  prev.MirrorError,
+	}
+	if entry.ID == "" {
+		entry.ID = prev.ID
 	}
 	// On error, preserve the last successful SHA if the new write doesn't have one.
 	if status == StateStatusError && entry.LastSHA == "" {
 		entry.LastSHA = prev.LastSHA
 	}
+	if status == StateStatusError {
+		entry.FailureCount++
+	}
 	m.Repos[repo.URL] = entry
 }
 
+// RecordMirror updates the push mirror outcome of the given repo's entry.
+// Safe for concurrent callers. errStr may be empty for success cases.
+func (m *StateManifest) RecordMirror(repo scm.Repo, mirrorURL, status, errStr string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Repos == nil {
+		m.Repos = make(map[string]RepoState)
+	}
+	entry, ok := m.Repos[repo.URL]
+	if !ok {
+		entry = RepoState{
+			Name:       repo.Name,
+			ID:         stateRepoID(repo),
+			HostPath:   repo.HostPath,
+			LastSeenAt: time.Now().UTC(),
+		}
+	}
+	entry.MirrorURL = mirrorURL
+	entry.MirrorStatus = status
+	entry.MirrorError = errStr
+	m.Repos[repo.URL] = entry
+}
+
+// RecordDuration sets how long the last clone or pull of the repo with the
+// given URL took. It is a no-op for repos without an entry.
+func (m *StateManifest) RecordDuration(repoURL string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.Repos[repoURL]
+	if !ok {
+		return
+	}
+	entry.LastDurationSeconds = d.Seconds()
+	m.Repos[repoURL] = entry
+}
+
+// LastSHA returns the HEAD SHA last recorded for the repo with the given URL,
+// or an empty string if there is none.
+func (m *StateManifest) LastSHA(repoURL string) string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Repos[repoURL].LastSHA
+}
+
 // FailedRepos returns the URLs of repos whose last recorded status was error.
 func (m *StateManifest) FailedRepos() []string {
 	if m == nil {
@@ -162,3 +241,63 @@ func (m *StateManifest) FailedRepos() []string {
 	}
 	return out
 }
+
+// stateRepoID returns the provider ID recorded for repo. Wikis and snippets
+// share the ID of their repo, or have none, and are not tracked by ID.
+func stateRepoID(repo scm.Repo) string {
+	if repo.IsWiki || repo.IsGitLabSnippet {
+		return ""
+	}
+	return repo.ID
+}
+
+// FindByID returns the URL and entry of the repo last recorded with the
+// provider ID id.
+func (m *StateManifest) FindByID(id string) (string, RepoState, bool) {
+	if m == nil || id == "" {
+		return "", RepoState{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for url, r := range m.Repos {
+		if r.ID == id {
+			return url, r, true
+		}
+	}
+	return "", RepoState{}, false
+}
+
+// IDAt returns the provider ID of the repo last recorded at hostPath, or an
+// empty string if there is none.
+func (m *StateManifest) IDAt(hostPath string) string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.Repos {
+		if r.HostPath == hostPath && r.ID != "" {
+			return r.ID
+		}
+	}
+	return ""
+}
+
+// Move re-keys the entry of oldURL under repo's URL after the repo was
+// renamed or transferred, keeping its history.
+func (m *StateManifest) Move(oldURL string, repo scm.Repo) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.Repos[oldURL]
+	if !ok {
+		return
+	}
+	delete(m.Repos, oldURL)
+	entry.Name = repo.Name
+	entry.HostPath = repo.HostPath
+	m.Repos[repo.URL] = entry
+}
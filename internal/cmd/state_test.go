@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/blairham/ghorg/internal/scm"
 )
@@ -153,6 +154,29 @@ func TestRecordPreservesPreviousSHAOnError(t *testing.T) {
 	}
 }
 
+func TestRecordKeepsRunHistory(t *testing.T) {
+	t.Parallel()
+	m := NewStateManifest("github", "blairham")
+	repo := scm.Repo{Name: "r", URL: "u", HostPath: "/p", CloneBranch: "main"}
+
+	m.Record(repo, StateStatusError, "", "boom")
+	m.RecordDuration("u", 3*time.Second)
+	m.Record(repo, StateStatusOK, "sha1", "")
+	m.Record(repo, StateStatusError, "", "boom again")
+	m.RecordDuration("missing", time.Second)
+
+	got := m.Repos["u"]
+	if got.FailureCount != 2 {
+		t.Errorf("FailureCount = %d, want 2", got.FailureCount)
+	}
+	if got.LastDurationSeconds != 3 {
+		t.Errorf("LastDurationSeconds = %v, want 3 (kept until the next RecordDuration)", got.LastDurationSeconds)
+	}
+	if _, ok := m.Repos["missing"]; ok {
+		t.Error("RecordDuration should not create entries")
+	}
+}
+
 func TestLoadStateUnsupportedVersion(t *testing.T) {
 	t.Parallel()
 	dir := t.TempDir()
@@ -182,3 +206,49 @@ func TestLoadStateCorruptJSON(t *testing.T) {
 		t.Errorf("Did not expect ErrNotExist for corrupt JSON")
 	}
 }
+
+func TestRecordPreservesMirrorOutcome(t *testing.T) {
+	t.Parallel()
+	m := NewStateManifest("github", "blairham")
+	repo := scm.Repo{Name: "r", URL: "u", HostPath: "/p", CloneBranch: "main"}
+
+	m.Record(repo, StateStatusOK, "sha1", "")
+	m.RecordMirror(repo, "https://mirror/r.git", StateStatusError, "push failed")
+	m.Record(repo, StateStatusOK, "sha2", "")
+
+	got := m.Repos["u"]
+	if got.LastSHA != "sha2" {
+		t.Errorf("LastSHA = %q, want sha2", got.LastSHA)
+	}
+	if got.MirrorURL != "https://mirror/r.git" || got.MirrorStatus != StateStatusError || got.MirrorError != "push failed" {
+		t.Errorf("mirror outcome not preserved: %+v", got)
+	}
+}
+
+func TestStateRenameLookups(t *testing.T) {
+	t.Parallel()
+	m := NewStateManifest("github", "blairham")
+	m.Record(scm.Repo{Name: "old", ID: "42", URL: "https://github.com/org/old", HostPath: "/c/old"}, StateStatusOK, "sha1", "")
+	m.Record(scm.Repo{Name: "old", IsWiki: true, ID: "42", URL: "https://github.com/org/old.wiki", HostPath: "/c/old.wiki"}, StateStatusOK, "sha1", "")
+	m.Record(scm.Repo{Name: "old", ID: "", URL: "https://github.com/org/old", HostPath: "/c/old"}, StateStatusError, "", "boom")
+
+	url, entry, ok := m.FindByID("42")
+	if !ok || url != "https://github.com/org/old" || entry.ID != "42" {
+		t.Fatalf("FindByID(42) = %q, %+v, %v", url, entry, ok)
+	}
+	if m.Repos["https://github.com/org/old.wiki"].ID != "" {
+		t.Error("wikis should not be tracked by ID")
+	}
+	if m.IDAt("/c/old") != "42" || m.IDAt("/c/other") != "" {
+		t.Errorf("IDAt = %q, %q", m.IDAt("/c/old"), m.IDAt("/c/other"))
+	}
+
+	m.Move(url, scm.Repo{Name: "new", URL: "https://github.com/org/new", HostPath: "/c/new"})
+	if _, ok := m.Repos[url]; ok {
+		t.Error("old entry kept after Move")
+	}
+	moved := m.Repos["https://github.com/org/new"]
+	if moved.ID != "42" || moved.HostPath != "/c/new" || moved.Name != "new" || moved.FailureCount != 1 {
+		t.Errorf("moved entry = %+v", moved)
+	}
+}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/cli"
+	"github.com/jessevdk/go-flags"
+	"github.com/korovkin/limiter"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/git"
+)
+
+type StatusCommand struct {
+	UI cli.Ui
+}
+
+type StatusFlags struct {
+	JSON        bool   `long:"json" description:"Print the status of each repo as JSON"`
+	Dirty       bool   `long:"dirty" description:"Only show repos with uncommitted changes"`
+	Unpushed    bool   `long:"unpushed" description:"Only show repos with commits not pushed to their upstream"`
+	Detached    bool   `long:"detached" description:"Only show repos with a detached HEAD"`
+	Behind      bool   `long:"behind" description:"Only show repos whose HEAD is missing commits from the default branch"`
+	NonDefault  bool   `long:"non-default" description:"Only show repos that are not on their default branch"`
+	Concurrency string `long:"concurrency" description:"GHORG_CONCURRENCY - Max goroutines to spin up while checking repos (default 25)"`
+}
+
+// RepoStatus is the health of a single local clone.
+type RepoStatus struct {
+	Name          string `json:"name"`
+	Path          string `json:"path"`
+	Branch        string `json:"branch"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+	Dirty         bool   `json:"dirty"`
+	Unpushed      bool   `json:"unpushed"`
+	Detached      bool   `json:"detached"`
+	Behind        int    `json:"behind"`
+	NonDefault    bool   `json:"non_default_branch"`
+	Error         string `json:"error,omitempty"`
+}
+
+// statusFilter selects which repos are printed. A repo is shown when it
+// matches any enabled filter, or always when no filter is enabled.
+type statusFilter struct {
+	dirty, unpushed, detached, behind, nonDefault bool
+}
+
+func (f statusFilter) matches(s RepoStatus) bool {
+	if !f.dirty && !f.unpushed && !f.detached && !f.behind && !f.nonDefault {
+		return true
+	}
+	return (f.dirty && s.Dirty) ||
+		(f.unpushed && s.Unpushed) ||
+		(f.detached && s.Detached) ||
+		(f.behind && s.Behind > 0) ||
+		(f.nonDefault && s.NonDefault)
+}
+
+func (c *StatusCommand) Help() string {
+	return `Usage: ghorg status [options] [dir]
+
+Report the health of every local clone in a ghorg directory: uncommitted
+changes, unpushed commits, detached HEADs, commits missing from the default
+branch, and repos checked out on a non-default branch.
+If no dir is specified it will check every repo in GHORG_ABSOLUTE_PATH_TO_CLONE_TO.
+
+Repos are checked in parallel. Filters can be combined, a repo is shown when
+it matches any of them.
+
+Options:
+  --json         Print the status of each repo as JSON
+  --dirty        Only show repos with uncommitted changes
+  --unpushed     Only show repos with unpushed commits
+  --detached     Only show repos with a detached HEAD
+  --behind       Only show repos whose HEAD is behind the default branch
+  --non-default  Only show repos not on their default branch
+  --concurrency  Max goroutines to spin up while checking repos
+
+Examples:
+  ghorg status
+  ghorg status kubernetes --dirty --unpushed
+  ghorg status --behind --json
+`
+}
+
+func (c *StatusCommand) Synopsis() string {
+	return "Report uncommitted, unpushed and out of date local clones"
+}
+
+func (c *StatusCommand) Run(args []string) int {
+	var opts StatusFlags
+	parser := flags.NewParser(&opts, flags.Default)
+	remaining, err := parser.ParseArgs(args)
+	if err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(c.Help())
+			return 0
+		}
+		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
+		return 1
+	}
+
+	if opts.Concurrency != "" {
+		os.Setenv("GHORG_CONCURRENCY", opts.Concurrency)
+	}
+
+	var arg string
+	if len(remaining) > 0 {
+		arg = remaining[0]
+	}
+	root, err := resolveGhorgDir(arg)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+
+	repos, err := findLocalRepos(root)
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Error reading %s: %v", root, err))
+		return 1
+	}
+	if len(repos) == 0 {
+		colorlog.PrintError("No clones found. Please clone some and try again.")
+		return 1
+	}
+
+	defaults := clonedBranches(root, filepath.Dir(root), os.Getenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO"))
+	statuses := collectRepoStatuses(git.NewGit(), repos, defaults, statusConcurrency())
+
+	filter := statusFilter{
+		dirty:      opts.Dirty,
+		unpushed:   opts.Unpushed,
+		detached:   opts.Detached,
+		behind:     opts.Behind,
+		nonDefault: opts.NonDefault,
+	}
+	shown := make([]RepoStatus, 0, len(statuses))
+	for _, s := range statuses {
+		if filter.matches(s) {
+			shown = append(shown, s)
+		}
+	}
+
+	if opts.JSON {
+		if err := writeStatusJSON(os.Stdout, shown); err != nil {
+			colorlog.PrintError(err)
+			return 1
+		}
+		return 0
+	}
+
+	writeStatusTable(os.Stdout, shown)
+	colorlog.PrintInfo(fmt.Sprintf("\n%d of %d repos shown", len(shown), len(statuses)))
+	return 0
+}
+
+// statusConcurrency returns GHORG_CONCURRENCY, falling back to 25.
+func statusConcurrency() int {
+	n, err := strconv.Atoi(os.Getenv("GHORG_CONCURRENCY"))
+	if err != nil || n < 1 {
+		return 25
+	}
+	return n
+}
+
+// collectRepoStatuses checks every repo in parallel and returns the results
+// in the same order as repos.
+func collectRepoStatuses(g git.Gitter, repos []localRepo, defaults map[string]string, concurrency int) []RepoStatus {
+	statuses := make([]RepoStatus, len(repos))
+	limit := limiter.NewConcurrencyLimiter(concurrency)
+
+	for i := range repos {
+		//nolint:errcheck // results are written into statuses
+		limit.Execute(func() {
+			statuses[i] = repoStatus(g, repos[i], defaults[filepath.Clean(repos[i].Path)])
+		})
+	}
+	limit.WaitAndClose()
+
+	return statuses
+}
+
+// repoStatus inspects a single clone. defaultBranch may be empty, in which
+// case it is looked up from the remote.
+func repoStatus(g git.Gitter, local localRepo, defaultBranch string) RepoStatus {
+	repo := local.scmRepo()
+	status := RepoStatus{Name: local.Name, Path: local.Path}
+
+	branch, err := g.GetCurrentBranch(repo)
+	if err != nil {
+		status.Error = fmt.Sprintf("could not read HEAD: %v", err)
+		return status
+	}
+	status.Branch = branch
+
+	if detached, err := g.IsDetachedHead(repo); err == nil {
+		status.Detached = detached
+	}
+
+	if dirty, err := g.HasLocalChanges(repo); err == nil {
+		status.Dirty = dirty
+	}
+
+	if !status.Detached {
+		// No upstream tracking branch is reported as nothing unpushed.
+		if unpushed, err := g.HasUnpushedCommits(repo); err == nil {
+			status.Unpushed = unpushed
+		}
+	}
+
+	if defaultBranch == "" {
+		if remoteDefault, err := g.GetRemoteDefaultBranch(repo); err == nil {
+			defaultBranch = remoteDefault
+		}
+	}
+	status.DefaultBranch = defaultBranch
+	if defaultBranch == "" {
+		return status
+	}
+
+	status.NonDefault = status.Detached || branch != defaultBranch
+
+	// Behind counts the commits of the remote's default branch missing from HEAD.
+	missing, err := g.RevListCompare(repo, "origin/"+defaultBranch, "HEAD")
+	if err == nil && missing != "" {
+		status.Behind = len(strings.Split(missing, "\n"))
+	}
+
+	return status
+}
+
+// writeStatusJSON writes statuses as an indented JSON array.
+func writeStatusJSON(w io.Writer, statuses []RepoStatus) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(statuses)
+}
+
+// writeStatusTable writes statuses as an aligned table.
+func writeStatusTable(w io.Writer, statuses []RepoStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tBRANCH\tDIRTY\tUNPUSHED\tBEHIND\tNOTES")
+	for _, s := range statuses {
+		var notes []string
+		if s.Detached {
+			notes = append(notes, "detached HEAD")
+		} else if s.NonDefault {
+			notes = append(notes, "not on "+s.DefaultBranch)
+		}
+		if s.Error != "" {
+			notes = append(notes, s.Error)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			s.Name, s.Branch, yesNo(s.Dirty), yesNo(s.Unpushed), s.Behind, strings.Join(notes, ", "))
+	}
+	tw.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "-"
+}
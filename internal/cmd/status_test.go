@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blairham/ghorg/internal/git"
+)
+
+// setupStatusFixture creates a ghorg directory with an upstream bare repo and
+// clones of it in different states: clean, dirty, on a feature branch that is
+// behind origin/main, on a main that is behind origin/main, with a detached
+// HEAD, and on a branch named like the start of its commit's SHA.
+func setupStatusFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+
+	work := filepath.Join(root, ".work")
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, work, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(work, "README.md"), []byte("# repo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, work, "add", ".")
+	runTestGit(t, work, "commit", "-m", "initial")
+	upstream := filepath.Join(root, ".upstream.git")
+	runTestGit(t, root, "clone", "--bare", work, upstream)
+
+	org := filepath.Join(root, "org")
+	for _, name := range []string{"clean", "dirty", "feature", "behind", "detached", "hexbranch"} {
+		runTestGit(t, root, "clone", upstream, filepath.Join(org, name))
+	}
+
+	if err := os.WriteFile(filepath.Join(org, "dirty", "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	feature := filepath.Join(org, "feature")
+	runTestGit(t, feature, "checkout", "-b", "feature")
+
+	runTestGit(t, filepath.Join(org, "detached"), "checkout", "--detach", "HEAD")
+
+	// A branch named after the start of the commit it points at is not detached.
+	hexbranch := filepath.Join(org, "hexbranch")
+	sha, err := exec.Command("git", "-C", hexbranch, "rev-parse", "--short=4", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, hexbranch, "checkout", "-b", strings.TrimSpace(string(sha)))
+
+	// The upstream moves on; feature and behind fetch it without merging, so
+	// their local main stays where it was.
+	runTestGit(t, work, "commit", "--allow-empty", "-m", "upstream moved on")
+	runTestGit(t, work, "push", upstream, "main")
+	runTestGit(t, feature, "fetch", "origin")
+	runTestGit(t, filepath.Join(org, "behind"), "fetch", "origin")
+
+	return org
+}
+
+func TestFindLocalRepos(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"a/.git",
+		"group/b/.git",
+		"group/b/sub/.git", // nested repos are not reported
+		".ghorg-trash/c/.git",
+		"not-a-repo/docs",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repos, err := findLocalRepos(root)
+	if err != nil {
+		t.Fatalf("findLocalRepos failed: %v", err)
+	}
+
+	var names []string
+	for _, r := range repos {
+		names = append(names, r.Name)
+	}
+	if strings.Join(names, ",") != "a,group/b" {
+		t.Errorf("repos = %v, want [a group/b]", names)
+	}
+}
+
+func TestResolveGhorgDir(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	home := t.TempDir()
+	os.Setenv("GHORG_ABSOLUTE_PATH_TO_CLONE_TO", home)
+	if err := os.MkdirAll(filepath.Join(home, "my_org"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := resolveGhorgDir(""); err != nil || got != home {
+		t.Errorf("resolveGhorgDir(\"\") = %q, %v; want %q", got, err, home)
+	}
+	if got, err := resolveGhorgDir("my-org"); err != nil || got != filepath.Join(home, "my_org") {
+		t.Errorf("resolveGhorgDir(my-org) = %q, %v", got, err)
+	}
+	if got, err := resolveGhorgDir(home); err != nil || got != home {
+		t.Errorf("resolveGhorgDir(abs) = %q, %v", got, err)
+	}
+	if _, err := resolveGhorgDir("missing"); err == nil {
+		t.Error("Expected error for missing directory")
+	}
+}
+
+func TestCollectRepoStatuses(t *testing.T) {
+	org := setupStatusFixture(t)
+
+	repos, err := findLocalRepos(org)
+	if err != nil {
+		t.Fatalf("findLocalRepos failed: %v", err)
+	}
+
+	backends := map[string]git.Gitter{
+		"exec":   git.NewExecGit(),
+		"golang": git.GoGitClient(),
+	}
+	for name, g := range backends {
+		t.Run(name, func(t *testing.T) {
+			defaults := make(map[string]string)
+			for _, r := range repos {
+				defaults[r.Path] = "main"
+			}
+
+			byName := make(map[string]RepoStatus)
+			for _, s := range collectRepoStatuses(g, repos, defaults, 2) {
+				if s.Error != "" {
+					t.Fatalf("%s: unexpected error %s", s.Name, s.Error)
+				}
+				byName[s.Name] = s
+			}
+
+			if s := byName["clean"]; s.Dirty || s.Unpushed || s.Detached || s.NonDefault || s.Behind != 0 {
+				t.Errorf("clean repo reported issues: %+v", s)
+			}
+			if s := byName["dirty"]; !s.Dirty {
+				t.Errorf("dirty repo not reported dirty: %+v", s)
+			}
+			if s := byName["feature"]; !s.NonDefault || s.Behind != 1 || s.Branch != "feature" {
+				t.Errorf("feature repo = %+v, want non-default and 1 behind", s)
+			}
+			if s := byName["behind"]; s.NonDefault || s.Behind != 1 {
+				t.Errorf("behind repo = %+v, want on main and 1 behind origin/main", s)
+			}
+			if s := byName["detached"]; !s.Detached || !s.NonDefault {
+				t.Errorf("detached repo = %+v, want detached", s)
+			}
+			if s := byName["hexbranch"]; s.Detached {
+				t.Errorf("hexbranch repo = %+v, want a branch, not detached", s)
+			}
+		})
+	}
+}
+
+func TestStatusFilterMatches(t *testing.T) {
+	dirty := RepoStatus{Name: "dirty", Dirty: true}
+	behind := RepoStatus{Name: "behind", Behind: 2}
+	clean := RepoStatus{Name: "clean"}
+
+	tests := []struct {
+		name   string
+		filter statusFilter
+		want   []bool
+	}{
+		{"no filter shows all", statusFilter{}, []bool{true, true, true}},
+		{"dirty", statusFilter{dirty: true}, []bool{true, false, false}},
+		{"behind", statusFilter{behind: true}, []bool{false, true, false}},
+		{"dirty or behind", statusFilter{dirty: true, behind: true}, []bool{true, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, s := range []RepoStatus{dirty, behind, clean} {
+				if got := tt.filter.matches(s); got != tt.want[i] {
+					t.Errorf("matches(%s) = %v, want %v", s.Name, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteStatusOutput(t *testing.T) {
+	statuses := []RepoStatus{
+		{Name: "org/api", Branch: "main", DefaultBranch: "main", Dirty: true},
+		{Name: "org/web", Branch: "feature", DefaultBranch: "main", NonDefault: true, Behind: 3},
+	}
+
+	var table bytes.Buffer
+	writeStatusTable(&table, statuses)
+	out := table.String()
+	for _, want := range []string{"REPO", "org/api", "org/web", "not on main"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table output missing %q:\n%s", want, out)
+		}
+	}
+
+	var js bytes.Buffer
+	if err := writeStatusJSON(&js, statuses); err != nil {
+		t.Fatalf("writeStatusJSON failed: %v", err)
+	}
+	var decoded []RepoStatus
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded) != 2 || decoded[1].Behind != 3 {
+		t.Errorf("decoded = %+v", decoded)
+	}
+}
+
+func TestStatusCommand_Help(t *testing.T) {
+	cmd := &StatusCommand{}
+	help := cmd.Help()
+	for _, flag := range []string{"--json", "--dirty", "--behind"} {
+		if !strings.Contains(help, flag) {
+			t.Errorf("Help should mention %s", flag)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+)
+
+const (
+	// TrashDirName is the directory inside a clone directory that pruned
+	// repos are moved to with GHORG_PRUNE_TRASH.
+	TrashDirName = ".ghorg-trash"
+	// trashManifestName lists the repos of a trash batch and where they came from.
+	trashManifestName = "_ghorg_trash.json"
+	// trashBatchLayout names trash batches, so they sort by the time of the prune.
+	trashBatchLayout = "20060102T150405Z"
+
+	defaultTrashRetentionDays = 30
+)
+
+// TrashedRepo is a repo moved into a trash batch.
+type TrashedRepo struct {
+	// Path is the path of the clone relative to the clone directory, with
+	// forward slashes. The repo is stored at the same path inside the batch.
+	Path      string    `json:"path"`
+	Reason    string    `json:"reason,omitempty"`
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+// trashManifest is the _ghorg_trash.json of a trash batch.
+type trashManifest struct {
+	Target    string        `json:"target,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Repos     []TrashedRepo `json:"repos"`
+}
+
+// trashBatch moves the repos pruned by one run into
+// <clone dir>/.ghorg-trash/<timestamp>/. The batch directory is created with
+// the first repo. A nil *trashBatch deletes repos instead.
+type trashBatch struct {
+	root     string
+	dir      string
+	manifest trashManifest
+}
+
+// newTrashFromEnv returns the trash batch for pruning clones in root, or nil
+// when GHORG_PRUNE_TRASH is not enabled.
+func newTrashFromEnv(root string) *trashBatch {
+	if os.Getenv("GHORG_PRUNE_TRASH") != "true" {
+		return nil
+	}
+	return &trashBatch{root: root, manifest: trashManifest{Target: targetCloneSource}}
+}
+
+// trashRetentionFromEnv returns GHORG_PRUNE_TRASH_RETENTION_DAYS, 0 meaning
+// batches are kept until they are emptied.
+func trashRetentionFromEnv() (int, error) {
+	value := os.Getenv("GHORG_PRUNE_TRASH_RETENTION_DAYS")
+	if value == "" {
+		return defaultTrashRetentionDays, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid GHORG_PRUNE_TRASH_RETENTION_DAYS %q, expected a number of days", value)
+	}
+	return days, nil
+}
+
+// discard removes the clone at absPath, moving it into the batch unless b is nil.
+func (b *trashBatch) discard(absPath, reason string) error {
+	if b == nil {
+		return os.RemoveAll(absPath)
+	}
+
+	rel, err := filepath.Rel(b.root, absPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("%s is outside of %s", absPath, b.root)
+	}
+	if b.dir == "" {
+		if b.dir, err = createTrashBatchDir(b.root, time.Now()); err != nil {
+			return err
+		}
+		b.manifest.CreatedAt = time.Now().UTC()
+	}
+
+	dest := filepath.Join(b.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(absPath, dest); err != nil {
+		return err
+	}
+
+	b.manifest.Repos = append(b.manifest.Repos, TrashedRepo{Path: filepath.ToSlash(rel), Reason: reason, TrashedAt: time.Now().UTC()})
+	return writeTrashManifest(b.dir, b.manifest)
+}
+
+// createTrashBatchDir creates the directory of a new trash batch below root,
+// adding a suffix when a batch was already created in the same second.
+func createTrashBatchDir(root string, now time.Time) (string, error) {
+	trashDir := filepath.Join(root, TrashDirName)
+	if err := os.MkdirAll(trashDir, 0o755); err != nil {
+		return "", err
+	}
+	name := now.UTC().Format(trashBatchLayout)
+	for i := 2; ; i++ {
+		dir := filepath.Join(trashDir, name)
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		name = fmt.Sprintf("%s-%d", now.UTC().Format(trashBatchLayout), i)
+	}
+}
+
+func writeTrashManifest(dir string, m trashManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, trashManifestName), append(data, '\n'))
+}
+
+// TrashBatch is a trash batch found on disk.
+type TrashBatch struct {
+	ID        string
+	Dir       string
+	Target    string
+	CreatedAt time.Time
+	Repos     []TrashedRepo
+}
+
+// listTrash returns the trash batches below root, oldest first.
+func listTrash(root string) ([]TrashBatch, error) {
+	entries, err := os.ReadDir(filepath.Join(root, TrashDirName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var batches []TrashBatch
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, TrashDirName, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, trashManifestName))
+		if err != nil {
+			return nil, fmt.Errorf("reading trash batch %s: %w", entry.Name(), err)
+		}
+		var m trashManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("parsing trash batch %s: %w", entry.Name(), err)
+		}
+		batches = append(batches, TrashBatch{ID: entry.Name(), Dir: dir, Target: m.Target, CreatedAt: m.CreatedAt, Repos: m.Repos})
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].ID < batches[j].ID })
+	return batches, nil
+}
+
+// restoreTrash moves the repos of batch back into root. With paths only those
+// repos are restored. A repo whose original path exists again is left in the
+// trash and reported in the error. The batch is removed once it is empty.
+func restoreTrash(root string, batch TrashBatch, paths []string) ([]string, error) {
+	var restored []string
+	var kept []TrashedRepo
+	var errs []error
+	for _, repo := range batch.Repos {
+		if len(paths) > 0 && !slices.Contains(paths, repo.Path) {
+			kept = append(kept, repo)
+			continue
+		}
+		dest := filepath.Join(root, filepath.FromSlash(repo.Path))
+		if _, err := os.Stat(dest); err == nil {
+			errs = append(errs, fmt.Errorf("not restoring %s, %s already exists", repo.Path, dest))
+			kept = append(kept, repo)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return restored, err
+		}
+		if err := os.Rename(filepath.Join(batch.Dir, filepath.FromSlash(repo.Path)), dest); err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %w", repo.Path, err))
+			kept = append(kept, repo)
+			continue
+		}
+		restored = append(restored, repo.Path)
+	}
+	for _, p := range paths {
+		if !slices.Contains(restored, p) && !batchHasRepo(batch, p) {
+			errs = append(errs, fmt.Errorf("%s is not in trash batch %s", p, batch.ID))
+		}
+	}
+
+	if len(kept) == 0 {
+		if err := os.RemoveAll(batch.Dir); err != nil {
+			errs = append(errs, err)
+		}
+	} else if len(restored) > 0 {
+		m := trashManifest{Target: batch.Target, CreatedAt: batch.CreatedAt, Repos: kept}
+		if err := writeTrashManifest(batch.Dir, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return restored, errors.Join(errs...)
+}
+
+// expireTrash deletes the trash batches below root created more than
+// retentionDays ago. A retention of 0 keeps every batch.
+func expireTrash(root string, retentionDays int, now time.Time) ([]string, error) {
+	if retentionDays == 0 {
+		return nil, nil
+	}
+	return emptyTrash(root, now.AddDate(0, 0, -retentionDays))
+}
+
+// emptyTrash deletes the trash batches below root created before cutoff, or
+// every batch with a zero cutoff.
+func emptyTrash(root string, cutoff time.Time) ([]string, error) {
+	batches, err := listTrash(root)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, batch := range batches {
+		if !cutoff.IsZero() && !batch.CreatedAt.Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(batch.Dir); err != nil {
+			return removed, err
+		}
+		removed = append(removed, batch.ID)
+	}
+	return removed, nil
+}
+
+func batchHasRepo(batch TrashBatch, path string) bool {
+	for _, repo := range batch.Repos {
+		if repo.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPruneThreshold returns an error when pruning candidates of total local
+// clones exceeds GHORG_PRUNE_MAX_PERCENT. Such a prune usually means the
+// remote listing was incomplete, e.g. because of a token without access.
+func checkPruneThreshold(candidates, total int) error {
+	value := os.Getenv("GHORG_PRUNE_MAX_PERCENT")
+	if value == "" || value == "0" || candidates == 0 || total == 0 {
+		return nil
+	}
+	maxPercent, err := strconv.Atoi(value)
+	if err != nil || maxPercent < 0 || maxPercent > 100 {
+		return fmt.Errorf("invalid GHORG_PRUNE_MAX_PERCENT %q, expected a percentage between 0 and 100", value)
+	}
+	if candidates*100 > maxPercent*total {
+		return fmt.Errorf("%d of %d local clones (%d%%) would be pruned, more than GHORG_PRUNE_MAX_PERCENT=%d%%", candidates, total, candidates*100/total, maxPercent)
+	}
+	return nil
+}
+
+// expireTrashFromEnv deletes the trash batches of the clone directory that
+// are older than GHORG_PRUNE_TRASH_RETENTION_DAYS.
+func expireTrashFromEnv(root string) {
+	days, err := trashRetentionFromEnv()
+	if err != nil {
+		colorlog.PrintError(err)
+		return
+	}
+	removed, err := expireTrash(root, days, time.Now())
+	if err != nil {
+		colorlog.PrintError(fmt.Sprintf("Could not clean up %s: %v", filepath.Join(root, TrashDirName), err))
+	}
+	for _, id := range removed {
+		colorlog.PrintSubtleInfo(fmt.Sprintf("Deleted trash batch %s, older than %d days", id, days))
+	}
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/cli"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+)
+
+type TrashCommand struct {
+	UI cli.Ui
+}
+
+type TrashRestoreFlags struct {
+	Batch string `long:"batch" description:"Trash batch to restore from (default the most recent batch holding each repo)"`
+}
+
+type TrashEmptyFlags struct {
+	OlderThanDays int  `long:"older-than-days" description:"Only delete batches older than this many days"`
+	Yes           bool `long:"yes" description:"Do not prompt before deleting"`
+}
+
+func (c *TrashCommand) Help() string {
+	return `Usage: ghorg trash <subcommand> <dir> [options] [repo...]
+
+Manage repos pruned with ghorg clone --prune-trash (GHORG_PRUNE_TRASH). Each
+prune moves the clones it removes into a batch named after the time of the
+run, <dir>/.ghorg-trash/<timestamp>/, instead of deleting them. Batches older
+than GHORG_PRUNE_TRASH_RETENTION_DAYS (default 30) are deleted by the next
+prune. ghorg prune --undo restores the most recent batch.
+
+<dir> is a clone directory, either a path or its name inside
+GHORG_ABSOLUTE_PATH_TO_CLONE_TO, as for ghorg ls.
+
+Subcommands:
+  list       List trash batches and the repos in them.
+  restore    Move repos back to where they were pruned from. Without repos
+             the whole batch is restored, the most recent one by default.
+             Repos whose directory exists again are left in the trash.
+  empty      Delete trash batches for good.
+
+Restore options:
+  --batch              Trash batch to restore from
+
+Empty options:
+  --older-than-days    Only delete batches older than this many days
+  --yes                Do not prompt before deleting
+
+Examples:
+  ghorg trash list kubernetes
+  ghorg trash restore kubernetes
+  ghorg trash restore kubernetes --batch 20261018T101500Z old-tool
+  ghorg trash empty kubernetes --older-than-days 7 --yes
+`
+}
+
+func (c *TrashCommand) Synopsis() string {
+	return "List, restore and empty repos pruned into .ghorg-trash"
+}
+
+func (c *TrashCommand) Run(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		return c.runList(args[1:])
+	case "restore":
+		return c.runRestore(args[1:])
+	case "empty":
+		return c.runEmpty(args[1:])
+	case "-h", "--help", "help":
+		fmt.Println(c.Help())
+		return 0
+	default:
+		colorlog.PrintError(fmt.Sprintf("Unknown trash subcommand: %s", args[0]))
+		return 1
+	}
+}
+
+// parseTrashArgs parses args into opts and resolves the clone directory given
+// as the first positional argument, returning an exit code when the command
+// should stop.
+func (c *TrashCommand) parseTrashArgs(opts any, args []string) (string, []string, int, bool) {
+	parser := flags.NewParser(opts, flags.Default)
+	rest, err := parser.ParseArgs(args)
+	if err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(c.Help())
+			return "", nil, 0, true
+		}
+		colorlog.PrintError(fmt.Sprintf("Error parsing flags: %v", err))
+		return "", nil, 1, true
+	}
+	if len(rest) == 0 {
+		colorlog.PrintError("Missing clone directory, see ghorg trash --help")
+		return "", nil, 1, true
+	}
+	root, err := resolveGhorgDir(rest[0])
+	if err != nil {
+		colorlog.PrintError(err)
+		return "", nil, 1, true
+	}
+	return root, rest[1:], 0, false
+}
+
+func (c *TrashCommand) runList(args []string) int {
+	var opts struct{}
+	root, _, code, stop := c.parseTrashArgs(&opts, args)
+	if stop {
+		return code
+	}
+
+	batches, err := listTrash(root)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	if len(batches) == 0 {
+		colorlog.PrintInfo(fmt.Sprintf("No pruned repos in %s", root))
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BATCH\tREPO\tREASON\tTRASHED")
+	for _, batch := range batches {
+		for _, repo := range batch.Repos {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", batch.ID, repo.Path, repo.Reason, repo.TrashedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+	}
+	tw.Flush()
+	return 0
+}
+
+func (c *TrashCommand) runRestore(args []string) int {
+	var opts TrashRestoreFlags
+	root, paths, code, stop := c.parseTrashArgs(&opts, args)
+	if stop {
+		return code
+	}
+
+	batches, err := listTrash(root)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	if len(batches) == 0 {
+		colorlog.PrintError(fmt.Sprintf("No pruned repos in %s", root))
+		return 1
+	}
+
+	restored, err := restoreFromTrash(root, batches, opts.Batch, paths)
+	for _, p := range restored {
+		colorlog.PrintSuccess(fmt.Sprintf("Restored %s", p))
+	}
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	return 0
+}
+
+// restoreFromTrash restores paths from the batch with the ID batchID, or each
+// path from the most recent batch holding it. Without paths it restores the
+// whole batch, the most recent one without batchID.
+func restoreFromTrash(root string, batches []TrashBatch, batchID string, paths []string) ([]string, error) {
+	if batchID != "" {
+		i := slices.IndexFunc(batches, func(b TrashBatch) bool { return b.ID == batchID })
+		if i < 0 {
+			return nil, fmt.Errorf("no trash batch %s in %s", batchID, root)
+		}
+		return restoreTrash(root, batches[i], paths)
+	}
+	if len(paths) == 0 {
+		return restoreTrash(root, batches[len(batches)-1], nil)
+	}
+
+	var restored []string
+	var errs []error
+	remaining := slices.Clone(paths)
+	for i := len(batches) - 1; i >= 0 && len(remaining) > 0; i-- {
+		var here []string
+		for _, p := range remaining {
+			if batchHasRepo(batches[i], p) {
+				here = append(here, p)
+			}
+		}
+		if len(here) == 0 {
+			continue
+		}
+		r, err := restoreTrash(root, batches[i], here)
+		restored = append(restored, r...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		remaining = slices.DeleteFunc(remaining, func(p string) bool { return slices.Contains(here, p) })
+	}
+	if len(remaining) > 0 {
+		errs = append(errs, fmt.Errorf("not in the trash: %s", strings.Join(remaining, ", ")))
+	}
+	return restored, errors.Join(errs...)
+}
+
+func (c *TrashCommand) runEmpty(args []string) int {
+	var opts TrashEmptyFlags
+	root, _, code, stop := c.parseTrashArgs(&opts, args)
+	if stop {
+		return code
+	}
+	if opts.OlderThanDays < 0 {
+		colorlog.PrintError("--older-than-days must not be negative")
+		return 1
+	}
+
+	var cutoff time.Time
+	what := "all trash batches"
+	if opts.OlderThanDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -opts.OlderThanDays)
+		what = fmt.Sprintf("trash batches older than %d days", opts.OlderThanDays)
+	}
+	if !opts.Yes && !interactiveYesNoPrompt(fmt.Sprintf("Delete %s in %s for good?", what, root)) {
+		colorlog.PrintInfo("Not emptying the trash")
+		return 0
+	}
+
+	removed, err := emptyTrash(root, cutoff)
+	for _, id := range removed {
+		colorlog.PrintSuccess(fmt.Sprintf("Deleted trash batch %s", id))
+	}
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	if len(removed) == 0 {
+		colorlog.PrintInfo(fmt.Sprintf("No %s in %s", what, root))
+	}
+	return 0
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+func TestTrashBatch_DiscardAndRestore(t *testing.T) {
+	savedDir := outputDirAbsolutePath
+	defer func() { outputDirAbsolutePath = savedDir }()
+	root := setupExecFixture(t, "app", "group/lib", "kept")
+	outputDirAbsolutePath = root
+
+	trash := &trashBatch{root: root}
+	for _, p := range []string{"app", "group/lib"} {
+		if err := trash.discard(filepath.Join(root, filepath.FromSlash(p)), "not found on remote"); err != nil {
+			t.Fatalf("discard %s: %v", p, err)
+		}
+	}
+	if err := trash.discard(filepath.Dir(root), ""); err == nil {
+		t.Error("expected an error discarding a directory outside of the clone directory")
+	}
+
+	local, err := getRelativePathRepositories(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(local) != 1 || local[0] != "kept" {
+		t.Errorf("local clones = %v, trash should not be scanned", local)
+	}
+
+	batches, err := listTrash(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || len(batches[0].Repos) != 2 || batches[0].Repos[1].Path != "group/lib" || batches[0].Repos[1].Reason != "not found on remote" {
+		t.Fatalf("unexpected trash: %+v", batches)
+	}
+	if !isGitRepository(filepath.Join(batches[0].Dir, "group", "lib")) {
+		t.Error("group/lib was not moved into the batch")
+	}
+
+	restored, err := restoreFromTrash(root, batches, "", []string{"group/lib"})
+	if err != nil || len(restored) != 1 || !isGitRepository(filepath.Join(root, "group", "lib")) {
+		t.Fatalf("restore group/lib = %v, %v", restored, err)
+	}
+
+	// a new clone took the place of app, so it stays in the trash
+	if err := os.MkdirAll(filepath.Join(root, "app", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	batches, _ = listTrash(root)
+	restored, err = restoreFromTrash(root, batches, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "already exists") || len(restored) != 0 {
+		t.Errorf("restore = %v, %v; want app to stay in the trash", restored, err)
+	}
+	if batches, _ = listTrash(root); len(batches) != 1 || len(batches[0].Repos) != 1 || batches[0].Repos[0].Path != "app" {
+		t.Errorf("trash after restores = %+v", batches)
+	}
+
+	if _, err := restoreFromTrash(root, batches, "", []string{"missing"}); err == nil || !strings.Contains(err.Error(), "not in the trash: missing") {
+		t.Errorf("expected an error for a repo not in the trash, got %v", err)
+	}
+
+	// without a batch repos are deleted
+	var none *trashBatch
+	if err := none.discard(filepath.Join(root, "kept"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "kept")); !os.IsNotExist(err) {
+		t.Error("kept should have been deleted")
+	}
+}
+
+func TestExpireTrash(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	for _, age := range []int{40, 10} {
+		created := now.AddDate(0, 0, -age)
+		dir, err := createTrashBatchDir(root, created)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeTrashManifest(dir, trashManifest{CreatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a second batch in the same second gets a suffix
+	if dir, err := createTrashBatchDir(root, now.AddDate(0, 0, -10)); err != nil || !strings.HasSuffix(dir, "-2") {
+		t.Errorf("createTrashBatchDir = %q, %v; want a -2 suffix", dir, err)
+	} else {
+		os.Remove(dir)
+	}
+
+	if removed, err := expireTrash(root, 0, now); err != nil || len(removed) != 0 {
+		t.Errorf("retention 0 removed %v, %v", removed, err)
+	}
+	removed, err := expireTrash(root, 30, now)
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("expireTrash = %v, %v; want the 40 day old batch", removed, err)
+	}
+	if batches, _ := listTrash(root); len(batches) != 1 {
+		t.Errorf("expected one batch to be left, got %d", len(batches))
+	}
+}
+
+func TestCheckPruneThreshold(t *testing.T) {
+	defer UnsetEnv("GHORG_PRUNE_MAX_PERCENT")()
+
+	tests := []struct {
+		maxPercent        string
+		candidates, total int
+		wantErr           string
+	}{
+		{"", 10, 10, ""},
+		{"0", 10, 10, ""},
+		{"50", 5, 10, ""},
+		{"50", 6, 10, "6 of 10 local clones (60%) would be pruned"},
+		{"10", 0, 10, ""},
+		{"150", 1, 10, "invalid GHORG_PRUNE_MAX_PERCENT"},
+	}
+	for _, tt := range tests {
+		os.Setenv("GHORG_PRUNE_MAX_PERCENT", tt.maxPercent)
+		err := checkPruneThreshold(tt.candidates, tt.total)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("max %s%%, %d of %d: got %v, want %q", tt.maxPercent, tt.candidates, tt.total, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPruneRepos_TrashAndProtection(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedDir := outputDirAbsolutePath
+	defer func() { outputDirAbsolutePath = savedDir }()
+	root := setupExecFixture(t, "keep", "gone", "dirty")
+	outputDirAbsolutePath = root
+	os.Setenv("GHORG_PRUNE_NO_CONFIRM", "true")
+	os.Setenv("GHORG_PRUNE_TRASH", "true")
+	targets := []scm.Repo{{Path: "keep"}}
+
+	os.Setenv("GHORG_PRUNE_MAX_PERCENT", "50")
+	processor := NewRepositoryProcessor(dirtyMockGit{dirty: map[string]bool{filepath.Join(root, "dirty"): true}})
+	if pruned := pruneRepos(processor, newTrashFromEnv(root), targets); len(pruned) != 0 {
+		t.Errorf("pruned %v above the threshold", pruned)
+	}
+	if stats := processor.GetStats(); len(stats.CloneErrors) != 1 || !strings.Contains(stats.CloneErrors[0], "Not pruning, 2 of 3") {
+		t.Errorf("CloneErrors = %q", stats.CloneErrors)
+	}
+
+	os.Unsetenv("GHORG_PRUNE_MAX_PERCENT")
+	processor = NewRepositoryProcessor(dirtyMockGit{dirty: map[string]bool{filepath.Join(root, "dirty"): true}})
+	pruned := pruneRepos(processor, newTrashFromEnv(root), targets)
+	if len(pruned) != 1 || pruned[0] != "gone" {
+		t.Errorf("pruned = %v, want [gone]", pruned)
+	}
+	if stats := processor.GetStats(); stats.ProtectedCount != 1 {
+		t.Errorf("ProtectedCount = %d, want 1", stats.ProtectedCount)
+	}
+	if !isGitRepository(filepath.Join(root, "dirty")) {
+		t.Error("a clone with local changes was pruned")
+	}
+
+	if code := (&TrashCommand{}).Run([]string{"restore", root}); code != 0 {
+		t.Fatalf("ghorg trash restore exited with %d", code)
+	}
+	if !isGitRepository(filepath.Join(root, "gone")) {
+		t.Error("gone was not restored")
+	}
+	if _, err := os.Stat(filepath.Join(root, TrashDirName)); err != nil {
+		t.Errorf("trash directory: %v", err)
+	}
+	if batches, _ := listTrash(root); len(batches) != 0 {
+		t.Errorf("restored batch was not removed: %+v", batches)
+	}
+}
+
+func TestPruneRepos_KeepsUnpushedBranches(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedDir := outputDirAbsolutePath
+	defer func() { outputDirAbsolutePath = savedDir }()
+	os.Setenv("GHORG_PRUNE_NO_CONFIRM", "true")
+
+	for name, g := range map[string]git.Gitter{"exec": git.NewExecGit(), "golang": git.GoGitClient()} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			outputDirAbsolutePath = root
+			remote := filepath.Join(t.TempDir(), "remote.git")
+			runTestGit(t, root, "init", "--bare", "-b", "main", remote)
+			seed := filepath.Join(t.TempDir(), "seed")
+			runTestGit(t, root, "init", "-b", "main", seed)
+			runTestGit(t, seed, "commit", "--allow-empty", "-m", "first")
+			runTestGit(t, seed, "push", remote, "main")
+
+			// pushed matches the remote, local-branch has a branch that
+			// was never pushed while HEAD is clean and up to date, no-remote
+			// has no remote at all and broken can't be checked.
+			for _, dir := range []string{"pushed", "local-branch"} {
+				runTestGit(t, root, "clone", remote, dir)
+			}
+			runTestGit(t, filepath.Join(root, "local-branch"), "checkout", "-b", "wip")
+			runTestGit(t, filepath.Join(root, "local-branch"), "commit", "--allow-empty", "-m", "local only")
+			runTestGit(t, filepath.Join(root, "local-branch"), "checkout", "main")
+			runTestGit(t, root, "init", "-b", "main", "no-remote")
+			runTestGit(t, filepath.Join(root, "no-remote"), "commit", "--allow-empty", "-m", "local only")
+			if err := os.MkdirAll(filepath.Join(root, "broken", ".git"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			processor := NewRepositoryProcessor(g)
+			pruned := pruneRepos(processor, nil, nil)
+			if len(pruned) != 1 || pruned[0] != "pushed" {
+				t.Errorf("pruned = %v, want [pushed]", pruned)
+			}
+			for _, dir := range []string{"local-branch", "no-remote", "broken"} {
+				if _, err := os.Stat(filepath.Join(root, dir)); err != nil {
+					t.Errorf("%s was pruned: %v", dir, err)
+				}
+			}
+			if stats := processor.GetStats(); stats.ProtectedCount != 3 {
+				t.Errorf("ProtectedCount = %d, want 3", stats.ProtectedCount)
+			}
+		})
+	}
+}
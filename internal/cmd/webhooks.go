@@ -0,0 +1,751 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blairham/ghorg/internal/colorlog"
+	"github.com/blairham/ghorg/internal/configs"
+	"github.com/blairham/ghorg/internal/git"
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+// Actions of webhook events.
+const (
+	WebhookActionPush    = "push"
+	WebhookActionCreated = "created"
+	WebhookActionDeleted = "deleted"
+	WebhookActionRenamed = "renamed"
+)
+
+// maxWebhookBodySize is the largest webhook payload the reclone server
+// reads. GitHub caps payloads at 25MB.
+const maxWebhookBodySize = 25 << 20
+
+// WebhookEvent is a push or repository event of an SCM webhook, reduced to
+// the repo it is about. FullName is the owner/name of the repo, its path
+// with namespace on GitLab and project key/slug on Bitbucket Server.
+type WebhookEvent struct {
+	SCM         string `json:"scm"`
+	Action      string `json:"action"`
+	Host        string `json:"host,omitempty"`
+	FullName    string `json:"full_name"`
+	OldFullName string `json:"old_full_name,omitempty"`
+}
+
+// webhookReceiver queues a job for every verified webhook event.
+type webhookReceiver struct {
+	secret string
+	jobs   *jobQueue
+}
+
+// newWebhookReceiver returns a receiver verifying webhooks with
+// GHORG_RECLONE_SERVER_WEBHOOK_SECRET. Without a secret every webhook is
+// rejected.
+func newWebhookReceiver(jobs *jobQueue) *webhookReceiver {
+	return &webhookReceiver{secret: os.Getenv("GHORG_RECLONE_SERVER_WEBHOOK_SECRET"), jobs: jobs}
+}
+
+// handle verifies the webhook of the SCM named by the scm path value and
+// queues a job for the repo its event is about. It answers 202 with the new
+// job, 200 with the queued job for the same event, and 204 for events that
+// do not change any repo, such as pings.
+func (wh *webhookReceiver) handle(w http.ResponseWriter, r *http.Request) {
+	if wh.secret == "" {
+		http.Error(w, "Webhooks are disabled, set GHORG_RECLONE_SERVER_WEBHOOK_SECRET to enable them", http.StatusNotFound)
+		return
+	}
+	scmType := r.PathValue("scm")
+	if !isWebhookSCM(scmType) {
+		http.Error(w, fmt.Sprintf("Webhooks are not supported for %q", scmType), http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		http.Error(w, "Unable to read webhook: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := verifyWebhook(scmType, r.Header, body, wh.secret); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	event, err := parseWebhookEvent(scmType, r.Header, body)
+	if err != nil {
+		http.Error(w, "Invalid webhook: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if event == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	job, created, err := wh.jobs.EnqueueWebhook(*event)
+	if err != nil {
+		http.Error(w, "Unable to queue job", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	status := http.StatusOK
+	if created {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, job)
+}
+
+func isWebhookSCM(scmType string) bool {
+	switch scmType {
+	case "github", "gitlab", "gitea", "bitbucket":
+		return true
+	}
+	return false
+}
+
+// sameWebhookEvent reports whether a and b, either of which may be nil, are
+// the same event.
+func sameWebhookEvent(a, b *WebhookEvent) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// verifyWebhook checks body was sent by an SCM that knows secret: by the
+// HMAC-SHA256 signature of GitHub, Gitea and Bitbucket, or by the secret
+// token GitLab sends as is.
+func verifyWebhook(scmType string, header http.Header, body []byte, secret string) error {
+	switch scmType {
+	case "github":
+		return verifyWebhookHMAC("X-Hub-Signature-256", header.Get("X-Hub-Signature-256"), "sha256=", body, secret)
+	case "gitea":
+		return verifyWebhookHMAC("X-Gitea-Signature", header.Get("X-Gitea-Signature"), "", body, secret)
+	case "bitbucket":
+		return verifyWebhookHMAC("X-Hub-Signature", header.Get("X-Hub-Signature"), "sha256=", body, secret)
+	case "gitlab":
+		token := header.Get("X-Gitlab-Token")
+		if token == "" {
+			return errors.New("missing X-Gitlab-Token header")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return errors.New("invalid X-Gitlab-Token")
+		}
+		return nil
+	}
+	return fmt.Errorf("webhooks are not supported for %q", scmType)
+}
+
+// verifyWebhookHMAC checks signature, the hex HMAC-SHA256 of body after
+// prefix, sent in the header name.
+func verifyWebhookHMAC(name, signature, prefix string, body []byte, secret string) error {
+	if signature == "" {
+		return fmt.Errorf("missing %s header", name)
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil || !strings.HasPrefix(signature, prefix) {
+		return fmt.Errorf("invalid %s signature", name)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return fmt.Errorf("invalid %s signature", name)
+	}
+	return nil
+}
+
+// parseWebhookEvent returns the event of a webhook payload, nil for events
+// that do not change a repo, such as pings or issue events.
+func parseWebhookEvent(scmType string, header http.Header, body []byte) (*WebhookEvent, error) {
+	var event *WebhookEvent
+	var err error
+	switch scmType {
+	case "github":
+		event, err = parseGithubWebhook(header.Get("X-GitHub-Event"), body)
+	case "gitlab":
+		event, err = parseGitlabWebhook(body)
+	case "gitea":
+		event, err = parseGiteaWebhook(header.Get("X-Gitea-Event"), body)
+	case "bitbucket":
+		event, err = parseBitbucketWebhook(header.Get("X-Event-Key"), body)
+	default:
+		return nil, fmt.Errorf("webhooks are not supported for %q", scmType)
+	}
+	if err != nil || event == nil {
+		return nil, err
+	}
+
+	event.SCM = scmType
+	for _, name := range []string{event.FullName, event.OldFullName} {
+		if name == "" {
+			continue
+		}
+		segments := strings.Split(name, "/")
+		if len(segments) < 2 || slices.ContainsFunc(segments, func(s string) bool { return s == "" || s == "." || s == ".." }) {
+			return nil, fmt.Errorf("invalid repository %q", name)
+		}
+	}
+	if event.FullName == "" {
+		return nil, errors.New("missing repository")
+	}
+	return event, nil
+}
+
+// webhookHost returns the host of the web URL of a repo.
+func webhookHost(webURL string) string {
+	u, err := url.Parse(webURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+type githubWebhook struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		Name     string `json:"name"`
+		HTMLURL  string `json:"html_url"`
+		Owner    struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Changes struct {
+		Repository struct {
+			Name struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"repository"`
+		Owner struct {
+			From struct {
+				Organization struct {
+					Login string `json:"login"`
+				} `json:"organization"`
+				User struct {
+					Login string `json:"login"`
+				} `json:"user"`
+			} `json:"from"`
+		} `json:"owner"`
+	} `json:"changes"`
+}
+
+// parseGithubWebhook parses push and repository events, named by the
+// X-GitHub-Event header. Transferred repos are renamed to their new owner.
+func parseGithubWebhook(eventType string, body []byte) (*WebhookEvent, error) {
+	if eventType != "push" && eventType != "repository" {
+		return nil, nil
+	}
+	var p githubWebhook
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	event := &WebhookEvent{Host: webhookHost(p.Repository.HTMLURL), FullName: p.Repository.FullName}
+	if eventType == "push" {
+		event.Action = WebhookActionPush
+		return event, nil
+	}
+
+	switch p.Action {
+	case "created":
+		event.Action = WebhookActionCreated
+	case "deleted":
+		event.Action = WebhookActionDeleted
+	case "renamed":
+		event.Action = WebhookActionRenamed
+		event.OldFullName = p.Repository.Owner.Login + "/" + p.Changes.Repository.Name.From
+	case "transferred":
+		event.Action = WebhookActionRenamed
+		from := p.Changes.Owner.From.Organization.Login
+		if from == "" {
+			from = p.Changes.Owner.From.User.Login
+		}
+		event.OldFullName = from + "/" + p.Repository.Name
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
+
+type gitlabWebhook struct {
+	ObjectKind string `json:"object_kind"`
+	EventName  string `json:"event_name"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+	PathWithNamespace    string `json:"path_with_namespace"`
+	OldPathWithNamespace string `json:"old_path_with_namespace"`
+}
+
+// parseGitlabWebhook parses push events of project and group webhooks, and
+// the push and project events of system hooks. Transferred projects are
+// renamed to their new namespace.
+func parseGitlabWebhook(body []byte) (*WebhookEvent, error) {
+	var p gitlabWebhook
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	kind := p.ObjectKind
+	if kind == "" {
+		// system hooks name their event in event_name
+		kind = p.EventName
+	}
+
+	switch kind {
+	case "push", "tag_push":
+		return &WebhookEvent{Action: WebhookActionPush, Host: webhookHost(p.Project.WebURL), FullName: p.Project.PathWithNamespace}, nil
+	case "project_create":
+		return &WebhookEvent{Action: WebhookActionCreated, FullName: p.PathWithNamespace}, nil
+	case "project_destroy":
+		return &WebhookEvent{Action: WebhookActionDeleted, FullName: p.PathWithNamespace}, nil
+	case "project_rename", "project_transfer":
+		return &WebhookEvent{Action: WebhookActionRenamed, FullName: p.PathWithNamespace, OldFullName: p.OldPathWithNamespace}, nil
+	}
+	return nil, nil
+}
+
+type giteaWebhook struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// parseGiteaWebhook parses push and repository events, named by the
+// X-Gitea-Event header. Gitea sends no event for renamed repos.
+func parseGiteaWebhook(eventType string, body []byte) (*WebhookEvent, error) {
+	if eventType != "push" && eventType != "repository" {
+		return nil, nil
+	}
+	var p giteaWebhook
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	event := &WebhookEvent{Host: webhookHost(p.Repository.HTMLURL), FullName: p.Repository.FullName}
+	switch {
+	case eventType == "push":
+		event.Action = WebhookActionPush
+	case p.Action == "created":
+		event.Action = WebhookActionCreated
+	case p.Action == "deleted":
+		event.Action = WebhookActionDeleted
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
+
+// bitbucketRepo is the repository of Bitbucket Cloud and Server payloads.
+type bitbucketRepo struct {
+	FullName string `json:"full_name"` // Cloud
+	Slug     string `json:"slug"`      // Server
+	Project  struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+		Self json.RawMessage `json:"self"`
+	} `json:"links"`
+}
+
+// fullName returns workspace/slug on Bitbucket Cloud and project key/slug on
+// Bitbucket Server.
+func (r bitbucketRepo) fullName() string {
+	if r.FullName != "" {
+		return r.FullName
+	}
+	if r.Slug == "" {
+		return ""
+	}
+	return r.Project.Key + "/" + r.Slug
+}
+
+func (r bitbucketRepo) host() string {
+	if r.Links.HTML.Href != "" {
+		return webhookHost(r.Links.HTML.Href)
+	}
+	// Bitbucket Server links self as a list
+	var self []struct {
+		Href string `json:"href"`
+	}
+	if json.Unmarshal(r.Links.Self, &self) == nil && len(self) > 0 {
+		return webhookHost(self[0].Href)
+	}
+	return ""
+}
+
+type bitbucketWebhook struct {
+	Repository bitbucketRepo `json:"repository"`
+	Changes    struct {
+		FullName struct {
+			Old string `json:"old"`
+		} `json:"full_name"`
+	} `json:"changes"`
+	Old *bitbucketRepo `json:"old"`
+	New *bitbucketRepo `json:"new"`
+}
+
+// parseBitbucketWebhook parses push and rename events, named by the
+// X-Event-Key header, of Bitbucket Cloud (repo:push, repo:updated) and
+// Bitbucket Server (repo:refs_changed, repo:modified).
+func parseBitbucketWebhook(eventKey string, body []byte) (*WebhookEvent, error) {
+	if eventKey != "repo:push" && eventKey != "repo:refs_changed" && eventKey != "repo:updated" && eventKey != "repo:modified" {
+		return nil, nil
+	}
+	var p bitbucketWebhook
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	switch eventKey {
+	case "repo:push", "repo:refs_changed":
+		return &WebhookEvent{Action: WebhookActionPush, Host: p.Repository.host(), FullName: p.Repository.fullName()}, nil
+	case "repo:updated":
+		if p.Changes.FullName.Old == "" {
+			return nil, nil
+		}
+		return &WebhookEvent{Action: WebhookActionRenamed, Host: p.Repository.host(), FullName: p.Repository.fullName(), OldFullName: p.Changes.FullName.Old}, nil
+	}
+	// repo:modified
+	if p.Old == nil || p.New == nil || p.Old.fullName() == p.New.fullName() {
+		return nil, nil
+	}
+	return &WebhookEvent{Action: WebhookActionRenamed, Host: p.New.host(), FullName: p.New.fullName(), OldFullName: p.Old.fullName()}, nil
+}
+
+// runWebhookEvent applies event to every reclone.yaml entry that clones its
+// repo and returns the result of each of them, writing their output to out.
+// The caller must hold recloneMu.
+func runWebhookEvent(event WebhookEvent, out io.Writer) ([]RecloneResult, error) {
+	defer colorlog.SetOutput(out)()
+	reclones, err := loadReclones(configs.GhorgReCloneLocation())
+	if err != nil {
+		return nil, err
+	}
+	keys, _ := recloneKeys(reclones, nil)
+
+	var results []RecloneResult
+	for _, key := range keys {
+		if result, ok := runWebhookEntry(reclones[key], key, event, out); ok {
+			results = append(results, result)
+		}
+	}
+	if len(results) == 0 {
+		colorlog.PrintInfo(fmt.Sprintf("No reclone entry clones %s repo %s", event.SCM, event.FullName))
+	}
+	return results, nil
+}
+
+// runWebhookEntry applies event to the clone of the reclone entry rc,
+// in-process like runReClone. It returns false when the entry does not
+// clone the repo of event.
+func runWebhookEntry(rc ReClone, key string, event WebhookEvent, out io.Writer) (RecloneResult, bool) {
+	result := RecloneResult{Key: key, StartedAt: time.Now().UTC()}
+	cloneArgs, cloneEnv, err := rc.cloneCommand()
+	if err != nil {
+		return result, false
+	}
+
+	matched := false
+	exitCode, report := runInProcess(cloneEnv, out, func() int {
+		return (&CloneCommand{}).runWebhookEvent(cloneArgs, event, &matched)
+	})
+	if !matched {
+		return result, false
+	}
+	result.Report = report
+
+	if exitCode != 0 {
+		err = fmt.Errorf("ghorg clone exited with status %d", exitCode)
+		colorlog.PrintError(fmt.Sprintf("ERROR: Applying %s of %s to reclone %s: %v", event.Action, event.FullName, key, err))
+	}
+	return finishReclone(result, err), true
+}
+
+// runWebhookEvent applies event to the clone configured by args, setting
+// matched when that clone includes the repo of event. Unlike Run it only
+// touches that one repo, every other clone is left as it is.
+func (c *CloneCommand) runWebhookEvent(args []string, event WebhookEvent, matched *bool) int {
+	commandStartTime = time.Now()
+
+	remaining, err := c.parseAndApplyFlags(args)
+	if err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	if remaining == nil || !webhookEventMatches(event, remaining[0]) {
+		return 0
+	}
+	*matched = true
+
+	if err := validateConfig(); err != nil {
+		colorlog.PrintError(err)
+		return 1
+	}
+	setCloneTarget(remaining)
+
+	colorlog.PrintInfo(fmt.Sprintf("Applying %s of %s to %s", event.Action, event.FullName, outputDirAbsolutePath))
+	if os.Getenv("GHORG_DRY_RUN") == "true" {
+		colorlog.PrintInfo("Dry run, leaving the clone as it is")
+		return 0
+	}
+	applyWebhookEvent(git.NewGit(), event)
+	return 0
+}
+
+// webhookEventMatches reports whether the clone of target configured in the
+// environment includes the repo of event, before or after a rename.
+func webhookEventMatches(event WebhookEvent, target string) bool {
+	if !strings.EqualFold(os.Getenv("GHORG_SCM_TYPE"), event.SCM) {
+		return false
+	}
+	if host := webhookEntryHost(); event.Host != "" && host != "" && !strings.EqualFold(host, event.Host) {
+		return false
+	}
+	return webhookRepoInTarget(target, event.FullName) || webhookRepoInTarget(target, event.OldFullName)
+}
+
+// webhookEntryHost returns the SCM host of the clone configured in the
+// environment, empty when it is unknown.
+func webhookEntryHost() string {
+	if baseURL := os.Getenv("GHORG_SCM_BASE_URL"); baseURL != "" {
+		return webhookHost(baseURL)
+	}
+	switch os.Getenv("GHORG_SCM_TYPE") {
+	case "github":
+		return "github.com"
+	case "gitlab":
+		return "gitlab.com"
+	case "bitbucket":
+		return "bitbucket.org"
+	}
+	return ""
+}
+
+// webhookRepoInTarget reports whether cloning target includes the repo at
+// fullName. GitLab groups include the projects of their subgroups.
+func webhookRepoInTarget(target, fullName string) bool {
+	if fullName == "" {
+		return false
+	}
+	owner := path.Dir(fullName)
+	if os.Getenv("GHORG_SCM_TYPE") != "gitlab" {
+		return strings.EqualFold(owner, target)
+	}
+	if target == "all-groups" || target == "all-users" {
+		return true
+	}
+	owner, target = strings.ToLower(owner), strings.ToLower(target)
+	return owner == target || strings.HasPrefix(owner, target+"/")
+}
+
+// webhookRepoDir returns where the clone of targetCloneSource keeps the
+// repo at fullName, without name collision suffixes.
+func webhookRepoDir(fullName string) (string, error) {
+	slug := path.Base(fullName)
+	if os.Getenv("GHORG_SCM_TYPE") == "gitlab" && os.Getenv("GHORG_PRESERVE_DIRECTORY_STRUCTURE") == "true" {
+		slug = fullName
+		if targetCloneSource != "all-groups" && targetCloneSource != "all-users" {
+			// GitLab paths are case insensitive, like in webhookRepoInTarget
+			prefix := targetCloneSource + "/"
+			if len(fullName) > len(prefix) && strings.EqualFold(fullName[:len(prefix)], prefix) {
+				slug = fullName[len(prefix):]
+			}
+		}
+	}
+	dir := filepath.Join(outputDirAbsolutePath, filepath.FromSlash(slug))
+	if !strings.HasPrefix(dir, outputDirAbsolutePath+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of %s", fullName, outputDirAbsolutePath)
+	}
+	return dir, nil
+}
+
+// applyWebhookEvent clones or updates, prunes or moves the repo of event in
+// the clone configured in the environment, with the state, push mirror,
+// hooks, reports and search index of a full clone.
+func applyWebhookEvent(g git.Gitter, event WebhookEvent) {
+	processor, reporter, save, err := newRunProcessor(g, getGhorgStateFilePath())
+	if err != nil {
+		colorlog.PrintError(err)
+		colorlog.Exit(1)
+	}
+
+	if err := processor.RunPreRunHook(1); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Not updating %s, %v", event.FullName, err))
+		colorlog.Exit(1)
+	}
+
+	switch {
+	case event.Action == WebhookActionDeleted:
+		pruneWebhookRepo(processor, event.FullName)
+	case event.Action == WebhookActionRenamed && !webhookRepoInTarget(targetCloneSource, event.FullName):
+		// moved out of this clone, e.g. transferred to another org
+		pruneWebhookRepo(processor, event.OldFullName)
+	default:
+		if event.Action == WebhookActionRenamed && webhookRepoInTarget(targetCloneSource, event.OldFullName) {
+			moveWebhookRepo(event.OldFullName, event.FullName)
+		}
+		updateWebhookRepo(processor, event.FullName)
+	}
+
+	processor.SetTotalDuration(int(time.Since(commandStartTime).Seconds() + 0.5))
+	stats := processor.GetStats()
+	cloneInfos = stats.CloneInfos
+	cloneErrors = stats.CloneErrors
+	printRemainingMessages()
+	save()
+
+	report := RunReport{
+		SCM:        strings.ToLower(os.Getenv("GHORG_SCM_TYPE")),
+		Target:     targetCloneSource,
+		OutputDir:  outputDirAbsolutePath,
+		StartedAt:  commandStartTime.UTC(),
+		FinishedAt: time.Now().UTC(),
+		Summary:    stats,
+		API:        scm.APIStats(),
+	}
+	report.Repos = reporter.Outcomes()
+	lastRunReport = &report
+	if err := reporter.Finish(report); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Could not write run report: %v", err))
+	}
+
+	processor.RunPostRunHook()
+	handleExitCodes(len(stats.CloneInfos), len(stats.CloneErrors))
+}
+
+// updateWebhookRepo clones or pulls the repo at fullName, unless the clone
+// filters skip it.
+func updateWebhookRepo(processor *RepositoryProcessor, fullName string) {
+	scmType := strings.ToLower(os.Getenv("GHORG_SCM_TYPE"))
+	client, err := scm.GetClient(scmType)
+	if err != nil {
+		colorlog.PrintError(err)
+		colorlog.Exit(1)
+	}
+	getter, ok := client.(scm.RepoGetter)
+	if !ok {
+		colorlog.PrintErrorAndExit(fmt.Sprintf("Updating a single repo is not supported for %s", scmType))
+	}
+
+	repos, err := getter.GetRepo(targetCloneSource, fullName)
+	if err != nil {
+		processor.addError(fmt.Sprintf("Could not get %s: %v", fullName, err))
+		return
+	}
+	repos = NewRepositoryFilter().ApplyAllFilters(repos)
+	if len(repos) == 0 {
+		colorlog.PrintInfo(fmt.Sprintf("%s is excluded by the filters of this clone", fullName))
+		return
+	}
+
+	if err := createDirIfNotExist(); err != nil {
+		colorlog.PrintError(err)
+		colorlog.Exit(1)
+	}
+	for i := range repos {
+		repo := repos[i]
+		repoSlug := resolveRepoSlug(&repo)
+		if !isPathSegmentSafe(repoSlug) {
+			processor.addError(fmt.Sprintf("Unsafe path segment found in SCM output for %s", fullName))
+			continue
+		}
+		if repo.Path != "" && os.Getenv("GHORG_PRESERVE_DIRECTORY_STRUCTURE") == "true" {
+			repoSlug = repo.Path
+		}
+		processor.reportQueued(repo)
+		processor.ProcessRepository(&repo, nil, false, repoSlug, i)
+	}
+}
+
+// moveWebhookRepo moves the clone of a renamed repo from oldFullName to
+// fullName. The update that follows points its remote at the new URL.
+func moveWebhookRepo(oldFullName, fullName string) {
+	oldDir, err := webhookRepoDir(oldFullName)
+	if err != nil {
+		colorlog.PrintError(err)
+		return
+	}
+	newDir, err := webhookRepoDir(fullName)
+	if err != nil {
+		colorlog.PrintError(err)
+		return
+	}
+	if oldDir == newDir || !isGitRepository(oldDir) {
+		return
+	}
+	if _, err := os.Stat(newDir); err == nil {
+		colorlog.PrintInfo(fmt.Sprintf("Not moving %s to %s, which already exists", oldDir, newDir))
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newDir), 0o755); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Could not move %s: %v", oldDir, err))
+		return
+	}
+	if err := os.Rename(oldDir, newDir); err != nil {
+		colorlog.PrintError(fmt.Sprintf("Could not move %s: %v", oldDir, err))
+		return
+	}
+	colorlog.PrintSubtleInfo(fmt.Sprintf("Moved %s to %s", oldDir, newDir))
+}
+
+// pruneWebhookRepo deletes the clone of the repo at fullName, which was
+// deleted or moved away on the SCM, or moves it into the trash with
+// GHORG_PRUNE_TRASH. Like pruneRepos it only deletes with
+// GHORG_PRUNE, and without a prompt with GHORG_PRUNE_NO_CONFIRM only, and
+// never a clone with local changes or unpushed commits, or one that can't be
+// checked.
+func pruneWebhookRepo(processor *RepositoryProcessor, fullName string) {
+	dir, err := webhookRepoDir(fullName)
+	if err != nil {
+		colorlog.PrintError(err)
+		return
+	}
+	if !isGitRepository(dir) {
+		return
+	}
+	if os.Getenv("GHORG_PRUNE") != "true" || os.Getenv("GHORG_PRUNE_NO_CONFIRM") != "true" {
+		colorlog.PrintInfo(fmt.Sprintf("%s was removed from the remote, set --prune and --prune-no-confirm to prune it", dir))
+		return
+	}
+
+	start := time.Now()
+	repo := scm.Repo{Name: path.Base(fullName), URL: fullName, HostPath: dir}
+	if err := processor.checkPruneSafe(repo); err != nil {
+		colorlog.PrintWarning(fmt.Sprintf("Not pruning %s (%v)", dir, err))
+		processor.addProtected(fmt.Sprintf("%s: %v", dir, err))
+		processor.report(RepoEventProtected, &repo, start, "", err.Error())
+		return
+	}
+
+	trash := newTrashFromEnv(outputDirAbsolutePath)
+	if trash != nil {
+		expireTrashFromEnv(outputDirAbsolutePath)
+		colorlog.PrintSubtleInfo(fmt.Sprintf("Moving %s to %s", dir, TrashDirName))
+	} else {
+		colorlog.PrintSubtleInfo(fmt.Sprintf("Deleting %s", dir))
+	}
+	if err := trash.discard(dir, "removed from the remote"); err != nil {
+		processor.addError(fmt.Sprintf("Could not prune %s: %v", dir, err))
+		processor.report(RepoEventError, &repo, start, "", err.Error())
+		return
+	}
+	processor.report(RepoEventPruned, &repo, start, "", "")
+}
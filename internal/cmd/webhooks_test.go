@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blairham/ghorg/internal/scm"
+)
+
+func signWebhook(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := `{"ref":"refs/heads/main"}`
+	sig := signWebhook("s3cret", body)
+	tests := []struct {
+		name   string
+		scm    string
+		header http.Header
+		ok     bool
+	}{
+		{"github", "github", http.Header{"X-Hub-Signature-256": {"sha256=" + sig}}, true},
+		{"github wrong secret", "github", http.Header{"X-Hub-Signature-256": {"sha256=" + signWebhook("other", body)}}, false},
+		{"github without prefix", "github", http.Header{"X-Hub-Signature-256": {sig}}, false},
+		{"github unsigned", "github", http.Header{}, false},
+		{"gitea", "gitea", http.Header{"X-Gitea-Signature": {sig}}, true},
+		{"gitea not hex", "gitea", http.Header{"X-Gitea-Signature": {"zz"}}, false},
+		{"bitbucket", "bitbucket", http.Header{"X-Hub-Signature": {"sha256=" + sig}}, true},
+		{"gitlab", "gitlab", http.Header{"X-Gitlab-Token": {"s3cret"}}, true},
+		{"gitlab wrong token", "gitlab", http.Header{"X-Gitlab-Token": {"s3cre"}}, false},
+		{"sourcehut", "sourcehut", http.Header{}, false},
+	}
+	for _, tt := range tests {
+		if err := verifyWebhook(tt.scm, tt.header, []byte(body), "s3cret"); (err == nil) != tt.ok {
+			t.Errorf("%s: verifyWebhook = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestParseWebhookEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		scm    string
+		header http.Header
+		body   string
+		want   *WebhookEvent
+	}{
+		{
+			"github push", "github", http.Header{"X-Github-Event": {"push"}},
+			`{"repository": {"full_name": "org/api", "html_url": "https://github.com/org/api"}}`,
+			&WebhookEvent{Action: WebhookActionPush, Host: "github.com", FullName: "org/api"},
+		},
+		{
+			"github renamed", "github", http.Header{"X-Github-Event": {"repository"}},
+			`{"action": "renamed", "repository": {"full_name": "org/web", "name": "web", "owner": {"login": "org"}}, "changes": {"repository": {"name": {"from": "api"}}}}`,
+			&WebhookEvent{Action: WebhookActionRenamed, FullName: "org/web", OldFullName: "org/api"},
+		},
+		{
+			"github transferred", "github", http.Header{"X-Github-Event": {"repository"}},
+			`{"action": "transferred", "repository": {"full_name": "new/api", "name": "api"}, "changes": {"owner": {"from": {"user": {"login": "me"}}}}}`,
+			&WebhookEvent{Action: WebhookActionRenamed, FullName: "new/api", OldFullName: "me/api"},
+		},
+		{
+			"github deleted", "github", http.Header{"X-Github-Event": {"repository"}},
+			`{"action": "deleted", "repository": {"full_name": "org/api"}}`,
+			&WebhookEvent{Action: WebhookActionDeleted, FullName: "org/api"},
+		},
+		{"github archived", "github", http.Header{"X-Github-Event": {"repository"}}, `{"action": "archived", "repository": {"full_name": "org/api"}}`, nil},
+		{"github ping", "github", http.Header{"X-Github-Event": {"ping"}}, `{"zen": "Keep it simple."}`, nil},
+		{
+			"gitlab push", "gitlab", http.Header{"X-Gitlab-Event": {"Push Hook"}},
+			`{"object_kind": "push", "project": {"path_with_namespace": "group/sub/api", "web_url": "https://gitlab.example.com/group/sub/api"}}`,
+			&WebhookEvent{Action: WebhookActionPush, Host: "gitlab.example.com", FullName: "group/sub/api"},
+		},
+		{
+			"gitlab system hook create", "gitlab", http.Header{"X-Gitlab-Event": {"System Hook"}},
+			`{"event_name": "project_create", "path_with_namespace": "group/api"}`,
+			&WebhookEvent{Action: WebhookActionCreated, FullName: "group/api"},
+		},
+		{
+			"gitlab system hook transfer", "gitlab", http.Header{"X-Gitlab-Event": {"System Hook"}},
+			`{"event_name": "project_transfer", "path_with_namespace": "other/api", "old_path_with_namespace": "group/api"}`,
+			&WebhookEvent{Action: WebhookActionRenamed, FullName: "other/api", OldFullName: "group/api"},
+		},
+		{"gitlab merge request", "gitlab", http.Header{}, `{"object_kind": "merge_request"}`, nil},
+		{
+			"gitea created", "gitea", http.Header{"X-Gitea-Event": {"repository"}},
+			`{"action": "created", "repository": {"full_name": "org/api", "html_url": "https://gitea.example.com/org/api"}}`,
+			&WebhookEvent{Action: WebhookActionCreated, Host: "gitea.example.com", FullName: "org/api"},
+		},
+		{
+			"bitbucket cloud push", "bitbucket", http.Header{"X-Event-Key": {"repo:push"}},
+			`{"repository": {"full_name": "ws/api", "links": {"html": {"href": "https://bitbucket.org/ws/api"}}}}`,
+			&WebhookEvent{Action: WebhookActionPush, Host: "bitbucket.org", FullName: "ws/api"},
+		},
+		{
+			"bitbucket server push", "bitbucket", http.Header{"X-Event-Key": {"repo:refs_changed"}},
+			`{"repository": {"slug": "api", "project": {"key": "PROJ"}, "links": {"self": [{"href": "https://bitbucket.example.com/projects/PROJ/repos/api/browse"}]}}}`,
+			&WebhookEvent{Action: WebhookActionPush, Host: "bitbucket.example.com", FullName: "PROJ/api"},
+		},
+		{
+			"bitbucket server renamed", "bitbucket", http.Header{"X-Event-Key": {"repo:modified"}},
+			`{"old": {"slug": "api", "project": {"key": "PROJ"}}, "new": {"slug": "web", "project": {"key": "PROJ"}}}`,
+			&WebhookEvent{Action: WebhookActionRenamed, FullName: "PROJ/web", OldFullName: "PROJ/api"},
+		},
+		{"bitbucket cloud updated description", "bitbucket", http.Header{"X-Event-Key": {"repo:updated"}}, `{"repository": {"full_name": "ws/api"}, "changes": {}}`, nil},
+	}
+	for _, tt := range tests {
+		got, err := parseWebhookEvent(tt.scm, tt.header, []byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if tt.want != nil {
+			tt.want.SCM = tt.scm
+		}
+		if !sameWebhookEvent(got, tt.want) {
+			t.Errorf("%s: event = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, body := range []string{`{"repository": {"full_name": "../api"}}`, `{"repository": {"full_name": "api"}}`, `{"repository": {}}`, `{`} {
+		if _, err := parseWebhookEvent("github", http.Header{"X-Github-Event": {"push"}}, []byte(body)); err == nil {
+			t.Errorf("Expected an error for %s", body)
+		}
+	}
+}
+
+func TestWebhookEventMatches(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	tests := []struct {
+		scm, baseURL, target string
+		event                WebhookEvent
+		want                 bool
+	}{
+		{"github", "", "org", WebhookEvent{SCM: "github", Host: "github.com", FullName: "Org/api"}, true},
+		{"github", "", "org", WebhookEvent{SCM: "github", Host: "ghe.example.com", FullName: "org/api"}, false},
+		{"github", "https://ghe.example.com/api/v3/", "org", WebhookEvent{SCM: "github", Host: "ghe.example.com", FullName: "org/api"}, true},
+		{"github", "", "other", WebhookEvent{SCM: "github", FullName: "org/api"}, false},
+		{"github", "", "new", WebhookEvent{SCM: "github", FullName: "old/api", OldFullName: "new/api"}, true},
+		{"gitlab", "", "org", WebhookEvent{SCM: "github", FullName: "org/api"}, false},
+		{"gitlab", "https://gitlab.example.com", "group", WebhookEvent{SCM: "gitlab", FullName: "group/sub/api"}, true},
+		{"gitlab", "https://gitlab.example.com", "group/sub", WebhookEvent{SCM: "gitlab", FullName: "group/api"}, false},
+		{"gitlab", "https://gitlab.example.com", "group", WebhookEvent{SCM: "gitlab", FullName: "groupie/api"}, false},
+		{"gitlab", "https://gitlab.example.com", "all-groups", WebhookEvent{SCM: "gitlab", FullName: "any/api"}, true},
+		{"bitbucket", "https://bitbucket.example.com", "PROJ", WebhookEvent{SCM: "bitbucket", Host: "bitbucket.example.com", FullName: "PROJ/api"}, true},
+	}
+	for _, tt := range tests {
+		os.Setenv("GHORG_SCM_TYPE", tt.scm)
+		os.Setenv("GHORG_SCM_BASE_URL", tt.baseURL)
+		if got := webhookEventMatches(tt.event, tt.target); got != tt.want {
+			t.Errorf("webhookEventMatches(%+v) for %s %s %q = %v, want %v", tt.event, tt.scm, tt.baseURL, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	q, err := newJobQueue(filepath.Join(t.TempDir(), JobsFileName), (&fakeJobRunner{}).run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wh := &webhookReceiver{jobs: q}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /webhooks/{scm}", wh.handle)
+
+	push := `{"repository": {"full_name": "org/api", "html_url": "https://github.com/org/api"}}`
+	send := func(scm, event, body, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/"+scm, strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+signature)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send("github", "push", push, signWebhook("", push)); rec.Code != http.StatusNotFound {
+		t.Errorf("webhook without a secret = %d, want 404", rec.Code)
+	}
+
+	wh.secret = "s3cret"
+	if rec := send("sourcehut", "push", push, signWebhook("s3cret", push)); rec.Code != http.StatusNotFound {
+		t.Errorf("webhook of an unsupported SCM = %d, want 404", rec.Code)
+	}
+	if rec := send("github", "push", push, signWebhook("wrong", push)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("webhook with a bad signature = %d, want 401", rec.Code)
+	}
+	if rec := send("github", "ping", `{}`, signWebhook("s3cret", `{}`)); rec.Code != http.StatusNoContent {
+		t.Errorf("ping = %d, want 204", rec.Code)
+	}
+
+	rec := send("github", "push", push, signWebhook("s3cret", push))
+	var job Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil || rec.Code != http.StatusAccepted {
+		t.Fatalf("push = %d %s", rec.Code, rec.Body.String())
+	}
+	want := WebhookEvent{SCM: "github", Action: WebhookActionPush, Host: "github.com", FullName: "org/api"}
+	if job.Webhook == nil || *job.Webhook != want || job.Keys != nil || rec.Header().Get("Location") != "/jobs/"+job.ID {
+		t.Errorf("job = %+v, want a job for %+v", job, want)
+	}
+	if rec := send("github", "push", push, signWebhook("s3cret", push)); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), job.ID) {
+		t.Errorf("duplicate push = %d %s, want 200 with the queued job", rec.Code, rec.Body.String())
+	}
+
+	// A webhook job never deduplicates with a job for every entry.
+	if _, created, _ := q.Enqueue(nil); !created {
+		t.Error("Expected a new job for every entry")
+	}
+}
+
+// dirtyMockGit reports local changes for the clones in dirty and fails to
+// check the clones in broken.
+type dirtyMockGit struct {
+	MockGitClient
+	dirty  map[string]bool
+	broken map[string]bool
+}
+
+func (g dirtyMockGit) ShortStatus(repo scm.Repo) (string, error) {
+	if g.broken[repo.HostPath] {
+		return "", errors.New("not a git repository")
+	}
+	if g.dirty[repo.HostPath] {
+		return " M README.md", nil
+	}
+	return "", nil
+}
+
+func TestPruneAndMoveWebhookRepo(t *testing.T) {
+	defer UnsetEnv("GHORG_")()
+	savedDir, savedTarget := outputDirAbsolutePath, targetCloneSource
+	defer func() { outputDirAbsolutePath, targetCloneSource = savedDir, savedTarget }()
+	outputDirAbsolutePath = t.TempDir()
+	targetCloneSource = "org"
+
+	clone := func(name string) string {
+		dir := filepath.Join(outputDirAbsolutePath, name)
+		if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	exists := func(dir string) bool {
+		_, err := os.Stat(dir)
+		return err == nil
+	}
+	api, dirty := clone("api"), clone("dirty")
+	reporter := &runReporter{}
+	processor := NewRepositoryProcessor(dirtyMockGit{dirty: map[string]bool{dirty: true}})
+	processor.SetReporter(reporter)
+
+	pruneWebhookRepo(processor, "org/api")
+	if !exists(api) {
+		t.Error("Pruned without GHORG_PRUNE")
+	}
+
+	os.Setenv("GHORG_PRUNE", "true")
+	os.Setenv("GHORG_PRUNE_NO_CONFIRM", "true")
+	pruneWebhookRepo(processor, "org/dirty")
+	pruneWebhookRepo(processor, "org/api")
+	pruneWebhookRepo(processor, "org/..")
+	if exists(api) || !exists(dirty) || !exists(outputDirAbsolutePath) {
+		t.Errorf("api kept %v, dirty kept %v; want only the clone with local changes kept", exists(api), exists(dirty))
+	}
+	outcomes := reporter.Outcomes()
+	if len(outcomes) != 2 || outcomes[0].Event != RepoEventProtected || outcomes[1].Event != RepoEventPruned {
+		t.Errorf("outcomes = %+v, want protected then pruned", outcomes)
+	}
+
+	web := clone("web")
+	moveWebhookRepo("org/web", "org/site")
+	if exists(web) || !exists(filepath.Join(outputDirAbsolutePath, "site", ".git")) {
+		t.Error("Expected web to be moved to site")
+	}
+	moveWebhookRepo("org/dirty", "org/site")
+	if !exists(dirty) {
+		t.Error("Moved onto an existing clone")
+	}
+
+	// GitLab keeps subgroups with GHORG_PRESERVE_DIRECTORY_STRUCTURE.
+	os.Setenv("GHORG_SCM_TYPE", "gitlab")
+	os.Setenv("GHORG_PRESERVE_DIRECTORY_STRUCTURE", "true")
+	if dir, _ := webhookRepoDir("org/sub/api"); dir != filepath.Join(outputDirAbsolutePath, "sub", "api") {
+		t.Errorf("webhookRepoDir = %s", dir)
+	}
+	if dir, _ := webhookRepoDir("Org/sub/app"); dir != filepath.Join(outputDirAbsolutePath, "sub", "app") {
+		t.Errorf("webhookRepoDir with a differently cased group = %s", dir)
+	}
+	if dir, _ := webhookRepoDir("orgfoo/app"); dir != filepath.Join(outputDirAbsolutePath, "orgfoo", "app") {
+		t.Errorf("webhookRepoDir of a group sharing the target's prefix = %s", dir)
+	}
+	if _, err := webhookRepoDir("org/../../api"); err == nil {
+		t.Error("Expected an error for a path outside of the clone")
+	}
+}
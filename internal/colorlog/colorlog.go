@@ -2,9 +2,14 @@
 package colorlog
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
@@ -15,10 +20,48 @@ var (
 	loggerOnce sync.Once
 )
 
+var (
+	outputMu sync.RWMutex
+	output   io.Writer
+)
+
+// Output returns the writer the Print functions write to, the current
+// os.Stdout unless SetOutput changed it.
+func Output() io.Writer {
+	outputMu.RLock()
+	defer outputMu.RUnlock()
+	if output == nil {
+		return os.Stdout
+	}
+	return output
+}
+
+// SetOutput makes the Print functions write to w until the returned func is
+// called, which restores the writer used before. The process's stdout and
+// stderr are left alone.
+func SetOutput(w io.Writer) func() {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	previous := output
+	output = w
+	return func() {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		output = previous
+	}
+}
+
+// outputWriter writes to Output, so the logger follows SetOutput.
+type outputWriter struct{}
+
+func (outputWriter) Write(p []byte) (int, error) {
+	return Output().Write(p)
+}
+
 // getLogger returns the configured logger instance (singleton)
 func getLogger() *log.Logger {
 	loggerOnce.Do(func() {
-		logger = log.NewWithOptions(os.Stdout, log.Options{
+		logger = log.NewWithOptions(outputWriter{}, log.Options{
 			ReportTimestamp: false,
 			ReportCaller:    false,
 		})
@@ -53,9 +96,9 @@ func PrintInfo(msg any) {
 
 	if os.Getenv("GHORG_COLOR") == "enabled" {
 		style := lipgloss.NewStyle().Foreground(lipgloss.Color("11")) // Yellow
-		fmt.Println(style.Render(formatMsg(msg)))
+		fmt.Fprintln(Output(), style.Render(formatMsg(msg)))
 	} else {
-		fmt.Println(formatMsg(msg))
+		fmt.Fprintln(Output(), formatMsg(msg))
 	}
 }
 
@@ -63,9 +106,9 @@ func PrintInfo(msg any) {
 func PrintSuccess(msg any) {
 	if os.Getenv("GHORG_COLOR") == "enabled" {
 		style := lipgloss.NewStyle().Foreground(lipgloss.Color("10")) // Green
-		fmt.Println(style.Render(formatMsg(msg)))
+		fmt.Fprintln(Output(), style.Render(formatMsg(msg)))
 	} else {
-		fmt.Println(formatMsg(msg))
+		fmt.Fprintln(Output(), formatMsg(msg))
 	}
 }
 
@@ -73,16 +116,85 @@ func PrintSuccess(msg any) {
 func PrintError(msg any) {
 	if os.Getenv("GHORG_COLOR") == "enabled" {
 		style := lipgloss.NewStyle().Foreground(lipgloss.Color("9")) // Red
-		fmt.Println(style.Render(formatMsg(msg)))
+		fmt.Fprintln(Output(), style.Render(formatMsg(msg)))
 	} else {
-		fmt.Println(formatMsg(msg))
+		fmt.Fprintln(Output(), formatMsg(msg))
 	}
 }
 
 // PrintErrorAndExit prints red colored text to standard out then exits 1
 func PrintErrorAndExit(msg any) {
 	PrintError(msg)
-	os.Exit(1)
+	Exit(1)
+}
+
+// ExitCode is the panic value of Exit while CatchExit is running.
+type ExitCode int
+
+var (
+	catchingExit atomic.Int32
+	// catchingGoroutines counts the CatchExit calls running on each goroutine.
+	catchingGoroutines sync.Map
+)
+
+// Exit ends the process with code, unless it is called on a goroutine running
+// a command in-process under CatchExit, in which case only that command is
+// stopped. Exit on any other goroutine still ends the process, a panic there
+// could not be recovered.
+func Exit(code int) {
+	if catching() {
+		panic(ExitCode(code))
+	}
+	os.Exit(code)
+}
+
+// catching reports whether the calling goroutine is running under CatchExit.
+func catching() bool {
+	if catchingExit.Load() == 0 {
+		return false
+	}
+	_, ok := catchingGoroutines.Load(goroutineID())
+	return ok
+}
+
+// CatchExit runs fn and returns its exit code. A call to Exit made by fn on
+// the calling goroutine returns its code instead of ending the process.
+func CatchExit(fn func() int) (code int) {
+	id := goroutineID()
+	depth, _ := catchingGoroutines.LoadOrStore(id, 0)
+	catchingGoroutines.Store(id, depth.(int)+1)
+	catchingExit.Add(1)
+	defer func() {
+		catchingExit.Add(-1)
+		if depth.(int) == 0 {
+			catchingGoroutines.Delete(id)
+		} else {
+			catchingGoroutines.Store(id, depth)
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			exitCode, ok := r.(ExitCode)
+			if !ok {
+				panic(r)
+			}
+			code = int(exitCode)
+		}
+	}()
+	return fn()
+}
+
+// goroutineID returns the id of the calling goroutine, read from the header of
+// its stack trace ("goroutine 18 [running]:").
+func goroutineID() uint64 {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+	header = bytes.TrimPrefix(header, []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i >= 0 {
+		header = header[:i]
+	}
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+	return id
 }
 
 // PrintWarning prints yellow colored text to standard out.
@@ -90,9 +202,9 @@ func PrintErrorAndExit(msg any) {
 func PrintWarning(msg any) {
 	if os.Getenv("GHORG_COLOR") == "enabled" {
 		style := lipgloss.NewStyle().Foreground(lipgloss.Color("11")) // Yellow
-		fmt.Println(style.Render(formatMsg(msg)))
+		fmt.Fprintln(Output(), style.Render(formatMsg(msg)))
 	} else {
-		fmt.Println(formatMsg(msg))
+		fmt.Fprintln(Output(), formatMsg(msg))
 	}
 }
 
@@ -104,9 +216,9 @@ func PrintSubtleInfo(msg any) {
 
 	if os.Getenv("GHORG_COLOR") == "enabled" {
 		style := lipgloss.NewStyle().Foreground(lipgloss.Color("13")) // Magenta
-		fmt.Println(style.Render(formatMsg(msg)))
+		fmt.Fprintln(Output(), style.Render(formatMsg(msg)))
 	} else {
-		fmt.Println(formatMsg(msg))
+		fmt.Fprintln(Output(), formatMsg(msg))
 	}
 }
 
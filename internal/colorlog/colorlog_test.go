@@ -143,3 +143,81 @@ func TestPrintError_WithError(t *testing.T) {
 		t.Errorf("expected output to contain 'test error value', got %q", output)
 	}
 }
+
+func TestCatchExitOnlyCatchesItsGoroutine(t *testing.T) {
+	var inside, other bool
+	CatchExit(func() int {
+		inside = catching()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			other = catching()
+		}()
+		<-done
+		CatchExit(func() int { return 0 })
+		if !catching() {
+			t.Error("a nested CatchExit should leave the outer one catching")
+		}
+		return 0
+	})
+	if !inside {
+		t.Error("Exit should be caught on the goroutine running CatchExit")
+	}
+	if other {
+		t.Error("Exit on another goroutine should end the process, not panic")
+	}
+	if catching() {
+		t.Error("CatchExit should stop catching when it returns")
+	}
+}
+
+func TestCatchExit(t *testing.T) {
+	code := CatchExit(func() int {
+		Exit(3)
+		return 0
+	})
+	if code != 3 {
+		t.Errorf("CatchExit = %d, want 3", code)
+	}
+
+	code = CatchExit(func() int {
+		PrintErrorAndExit("boom")
+		return 0
+	})
+	if code != 1 {
+		t.Errorf("CatchExit after PrintErrorAndExit = %d, want 1", code)
+	}
+
+	if code := CatchExit(func() int { return 2 }); code != 2 {
+		t.Errorf("CatchExit = %d, want the returned 2", code)
+	}
+	if catchingExit.Load() != 0 {
+		t.Error("CatchExit should stop catching when it returns")
+	}
+
+	defer func() {
+		if r := recover(); r != "other" {
+			t.Errorf("recovered %v, want other panics to propagate", r)
+		}
+	}()
+	CatchExit(func() int { panic("other") })
+}
+
+func TestSetOutput(t *testing.T) {
+	var buf bytes.Buffer
+	restore := SetOutput(&buf)
+	stdout := captureStdout(t, func() {
+		PrintSuccess("to the writer")
+	})
+	restore()
+
+	if buf.String() != "to the writer\n" {
+		t.Errorf("writer got %q", buf.String())
+	}
+	if stdout != "" {
+		t.Errorf("stdout got %q, want nothing while the output is set", stdout)
+	}
+	if Output() != os.Stdout {
+		t.Error("restore should make stdout the output again")
+	}
+}
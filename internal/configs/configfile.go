@@ -141,6 +141,73 @@ func ListConfigValues(path string) (map[string]string, error) {
 	return result, nil
 }
 
+// ProfilesSection is the top-level config section holding the named
+// profiles, each a full set of config keys.
+const ProfilesSection = "profiles"
+
+// ProfileKey returns the config key of dotKey in profile, or the key of the
+// profile itself when dotKey is empty.
+func ProfileKey(profile, dotKey string) string {
+	if dotKey == "" {
+		return ProfilesSection + "." + profile
+	}
+	return ProfilesSection + "." + profile + "." + dotKey
+}
+
+// ValidateProfileName returns an error if name can't be used as a profile.
+func ValidateProfileName(name string) error {
+	if name == "" || strings.ContainsAny(name, ". \t") {
+		return fmt.Errorf("invalid profile name %q, profile names can't be empty or contain dots or spaces", name)
+	}
+	return nil
+}
+
+// ListProfiles returns the sorted names of the profiles in a config file.
+func ListProfiles(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	profiles, _ := raw[ProfilesSection].(map[string]any)
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ProfileValues returns the values of profile in values, as read by
+// ListConfigValues, with the profile prefix removed. Without a profile it
+// returns the values outside of all profiles.
+func ProfileValues(values map[string]string, profile string) map[string]string {
+	result := make(map[string]string)
+	if profile == "" {
+		for k, v := range values {
+			if !strings.HasPrefix(k, ProfilesSection+".") {
+				result[k] = v
+			}
+		}
+		return result
+	}
+	prefix := ProfileKey(profile, "") + "."
+	for k, v := range values {
+		if rest, ok := strings.CutPrefix(k, prefix); ok {
+			if strings.HasPrefix(rest, "GHORG_") {
+				if dot := EnvVarToDot(rest); dot != "" {
+					rest = dot
+				}
+			}
+			result[rest] = v
+		}
+	}
+	return result
+}
+
 // setNestedValue sets a value in a nested map structure.
 func setNestedValue(m map[string]any, parts []string, value string) {
 	if len(parts) == 1 {
@@ -203,7 +270,8 @@ func writeYAML(path string, data map[string]any) error {
 }
 
 // FormatConfigList formats config key-value pairs for display, sorted by key.
-// Secret values are redacted unless showSecrets is true.
+// Secret values are redacted unless showSecrets is true. Secret references
+// are shown as they are, since they don't contain the secret.
 func FormatConfigList(values map[string]string, showSecrets bool) string {
 	keys := make([]string, 0, len(values))
 	for k := range values {
@@ -216,7 +284,7 @@ func FormatConfigList(values map[string]string, showSecrets bool) string {
 		v := values[k]
 		if !showSecrets {
 			ck := LookupByDot(k)
-			if ck != nil && ck.IsSecret && v != "" {
+			if ck != nil && ck.IsSecret && v != "" && !IsSecretRef(v) {
 				v = "********"
 			}
 		}
@@ -190,12 +190,16 @@ func TestFormatConfigListRedactsSecrets(t *testing.T) {
 	values := map[string]string{
 		"scm.type":     "github",
 		"github.token": "secret123",
+		"gitlab.token": "exec:pass show gitlab",
 	}
 
 	output := FormatConfigList(values, false)
 	if !contains(output, "github.token=********") {
 		t.Errorf("expected token to be redacted, got:\n%s", output)
 	}
+	if !contains(output, "gitlab.token=exec:pass show gitlab") {
+		t.Errorf("expected the secret reference to be shown, got:\n%s", output)
+	}
 	if !contains(output, "scm.type=github") {
 		t.Errorf("expected scm.type=github in output, got:\n%s", output)
 	}
@@ -250,3 +254,51 @@ func searchString(s, substr string) bool {
 	}
 	return false
 }
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conf.yaml")
+
+	content := []byte("scm:\n  type: github\nprofiles:\n  work:\n    scm:\n      type: gitlab\n    GHORG_GITLAB_TOKEN: secret\n  home:\n    clone:\n      protocol: ssh\n")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	names, err := ListProfiles(path)
+	if err != nil {
+		t.Fatalf("ListProfiles failed: %v", err)
+	}
+	if len(names) != 2 || names[0] != "home" || names[1] != "work" {
+		t.Errorf("expected [home work], got %v", names)
+	}
+
+	values, err := ListConfigValues(path)
+	if err != nil {
+		t.Fatalf("ListConfigValues failed: %v", err)
+	}
+	base := ProfileValues(values, "")
+	if len(base) != 1 || base["scm.type"] != "github" {
+		t.Errorf("expected only scm.type=github outside of profiles, got %v", base)
+	}
+	work := ProfileValues(values, "work")
+	if len(work) != 2 || work["scm.type"] != "gitlab" || work["gitlab.token"] != "secret" {
+		t.Errorf("unexpected work profile %v", work)
+	}
+	if output := FormatConfigList(work, false); !contains(output, "gitlab.token=********") {
+		t.Errorf("expected the profile token to be redacted, got:\n%s", output)
+	}
+
+	if err := WriteConfigValue(path, ProfileKey("home", "scm.type"), "gitea"); err != nil {
+		t.Fatalf("WriteConfigValue failed: %v", err)
+	}
+	if val, found, _ := ReadConfigValue(path, "profiles.home.scm.type"); !found || val != "gitea" {
+		t.Errorf("expected profiles.home.scm.type=gitea, got %q", val)
+	}
+
+	for _, name := range []string{"", "a.b", "a b"} {
+		if ValidateProfileName(name) == nil {
+			t.Errorf("expected %q to be an invalid profile name", name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package configs
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -58,6 +59,9 @@ var (
 	// ErrIncorrectProtocolType indicates an unsupported protocol type being used
 	ErrIncorrectProtocolType = errors.New("GHORG_CLONE_PROTOCOL or --protocol must be one of https or ssh")
 
+	// ErrIncorrectOutputFormat indicates an unsupported machine-readable output format
+	ErrIncorrectOutputFormat = errors.New("GHORG_OUTPUT or --output must be one of json or ndjson")
+
 	// ErrIncorrectGithubUserOptionValue indicates an incorrectly set GHORG_GITHUB_USER_OPTION value
 	ErrIncorrectGithubUserOptionValue = errors.New("GHORG_GITHUB_USER_OPTION or --github-user-option must be one of 'owner', 'member', or 'all' and is only available to be used when GHORG_CLONE_TYPE: user or --clone-type=user is set")
 )
@@ -77,7 +81,8 @@ func GetRequiredString(key string) string {
 		}
 	}
 
-	log.Fatalf("Fatal: '%s' ENV VAR is required", key)
+	log.Printf("Fatal: '%s' ENV VAR is required", key)
+	colorlog.Exit(1)
 	return ""
 }
 
@@ -184,7 +189,8 @@ func DefaultConfFile() string {
 func HomeDir() string {
 	home, err := homedir.Dir()
 	if err != nil {
-		log.Fatal("Error trying to find users home directory")
+		log.Print("Error trying to find users home directory")
+		colorlog.Exit(1)
 	}
 
 	return home
@@ -197,7 +203,7 @@ func GhorgQuiet() bool {
 func IsFilePath(path string) bool {
 	pathValue, err := homedir.Expand(path)
 	if err != nil {
-		log.Fatal("Error while expanding tilde to user home directory")
+		return false
 	}
 	info, err := os.Stat(pathValue)
 	if err != nil {
@@ -210,11 +216,12 @@ func IsFilePath(path string) bool {
 	return false
 }
 
-func GetTokenFromFile(path string) string {
+// GetTokenFromFile reads the token in the file at path.
+func GetTokenFromFile(path string) (string, error) {
 	expandedPath, _ := homedir.Expand(path)
 	fileContents, err := os.ReadFile(expandedPath)
 	if err != nil {
-		log.Fatal("Error while reading file")
+		return "", fmt.Errorf("error while reading token file: %w", err)
 	}
 
 	// Convert to string and remove all whitespace and control characters
@@ -235,30 +242,50 @@ func GetTokenFromFile(path string) string {
 		}
 	}
 
-	return cleaned.String()
+	return cleaned.String(), nil
 }
 
-// GetOrSetToken will set token based on scm
-func GetOrSetToken() {
+// GetOrSetToken will set token based on scm, after replacing the secret
+// references of every secret key with the secrets they refer to
+func GetOrSetToken() error {
+	if err := ResolveSecretRefs(); err != nil {
+		return err
+	}
+
 	switch os.Getenv("GHORG_SCM_TYPE") {
 	case "github":
-		getOrSetGitHubToken()
+		return getOrSetGitHubToken()
 	case "gitlab":
-		getOrSetGitLabToken()
+		return getOrSetGitLabToken()
 	case "bitbucket":
-		getOrSetBitBucketToken()
+		return getOrSetBitBucketToken()
 	case "gitea":
-		getOrSetGiteaToken()
+		return getOrSetGiteaToken()
 	case "sourcehut":
-		getOrSetSourcehutToken()
+		return getOrSetSourcehutToken()
+	}
+	return nil
+}
+
+// setTokenFromFile replaces the path in envVar with the token in the file
+// it points at. Other values are left alone.
+func setTokenFromFile(envVar string) error {
+	token := os.Getenv(envVar)
+	if !IsFilePath(token) {
+		return nil
+	}
+	token, err := GetTokenFromFile(token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", envVar, err)
 	}
+	os.Setenv(envVar, token)
+	return nil
 }
 
-func getOrSetGitHubToken() {
+func getOrSetGitHubToken() error {
 	token := os.Getenv("GHORG_GITHUB_TOKEN")
 	if IsFilePath(token) {
-		os.Setenv("GHORG_GITHUB_TOKEN", GetTokenFromFile(token))
-		return
+		return setTokenFromFile("GHORG_GITHUB_TOKEN")
 	}
 
 	if isZero(token) {
@@ -267,7 +294,7 @@ func getOrSetGitHubToken() {
 			token = strings.TrimSpace(string(out))
 			if token != "" {
 				os.Setenv("GHORG_GITHUB_TOKEN", token)
-				return
+				return nil
 			}
 		}
 
@@ -279,18 +306,19 @@ func getOrSetGitHubToken() {
 			os.Setenv("GHORG_GITHUB_TOKEN", token)
 		}
 	}
+	return nil
 }
 
-func getOrSetGitLabToken() {
+func getOrSetGitLabToken() error {
 	token := os.Getenv("GHORG_GITLAB_TOKEN")
 
-	if IsFilePath(token) {
-		os.Setenv("GHORG_GITLAB_TOKEN", GetTokenFromFile(token))
+	if err := setTokenFromFile("GHORG_GITLAB_TOKEN"); err != nil {
+		return err
 	}
 
 	if isZero(token) {
 		if runtime.GOOS == "windows" {
-			return
+			return nil
 		}
 		cmd := `security find-internet-password -s gitlab.com | grep "acct" | awk -F\" '{ print $4 }'`
 		out, _ := exec.Command("bash", "-c", cmd).Output()
@@ -299,22 +327,19 @@ func getOrSetGitLabToken() {
 
 		os.Setenv("GHORG_GITLAB_TOKEN", token)
 	}
+	return nil
 }
 
-func getOrSetBitBucketToken() {
+func getOrSetBitBucketToken() error {
 	// If API token is already set, use it
 	if !isZero(os.Getenv("GHORG_BITBUCKET_API_TOKEN")) {
-		token := os.Getenv("GHORG_BITBUCKET_API_TOKEN")
-		if IsFilePath(token) {
-			os.Setenv("GHORG_BITBUCKET_API_TOKEN", GetTokenFromFile(token))
-		}
-		return
+		return setTokenFromFile("GHORG_BITBUCKET_API_TOKEN")
 	}
 
 	var token string
 	if isZero(os.Getenv("GHORG_BITBUCKET_APP_PASSWORD")) && isZero(os.Getenv("GHORG_BITBUCKET_OAUTH_TOKEN")) {
 		if runtime.GOOS == "windows" {
-			return
+			return nil
 		}
 		cmd := `security find-internet-password -s bitbucket.com | grep "acct" | awk -F\" '{ print $4 }'`
 		out, _ := exec.Command("bash", "-c", cmd).Output()
@@ -327,36 +352,39 @@ func getOrSetBitBucketToken() {
 			os.Setenv("GHORG_BITBUCKET_OAUTH_TOKEN", token)
 		}
 	}
+	return nil
 }
 
-func getOrSetGiteaToken() {
+func getOrSetGiteaToken() error {
 	token := os.Getenv("GHORG_GITEA_TOKEN")
 
-	if IsFilePath(token) {
-		os.Setenv("GHORG_GITEA_TOKEN", GetTokenFromFile(token))
+	if err := setTokenFromFile("GHORG_GITEA_TOKEN"); err != nil {
+		return err
 	}
 
 	if isZero(token) {
 		if runtime.GOOS == "windows" {
-			return
+			return nil
 		}
 		os.Setenv("GHORG_GITEA_TOKEN", token)
 	}
+	return nil
 }
 
-func getOrSetSourcehutToken() {
+func getOrSetSourcehutToken() error {
 	token := os.Getenv("GHORG_SOURCEHUT_TOKEN")
 
-	if IsFilePath(token) {
-		os.Setenv("GHORG_SOURCEHUT_TOKEN", GetTokenFromFile(token))
+	if err := setTokenFromFile("GHORG_SOURCEHUT_TOKEN"); err != nil {
+		return err
 	}
 
 	if isZero(token) {
 		if runtime.GOOS == "windows" {
-			return
+			return nil
 		}
 		os.Setenv("GHORG_SOURCEHUT_TOKEN", token)
 	}
+	return nil
 }
 
 // VerifyTokenSet checks to make sure env is set for the correct scm provider
@@ -450,5 +478,9 @@ func VerifyConfigsSetCorrectly() error {
 		return ErrIncorrectProtocolType
 	}
 
+	if output := os.Getenv("GHORG_OUTPUT"); output != "" && output != "json" && output != "ndjson" {
+		return ErrIncorrectOutputFormat
+	}
+
 	return nil
 }
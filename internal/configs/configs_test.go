@@ -86,6 +86,20 @@ func TestVerifyConfigsSetCorrectly(t *testing.T) {
 			tt.Errorf("Expected ErrIncorrectProtocolType, got: %v", err)
 		}
 	})
+
+	t.Run("When unsupported output format", func(tt *testing.T) {
+		os.Setenv("GHORG_SCM_TYPE", "github")
+		os.Setenv("GHORG_CLONE_TYPE", "org")
+		os.Setenv("GHORG_CLONE_PROTOCOL", "https")
+
+		os.Setenv("GHORG_OUTPUT", "xml")
+		defer os.Unsetenv("GHORG_OUTPUT")
+
+		err := configs.VerifyConfigsSetCorrectly()
+		if err != configs.ErrIncorrectOutputFormat {
+			tt.Errorf("Expected ErrIncorrectOutputFormat, got: %v", err)
+		}
+	})
 }
 
 func TestTrailingSlashes(t *testing.T) {
@@ -80,7 +80,10 @@ func TestGetTokenFromFile(t *testing.T) {
 			}
 
 			// Test GetTokenFromFile
-			result := GetTokenFromFile(testFile)
+			result, err := GetTokenFromFile(testFile)
+			if err != nil {
+				t.Fatalf("GetTokenFromFile() error: %v", err)
+			}
 			if result != tt.expected {
 				t.Errorf("GetTokenFromFile() = %q, expected %q\nDescription: %s", result, tt.expected, tt.description)
 			}
@@ -96,8 +99,8 @@ func TestGetTokenFromFile(t *testing.T) {
 }
 
 func TestGetTokenFromFileNonExistent(t *testing.T) {
-	// This test verifies that the function handles non-existent files properly
-	// Note: The current implementation calls log.Fatal, so we can't easily test this
-	// without changing the implementation. This is a design consideration for future improvement.
-	t.Skip("Skipping test for non-existent file as current implementation calls log.Fatal")
+	_, err := GetTokenFromFile(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Error("GetTokenFromFile() expected an error for a missing file")
+	}
 }
@@ -126,6 +126,12 @@ var AllKeys = []ConfigKey{
 		DefaultValue: "org",
 		Description:  "Clone target type (org or user)",
 	},
+	{
+		DotNotation:  "clone.targets-file",
+		EnvVar:       "GHORG_TARGETS_FILE",
+		DefaultValue: "",
+		Description:  "Path to file listing orgs or users to clone in one run",
+	},
 	{
 		DotNotation:  "clone.branch",
 		EnvVar:       "GHORG_BRANCH",
@@ -207,6 +213,37 @@ var AllKeys = []ConfigKey{
 		IsBool:       true,
 		Description:  "Skip repos with uncommitted changes or unpushed commits",
 	},
+	{
+		DotNotation:  "clone.output",
+		EnvVar:       "GHORG_OUTPUT",
+		DefaultValue: "",
+		Description:  "Machine-readable run report on stdout: json or ndjson",
+	},
+	{
+		DotNotation:  "clone.output-file",
+		EnvVar:       "GHORG_OUTPUT_FILE",
+		DefaultValue: "",
+		Description:  "Write the run report to this file instead of stdout",
+	},
+	{
+		DotNotation:  "clone.junit-report",
+		EnvVar:       "GHORG_JUNIT_REPORT",
+		DefaultValue: "",
+		Description:  "Write a JUnit XML report with one test case per repo to this path",
+	},
+	{
+		DotNotation:  "clone.search-index",
+		EnvVar:       "GHORG_SEARCH_INDEX",
+		DefaultValue: "false",
+		IsBool:       true,
+		Description:  "Maintain a trigram search index for ghorg search",
+	},
+	{
+		DotNotation:  "clone.search-index-max-file-size-kb",
+		EnvVar:       "GHORG_SEARCH_INDEX_MAX_FILE_SIZE_KB",
+		DefaultValue: "1024",
+		Description:  "Size in KB of the largest file the search index covers, 0 indexes every file",
+	},
 
 	// ── Auth ─────────────────────────────────────────────────────────────
 	{
@@ -324,6 +361,25 @@ var AllKeys = []ConfigKey{
 		IsBool:       true,
 		Description:  "Skip confirmation when pruning untouched repos",
 	},
+	{
+		DotNotation:  "prune.trash",
+		EnvVar:       "GHORG_PRUNE_TRASH",
+		DefaultValue: "false",
+		IsBool:       true,
+		Description:  "Move pruned repos into .ghorg-trash instead of deleting them",
+	},
+	{
+		DotNotation:  "prune.trash-retention-days",
+		EnvVar:       "GHORG_PRUNE_TRASH_RETENTION_DAYS",
+		DefaultValue: "30",
+		Description:  "Days to keep pruned repos in .ghorg-trash, 0 to keep them",
+	},
+	{
+		DotNotation:  "prune.max-percent",
+		EnvVar:       "GHORG_PRUNE_MAX_PERCENT",
+		DefaultValue: "0",
+		Description:  "Prune nothing when more than this % of clones would be pruned, 0 to disable",
+	},
 
 	// ── Fetch ────────────────────────────────────────────────────────────
 	{
@@ -363,6 +419,19 @@ var AllKeys = []ConfigKey{
 		IsBool:       true,
 		Description:  "Generate a stats CSV file after cloning",
 	},
+	{
+		DotNotation:  "stats.history",
+		EnvVar:       "GHORG_STATS_HISTORY",
+		DefaultValue: "false",
+		IsBool:       true,
+		Description:  "Record runs and per-repo outcomes in a SQLite history database",
+	},
+	{
+		DotNotation:  "stats.history-path",
+		EnvVar:       "GHORG_STATS_HISTORY_PATH",
+		DefaultValue: "",
+		Description:  "Path of the SQLite history database (default _ghorg_history.db next to _ghorg_stats.csv)",
+	},
 
 	// ── SSH ──────────────────────────────────────────────────────────────
 	{
@@ -527,6 +596,161 @@ var AllKeys = []ConfigKey{
 		Description:  "Allow HTTP for Sourcehut",
 	},
 
+	// ── Mirror ───────────────────────────────────────────────────────────
+	{
+		DotNotation:  "mirror.push-to",
+		EnvVar:       "GHORG_PUSH_MIRROR_TO",
+		DefaultValue: "",
+		Description:  "URL template each repo is pushed to after clone/pull",
+	},
+	{
+		DotNotation:  "mirror.scm",
+		EnvVar:       "GHORG_PUSH_MIRROR_SCM_TYPE",
+		DefaultValue: "",
+		Description:  "SCM type used to create missing mirror repos (gitea)",
+	},
+	{
+		DotNotation:  "mirror.base-url",
+		EnvVar:       "GHORG_PUSH_MIRROR_BASE_URL",
+		DefaultValue: "",
+		Description:  "API base URL of the mirror SCM",
+	},
+	{
+		DotNotation:  "mirror.owner",
+		EnvVar:       "GHORG_PUSH_MIRROR_OWNER",
+		DefaultValue: "",
+		Description:  "Org or user that owns mirror repos (default clone target)",
+	},
+	{
+		DotNotation:  "mirror.token",
+		EnvVar:       "GHORG_PUSH_MIRROR_TOKEN",
+		DefaultValue: "",
+		IsSecret:     true,
+		Description:  "Token used to push to and create mirror repos",
+	},
+
+	// ── Hooks ────────────────────────────────────────────────────────────
+	{
+		DotNotation:  "hooks.pre-run",
+		EnvVar:       "GHORG_HOOKS_PRE_RUN",
+		DefaultValue: "",
+		Description:  "Shell command run before any repo is cloned, failure aborts the run",
+	},
+	{
+		DotNotation:  "hooks.post-clone",
+		EnvVar:       "GHORG_HOOKS_POST_CLONE",
+		DefaultValue: "",
+		Description:  "Shell command run in each newly cloned repo",
+	},
+	{
+		DotNotation:  "hooks.post-pull-with-changes",
+		EnvVar:       "GHORG_HOOKS_POST_PULL_WITH_CHANGES",
+		DefaultValue: "",
+		Description:  "Shell command run in each pulled repo whose HEAD moved",
+	},
+	{
+		DotNotation:  "hooks.on-error",
+		EnvVar:       "GHORG_HOOKS_ON_ERROR",
+		DefaultValue: "",
+		Description:  "Shell command run for each repo that failed to clone or pull",
+	},
+	{
+		DotNotation:  "hooks.post-run",
+		EnvVar:       "GHORG_HOOKS_POST_RUN",
+		DefaultValue: "",
+		Description:  "Shell command run once after all repos are processed",
+	},
+	{
+		DotNotation:  "hooks.timeout",
+		EnvVar:       "GHORG_HOOKS_TIMEOUT",
+		DefaultValue: "5m",
+		Description:  "Maximum duration of a single hook, e.g. 30s or 10m",
+	},
+	{
+		DotNotation:  "hooks.concurrency",
+		EnvVar:       "GHORG_HOOKS_CONCURRENCY",
+		DefaultValue: "4",
+		Description:  "Maximum number of repo hooks running at once",
+	},
+
+	// ── Notify ───────────────────────────────────────────────────────────
+	{
+		DotNotation:  "notify.on",
+		EnvVar:       "GHORG_NOTIFY_ON",
+		DefaultValue: "always",
+		Description:  "When to notify: comma separated always, failure, change",
+	},
+	{
+		DotNotation:  "notify.template",
+		EnvVar:       "GHORG_NOTIFY_TEMPLATE",
+		DefaultValue: "",
+		Description:  "Go template for the notification text",
+	},
+	{
+		DotNotation:  "notify.template-file",
+		EnvVar:       "GHORG_NOTIFY_TEMPLATE_FILE",
+		DefaultValue: "",
+		Description:  "File holding the Go template for the notification text",
+	},
+	{
+		DotNotation:  "notify.webhook-url",
+		EnvVar:       "GHORG_NOTIFY_WEBHOOK_URL",
+		DefaultValue: "",
+		IsSecret:     true,
+		Description:  "URL the run summary is posted to as JSON",
+	},
+	{
+		DotNotation:  "notify.slack-webhook-url",
+		EnvVar:       "GHORG_NOTIFY_SLACK_WEBHOOK_URL",
+		DefaultValue: "",
+		IsSecret:     true,
+		Description:  "Slack-compatible incoming webhook URL",
+	},
+	{
+		DotNotation:  "notify.teams-webhook-url",
+		EnvVar:       "GHORG_NOTIFY_TEAMS_WEBHOOK_URL",
+		DefaultValue: "",
+		IsSecret:     true,
+		Description:  "Microsoft Teams workflow webhook URL",
+	},
+	{
+		DotNotation:  "notify.smtp-host",
+		EnvVar:       "GHORG_NOTIFY_SMTP_HOST",
+		DefaultValue: "",
+		Description:  "SMTP server notifications are emailed through",
+	},
+	{
+		DotNotation:  "notify.smtp-port",
+		EnvVar:       "GHORG_NOTIFY_SMTP_PORT",
+		DefaultValue: "587",
+		Description:  "SMTP server port, 465 for implicit TLS",
+	},
+	{
+		DotNotation:  "notify.smtp-username",
+		EnvVar:       "GHORG_NOTIFY_SMTP_USERNAME",
+		DefaultValue: "",
+		Description:  "SMTP username",
+	},
+	{
+		DotNotation:  "notify.smtp-password",
+		EnvVar:       "GHORG_NOTIFY_SMTP_PASSWORD",
+		DefaultValue: "",
+		IsSecret:     true,
+		Description:  "SMTP password",
+	},
+	{
+		DotNotation:  "notify.smtp-from",
+		EnvVar:       "GHORG_NOTIFY_SMTP_FROM",
+		DefaultValue: "",
+		Description:  "Sender address of notification emails",
+	},
+	{
+		DotNotation:  "notify.smtp-to",
+		EnvVar:       "GHORG_NOTIFY_SMTP_TO",
+		DefaultValue: "",
+		Description:  "Comma separated recipients of notification emails",
+	},
+
 	// ── Reclone ──────────────────────────────────────────────────────────
 	{
 		DotNotation:  "reclone.path",
@@ -554,12 +778,112 @@ var AllKeys = []ConfigKey{
 		DefaultValue: ":8080",
 		Description:  "Port for the reclone HTTP server",
 	},
+	{
+		DotNotation:  "reclone.server-jobs-path",
+		EnvVar:       "GHORG_RECLONE_SERVER_JOBS_PATH",
+		DefaultValue: "", // computed at runtime via getGhorgJobsFilePath()
+		Description:  "File the reclone server persists its job queue and history to",
+	},
+	{
+		DotNotation:  "reclone.server-logs-path",
+		EnvVar:       "GHORG_RECLONE_SERVER_LOGS_PATH",
+		DefaultValue: "", // computed at runtime via getGhorgJobLogsDir()
+		Description:  "Directory the reclone server keeps job logs in",
+	},
+	{
+		DotNotation:  "reclone.server-logs-max-size-mb",
+		EnvVar:       "GHORG_RECLONE_SERVER_LOGS_MAX_SIZE_MB",
+		DefaultValue: "100",
+		Description:  "Disk space in MB job logs may use before the oldest are removed, 0 keeps every log",
+	},
+	{
+		DotNotation:  "reclone.server-read-tokens",
+		EnvVar:       "GHORG_RECLONE_SERVER_READ_TOKENS",
+		DefaultValue: "",
+		IsSecret:     true,
+		Description:  "Comma separated bearer tokens allowed to read reclone server jobs, logs, stats and metrics",
+	},
+	{
+		DotNotation:  "reclone.server-trigger-tokens",
+		EnvVar:       "GHORG_RECLONE_SERVER_TRIGGER_TOKENS",
+		DefaultValue: "",
+		IsSecret:     true,
+		Description:  "Comma separated bearer tokens allowed to queue reclone server jobs and read",
+	},
+	{
+		DotNotation:  "reclone.server-tokens-file",
+		EnvVar:       "GHORG_RECLONE_SERVER_TOKENS_FILE",
+		DefaultValue: "",
+		Description:  "File of reclone server bearer tokens, one 'name scope token' per line",
+	},
+	{
+		DotNotation:  "reclone.server-tls-cert",
+		EnvVar:       "GHORG_RECLONE_SERVER_TLS_CERT",
+		DefaultValue: "",
+		Description:  "Certificate the reclone server serves HTTPS with",
+	},
+	{
+		DotNotation:  "reclone.server-tls-key",
+		EnvVar:       "GHORG_RECLONE_SERVER_TLS_KEY",
+		DefaultValue: "",
+		Description:  "Private key of the reclone server certificate",
+	},
+	{
+		DotNotation:  "reclone.server-tls-client-ca",
+		EnvVar:       "GHORG_RECLONE_SERVER_TLS_CLIENT_CA",
+		DefaultValue: "",
+		Description:  "CA bundle the reclone server verifies client certificates against",
+	},
+	{
+		DotNotation:  "reclone.server-tls-client-scope",
+		EnvVar:       "GHORG_RECLONE_SERVER_TLS_CLIENT_SCOPE",
+		DefaultValue: "read",
+		Description:  "Scope of callers authenticated by a client certificate, read or trigger",
+	},
+	{
+		DotNotation:  "reclone.server-webhook-secret",
+		EnvVar:       "GHORG_RECLONE_SERVER_WEBHOOK_SECRET",
+		DefaultValue: "",
+		IsSecret:     true,
+		Description:  "Secret SCM webhooks are signed with, enables the /webhooks endpoints of the reclone server",
+	},
 	{
 		DotNotation:  "reclone.cron-timer-minutes",
 		EnvVar:       "GHORG_CRON_TIMER_MINUTES",
 		DefaultValue: "60",
 		Description:  "Interval in minutes for cron-based recloning",
 	},
+	{
+		DotNotation:  "reclone.cron-schedule",
+		EnvVar:       "GHORG_CRON_SCHEDULE",
+		DefaultValue: "",
+		Description:  "Cron expression reclone entries without their own schedule run on, overrides cron-timer-minutes",
+	},
+	{
+		DotNotation:  "reclone.cron-timezone",
+		EnvVar:       "GHORG_CRON_TIMEZONE",
+		DefaultValue: "", // local time
+		Description:  "Time zone cron expressions are evaluated in",
+	},
+	{
+		DotNotation:  "reclone.cron-jitter",
+		EnvVar:       "GHORG_CRON_JITTER",
+		DefaultValue: "",
+		Description:  "Maximum random delay added to every scheduled reclone run, e.g. 5m",
+	},
+	{
+		DotNotation:  "reclone.cron-catch-up",
+		EnvVar:       "GHORG_CRON_CATCH_UP",
+		DefaultValue: "false",
+		IsBool:       true,
+		Description:  "Run reclone entries that missed a scheduled run while the cron was down once at startup",
+	},
+	{
+		DotNotation:  "reclone.cron-state-path",
+		EnvVar:       "GHORG_CRON_STATE_PATH",
+		DefaultValue: "", // computed at runtime via getCronStateFilePath()
+		Description:  "File reclone-cron records the last run of every reclone entry in",
+	},
 }
 
 // indexes built on first access
@@ -0,0 +1,160 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// secretExecTimeout bounds how long the command of an exec: secret reference
+// may run.
+var secretExecTimeout = 30 * time.Second
+
+// secretCache holds the output of exec: secret references, so a command is
+// run once per ghorg run however often its reference is resolved.
+var secretCache = struct {
+	sync.Mutex
+	values map[string]string
+}{values: make(map[string]string)}
+
+// ParseSecretRef splits a secret reference into its kind, env, file or exec,
+// and the environment variable, path or command it refers to. ok is false
+// when value is not a secret reference, like a plain token.
+func ParseSecretRef(value string) (kind, target string, ok bool) {
+	kind, target, found := strings.Cut(value, ":")
+	if !found || strings.TrimSpace(target) == "" {
+		return "", "", false
+	}
+	switch kind {
+	case "env", "file", "exec":
+		return kind, target, true
+	}
+	return "", "", false
+}
+
+// IsSecretRef reports whether value is an env:, file: or exec: secret
+// reference.
+func IsSecretRef(value string) bool {
+	_, _, ok := ParseSecretRef(value)
+	return ok
+}
+
+// ResolveSecretRef returns the secret ref refers to. Errors name the
+// reference, never the secret.
+func ResolveSecretRef(ref string) (string, error) {
+	kind, target, ok := ParseSecretRef(ref)
+	if !ok {
+		return "", fmt.Errorf("invalid secret reference %q, expected env:<VARIABLE>, file:<path> or exec:<command>", ref)
+	}
+
+	var secret string
+	switch kind {
+	case "env":
+		secret = os.Getenv(target)
+		if secret == "" {
+			return "", fmt.Errorf("%s: environment variable %s is not set", ref, target)
+		}
+		return secret, nil
+	case "file":
+		path, err := homedir.Expand(target)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ref, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ref, err)
+		}
+		secret = strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+		if secret == "" {
+			return "", fmt.Errorf("%s: file is empty", ref)
+		}
+		return secret, nil
+	}
+
+	secretCache.Lock()
+	defer secretCache.Unlock()
+	if secret, ok := secretCache.values[ref]; ok {
+		return secret, nil
+	}
+	secret, err := runSecretCommand(target)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	secretCache.values[ref] = secret
+	return secret, nil
+}
+
+// runSecretCommand runs command in the shell and returns what it printed.
+// Its stderr goes to ghorg's, so the command can prompt for a passphrase.
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretExecTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	// Don't wait for children of the shell that keep stdout open once
+	// the command timed out.
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("command timed out after %s", secretExecTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("command failed: %w", err)
+	}
+	secret := strings.TrimSpace(string(out))
+	if secret == "" {
+		return "", errors.New("command printed nothing")
+	}
+	return secret, nil
+}
+
+// ResetSecretCache forgets the output of exec: secret references, so the
+// next run of a long running ghorg process runs their commands again.
+func ResetSecretCache() {
+	secretCache.Lock()
+	defer secretCache.Unlock()
+	clear(secretCache.values)
+}
+
+// ResolveSecretEnv replaces the secret reference in the environment
+// variable envVar with the secret it refers to. Other values are left alone.
+func ResolveSecretEnv(envVar string) error {
+	value := os.Getenv(envVar)
+	if !IsSecretRef(value) {
+		return nil
+	}
+	secret, err := ResolveSecretRef(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", envVar, err)
+	}
+	os.Setenv(envVar, secret)
+	return nil
+}
+
+// ResolveSecretRefs resolves the secret references of every secret key in
+// the registry, or only of those in sections when any are given.
+func ResolveSecretRefs(sections ...string) error {
+	var errs []error
+	for _, ck := range AllKeys {
+		if ck.IsSecret && (len(sections) == 0 || slices.Contains(sections, ck.Section())) {
+			errs = append(errs, ResolveSecretEnv(ck.EnvVar))
+		}
+	}
+	return errors.Join(errs...)
+}
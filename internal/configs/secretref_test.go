@@ -0,0 +1,113 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value, kind, target string
+		ok                  bool
+	}{
+		{"env:GITHUB_TOKEN", "env", "GITHUB_TOKEN", true},
+		{"file:~/.config/ghorg/token", "file", "~/.config/ghorg/token", true},
+		{"exec:pass show gh", "exec", "pass show gh", true},
+		{"ghp_1234567890", "", "", false},
+		{"https://hooks.slack.com/services/x", "", "", false},
+		{"exec: ", "", "", false},
+		{"vault:secret", "", "", false},
+	}
+	for _, tt := range tests {
+		kind, target, ok := ParseSecretRef(tt.value)
+		if kind != tt.kind || target != tt.target || ok != tt.ok {
+			t.Errorf("ParseSecretRef(%q) = %q, %q, %v; want %q, %q, %v", tt.value, kind, target, ok, tt.kind, tt.target, tt.ok)
+		}
+	}
+}
+
+func TestResolveSecretRef(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("\ufefffile-token\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GHORG_TEST_SECRET", "env-token")
+	defer os.Unsetenv("GHORG_TEST_SECRET")
+	ResetSecretCache()
+	defer ResetSecretCache()
+
+	for ref, want := range map[string]string{
+		"env:GHORG_TEST_SECRET": "env-token",
+		"file:" + tokenFile:     "file-token",
+		"exec:echo exec-token":  "exec-token",
+	} {
+		if got, err := ResolveSecretRef(ref); err != nil || got != want {
+			t.Errorf("ResolveSecretRef(%s) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+
+	for _, ref := range []string{"env:GHORG_TEST_MISSING", "file:" + filepath.Join(dir, "missing"), "exec:exit 3", "exec:true", "token"} {
+		if _, err := ResolveSecretRef(ref); err == nil {
+			t.Errorf("ResolveSecretRef(%s) expected error", ref)
+		}
+	}
+
+	// exec: commands run once per run
+	count := filepath.Join(dir, "count")
+	ref := "exec:echo x >> " + count + " && echo cached-token"
+	for range 2 {
+		if got, err := ResolveSecretRef(ref); err != nil || got != "cached-token" {
+			t.Fatalf("ResolveSecretRef(%s) = %q, %v", ref, got, err)
+		}
+	}
+	if data, _ := os.ReadFile(count); strings.Count(string(data), "x") != 1 {
+		t.Errorf("the command ran %d times, want once", strings.Count(string(data), "x"))
+	}
+	ResetSecretCache()
+	if _, err := ResolveSecretRef(ref); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(count); strings.Count(string(data), "x") != 2 {
+		t.Error("the command did not run again after ResetSecretCache")
+	}
+
+	saved := secretExecTimeout
+	secretExecTimeout = 100 * time.Millisecond
+	defer func() { secretExecTimeout = saved }()
+	if _, err := ResolveSecretRef("exec:exec sleep 5"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestResolveSecretRefs(t *testing.T) {
+	for _, key := range []string{"GHORG_GITHUB_TOKEN", "GHORG_GITLAB_TOKEN", "GHORG_NOTIFY_SMTP_PASSWORD", "GHORG_TEST_SECRET"} {
+		defer os.Setenv(key, os.Getenv(key))
+	}
+	os.Setenv("GHORG_TEST_SECRET", "resolved")
+	os.Setenv("GHORG_GITHUB_TOKEN", "env:GHORG_TEST_SECRET")
+	os.Setenv("GHORG_GITLAB_TOKEN", "glpat-plain")
+	os.Setenv("GHORG_NOTIFY_SMTP_PASSWORD", "env:GHORG_TEST_SECRET")
+
+	if err := ResolveSecretRefs("notify"); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("GHORG_NOTIFY_SMTP_PASSWORD") != "resolved" || os.Getenv("GHORG_GITHUB_TOKEN") != "env:GHORG_TEST_SECRET" {
+		t.Error("only the keys of the notify section should be resolved")
+	}
+	if err := ResolveSecretRefs(); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("GHORG_GITHUB_TOKEN") != "resolved" || os.Getenv("GHORG_GITLAB_TOKEN") != "glpat-plain" {
+		t.Errorf("GHORG_GITHUB_TOKEN = %q, GHORG_GITLAB_TOKEN = %q", os.Getenv("GHORG_GITHUB_TOKEN"), os.Getenv("GHORG_GITLAB_TOKEN"))
+	}
+
+	os.Setenv("GHORG_GITHUB_TOKEN", "env:GHORG_TEST_MISSING")
+	if err := ResolveSecretRefs(); err == nil || !strings.HasPrefix(err.Error(), "GHORG_GITHUB_TOKEN: env:GHORG_TEST_MISSING: ") {
+		t.Errorf("expected the key and reference in the error, got %v", err)
+	}
+}
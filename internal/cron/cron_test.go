@@ -0,0 +1,190 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"CRON_TZ=Nowhere/Nope * * * * *",
+	}
+
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			t.Parallel()
+			if _, err := Parse(spec, time.UTC); err == nil {
+				t.Errorf("Parse(%q) expected error", spec)
+			}
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, time.March, 14, 10, 7, 30, 0, time.UTC) // a Saturday
+
+	tests := []struct {
+		spec     string
+		expected []time.Time
+	}{
+		{
+			spec: "*/15 * * * *",
+			expected: []time.Time{
+				time.Date(2026, time.March, 14, 10, 15, 0, 0, time.UTC),
+				time.Date(2026, time.March, 14, 10, 30, 0, 0, time.UTC),
+				time.Date(2026, time.March, 14, 10, 45, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 2 * * *",
+			expected: []time.Time{
+				time.Date(2026, time.March, 15, 2, 0, 0, 0, time.UTC),
+				time.Date(2026, time.March, 16, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "30 3 * * sun",
+			expected: []time.Time{
+				time.Date(2026, time.March, 15, 3, 30, 0, 0, time.UTC),
+				time.Date(2026, time.March, 22, 3, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 0 * * 7",
+			expected: []time.Time{
+				time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 9-17/4 * * mon-fri",
+			expected: []time.Time{
+				time.Date(2026, time.March, 16, 9, 0, 0, 0, time.UTC),
+				time.Date(2026, time.March, 16, 13, 0, 0, 0, time.UTC),
+				time.Date(2026, time.March, 16, 17, 0, 0, 0, time.UTC),
+				time.Date(2026, time.March, 17, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			// Both day fields restricted: either one matches.
+			spec: "0 0 1 * fri",
+			expected: []time.Time{
+				time.Date(2026, time.March, 20, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, time.March, 27, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, time.April, 3, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 0 29 feb *",
+			expected: []time.Time{
+				time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "@weekly",
+			expected: []time.Time{
+				time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "@monthly",
+			expected: []time.Time{
+				time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+			s, err := Parse(tt.spec, time.UTC)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			next := from
+			for i, want := range tt.expected {
+				next = s.Next(next)
+				if !next.Equal(want) {
+					t.Fatalf("run %d: expected %v, got %v", i, want, next)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedule_NextNever(t *testing.T) {
+	t.Parallel()
+
+	s, err := Parse("0 0 30 2 *", time.UTC)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("expected zero time, got %v", next)
+	}
+}
+
+func TestSchedule_TimeZone(t *testing.T) {
+	t.Parallel()
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	from := time.Date(2026, time.March, 14, 12, 0, 0, 0, time.UTC)
+
+	t.Run("location argument", func(t *testing.T) {
+		s, err := Parse("0 23 * * *", ny)
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		expected := time.Date(2026, time.March, 14, 23, 0, 0, 0, ny)
+		if next := s.Next(from); !next.Equal(expected) {
+			t.Errorf("expected %v, got %v", expected, next)
+		}
+		if s.Location() != ny {
+			t.Errorf("expected location %v, got %v", ny, s.Location())
+		}
+	})
+
+	t.Run("CRON_TZ prefix", func(t *testing.T) {
+		s, err := Parse("CRON_TZ=America/New_York 0 23 * * *", time.UTC)
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		expected := time.Date(2026, time.March, 15, 3, 0, 0, 0, time.UTC)
+		if next := s.Next(from); !next.Equal(expected) {
+			t.Errorf("expected %v, got %v", expected, next)
+		}
+	})
+
+	t.Run("DST gap", func(t *testing.T) {
+		// 2:30 does not exist on 8 March 2026 in New York.
+		s, err := Parse("30 2 * * *", ny)
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		start := time.Date(2026, time.March, 8, 0, 0, 0, 0, ny)
+		next := s.Next(start)
+		expected := time.Date(2026, time.March, 9, 2, 30, 0, 0, ny)
+		if !next.Equal(expected) {
+			t.Errorf("expected %v, got %v", expected, next)
+		}
+	})
+}
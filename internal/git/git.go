@@ -1,8 +1,11 @@
 package git
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	neturl "net/url"
 	"os"
 	"os/exec"
 	"strconv"
@@ -10,6 +13,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 
+	"github.com/blairham/ghorg/internal/colorlog"
 	"github.com/blairham/ghorg/internal/scm"
 )
 
@@ -35,13 +39,16 @@ type Gitter interface {
 	FetchCloneBranch(scm.Repo) error
 	HasRemoteHeads(scm.Repo) (bool, error)
 	GetRemoteURL(scm.Repo, string) (string, error)
+	GetRemoteDefaultBranch(scm.Repo) (string, error)
 
 	// Branch and status operations
 	Branch(scm.Repo) (string, error)
 	GetCurrentBranch(scm.Repo) (string, error)
+	IsDetachedHead(scm.Repo) (bool, error)
 	ShortStatus(scm.Repo) (string, error)
 	HasLocalChanges(scm.Repo) (bool, error)
 	HasUnpushedCommits(scm.Repo) (bool, error)
+	HasUnpushedBranches(scm.Repo) (bool, error)
 
 	// Commit comparison operations
 	RevListCompare(scm.Repo, string, string) (string, error)
@@ -56,6 +63,10 @@ type Gitter interface {
 
 	// HeadSHA returns the commit hash that HEAD currently points to.
 	HeadSHA(scm.Repo) (string, error)
+
+	// PushMirror pushes every branch and tag of the local clone to the given
+	// remote URL, authenticating https pushes with the given token.
+	PushMirror(repo scm.Repo, url, token string) error
 }
 
 // Environment variable names used for git configuration
@@ -175,36 +186,44 @@ func NewExecGit() GitClient {
 }
 
 func printDebugCmd(cmd *exec.Cmd, repo scm.Repo) error {
-	fmt.Println("------------- GIT DEBUG -------------")
-	fmt.Printf("%s=%v\n", envOutputDir, os.Getenv(envOutputDir))
-	fmt.Printf("%s=%v\n", envAbsolutePathTo, os.Getenv(envAbsolutePathTo))
-	fmt.Print("Repo Data: ")
-	spew.Dump(repo)
-	fmt.Print("Command Ran: ")
-	spew.Dump(*cmd)
-	fmt.Println("")
+	out := colorlog.Output()
+	fmt.Fprintln(out, "------------- GIT DEBUG -------------")
+	fmt.Fprintf(out, "%s=%v\n", envOutputDir, os.Getenv(envOutputDir))
+	fmt.Fprintf(out, "%s=%v\n", envAbsolutePathTo, os.Getenv(envAbsolutePathTo))
+	fmt.Fprint(out, "Repo Data: ")
+	spew.Fdump(out, repo)
+	printDebugCommand(out, cmd)
+	fmt.Fprintln(out, "")
 	output, err := cmd.CombinedOutput()
-	fmt.Printf("Command Output: %s\n", string(output))
+	fmt.Fprintf(out, "Command Output: %s\n", string(output))
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Fprintf(out, "Error: %v\n", err)
 	}
 	return err
 }
 
+// printDebugCommand prints the arguments and directory of cmd. Its
+// environment is left out, as it holds the resolved tokens and the
+// Authorization header of a mirror push.
+func printDebugCommand(out io.Writer, cmd *exec.Cmd) {
+	fmt.Fprintf(out, "Command Ran: %s\n", strings.Join(cmd.Args, " "))
+	fmt.Fprintf(out, "Command Dir: %s\n", cmd.Dir)
+}
+
 // printDebugCmdWithOutput executes a command with debug output and returns the output string.
 func printDebugCmdWithOutput(cmd *exec.Cmd, repo scm.Repo) (string, error) {
-	fmt.Println("------------- GIT DEBUG -------------")
-	fmt.Printf("%s=%v\n", envOutputDir, os.Getenv(envOutputDir))
-	fmt.Printf("%s=%v\n", envAbsolutePathTo, os.Getenv(envAbsolutePathTo))
-	fmt.Print("Repo Data: ")
-	spew.Dump(repo)
-	fmt.Print("Command Ran: ")
-	spew.Dump(*cmd)
-	fmt.Println("")
+	out := colorlog.Output()
+	fmt.Fprintln(out, "------------- GIT DEBUG -------------")
+	fmt.Fprintf(out, "%s=%v\n", envOutputDir, os.Getenv(envOutputDir))
+	fmt.Fprintf(out, "%s=%v\n", envAbsolutePathTo, os.Getenv(envAbsolutePathTo))
+	fmt.Fprint(out, "Repo Data: ")
+	spew.Fdump(out, repo)
+	printDebugCommand(out, cmd)
+	fmt.Fprintln(out, "")
 	output, err := cmd.CombinedOutput()
-	fmt.Printf("Command Output: %s\n", string(output))
+	fmt.Fprintf(out, "Command Output: %s\n", string(output))
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Fprintf(out, "Error: %v\n", err)
 		return "", err
 	}
 	return strings.TrimSpace(string(output)), nil
@@ -379,6 +398,7 @@ func (g GitClient) Branch(repo scm.Repo) (string, error) {
 }
 
 // RevListCompare returns the list of commits in the local branch that are not in the remote branch.
+// Both are revisions, e.g. main, origin/main or HEAD.
 func (g GitClient) RevListCompare(repo scm.Repo, localBranch string, remoteBranch string) (string, error) {
 	cmd := exec.Command("git", "-C", repo.HostPath, "rev-list", localBranch, "^"+remoteBranch)
 	output, err := cmd.CombinedOutput()
@@ -503,6 +523,24 @@ func (g GitClient) HasUnpushedCommits(repo scm.Repo) (bool, error) {
 	return count > 0, nil
 }
 
+// HasUnpushedBranches returns true if any local branch has commits that are
+// not on a remote-tracking branch, including branches that were never pushed.
+func (g GitClient) HasUnpushedBranches(repo scm.Repo) (bool, error) {
+	cmd := exec.Command("git", "rev-list", "--count", "--branches", "--not", "--remotes")
+	cmd.Dir = repo.HostPath
+
+	output, err := runGitCommandWithOutput(cmd, repo)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := strconv.Atoi(output)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse unpushed commit count: %w", err)
+	}
+	return count > 0, nil
+}
+
 // GetCurrentBranch returns the currently checked-out branch name.
 func (g GitClient) GetCurrentBranch(repo scm.Repo) (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
@@ -510,6 +548,18 @@ func (g GitClient) GetCurrentBranch(repo scm.Repo) (string, error) {
 	return runGitCommandWithOutput(cmd, repo)
 }
 
+// IsDetachedHead reports whether HEAD points at a commit instead of a branch.
+func (g GitClient) IsDetachedHead(repo scm.Repo) (bool, error) {
+	cmd := exec.Command("git", "symbolic-ref", "-q", "HEAD")
+	cmd.Dir = repo.HostPath
+	_, err := runGitCommandWithOutput(cmd, repo)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, err
+}
+
 // GetRefHash returns the commit hash for the given ref.
 func (g GitClient) GetRefHash(repo scm.Repo, ref string) (string, error) {
 	cmd := exec.Command("git", "rev-parse", ref)
@@ -607,3 +657,90 @@ func (g GitClient) UpdateRef(repo scm.Repo, refName string, commitRef string) er
 	updCmd.Dir = repo.HostPath
 	return runGitCommand(updCmd, repo)
 }
+
+// mirrorRefSpecs converts the refs of a regular (non-bare) clone into push
+// refspecs that recreate the upstream branches and tags on a mirror remote.
+// Remote-tracking branches become branches on the mirror so that everything
+// fetched from origin is replicated, not just the locally checked out branch.
+func mirrorRefSpecs(refs []string) []string {
+	specs := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		switch {
+		case ref == "" || ref == "refs/remotes/origin/HEAD":
+			continue
+		case strings.HasPrefix(ref, "refs/remotes/origin/"):
+			branch := strings.TrimPrefix(ref, "refs/remotes/origin/")
+			specs = append(specs, fmt.Sprintf("+%s:refs/heads/%s", ref, branch))
+		case strings.HasPrefix(ref, "refs/tags/"):
+			specs = append(specs, fmt.Sprintf("+%s:%s", ref, ref))
+		}
+	}
+	return specs
+}
+
+// mirrorCredentials splits the credentials for an https push to rawURL with
+// token off the URL. The token is the password of the user in the URL, or the
+// user itself when the URL has none. Other URLs and pushes without a token are
+// returned as they are, with ok false.
+func mirrorCredentials(rawURL, token string) (pushURL, username, password string, ok bool) {
+	u, err := neturl.Parse(rawURL)
+	if err != nil || token == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return rawURL, "", "", false
+	}
+	username, password = token, ""
+	if u.User != nil && u.User.Username() != "" {
+		username, password = u.User.Username(), token
+	}
+	u.User = nil
+	return u.String(), username, password, true
+}
+
+// mirrorAuthEnv returns the environment of a git push to rawURL with token
+// and the URL to push to. The token is passed in an http.extraHeader set
+// through GIT_CONFIG_* variables, so it never appears in the arguments of the
+// push, in its error output or in the GHORG_DEBUG output.
+func mirrorAuthEnv(rawURL, token string) (string, []string) {
+	pushURL, username, password, ok := mirrorCredentials(rawURL, token)
+	if !ok {
+		return rawURL, nil
+	}
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	return pushURL, append(os.Environ(),
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", count+1),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=http.extraHeader", count),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count, header),
+	)
+}
+
+// PushMirror pushes every branch and tag of the local clone to the given remote URL.
+// Backup (bare mirror) clones are pushed with --mirror, regular clones push their
+// remote-tracking branches and tags.
+func (g GitClient) PushMirror(repo scm.Repo, url, token string) error {
+	url, env := mirrorAuthEnv(url, token)
+	if isBackupMode() {
+		cmd := exec.Command("git", "push", "--mirror", url)
+		cmd.Dir = repo.HostPath
+		cmd.Env = env
+		return runGitCommand(cmd, repo)
+	}
+
+	listCmd := exec.Command("git", "for-each-ref", "--format=%(refname)", "refs/remotes/origin/", "refs/tags/")
+	listCmd.Dir = repo.HostPath
+	output, err := runGitCommandWithOutput(listCmd, repo)
+	if err != nil {
+		return fmt.Errorf("failed to list refs: %w", err)
+	}
+
+	specs := mirrorRefSpecs(strings.Split(output, "\n"))
+	if len(specs) == 0 {
+		return nil
+	}
+
+	args := append([]string{"push", "--force", url}, specs...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repo.HostPath
+	cmd.Env = env
+	return runGitCommand(cmd, repo)
+}
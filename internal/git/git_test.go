@@ -808,3 +808,30 @@ func TestHeadSHA(t *testing.T) {
 		}
 	})
 }
+
+func TestMirrorRefSpecs(t *testing.T) {
+	refs := []string{
+		"refs/remotes/origin/HEAD",
+		"refs/remotes/origin/main",
+		"refs/remotes/origin/feature/x",
+		"refs/tags/v1.0.0",
+		"refs/heads/local-only",
+		"",
+	}
+
+	got := mirrorRefSpecs(refs)
+	want := []string{
+		"+refs/remotes/origin/main:refs/heads/main",
+		"+refs/remotes/origin/feature/x:refs/heads/feature/x",
+		"+refs/tags/v1.0.0:refs/tags/v1.0.0",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d refspecs, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("refspec %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
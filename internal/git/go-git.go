@@ -10,7 +10,10 @@ import (
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
@@ -91,15 +94,16 @@ func (g goGitClient) debugLog(operation string, repo scm.Repo, details ...string
 	if !isDebugMode() {
 		return
 	}
-	fmt.Println("------------- GO-GIT DEBUG -------------")
-	fmt.Printf("%s=%v\n", envOutputDir, os.Getenv(envOutputDir))
-	fmt.Printf("%s=%v\n", envAbsolutePathTo, os.Getenv(envAbsolutePathTo))
-	fmt.Printf("Operation: %s\n", operation)
-	fmt.Printf("Repo: %s (Path: %s)\n", repo.Name, repo.HostPath)
+	out := colorlog.Output()
+	fmt.Fprintln(out, "------------- GO-GIT DEBUG -------------")
+	fmt.Fprintf(out, "%s=%v\n", envOutputDir, os.Getenv(envOutputDir))
+	fmt.Fprintf(out, "%s=%v\n", envAbsolutePathTo, os.Getenv(envAbsolutePathTo))
+	fmt.Fprintf(out, "Operation: %s\n", operation)
+	fmt.Fprintf(out, "Repo: %s (Path: %s)\n", repo.Name, repo.HostPath)
 	for _, detail := range details {
-		fmt.Printf("  %s\n", detail)
+		fmt.Fprintf(out, "  %s\n", detail)
 	}
-	fmt.Println("")
+	fmt.Fprintln(out, "")
 }
 
 // HasRemoteHeads checks if the remote repository has any heads (branches).
@@ -490,6 +494,7 @@ func (g goGitClient) Branch(repo scm.Repo) (string, error) {
 }
 
 // RevListCompare returns the list of commits in the local branch that are not in the remote branch.
+// Both are revisions, e.g. main, origin/main or HEAD.
 func (g goGitClient) RevListCompare(repo scm.Repo, localBranch string, remoteBranch string) (string, error) {
 	g.debugLog("RevListCompare", repo, fmt.Sprintf("Local: %s, Remote: %s", localBranch, remoteBranch))
 
@@ -498,25 +503,23 @@ func (g goGitClient) RevListCompare(repo scm.Repo, localBranch string, remoteBra
 		return "", err
 	}
 
-	// Get local branch reference
-	localRef, err := r.Reference(plumbing.NewBranchReferenceName(localBranch), true)
+	localHash, err := r.ResolveRevision(plumbing.Revision(localBranch))
 	if err != nil {
 		return "", err
 	}
 
-	// Get remote branch reference
-	remoteRef, err := r.Reference(plumbing.ReferenceName(remoteBranch), true)
+	remoteHash, err := r.ResolveRevision(plumbing.Revision(remoteBranch))
 	if err != nil {
 		return "", err
 	}
 
 	// Get commits reachable from local but not from remote
-	localCommit, err := r.CommitObject(localRef.Hash())
+	localCommit, err := r.CommitObject(*localHash)
 	if err != nil {
 		return "", err
 	}
 
-	remoteCommit, err := r.CommitObject(remoteRef.Hash())
+	remoteCommit, err := r.CommitObject(*remoteHash)
 	if err != nil {
 		return "", err
 	}
@@ -792,6 +795,69 @@ func (g goGitClient) HasUnpushedCommits(repo scm.Repo) (bool, error) {
 	return isAncestor && head.Hash() != upstream.Hash(), nil
 }
 
+// HasUnpushedBranches returns true if any local branch has commits that are
+// not on a remote-tracking branch, including branches that were never pushed.
+func (g goGitClient) HasUnpushedBranches(repo scm.Repo) (bool, error) {
+	g.debugLog("HasUnpushedBranches", repo)
+
+	r, err := gogit.PlainOpen(repo.HostPath)
+	if err != nil {
+		return false, err
+	}
+
+	var remotes []*object.Commit
+	refs, err := r.References()
+	if err != nil {
+		return false, err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if !ref.Name().IsRemote() || ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		commit, err := r.CommitObject(ref.Hash())
+		if err != nil {
+			return fmt.Errorf("failed to get commit of %s: %w", ref.Name(), err)
+		}
+		remotes = append(remotes, commit)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	branches, err := r.Branches()
+	if err != nil {
+		return false, err
+	}
+	unpushed := false
+	err = branches.ForEach(func(branch *plumbing.Reference) error {
+		head, err := r.CommitObject(branch.Hash())
+		if err != nil {
+			return fmt.Errorf("failed to get commit of %s: %w", branch.Name(), err)
+		}
+		// A branch has no unpushed commits when its head is reachable from
+		// a remote-tracking branch.
+		for _, remote := range remotes {
+			if remote.Hash == head.Hash {
+				return nil
+			}
+			isAncestor, err := head.IsAncestor(remote)
+			if err != nil {
+				return fmt.Errorf("failed to check ancestor relationship: %w", err)
+			}
+			if isAncestor {
+				return nil
+			}
+		}
+		unpushed = true
+		return storer.ErrStop
+	})
+	if err != nil {
+		return false, err
+	}
+	return unpushed, nil
+}
+
 // GetCurrentBranch returns the currently checked-out branch name.
 func (g goGitClient) GetCurrentBranch(repo scm.Repo) (string, error) {
 	g.debugLog("GetCurrentBranch", repo)
@@ -814,6 +880,22 @@ func (g goGitClient) GetCurrentBranch(repo scm.Repo) (string, error) {
 	return head.Hash().String()[:7], nil
 }
 
+// IsDetachedHead reports whether HEAD points at a commit instead of a branch.
+func (g goGitClient) IsDetachedHead(repo scm.Repo) (bool, error) {
+	g.debugLog("IsDetachedHead", repo)
+
+	r, err := gogit.PlainOpen(repo.HostPath)
+	if err != nil {
+		return false, err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return false, err
+	}
+	return !head.Name().IsBranch(), nil
+}
+
 // HeadSHA returns the commit hash that HEAD currently points to.
 func (g goGitClient) HeadSHA(repo scm.Repo) (string, error) {
 	return g.GetRefHash(repo, "HEAD")
@@ -1113,3 +1195,96 @@ func (g goGitClient) SyncDefaultBranch(repo scm.Repo) (bool, error) {
 	wasUpdated := beforeHash != afterHash
 	return wasUpdated, nil
 }
+
+// pushMirrorRemote is the name of the in-memory remote used by PushMirror.
+const pushMirrorRemote = "ghorg-push-mirror"
+
+// PushMirror pushes every branch and tag of the local clone to the given remote URL.
+// An in-memory remote is used so the clone's configured remotes are left untouched.
+func (g goGitClient) PushMirror(repo scm.Repo, url, token string) error {
+	g.debugLog("PushMirror", repo)
+
+	r, err := gogit.PlainOpen(repo.HostPath)
+	if err != nil {
+		return err
+	}
+
+	var specs []config.RefSpec
+	if isBackupMode() {
+		specs = []config.RefSpec{"+refs/*:refs/*"}
+	} else {
+		refs, err := r.References()
+		if err != nil {
+			return fmt.Errorf("failed to list refs: %w", err)
+		}
+		var names []string
+		_ = refs.ForEach(func(ref *plumbing.Reference) error {
+			if ref.Type() == plumbing.HashReference {
+				names = append(names, ref.Name().String())
+			}
+			return nil
+		})
+		for _, spec := range mirrorRefSpecs(names) {
+			specs = append(specs, config.RefSpec(spec))
+		}
+	}
+
+	if len(specs) == 0 {
+		return nil
+	}
+
+	auth := g.getAuth(url)
+	if pushURL, username, password, ok := mirrorCredentials(url, token); ok {
+		url, auth = pushURL, &http.BasicAuth{Username: username, Password: password}
+	}
+	remote := gogit.NewRemote(r.Storer, &config.RemoteConfig{
+		Name: pushMirrorRemote,
+		URLs: []string{url},
+	})
+
+	err = remote.Push(&gogit.PushOptions{
+		RemoteName: pushMirrorRemote,
+		RefSpecs:   specs,
+		Auth:       auth,
+		Force:      true,
+		Prune:      isBackupMode(),
+	})
+	if errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// TrackedFiles returns the paths, relative to the work tree, of every regular
+// file in the index of the repository at path. Submodules, symlinks and files
+// matched by the repository's .gitignore files are left out.
+func TrackedFiles(path string) ([]string, error) {
+	r, err := gogit.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var matcher gitignore.Matcher
+	if wt, err := r.Worktree(); err == nil {
+		if patterns, err := gitignore.ReadPatterns(wt.Filesystem, nil); err == nil && len(patterns) > 0 {
+			matcher = gitignore.NewMatcher(patterns)
+		}
+	}
+
+	files := make([]string, 0, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		if entry.Mode != filemode.Regular && entry.Mode != filemode.Executable {
+			continue
+		}
+		if matcher != nil && matcher.Match(strings.Split(entry.Name, "/"), false) {
+			continue
+		}
+		files = append(files, entry.Name)
+	}
+	return files, nil
+}
@@ -1,12 +1,19 @@
 package git
 
 import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/blairham/ghorg/internal/colorlog"
 	"github.com/blairham/ghorg/internal/scm"
 )
 
@@ -577,6 +584,39 @@ func TestBackendParityWithRemote(t *testing.T) {
 			t.Errorf("HasUnpushedCommits mismatch: exec=%v, go-git=%v", execHas, goGitHas)
 		}
 	})
+
+	t.Run("HasUnpushedBranches parity", func(t *testing.T) {
+		check := func(want bool) {
+			t.Helper()
+			execHas, execErr := execGit.HasUnpushedBranches(repo)
+			goGitHas, goGitErr := goGit.HasUnpushedBranches(repo)
+			if execErr != nil || goGitErr != nil {
+				t.Fatalf("unexpected errors: exec=%v, go-git=%v", execErr, goGitErr)
+			}
+			if execHas != want || goGitHas != want {
+				t.Errorf("HasUnpushedBranches: exec=%v, go-git=%v, want %v", execHas, goGitHas, want)
+			}
+		}
+		git := func(args ...string) {
+			t.Helper()
+			cmd := exec.Command("git", append([]string{"-c", "commit.gpgsign=false"}, args...)...)
+			cmd.Dir = cloneRepo
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %v\n%s", args, err, out)
+			}
+		}
+
+		// The unpushed commit on main
+		check(true)
+		git("push", "origin", "main")
+		check(false)
+
+		// A branch that was never pushed, with main clean and pushed
+		git("checkout", "-b", "local-only")
+		git("commit", "--allow-empty", "-m", "Local only")
+		git("checkout", "main")
+		check(true)
+	})
 }
 
 // TestGoGitClientClean tests Clean for go-git backend.
@@ -950,3 +990,193 @@ func TestGoGitHeadSHA(t *testing.T) {
 		}
 	})
 }
+
+func TestPushMirror(t *testing.T) {
+	backends := map[string]Gitter{
+		"exec":   NewExecGit(),
+		"golang": GoGitClient(),
+	}
+
+	for name, client := range backends {
+		t.Run(name, func(t *testing.T) {
+			_, clonePath, cleanup := setupBareRepoWithClone(t)
+			defer cleanup()
+
+			cmd := exec.Command("git", "tag", "v1.0.0")
+			cmd.Dir = clonePath
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("failed to create tag: %v", err)
+			}
+
+			mirrorDir := t.TempDir()
+			cmd = exec.Command("git", "init", "--bare")
+			cmd.Dir = mirrorDir
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("failed to init mirror repo: %v", err)
+			}
+
+			repo := scm.Repo{Name: "test", HostPath: clonePath, CloneBranch: "main"}
+			if err := client.PushMirror(repo, mirrorDir, ""); err != nil {
+				t.Fatalf("PushMirror failed: %v", err)
+			}
+
+			expected, err := client.HeadSHA(repo)
+			if err != nil {
+				t.Fatalf("HeadSHA failed: %v", err)
+			}
+
+			for _, ref := range []string{"refs/heads/main", "refs/tags/v1.0.0"} {
+				cmd = exec.Command("git", "rev-parse", ref)
+				cmd.Dir = mirrorDir
+				out, err := cmd.Output()
+				if err != nil {
+					t.Fatalf("expected %s on mirror: %v", ref, err)
+				}
+				if sha := strings.TrimSpace(string(out)); sha != expected {
+					t.Errorf("%s: got %s, want %s", ref, sha, expected)
+				}
+			}
+
+			// Pushing again with nothing new must not fail.
+			if err := client.PushMirror(repo, mirrorDir, ""); err != nil {
+				t.Errorf("second PushMirror failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestPushMirrorCredentials(t *testing.T) {
+	backends := map[string]Gitter{
+		"exec":   NewExecGit(),
+		"golang": GoGitClient(),
+	}
+
+	var mu sync.Mutex
+	var authHeaders, paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		paths = append(paths, r.URL.String())
+		mu.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	for name, client := range backends {
+		t.Run(name, func(t *testing.T) {
+			_, clonePath, cleanup := setupBareRepoWithClone(t)
+			defer cleanup()
+
+			mu.Lock()
+			authHeaders, paths = nil, nil
+			mu.Unlock()
+
+			repo := scm.Repo{Name: "test", HostPath: clonePath, CloneBranch: "main"}
+			mirrorURL := strings.Replace(server.URL, "http://", "http://mirror-bot@", 1) + "/backup/test.git"
+			err := client.PushMirror(repo, mirrorURL, "s3cr3t")
+			if err == nil {
+				t.Fatal("Expected the push to be refused")
+			}
+			if strings.Contains(err.Error(), "s3cr3t") {
+				t.Errorf("error leaks the token: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			want := "Basic " + base64.StdEncoding.EncodeToString([]byte("mirror-bot:s3cr3t"))
+			if len(authHeaders) == 0 || authHeaders[0] != want {
+				t.Errorf("Authorization = %q, want %q", authHeaders, want)
+			}
+			for _, path := range paths {
+				if strings.Contains(path, "s3cr3t") {
+					t.Errorf("request path leaks the token: %s", path)
+				}
+			}
+		})
+	}
+}
+
+func TestMirrorAuthEnv(t *testing.T) {
+	t.Setenv("GIT_CONFIG_COUNT", "2")
+
+	pushURL, env := mirrorAuthEnv("https://gitea.example.com/backup/api.git", "s3cr3t")
+	if pushURL != "https://gitea.example.com/backup/api.git" {
+		t.Errorf("pushURL = %q", pushURL)
+	}
+	header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("s3cr3t:"))
+	for _, want := range []string{"GIT_CONFIG_COUNT=3", "GIT_CONFIG_KEY_2=http.extraHeader", "GIT_CONFIG_VALUE_2=" + header} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env is missing %s", want)
+		}
+	}
+
+	for _, url := range []string{"git@gitea.example.com:backup/api.git", "/srv/mirror/api.git"} {
+		if got, env := mirrorAuthEnv(url, "s3cr3t"); got != url || env != nil {
+			t.Errorf("mirrorAuthEnv(%q) = %q, %v; want it unchanged", url, got, env)
+		}
+	}
+}
+
+func TestPrintDebugCmdLeavesOutEnv(t *testing.T) {
+	_, env := mirrorAuthEnv("https://gitea.example.com/backup/api.git", "s3cr3t")
+	cmd := exec.Command("git", "--version")
+	cmd.Env = env
+
+	var out bytes.Buffer
+	defer colorlog.SetOutput(&out)()
+	if err := printDebugCmd(cmd, scm.Repo{Name: "api"}); err != nil {
+		t.Fatal(err)
+	}
+
+	header := base64.StdEncoding.EncodeToString([]byte("s3cr3t:"))
+	if strings.Contains(out.String(), header) {
+		t.Errorf("debug output contains the Authorization header:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Command Ran: git --version") {
+		t.Errorf("debug output is missing the command:\n%s", out.String())
+	}
+}
+
+func TestTrackedFiles(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	files := map[string]string{
+		".gitignore":  "*.log\n",
+		"src/main.go": "package main\n",
+		"debug.log":   "force added\n",
+	}
+	for name, content := range files {
+		full := filepath.Join(repoPath, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"add", ".gitignore", "src/main.go"},
+		{"add", "-f", "debug.log"},
+		{"commit", "-m", "add files"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, "untracked.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tracked, err := TrackedFiles(repoPath)
+	if err != nil {
+		t.Fatalf("TrackedFiles failed: %v", err)
+	}
+
+	got := strings.Join(tracked, ",")
+	if got != ".gitignore,README.md,src/main.go" {
+		t.Errorf("TrackedFiles = %s, want .gitignore,README.md,src/main.go", got)
+	}
+}
@@ -0,0 +1,106 @@
+package scm
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// APIStat summarises the API requests a client made while listing repos.
+// Listing is paginated, so every request is one page.
+type APIStat struct {
+	SCM                string  `json:"scm"`
+	Requests           int     `json:"requests"`
+	Errors             int     `json:"errors,omitempty"`
+	Seconds            float64 `json:"seconds"`
+	RateLimitRemaining *int    `json:"rate_limit_remaining,omitempty"`
+}
+
+var (
+	apiStatsMu sync.Mutex
+	apiStats   = map[string]*APIStat{}
+)
+
+// rateLimitHeaders are checked in order for the remaining request budget.
+// GitHub, Gitea and Bitbucket send the X- prefixed header, GitLab the other.
+var rateLimitHeaders = []string{"X-RateLimit-Remaining", "RateLimit-Remaining"}
+
+// apiStatsTransport records the latency and rate limit of every request.
+type apiStatsTransport struct {
+	scm  string
+	next http.RoundTripper
+}
+
+func (t *apiStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	recordAPIRequest(t.scm, time.Since(start), resp, err)
+	return resp, err
+}
+
+// instrumentHTTPClient returns a copy of c whose requests are counted in
+// APIStats under scmType. A nil c uses http.DefaultTransport.
+func instrumentHTTPClient(scmType string, c *http.Client) *http.Client {
+	instrumented := &http.Client{}
+	if c != nil {
+		*instrumented = *c
+	}
+	next := instrumented.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	instrumented.Transport = &apiStatsTransport{scm: scmType, next: next}
+	return instrumented
+}
+
+func recordAPIRequest(scmType string, d time.Duration, resp *http.Response, err error) {
+	apiStatsMu.Lock()
+	defer apiStatsMu.Unlock()
+
+	stat, ok := apiStats[scmType]
+	if !ok {
+		stat = &APIStat{SCM: scmType}
+		apiStats[scmType] = stat
+	}
+	stat.Requests++
+	stat.Seconds += d.Seconds()
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		stat.Errors++
+	}
+	if resp == nil {
+		return
+	}
+	for _, header := range rateLimitHeaders {
+		if remaining, err := strconv.Atoi(resp.Header.Get(header)); err == nil {
+			stat.RateLimitRemaining = &remaining
+			return
+		}
+	}
+}
+
+// APIStats returns the API requests made by this process, sorted by SCM.
+func APIStats() []APIStat {
+	apiStatsMu.Lock()
+	defer apiStatsMu.Unlock()
+
+	stats := make([]APIStat, 0, len(apiStats))
+	for _, stat := range apiStats {
+		s := *stat
+		if stat.RateLimitRemaining != nil {
+			remaining := *stat.RateLimitRemaining
+			s.RateLimitRemaining = &remaining
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].SCM < stats[j].SCM })
+	return stats
+}
+
+// ResetAPIStats clears the recorded API requests.
+func ResetAPIStats() {
+	apiStatsMu.Lock()
+	defer apiStatsMu.Unlock()
+	apiStats = map[string]*APIStat{}
+}
@@ -0,0 +1,56 @@
+package scm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInstrumentHTTPClient(t *testing.T) {
+	ResetAPIStats()
+	defer ResetAPIStats()
+
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gitlab" {
+			w.Header().Set("RateLimit-Remaining", "1999")
+		} else {
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	github := instrumentHTTPClient("github", nil)
+	gitlab := instrumentHTTPClient("gitlab", &http.Client{Transport: http.DefaultTransport})
+	for _, c := range []struct {
+		client *http.Client
+		path   string
+	}{{github, "/github"}, {github, "/github"}, {gitlab, "/gitlab"}} {
+		resp, err := c.client.Get(srv.URL + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	status = http.StatusTooManyRequests
+	if resp, err := gitlab.Get(srv.URL + "/gitlab"); err == nil {
+		resp.Body.Close()
+	}
+
+	stats := APIStats()
+	if len(stats) != 2 || stats[0].SCM != "github" || stats[1].SCM != "gitlab" {
+		t.Fatalf("APIStats = %+v, want github and gitlab", stats)
+	}
+	if stats[0].Requests != 2 || stats[0].Errors != 0 || *stats[0].RateLimitRemaining != 4999 || stats[0].Seconds <= 0 {
+		t.Errorf("github stats = %+v", stats[0])
+	}
+	if stats[1].Requests != 2 || stats[1].Errors != 1 || *stats[1].RateLimitRemaining != 1999 {
+		t.Errorf("gitlab stats = %+v", stats[1])
+	}
+
+	ResetAPIStats()
+	if stats := APIStats(); len(stats) != 0 {
+		t.Errorf("APIStats after reset = %+v", stats)
+	}
+}
@@ -3,10 +3,12 @@ package scm
 import (
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/ktrysmt/go-bitbucket"
@@ -69,12 +71,37 @@ func (c Bitbucket) GetUserRepos(targetUser string) ([]Repo, error) {
 	return c.filter(resp.Items)
 }
 
+// GetRepo gets a single repo by its workspace/slug, or project key/slug on
+// Bitbucket Server
+func (c Bitbucket) GetRepo(target, fullName string) ([]Repo, error) {
+	owner, slug, ok := strings.Cut(fullName, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid repo %q, expected owner/slug", fullName)
+	}
+
+	if c.isServer {
+		return c.getServerRepo(owner, slug)
+	}
+
+	repo, err := c.Repositories.Repository.Get(&bitbucket.RepositoryOptions{Owner: owner, RepoSlug: slug})
+	if err != nil {
+		return nil, err
+	}
+	return c.filter([]bitbucket.Repository{*repo})
+}
+
 // NewClient create new bitbucket scm client
-func (Bitbucket) NewClient() (Client, error) {
+func (c Bitbucket) NewClient() (Client, error) {
+	return c.newClientWithConfig(ClientConfigFromEnv("bitbucket"))
+}
+
+// newClientWithConfig takes the base URL from cfg, the credentials are read
+// from the environment.
+func (Bitbucket) newClientWithConfig(cfg ClientConfig) (Client, error) {
 	user := os.Getenv("GHORG_BITBUCKET_USERNAME")
 	password := os.Getenv("GHORG_BITBUCKET_APP_PASSWORD")
 	oAuth := os.Getenv("GHORG_BITBUCKET_OAUTH_TOKEN")
-	baseURL := os.Getenv("GHORG_SCM_BASE_URL")
+	baseURL := cfg.BaseURL
 
 	// Check if this is a Bitbucket Server instance
 	isServer := baseURL != ""
@@ -85,7 +112,7 @@ func (Bitbucket) NewClient() (Client, error) {
 
 		// Handle insecure connections
 		if strings.HasPrefix(baseURL, "http://") && os.Getenv("GHORG_INSECURE_BITBUCKET_CLIENT") != "true" {
-			colorlog.PrintErrorAndExit("You are attempting to clone from an insecure Bitbucket instance. You must set GHORG_INSECURE_BITBUCKET_CLIENT environment variable to 'true' to proceed.")
+			return nil, errors.New("you are attempting to clone from an insecure Bitbucket instance, you must set GHORG_INSECURE_BITBUCKET_CLIENT environment variable to 'true' to proceed")
 		}
 
 		if os.Getenv("GHORG_INSECURE_BITBUCKET_CLIENT") == "true" {
@@ -102,7 +129,7 @@ func (Bitbucket) NewClient() (Client, error) {
 			Client:     nil, // Not using the Cloud client
 			isServer:   true,
 			serverURL:  baseURL,
-			httpClient: httpClient,
+			httpClient: instrumentHTTPClient("bitbucket", httpClient),
 			username:   user,
 			password:   password,
 		}, nil
@@ -124,6 +151,7 @@ func (Bitbucket) NewClient() (Client, error) {
 	if clientErr != nil {
 		return Bitbucket{}, clientErr
 	}
+	c.HttpClient = instrumentHTTPClient("bitbucket", c.HttpClient)
 
 	return Bitbucket{
 		Client:   c,
@@ -133,6 +161,7 @@ func (Bitbucket) NewClient() (Client, error) {
 
 // ServerRepository represents the Bitbucket Server API repository structure
 type ServerRepository struct {
+	ID      int            `json:"id"`
 	Name    string         `json:"name"`
 	Slug    string         `json:"slug"`
 	Links   map[string]any `json:"links"`
@@ -192,6 +221,35 @@ func (c Bitbucket) getServerProjectRepos(projectKey string) ([]Repo, error) {
 	return c.fetchServerProjectReposParallel(projectKey, response.Values, response.Size, limit)
 }
 
+// getServerRepo gets a single repository of a Bitbucket Server project
+func (c Bitbucket) getServerRepo(projectKey, slug string) ([]Repo, error) {
+	apiURL := strings.TrimSuffix(c.serverURL, "/") + fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s", projectKey, slug)
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make API request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var repo ServerRepository
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return nil, fmt.Errorf("failed to decode API response: %w", err)
+	}
+	return c.filterServerRepos([]ServerRepository{repo}), nil
+}
+
 // getServerUserRepos gets repositories for a specific user (personal repositories) with parallel pagination
 func (c Bitbucket) getServerUserRepos(username string) ([]Repo, error) {
 	// For Bitbucket Server, user repos are typically in projects prefixed with ~username
@@ -265,6 +323,7 @@ func (c Bitbucket) filterServerRepos(repos []ServerRepository) []Repo {
 				}
 
 				r := Repo{
+					ID:   strconv.Itoa(repo.ID),
 					Name: repo.Name,
 					Path: fmt.Sprintf("%s/%s", repo.Project.Key, repo.Slug),
 					URL:  href,
@@ -341,6 +400,7 @@ func (Bitbucket) filter(resp []bitbucket.Repository) (repoData []Repo, err error
 			}
 
 			r := Repo{}
+			r.ID = a.Uuid
 			r.Name = a.Name
 			r.Path = a.Full_name
 			if os.Getenv("GHORG_BRANCH") == "" {
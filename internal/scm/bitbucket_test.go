@@ -1242,3 +1242,28 @@ func (c Bitbucket) filterReposForTest(repos []bitbucketRepository) ([]Repo, erro
 
 	return cloneData, nil
 }
+
+func TestGetServerRepo(t *testing.T) {
+	client, mux, _, teardown := setupBitbucketServerTest()
+	defer teardown()
+
+	os.Setenv("GHORG_CLONE_PROTOCOL", "https")
+	defer os.Unsetenv("GHORG_CLONE_PROTOCOL")
+
+	mux.HandleFunc("/rest/api/1.0/projects/PROJ/repos/repo1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newServerRepo("repo1", "PROJ", "https://bitbucket.example.com/scm/proj/repo1.git", "ssh://git@bitbucket.example.com:7999/proj/repo1.git"))
+	})
+
+	repos, err := client.GetRepo("PROJ", "PROJ/repo1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Path != "PROJ/repo1" || !strings.Contains(repos[0].CloneURL, "admin:secret@") {
+		t.Errorf("GetRepo = %+v", repos)
+	}
+
+	if _, err := client.GetRepo("PROJ", "PROJ/missing"); err == nil {
+		t.Error("Expected an error for a missing repo")
+	}
+}
@@ -1,6 +1,9 @@
 package scm
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+)
 
 // Client define the interface a scm client has to have
 type Client interface {
@@ -12,6 +15,83 @@ type Client interface {
 	GetType() string
 }
 
+// RepoCreator is implemented by clients that can create repositories on
+// demand, e.g. to provision missing push mirror destinations.
+type RepoCreator interface {
+	// EnsureRepo creates owner/name as a private repository unless it already exists.
+	EnsureRepo(owner, name string) error
+}
+
+// RepoGetter is implemented by clients that can look up a single
+// repository, e.g. to update one clone when a webhook reports a push.
+type RepoGetter interface {
+	// GetRepo returns the repo at fullName, its owner/name or GitLab path
+	// with namespace, the way listing target returns it. No repos are
+	// returned when the clone filters, such as GHORG_SKIP_FORKS, drop it.
+	GetRepo(target, fullName string) ([]Repo, error)
+}
+
+// ClientConfig is the host, token and clone type a client lists repos with.
+// GetClient reads it from the GHORG_ environment, GetClientWithConfig lets
+// targets on different hosts be listed at the same time, each with its own.
+type ClientConfig struct {
+	BaseURL   string
+	CloneType string
+	Token     string
+}
+
+// tokenEnvVars are the environment variables holding the token of each
+// client type. Bitbucket has several kinds of credentials and reads them
+// from the environment.
+var tokenEnvVars = map[string]string{
+	"github":    "GHORG_GITHUB_TOKEN",
+	"gitlab":    "GHORG_GITLAB_TOKEN",
+	"gitea":     "GHORG_GITEA_TOKEN",
+	"sourcehut": "GHORG_SOURCEHUT_TOKEN",
+}
+
+// ClientConfigFromEnv returns the ClientConfig of cType from
+// GHORG_SCM_BASE_URL, GHORG_CLONE_TYPE and the token of the client type.
+func ClientConfigFromEnv(cType string) ClientConfig {
+	cfg := ClientConfig{
+		BaseURL:   os.Getenv("GHORG_SCM_BASE_URL"),
+		CloneType: os.Getenv("GHORG_CLONE_TYPE"),
+	}
+	if key, ok := tokenEnvVars[cType]; ok {
+		cfg.Token = os.Getenv(key)
+	}
+	return cfg
+}
+
+// The accessors below fall back to the environment for clients that were
+// built without a ClientConfig.
+
+func (c *ClientConfig) baseURL() string {
+	if c == nil {
+		return os.Getenv("GHORG_SCM_BASE_URL")
+	}
+	return c.BaseURL
+}
+
+func (c *ClientConfig) cloneType() string {
+	if c == nil {
+		return os.Getenv("GHORG_CLONE_TYPE")
+	}
+	return c.CloneType
+}
+
+func (c *ClientConfig) token(cType string) string {
+	if c == nil {
+		return os.Getenv(tokenEnvVars[cType])
+	}
+	return c.Token
+}
+
+// configurableClient is a Client that can be created from a ClientConfig.
+type configurableClient interface {
+	newClientWithConfig(cfg ClientConfig) (Client, error)
+}
+
 var clients []Client
 
 // registerClient registers a client
@@ -20,8 +100,21 @@ func registerClient(c Client) {
 }
 
 func GetClient(cType string) (Client, error) {
+	return GetClientWithConfig(cType, ClientConfigFromEnv(cType))
+}
+
+// GetClientWithConfig returns a client of cType that lists repos with cfg
+// rather than the base URL, clone type and token in the environment. The
+// other settings, such as the clone filters, are still read from it.
+// Clients are not safe to create concurrently, as a GitHub App client
+// stores its installation token in GHORG_GITHUB_TOKEN, but the returned
+// clients can list repos at the same time.
+func GetClientWithConfig(cType string, cfg ClientConfig) (Client, error) {
 	for i := range clients {
 		if clients[i].GetType() == cType {
+			if c, ok := clients[i].(configurableClient); ok {
+				return c.newClientWithConfig(cfg)
+			}
 			return clients[i].NewClient()
 		}
 	}
@@ -36,3 +129,15 @@ func SupportedClients() []string {
 	}
 	return types
 }
+
+// NewRepoCreator returns a RepoCreator for the given client type that talks to
+// baseURL using token. Unlike GetClient it does not read the GHORG_ environment,
+// so it can target a different host than the one being cloned from.
+func NewRepoCreator(cType, baseURL, token string) (RepoCreator, error) {
+	switch cType {
+	case "gitea":
+		return newGiteaRepoCreator(baseURL, token)
+	default:
+		return nil, fmt.Errorf("creating repositories is not supported for client type '%s'", cType)
+	}
+}
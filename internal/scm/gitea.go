@@ -2,9 +2,11 @@ package scm
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	"code.gitea.io/sdk/gitea"
@@ -14,6 +16,10 @@ import (
 
 var _ Client = Gitea{}
 
+// errInsecureGitea is returned for http Gitea instances unless
+// GHORG_INSECURE_GITEA_CLIENT is set.
+var errInsecureGitea = errors.New("you are attempting clone from an insecure Gitea instance, you must set the (--insecure-gitea-client) flag to proceed")
+
 func init() {
 	registerClient(Gitea{})
 }
@@ -24,6 +30,10 @@ type Gitea struct {
 
 	// perPage contain the pagination item limit
 	perPage int
+
+	// config is the ClientConfig the client was created with, nil for the
+	// environment.
+	config *ClientConfig
 }
 
 func (Gitea) GetType() string {
@@ -82,10 +92,31 @@ func (c Gitea) GetUserRepos(targetUsername string) ([]Repo, error) {
 	return c.fetchUserReposParallel(targetUsername, rps)
 }
 
+// GetRepo gets a single repo by its owner/name
+func (c Gitea) GetRepo(target, fullName string) ([]Repo, error) {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid repo %q, expected owner/name", fullName)
+	}
+
+	rp, resp, err := c.Client.GetRepo(owner, name)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			err = fmt.Errorf("repo \"%s\" not found", fullName)
+		}
+		return nil, err
+	}
+	return c.filter([]*gitea.Repository{rp})
+}
+
 // NewClient create new gitea scm client
-func (Gitea) NewClient() (Client, error) {
-	baseURL := os.Getenv("GHORG_SCM_BASE_URL")
-	token := os.Getenv("GHORG_GITEA_TOKEN")
+func (c Gitea) NewClient() (Client, error) {
+	return c.newClientWithConfig(ClientConfigFromEnv("gitea"))
+}
+
+func (Gitea) newClientWithConfig(cfg ClientConfig) (Client, error) {
+	baseURL := cfg.BaseURL
+	token := cfg.Token
 
 	if baseURL == "" {
 		baseURL = "https://gitea.com"
@@ -94,7 +125,7 @@ func (Gitea) NewClient() (Client, error) {
 	isHTTP := strings.HasPrefix(baseURL, "http://")
 
 	if isHTTP && (os.Getenv("GHORG_INSECURE_GITEA_CLIENT") != "true") {
-		colorlog.PrintErrorAndExit("You are attempting clone from an insecure Gitea instance. You must set the (--insecure-gitea-client) flag to proceed.")
+		return nil, errInsecureGitea
 	}
 
 	var err error
@@ -114,19 +145,19 @@ func (Gitea) NewClient() (Client, error) {
 			TLSHandshakeTimeout:   defaultTransport.TLSHandshakeTimeout,
 			TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
 		}
-		client := &http.Client{Transport: customTransport}
+		client := instrumentHTTPClient("gitea", &http.Client{Transport: customTransport})
 		c, err = gitea.NewClient(baseURL, gitea.SetToken(token), gitea.SetHTTPClient(client))
 		if err != nil {
 			return nil, err
 		}
 		colorlog.PrintError("WARNING: USING AN INSECURE GITEA CLIENT")
 	} else {
-		c, err = gitea.NewClient(baseURL, gitea.SetToken(token))
+		c, err = gitea.NewClient(baseURL, gitea.SetToken(token), gitea.SetHTTPClient(instrumentHTTPClient("gitea", nil)))
 		if err != nil {
 			return nil, err
 		}
 	}
-	client := Gitea{Client: c}
+	client := Gitea{Client: c, config: &cfg}
 
 	// set small limit so gitea most likely will have a bigger one
 	client.perPage = 10
@@ -138,19 +169,19 @@ func (Gitea) NewClient() (Client, error) {
 	return client, nil
 }
 
-func (Gitea) addTokenToCloneURL(url string, token string) string {
+func (Gitea) addTokenToCloneURL(url string, token string) (string, error) {
 	isHTTP := strings.HasPrefix(url, "http://")
 
 	if isHTTP {
 		if os.Getenv("GHORG_INSECURE_GITEA_CLIENT") == "true" {
 			splitURL := strings.Split(url, "http://")
-			return "http://" + token + "@" + splitURL[1]
+			return "http://" + token + "@" + splitURL[1], nil
 		}
-		colorlog.PrintErrorAndExit("You are attempting clone from an insecure Gitea instance. You must set the (--insecure-gitea-client) flag to proceed.")
+		return "", errInsecureGitea
 	}
 
 	splitURL := strings.Split(url, "https://")
-	return "https://" + token + "@" + splitURL[1]
+	return "https://" + token + "@" + splitURL[1], nil
 }
 
 func (c Gitea) filter(rps []*gitea.Repository) (repoData []Repo, err error) {
@@ -181,6 +212,7 @@ func (c Gitea) filter(rps []*gitea.Repository) (repoData []Repo, err error) {
 		r := Repo{}
 		r.Path = rp.FullName
 		r.Name = rp.Name
+		r.ID = strconv.FormatInt(rp.ID, 10)
 
 		if os.Getenv("GHORG_BRANCH") == "" {
 			defaultBranch := rp.DefaultBranch
@@ -195,7 +227,9 @@ func (c Gitea) filter(rps []*gitea.Repository) (repoData []Repo, err error) {
 		if os.Getenv("GHORG_CLONE_PROTOCOL") == "https" {
 			cloneURL := rp.CloneURL
 			if rp.Private || rp.Internal {
-				cloneURL = c.addTokenToCloneURL(cloneURL, os.Getenv("GHORG_GITEA_TOKEN"))
+				if cloneURL, err = c.addTokenToCloneURL(cloneURL, c.config.token("gitea")); err != nil {
+					return nil, err
+				}
 			}
 			r.CloneURL = cloneURL
 			r.URL = cloneURL
@@ -218,3 +252,42 @@ func (c Gitea) filter(rps []*gitea.Repository) (repoData []Repo, err error) {
 	}
 	return repoData, nil
 }
+
+// newGiteaRepoCreator creates a gitea client used only to provision repositories.
+func newGiteaRepoCreator(baseURL, token string) (Gitea, error) {
+	if baseURL == "" {
+		baseURL = "https://gitea.com"
+	}
+
+	c, err := gitea.NewClient(baseURL, gitea.SetToken(token))
+	if err != nil {
+		return Gitea{}, err
+	}
+
+	return Gitea{Client: c, perPage: 10}, nil
+}
+
+// EnsureRepo creates owner/name as a private repository unless it already exists.
+// The repository is created inside the organization when owner is an org,
+// otherwise under the authenticated user.
+func (c Gitea) EnsureRepo(owner, name string) error {
+	_, resp, err := c.Client.GetRepo(owner, name)
+	if err == nil {
+		return nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to look up %s/%s: %w", owner, name, err)
+	}
+
+	opt := gitea.CreateRepoOption{Name: name, Private: true}
+	if _, _, orgErr := c.GetOrg(owner); orgErr == nil {
+		_, _, err = c.CreateOrgRepo(owner, opt)
+	} else {
+		_, _, err = c.CreateRepo(opt)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %s/%s: %w", owner, name, err)
+	}
+
+	return nil
+}
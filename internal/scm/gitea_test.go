@@ -369,8 +369,8 @@ func TestGitea_AddTokenToCloneURL(t *testing.T) {
 			}
 
 			g := Gitea{}
-			result := g.addTokenToCloneURL(tt.url, tt.token)
-			if result != tt.expected {
+			result, err := g.addTokenToCloneURL(tt.url, tt.token)
+			if err != nil || result != tt.expected {
 				t.Errorf("addTokenToCloneURL(%q, %q) = %q, want %q", tt.url, tt.token, result, tt.expected)
 			}
 		})
@@ -766,3 +766,87 @@ func TestGitea_GetUserRepos_MultiPage(t *testing.T) {
 		t.Errorf("expected %d repos, got %d", totalRepos, len(result))
 	}
 }
+
+func TestGitea_EnsureRepo(t *testing.T) {
+	_, mux, serverURL, teardown := setupGiteaTest()
+	defer teardown()
+
+	var created atomic.Int32
+	mux.HandleFunc("/api/v1/repos/mirror-org/existing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mockGiteaRepository(1, "existing"))
+	})
+	mux.HandleFunc("/api/v1/repos/mirror-org/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v1/orgs/mirror-org", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(gitea.Organization{UserName: "mirror-org"})
+	})
+	mux.HandleFunc("/api/v1/orgs/mirror-org/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST, got %s", r.Method)
+		}
+		var opt gitea.CreateRepoOption
+		if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+			t.Fatalf("Failed to decode body: %v", err)
+		}
+		if opt.Name != "missing" || !opt.Private {
+			t.Errorf("Unexpected create options: %+v", opt)
+		}
+		created.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(mockGiteaRepository(2, "missing"))
+	})
+
+	creator, err := NewRepoCreator("gitea", serverURL, "token")
+	if err != nil {
+		t.Fatalf("NewRepoCreator failed: %v", err)
+	}
+
+	if err := creator.EnsureRepo("mirror-org", "existing"); err != nil {
+		t.Errorf("EnsureRepo on existing repo failed: %v", err)
+	}
+	if created.Load() != 0 {
+		t.Errorf("Expected no repo to be created for an existing repo")
+	}
+
+	if err := creator.EnsureRepo("mirror-org", "missing"); err != nil {
+		t.Errorf("EnsureRepo on missing repo failed: %v", err)
+	}
+	if created.Load() != 1 {
+		t.Errorf("Expected missing repo to be created once, got %d", created.Load())
+	}
+}
+
+func TestNewRepoCreator_UnsupportedType(t *testing.T) {
+	if _, err := NewRepoCreator("sourcehut", "", ""); err == nil {
+		t.Error("Expected error for unsupported client type")
+	}
+}
+
+func TestGitea_GetRepo(t *testing.T) {
+	client, mux, _, teardown := setupGiteaTest()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/repos/test-org/repo-001", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mockGiteaRepository(1, "repo-001"))
+	})
+
+	os.Setenv("GHORG_CLONE_PROTOCOL", "https")
+	defer os.Unsetenv("GHORG_CLONE_PROTOCOL")
+
+	repos, err := client.GetRepo("test-org", "test-org/repo-001")
+	if err != nil {
+		t.Fatalf("GetRepo failed: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Path != "test-org/repo-001" || repos[0].CloneBranch != "main" {
+		t.Errorf("GetRepo = %+v", repos)
+	}
+
+	if _, err := client.GetRepo("test-org", "test-org/missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("GetRepo of a missing repo = %v, want not found", err)
+	}
+}
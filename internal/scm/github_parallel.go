@@ -94,7 +94,7 @@ func (c Github) fetchUserReposParallel(targetUser string, firstPageRepos []*gith
 			var repos []*github.Repository
 			var err error
 
-			if targetUser == tokenUsername {
+			if targetUser == c.tokenUser() {
 				authOpt := &github.RepositoryListByAuthenticatedUserOptions{
 					Type:        os.Getenv("GHORG_GITHUB_USER_OPTION"),
 					ListOptions: *opt,
@@ -109,7 +109,7 @@ func (c Github) fetchUserReposParallel(targetUser string, firstPageRepos []*gith
 			}
 
 			// Filter user repos if needed
-			if targetUser != tokenUsername && err == nil {
+			if targetUser != c.tokenUser() && err == nil {
 				userRepos := []*github.Repository{}
 				for _, repo := range repos {
 					if repo.Owner != nil && repo.Owner.Type != nil && *repo.Owner.Type == "User" {
@@ -2,6 +2,7 @@ package scm
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go"
 
@@ -16,9 +18,11 @@ import (
 )
 
 var (
-	perPage         int64 = 100
-	gitLabAllGroups       = false
-	gitLabAllUsers        = false
+	perPage int64 = 100
+	// gitLabAllGroups and gitLabAllUsers are set by all-groups and
+	// all-users listings, which may run concurrently.
+	gitLabAllGroups atomic.Bool
+	gitLabAllUsers  atomic.Bool
 )
 
 func init() {
@@ -28,14 +32,18 @@ func init() {
 type Gitlab struct {
 	// extend the gitlab client
 	*gitlab.Client
+
+	// config is the ClientConfig the client was created with, nil for the
+	// environment.
+	config *ClientConfig
 }
 
 func (Gitlab) GetType() string {
 	return "gitlab"
 }
 
-func (Gitlab) rootLevelSnippet(url string) bool {
-	baseURL := os.Getenv("GHORG_SCM_BASE_URL")
+func (c Gitlab) rootLevelSnippet(url string) bool {
+	baseURL := c.config.baseURL()
 	if baseURL != "" {
 		customSnippetPattern := regexp.MustCompile(`^` + baseURL + `/-/snippets/\d+$`)
 		return customSnippetPattern.MatchString(url)
@@ -53,14 +61,14 @@ func (c Gitlab) GetOrgRepos(targetOrg string) ([]Repo, error) {
 	longFetch := false
 
 	if targetOrg == "all-users" {
-		colorlog.PrintErrorAndExit("When using the 'all-users' keyword the '--clone-type=user' flag should be set")
+		return nil, errors.New("when using the 'all-users' keyword the '--clone-type=user' flag should be set")
 	}
 
 	spinningSpinner.Start()
 	defer spinningSpinner.Stop()
 
 	if targetOrg == "all-groups" {
-		gitLabAllGroups = true
+		gitLabAllGroups.Store(true)
 		longFetch = true
 
 		grps, err := c.GetTopLevelGroups()
@@ -86,7 +94,7 @@ func (c Gitlab) GetOrgRepos(targetOrg string) ([]Repo, error) {
 		if longFetch {
 			spinningSpinner.Stop()
 			if i == 0 {
-				fmt.Println("")
+				fmt.Fprintln(colorlog.Output(), "")
 			}
 			msg := fmt.Sprintf("fetching repos for group: %v", group)
 			colorlog.PrintInfo(msg)
@@ -100,7 +108,7 @@ func (c Gitlab) GetOrgRepos(targetOrg string) ([]Repo, error) {
 
 	}
 
-	snippets, err := c.GetSnippets(repoData, targetOrg)
+	snippets, err := c.getSnippets(repoData, targetOrg, false)
 	if err != nil {
 		spinningSpinner.Stop()
 		colorlog.PrintError(fmt.Sprintf("Error getting snippets, error: %v", err))
@@ -178,7 +186,7 @@ func (c Gitlab) createRootLevelSnippetCloneURL(snippetWebURL string) string {
 	// Both http and ssh clone urls do not have the /-/ in them so just remove it first and add the .git extention
 	cloneURL := strings.ReplaceAll(snippetWebURL, "/-/", "/") + ".git"
 	if os.Getenv("GHORG_CLONE_PROTOCOL") == "https" {
-		return c.addTokenToCloneURL(cloneURL, os.Getenv("GHORG_GITLAB_TOKEN"))
+		return c.addTokenToCloneURL(cloneURL, c.config.token("gitlab"))
 	}
 
 	if os.Getenv("GHORG_INSECURE_GITLAB_CLIENT") == "true" {
@@ -289,7 +297,15 @@ func (c Gitlab) getRepoSnippetsParallel(repos []Repo) []*gitlab.Snippet {
 	return allSnippets
 }
 
+// GetSnippets returns the snippets of the repos in cloneData, and root level
+// snippets for all-users and all-groups clones, for the clone type the client was created with.
 func (c Gitlab) GetSnippets(cloneData []Repo, target string) ([]Repo, error) {
+	return c.getSnippets(cloneData, target, c.config.cloneType() == "user")
+}
+
+// getSnippets is GetSnippets for a user clone when isUser is set and for a
+// group clone otherwise, so listings of several targets can run concurrently.
+func (c Gitlab) getSnippets(cloneData []Repo, target string, isUser bool) ([]Repo, error) {
 	if os.Getenv("GHORG_CLONE_SNIPPETS") != "true" {
 		return []Repo{}, nil
 	}
@@ -300,7 +316,7 @@ func (c Gitlab) GetSnippets(cloneData []Repo, target string) ([]Repo, error) {
 	snippetsToClone := []Repo{}
 
 	// If it is a cloud group clone iterate over each project and try to get its snippets. We have to do this because if you use the /snippets/all endpoint it will return every public snippet from the cloud.
-	if os.Getenv("GHORG_CLONE_TYPE") != "user" && os.Getenv("GHORG_SCM_BASE_URL") == "" {
+	if !isUser && c.config.baseURL() == "" {
 		// Iterate over all projects in the group. If it has snippets add them
 		colorlog.PrintInfo("Note: only snippets you have access to will be cloned. This process may take a while depending on the size of group you are trying to clone, please be patient.")
 		allSnippetsToClone = c.getRepoSnippetsParallel(cloneData)
@@ -316,7 +332,7 @@ func (c Gitlab) GetSnippets(cloneData []Repo, target string) ([]Repo, error) {
 					allSnippetsToClone = append(allSnippetsToClone, snippet)
 				}
 			}
-		} else if os.Getenv("GHORG_CLONE_TYPE") != "user" {
+		} else if !isUser {
 			// Handle single group clones on hosted instances
 			allSnippetsToClone = c.getRepoSnippetsParallel(cloneData)
 		}
@@ -402,7 +418,7 @@ func (c Gitlab) GetUserRepos(targetUsername string) ([]Repo, error) {
 	defer spinningSpinner.Stop()
 
 	if targetUsername == "all-users" {
-		gitLabAllUsers = true
+		gitLabAllUsers.Store(true)
 		for {
 			allUsers, resp, err := c.Users.ListUsers(userOpts)
 			if err != nil {
@@ -478,7 +494,7 @@ func (c Gitlab) GetUserRepos(targetUsername string) ([]Repo, error) {
 		}
 	}
 
-	snippets, err := c.GetSnippets(cloneData, targetUsername)
+	snippets, err := c.getSnippets(cloneData, targetUsername, true)
 	if err != nil {
 		spinningSpinner.Stop()
 		colorlog.PrintError(fmt.Sprintf("Error getting snippets, error: %v", err))
@@ -487,10 +503,31 @@ func (c Gitlab) GetUserRepos(targetUsername string) ([]Repo, error) {
 	return cloneData, nil
 }
 
+// GetRepo gets a single project by its path with namespace. Its path is
+// relative to target like when cloning target, which may be all-groups or
+// all-users.
+func (c Gitlab) GetRepo(target, fullName string) ([]Repo, error) {
+	gitLabAllGroups.Store(target == "all-groups")
+	gitLabAllUsers.Store(target == "all-users")
+
+	p, resp, err := c.Projects.GetProject(fullName, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return nil, fmt.Errorf("project '%s' does not exist", fullName)
+		}
+		return nil, err
+	}
+	return c.filter(target, []*gitlab.Project{p}), nil
+}
+
 // NewClient create new gitlab scm client
-func (Gitlab) NewClient() (Client, error) {
-	baseURL := os.Getenv("GHORG_SCM_BASE_URL")
-	token := os.Getenv("GHORG_GITLAB_TOKEN")
+func (c Gitlab) NewClient() (Client, error) {
+	return c.newClientWithConfig(ClientConfigFromEnv("gitlab"))
+}
+
+func (Gitlab) newClientWithConfig(cfg ClientConfig) (Client, error) {
+	baseURL := cfg.BaseURL
+	token := cfg.Token
 
 	var err error
 	var c *gitlab.Client
@@ -510,17 +547,17 @@ func (Gitlab) NewClient() (Client, error) {
 				TLSHandshakeTimeout:   defaultTransport.TLSHandshakeTimeout,
 				TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
 			}
-			client := &http.Client{Transport: customTransport}
+			client := instrumentHTTPClient("gitlab", &http.Client{Transport: customTransport})
 			opt := gitlab.WithHTTPClient(client)
 			c, err = gitlab.NewClient(token, gitlab.WithBaseURL(baseURL), opt)
 			colorlog.PrintError("WARNING: USING AN INSECURE GITLAB CLIENT")
 		} else {
-			c, err = gitlab.NewClient(token, gitlab.WithBaseURL(baseURL))
+			c, err = gitlab.NewClient(token, gitlab.WithBaseURL(baseURL), gitlab.WithHTTPClient(instrumentHTTPClient("gitlab", nil)))
 		}
 	} else {
-		c, err = gitlab.NewClient(token)
+		c, err = gitlab.NewClient(token, gitlab.WithHTTPClient(instrumentHTTPClient("gitlab", nil)))
 	}
-	return Gitlab{c}, err
+	return Gitlab{Client: c, config: &cfg}, err
 }
 
 func (Gitlab) addTokenToCloneURL(url string, token string) string {
@@ -591,7 +628,7 @@ func (c Gitlab) filter(group string, ps []*gitlab.Project) []Repo {
 		// https://github.com/blairham/ghorg/issues/228
 		// https://github.com/blairham/ghorg/issues/267
 		// https://github.com/blairham/ghorg/issues/271
-		if !gitLabAllGroups && !gitLabAllUsers {
+		if !gitLabAllGroups.Load() && !gitLabAllUsers.Load() {
 			if isSubgroup {
 				if os.Getenv("GHORG_OUTPUT_DIR") == "" {
 					path = strings.TrimPrefix(path, group)
@@ -604,7 +641,7 @@ func (c Gitlab) filter(group string, ps []*gitlab.Project) []Repo {
 		r.Path = path
 		r.ID = fmt.Sprint(p.ID)
 		if os.Getenv("GHORG_CLONE_PROTOCOL") == "https" {
-			r.CloneURL = c.addTokenToCloneURL(p.HTTPURLToRepo, os.Getenv("GHORG_GITLAB_TOKEN"))
+			r.CloneURL = c.addTokenToCloneURL(p.HTTPURLToRepo, c.config.token("gitlab"))
 			r.URL = p.HTTPURLToRepo
 			repoData = append(repoData, r)
 		} else {
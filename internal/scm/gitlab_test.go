@@ -695,3 +695,45 @@ func TestGitlab_GetGroupRepos_MultiPage_ErrorOnPage(t *testing.T) {
 		t.Fatal("expected error when page 2 returns 500, got nil")
 	}
 }
+
+func TestGitlab_GetRepo(t *testing.T) {
+	client, mux, _, teardown := setupGitlabTest(t)
+	defer teardown()
+
+	os.Setenv("GHORG_CLONE_PROTOCOL", "ssh")
+	defer os.Unsetenv("GHORG_CLONE_PROTOCOL")
+
+	mux.HandleFunc("/api/v4/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "test-group/sub/repo-one" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"id":                  1,
+			"name":                "repo-one",
+			"default_branch":      "main",
+			"path_with_namespace": "test-group/sub/repo-one",
+			"http_url_to_repo":    "https://gitlab.com/test-group/sub/repo-one.git",
+			"ssh_url_to_repo":     "git@gitlab.com:test-group/sub/repo-one.git",
+			"wiki_access_level":   "disabled",
+		})
+	})
+
+	repos, err := client.GetRepo("test-group", "test-group/sub/repo-one")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Path != "/sub/repo-one" || repos[0].URL != "git@gitlab.com:test-group/sub/repo-one.git" {
+		t.Errorf("GetRepo = %+v", repos)
+	}
+
+	repos, _ = client.GetRepo("all-groups", "test-group/sub/repo-one")
+	if len(repos) != 1 || repos[0].Path != "test-group/sub/repo-one" {
+		t.Errorf("GetRepo for all-groups = %+v, want the full path", repos)
+	}
+	gitLabAllGroups.Store(false)
+
+	if _, err := client.GetRepo("test-group", "test-group/missing"); err == nil {
+		t.Error("Expected an error for a missing project")
+	}
+}
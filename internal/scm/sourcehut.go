@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/blairham/ghorg/internal/colorlog"
@@ -103,9 +105,13 @@ func (c Sourcehut) GetUserRepos(targetUsername string) ([]Repo, error) {
 }
 
 // NewClient create new sourcehut scm client
-func (Sourcehut) NewClient() (Client, error) {
-	baseURL := os.Getenv("GHORG_SCM_BASE_URL")
-	token := os.Getenv("GHORG_SOURCEHUT_TOKEN")
+func (c Sourcehut) NewClient() (Client, error) {
+	return c.newClientWithConfig(ClientConfigFromEnv("sourcehut"))
+}
+
+func (Sourcehut) newClientWithConfig(cfg ClientConfig) (Client, error) {
+	baseURL := cfg.BaseURL
+	token := cfg.Token
 
 	if baseURL == "" {
 		baseURL = "https://git.sr.ht"
@@ -114,7 +120,7 @@ func (Sourcehut) NewClient() (Client, error) {
 	isHTTP := strings.HasPrefix(baseURL, "http://")
 
 	if isHTTP && (os.Getenv("GHORG_INSECURE_SOURCEHUT_CLIENT") != "true") {
-		colorlog.PrintErrorAndExit("You are attempting clone from an insecure sourcehut instance. You must set the (--insecure-sourcehut-client) flag to proceed.")
+		return nil, errors.New("you are attempting clone from an insecure sourcehut instance, you must set the (--insecure-sourcehut-client) flag to proceed")
 	}
 
 	var hc *http.Client
@@ -141,7 +147,7 @@ func (Sourcehut) NewClient() (Client, error) {
 
 	client := Sourcehut{
 		BaseURL: baseURL,
-		Client:  hc,
+		Client:  instrumentHTTPClient("sourcehut", hc),
 		Token:   token,
 	}
 
@@ -251,6 +257,7 @@ func (c Sourcehut) filter(rps []repository, apiUsername string, localUsername st
 		// Use localUsername (without ~) for local paths to avoid shell expansion issues
 		r.Path = path.Join(localUsername, rp.Name)
 		r.Name = rp.Name
+		r.ID = strconv.FormatInt(rp.ID, 10)
 
 		// Build the repo path WITH ~ for clone URLs (git needs this)
 		repoPathWithTilde := path.Join(rp.Owner.CanonicalName, rp.Name)
@@ -3,6 +3,7 @@ package scm
 // Repo represents an SCM repo, should probably be renamed to "cloneable" since we clone wikis and snippets with this
 type Repo struct {
 	// The ID of the repo that is assigned via the SCM provider. This is used for example with gitlab snippets on cloud gropus where we need to know the repo id to look up all he snippets it has.
+	// It is recorded in the state manifest to detect repos that were renamed or transferred.
 	ID string
 	// Name is the name of the repo https://www.github.com/blairham/ghorg.git the Name would be ghorg
 	Name string